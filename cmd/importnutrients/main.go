@@ -0,0 +1,67 @@
+// Command importnutrients bulk-loads the nutrient database from a USDA
+// FoodData Central CSV download.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	internal "github.com/pageza/recipe-book-api/internal"
+)
+
+// openOptional opens a file that may be missing from the download.
+func openOptional(path string) *os.File {
+	file, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Fatalf("Failed to open %s: %v", path, err)
+		}
+		return nil
+	}
+	return file
+}
+
+func main() {
+	dir := flag.String("dir", "", "directory containing food.csv and food_nutrient.csv (food_portion.csv and measure_unit.csv are optional)")
+	flag.Parse()
+
+	if *dir == "" {
+		log.Fatal("-dir is required")
+	}
+
+	food, err := os.Open(filepath.Join(*dir, "food.csv"))
+	if err != nil {
+		log.Fatalf("Failed to open food.csv: %v", err)
+	}
+	defer food.Close()
+	foodNutrient, err := os.Open(filepath.Join(*dir, "food_nutrient.csv"))
+	if err != nil {
+		log.Fatalf("Failed to open food_nutrient.csv: %v", err)
+	}
+	defer foodNutrient.Close()
+
+	files := internal.FoodDataCentralFiles{Food: food, FoodNutrient: foodNutrient}
+	if portion := openOptional(filepath.Join(*dir, "food_portion.csv")); portion != nil {
+		defer portion.Close()
+		files.FoodPortion = portion
+	}
+	if measureUnit := openOptional(filepath.Join(*dir, "measure_unit.csv")); measureUnit != nil {
+		defer measureUnit.Close()
+		files.MeasureUnit = measureUnit
+	}
+
+	foods, err := internal.ReadFoodDataCentral(files)
+	if err != nil {
+		log.Fatalf("Failed to read FoodData Central files: %v", err)
+	}
+
+	// Initialize the database connection
+	internal.InitDB()
+
+	if err := internal.ImportFoods(internal.DB, foods); err != nil {
+		log.Fatalf("Failed to import foods: %v", err)
+	}
+	log.Printf("Imported %d foods", len(foods))
+}
@@ -0,0 +1,54 @@
+// Command importproducts bulk-loads the barcode product catalog from an
+// Open Food Facts style CSV/TSV or JSONL dump.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	internal "github.com/pageza/recipe-book-api/internal"
+)
+
+func main() {
+	path := flag.String("file", "", "path to the product dump (.csv, .tsv or .jsonl)")
+	format := flag.String("format", "", "dump format: csv or jsonl (defaults to the file extension)")
+	flag.Parse()
+
+	if *path == "" {
+		log.Fatal("-file is required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*path), ".")
+	}
+
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("Failed to open product dump: %v", err)
+	}
+	defer file.Close()
+
+	read := internal.ReadProductsCSV
+	switch *format {
+	case "csv", "tsv":
+	case "jsonl", "json":
+		read = internal.ReadProductsJSONL
+	default:
+		log.Fatalf("Unsupported format %q", *format)
+	}
+
+	// Initialize the database connection
+	internal.InitDB()
+
+	// Products are upserted in batches while the dump is read.
+	importer := internal.NewProductImporter(internal.DB)
+	if err := read(file, importer.Add); err != nil {
+		log.Fatalf("Failed to import products after %d: %v", importer.Imported, err)
+	}
+	if err := importer.Flush(); err != nil {
+		log.Fatalf("Failed to import products after %d: %v", importer.Imported, err)
+	}
+	log.Printf("Imported %d products", importer.Imported)
+}
@@ -9,6 +9,15 @@ func main() {
 	// Initialize the database connection
 	internal.InitDB()
 
+	// Initialize storage for uploaded images
+	internal.InitBlobStore()
+
+	// Load the content filter run on comments
+	internal.InitContentFilter()
+
+	// Read how many reports hide content until a moderator looks at it
+	internal.InitModeration()
+
 	// Create a Gin router with default middleware (logger and recovery).
 	router := gin.Default()
 
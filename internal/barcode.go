@@ -0,0 +1,314 @@
+// barcode.go
+package internal
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidBarcode is returned for codes that are not a valid UPC/EAN.
+var ErrInvalidBarcode = errors.New("invalid barcode")
+
+// NormalizeBarcode validates a UPC-A, EAN-8, EAN-13 or GTIN-14 code
+// and returns it zero-padded to 14 digits so that the same product scanned
+// as UPC-A or EAN-13 resolves to one catalog key.
+func NormalizeBarcode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("%w: expected 8, 12, 13 or 14 digits", ErrInvalidBarcode)
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("%w: must contain only digits", ErrInvalidBarcode)
+		}
+	}
+	if !validGTINChecksum(code) {
+		return "", fmt.Errorf("%w: checksum mismatch", ErrInvalidBarcode)
+	}
+	return strings.Repeat("0", 14-len(code)) + code, nil
+}
+
+// validGTINChecksum verifies the GS1 mod-10 check digit. Digits are weighted
+// 3 and 1 alternately starting from the digit left of the check digit.
+func validGTINChecksum(code string) bool {
+	sum := 0
+	for i := len(code) - 2; i >= 0; i-- {
+		digit := int(code[i] - '0')
+		if (len(code)-2-i)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	check := (10 - sum%10) % 10
+	return check == int(code[len(code)-1]-'0')
+}
+
+// catalogRecord is one product row from an Open Food Facts style dump.
+// The ingredient field is our extension for curated mappings.
+type catalogRecord struct {
+	Code        string `json:"code"`
+	ProductName string `json:"product_name"`
+	GenericName string `json:"generic_name"`
+	Brands      string `json:"brands"`
+	Quantity    string `json:"quantity"`
+	Categories  string `json:"categories"`
+	Ingredient  string `json:"ingredient"`
+}
+
+// product maps a catalog record to a Product, deriving the canonical
+// ingredient and default package size. Records with invalid barcodes or no
+// name are rejected.
+func (r catalogRecord) product() (Product, bool) {
+	barcode, err := NormalizeBarcode(r.Code)
+	if err != nil || strings.TrimSpace(r.ProductName) == "" {
+		return Product{}, false
+	}
+	brand := strings.TrimSpace(strings.Split(r.Brands, ",")[0])
+	ingredient := r.Ingredient
+	if ingredient == "" {
+		ingredient = r.GenericName
+	}
+	if ingredient == "" {
+		ingredient = r.ProductName
+		if brand != "" {
+			ingredient = strings.TrimSpace(strings.ReplaceAll(strings.ToLower(ingredient), strings.ToLower(brand), ""))
+		}
+	}
+	category := strings.TrimSpace(strings.Split(r.Categories, ",")[0])
+	category = strings.TrimPrefix(category, "en:")
+
+	product := Product{
+		Barcode:        barcode,
+		Name:           strings.TrimSpace(r.ProductName),
+		Brand:          brand,
+		IngredientName: NormalizeIngredientName(ingredient),
+		Category:       category,
+	}
+	if quantity, ok := ParseQuantity(r.Quantity); ok {
+		product.PackageAmount = quantity.Amount
+		product.PackageUnit = quantity.Unit
+	}
+	return product, product.IngredientName != ""
+}
+
+// ReadProductsCSV reads products from a CSV or tab-separated dump with a
+// header row using Open Food Facts column names, passing each to fn as it
+// is read. The delimiter is detected from the header.
+func ReadProductsCSV(r io.Reader, fn func(Product) error) error {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(4096)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	firstLine, _, _ := strings.Cut(string(header), "\n")
+
+	reader := csv.NewReader(buffered)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	if strings.Count(firstLine, "\t") > strings.Count(firstLine, ",") {
+		reader.Comma = '\t'
+	}
+
+	columns, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[strings.TrimSpace(column)] = i
+	}
+	field := func(row []string, name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		record := catalogRecord{
+			Code:        field(row, "code"),
+			ProductName: field(row, "product_name"),
+			GenericName: field(row, "generic_name"),
+			Brands:      field(row, "brands"),
+			Quantity:    field(row, "quantity"),
+			Categories:  field(row, "categories"),
+			Ingredient:  field(row, "ingredient"),
+		}
+		if product, ok := record.product(); ok {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ReadProductsJSONL reads products from a JSON Lines dump, one product
+// object per line, passing each to fn as it is read.
+func ReadProductsJSONL(r io.Reader, fn func(Product) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var record catalogRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if product, ok := record.product(); ok {
+			if err := fn(product); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// dedupeProducts keeps the last of products sharing a barcode, such as the
+// UPC-A and EAN-13 codes of one product, in the place of the first. A batch
+// upsert fails if it touches the same row twice.
+func dedupeProducts(products []Product) []Product {
+	index := make(map[string]int, len(products))
+	deduped := make([]Product, 0, len(products))
+	for _, product := range products {
+		if i, ok := index[product.Barcode]; ok {
+			deduped[i] = product
+			continue
+		}
+		index[product.Barcode] = len(deduped)
+		deduped = append(deduped, product)
+	}
+	return deduped
+}
+
+// productBatchSize is how many products ProductImporter upserts at once.
+const productBatchSize = 500
+
+// ProductImporter upserts products into the catalog by barcode in batches
+// as they are added, so that a dump never has to fit in memory. Call Flush
+// after the last product.
+type ProductImporter struct {
+	db       *gorm.DB
+	batch    []Product
+	Imported int
+}
+
+// NewProductImporter returns an importer writing to db.
+func NewProductImporter(db *gorm.DB) *ProductImporter {
+	return &ProductImporter{db: db, batch: make([]Product, 0, productBatchSize)}
+}
+
+// Add queues a product, upserting the batch once it is full.
+func (p *ProductImporter) Add(product Product) error {
+	p.batch = append(p.batch, product)
+	if len(p.batch) < productBatchSize {
+		return nil
+	}
+	return p.Flush()
+}
+
+// Flush upserts the queued products. A product repeated in a later batch
+// overwrites the earlier one, as it does within a batch.
+func (p *ProductImporter) Flush() error {
+	products := dedupeProducts(p.batch)
+	p.batch = p.batch[:0]
+	if len(products) == 0 {
+		return nil
+	}
+	err := p.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "barcode"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "brand", "ingredient_name", "package_amount", "package_unit", "category", "updated_at"}),
+	}).Create(&products).Error
+	if err != nil {
+		return err
+	}
+	p.Imported += len(products)
+	return nil
+}
+
+// ScanPantryItem handles the POST /me/pantry/scan endpoint.
+// It resolves a scanned barcode against the product catalog and adds the
+// product's canonical ingredient to the pantry.
+func ScanPantryItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Code      string `json:"code" binding:"required"`
+		Location  string `json:"location" binding:"omitempty,oneof=fridge freezer pantry"`
+		ExpiresAt string `json:"expires_at"`
+		Private   bool   `json:"private"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	barcode, err := NormalizeBarcode(input.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expiresAt, err := parseOptionalDate(input.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_at date, expected YYYY-MM-DD"})
+		return
+	}
+
+	var product Product
+	if err := DB.Where("barcode = ?", barcode).First(&product).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Product not found"})
+		return
+	}
+
+	location := input.Location
+	if location == "" {
+		location = "pantry"
+	}
+	today := time.Now().Truncate(24 * time.Hour)
+	item := PantryItem{
+		UserID:      userID,
+		Name:        product.IngredientName,
+		Amount:      product.PackageAmount,
+		Unit:        product.PackageUnit,
+		Location:    location,
+		PurchasedAt: &today,
+		ExpiresAt:   expiresAt,
+	}
+	if !input.Private {
+		item.HouseholdID = currentHouseholdID(c)
+	}
+	if err := DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pantry item"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"item": item, "product": product})
+}
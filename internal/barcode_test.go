@@ -0,0 +1,127 @@
+// barcode_test.go
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestNormalizeBarcode verifies checksum validation and padding to GTIN-14.
+func TestNormalizeBarcode(t *testing.T) {
+	code, err := NormalizeBarcode("036000291452") // UPC-A
+	assert.NoError(t, err)
+	assert.Equal(t, "00036000291452", code)
+
+	code, err = NormalizeBarcode("4006381333931") // EAN-13
+	assert.NoError(t, err)
+	assert.Equal(t, "04006381333931", code)
+
+	code, err = NormalizeBarcode("96385074") // EAN-8
+	assert.NoError(t, err)
+	assert.Equal(t, "00000096385074", code)
+
+	_, err = NormalizeBarcode("036000291453")
+	assert.True(t, errors.Is(err, ErrInvalidBarcode))
+
+	_, err = NormalizeBarcode("03600029145a")
+	assert.True(t, errors.Is(err, ErrInvalidBarcode))
+
+	_, err = NormalizeBarcode("12345")
+	assert.True(t, errors.Is(err, ErrInvalidBarcode))
+}
+
+// collectProducts reads a dump with read into a slice.
+func collectProducts(read func(io.Reader, func(Product) error) error, dump string) ([]Product, error) {
+	var products []Product
+	err := read(strings.NewReader(dump), func(product Product) error {
+		products = append(products, product)
+		return nil
+	})
+	return products, err
+}
+
+// TestReadProductsCSV verifies tab-separated dumps map to canonical ingredients and package sizes.
+func TestReadProductsCSV(t *testing.T) {
+	dump := "code\tproduct_name\tgeneric_name\tbrands\tquantity\tcategories\n" +
+		"036000291452\tAcme Whole Milk\tWhole milk\tAcme\t1 l\ten:dairies\n" +
+		"4006381333931\tAcme Penne\t\tAcme,Other\t500 g\ten:pastas\n" +
+		"123\tBroken\t\t\t\t\n"
+
+	products, err := collectProducts(ReadProductsCSV, dump)
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+
+	assert.Equal(t, "00036000291452", products[0].Barcode)
+	assert.Equal(t, "whole milk", products[0].IngredientName)
+	assert.Equal(t, 1.0, products[0].PackageAmount)
+	assert.Equal(t, "l", products[0].PackageUnit)
+	assert.Equal(t, "dairies", products[0].Category)
+
+	assert.Equal(t, "Acme", products[1].Brand)
+	assert.Equal(t, "penne", products[1].IngredientName)
+	assert.Equal(t, 500.0, products[1].PackageAmount)
+	assert.Equal(t, "g", products[1].PackageUnit)
+}
+
+// TestReadProductsJSONL verifies JSON Lines dumps and explicit ingredient mappings.
+func TestReadProductsJSONL(t *testing.T) {
+	dump := `{"code":"96385074","product_name":"Choc Chips","quantity":"12 oz","ingredient":"chocolate chips"}
+{"code":"not-a-code","product_name":"Skipped"}
+`
+	products, err := collectProducts(ReadProductsJSONL, dump)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Equal(t, "chocolate chip", products[0].IngredientName)
+	assert.Equal(t, "oz", products[0].PackageUnit)
+}
+
+// TestDedupeProducts verifies a UPC-A and EAN-13 code for one product are
+// imported once, as the later row.
+func TestDedupeProducts(t *testing.T) {
+	dump := "code\tproduct_name\tgeneric_name\tbrands\tquantity\tcategories\n" +
+		"036000291452\tAcme Milk\tMilk\tAcme\t1 l\ten:dairies\n" +
+		"4006381333931\tAcme Penne\t\tAcme\t500 g\ten:pastas\n" +
+		"0036000291452\tAcme Whole Milk\tWhole milk\tAcme\t1 l\ten:dairies\n"
+
+	products, err := collectProducts(ReadProductsCSV, dump)
+	assert.NoError(t, err)
+	assert.Len(t, products, 3)
+
+	products = dedupeProducts(products)
+	assert.Len(t, products, 2)
+	assert.Equal(t, "00036000291452", products[0].Barcode)
+	assert.Equal(t, "whole milk", products[0].IngredientName)
+	assert.Equal(t, "04006381333931", products[1].Barcode)
+}
+
+// TestProductImporterBatches verifies products are upserted in batches as
+// they are added, each batch free of repeated barcodes.
+func TestProductImporterBatches(t *testing.T) {
+	db := DryRunDB(t)
+	var batches []int
+	db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		batches = append(batches, tx.Statement.ReflectValue.Len())
+		assert.Contains(t, tx.Statement.SQL.String(), `ON CONFLICT ("barcode") DO UPDATE`)
+	})
+
+	importer := NewProductImporter(db)
+	for i := 0; i < productBatchSize; i++ {
+		assert.NoError(t, importer.Add(Product{Barcode: fmt.Sprintf("%014d", i)}))
+	}
+	assert.Equal(t, []int{productBatchSize}, batches)
+	assert.NoError(t, importer.Add(Product{Barcode: "00036000291452", Name: "Milk"}))
+	assert.NoError(t, importer.Add(Product{Barcode: "00036000291452", Name: "Whole Milk"}))
+	assert.Len(t, batches, 1)
+	assert.NoError(t, importer.Flush())
+	assert.Equal(t, []int{productBatchSize, 1}, batches)
+	assert.Equal(t, productBatchSize+1, importer.Imported)
+
+	assert.NoError(t, importer.Flush())
+	assert.Len(t, batches, 2)
+}
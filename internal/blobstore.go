@@ -0,0 +1,447 @@
+// blobstore.go
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errBlobNotFound is returned for keys with no stored blob.
+var errBlobNotFound = errors.New("blob not found")
+
+// errInvalidBlobKey is returned for keys that are not relative slash paths.
+var errInvalidBlobKey = errors.New("invalid blob key")
+
+// BlobStore stores uploaded files under slash-separated keys such as
+// "recipes/12/3f9a/small.jpg".
+type BlobStore interface {
+	// Put stores data under key, replacing any blob there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get opens the blob under key, returning errBlobNotFound if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Missing blobs are not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns a link to the blob. With a positive ttl the link is
+	// signed and stops working after ttl; otherwise it does not expire.
+	URL(key string, ttl time.Duration) (string, error)
+}
+
+// Blobs is the store for uploaded files, set up by InitBlobStore.
+var Blobs BlobStore
+
+// InitBlobStore sets up the blob store named by BLOB_STORE: "local" (the
+// default) keeps files under BLOB_DIR, "s3" in an S3-compatible bucket.
+func InitBlobStore() {
+	switch kind := getEnv("BLOB_STORE", "local"); kind {
+	case "local":
+		Blobs = &LocalBlobStore{
+			Dir:     getEnv("BLOB_DIR", "uploads"),
+			BaseURL: "/blobs",
+			Secret:  []byte(getEnv("BLOB_SIGNING_KEY", string(jwtSecret))),
+		}
+	case "s3":
+		store := &S3BlobStore{
+			Endpoint:        getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:          getEnv("S3_REGION", "us-east-1"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PathStyle:       getEnv("S3_PATH_STYLE", "false") == "true",
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		}
+		if store.Bucket == "" || store.AccessKeyID == "" || store.SecretAccessKey == "" {
+			log.Fatal("S3 blob storage needs S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY")
+		}
+		Blobs = store
+	default:
+		log.Fatalf("Unknown BLOB_STORE %q, expected local or s3", kind)
+	}
+	log.Println("Blob storage configured")
+}
+
+// readBlob reads the whole blob under key.
+func readBlob(ctx context.Context, key string) ([]byte, error) {
+	blob, err := Blobs.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer blob.Close()
+	return io.ReadAll(blob)
+}
+
+// validBlobKey reports whether key is a clean relative slash path.
+func validBlobKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key &&
+		key != ".." && !strings.HasPrefix(key, "../") && !strings.Contains(key, "\\")
+}
+
+// LocalBlobStore keeps blobs as files under Dir. Its links point at the
+// /blobs endpoint, with signed links carrying an HMAC of the key and
+// expiry made with Secret.
+type LocalBlobStore struct {
+	Dir     string
+	BaseURL string
+	Secret  []byte
+}
+
+// path returns the file of a key.
+func (s *LocalBlobStore) path(key string) (string, error) {
+	if !validBlobKey(key) {
+		return "", errInvalidBlobKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the blob to a temporary file and renames it into place, so
+// readers never see a partial file.
+func (s *LocalBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Get opens the blob's file.
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return file, err
+}
+
+// Delete removes the blob's file.
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// URL returns the /blobs link of a key, signed when ttl is positive.
+func (s *LocalBlobStore) URL(key string, ttl time.Duration) (string, error) {
+	if !validBlobKey(key) {
+		return "", errInvalidBlobKey
+	}
+	link := s.BaseURL + "/" + key
+	if ttl <= 0 {
+		return link, nil
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	return link + "?expires=" + expires + "&signature=" + s.signature(key, expires), nil
+}
+
+// signature returns the HMAC authorizing a key until expires.
+func (s *LocalBlobStore) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyURL reports whether a signed link's expiry and signature authorize
+// reading key now.
+func (s *LocalBlobStore) VerifyURL(key, expires, signature string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(s.signature(key, expires)))
+}
+
+// S3BlobStore keeps blobs in a bucket of Amazon S3 or a compatible service
+// such as MinIO, signing requests with AWS Signature Version 4. PathStyle
+// addresses the bucket in the path rather than the host name, as most
+// compatible services need. Unsigned links use PublicURL, e.g. a CDN in
+// front of the bucket, and otherwise the bucket's own URL.
+type S3BlobStore struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	PathStyle       bool
+	PublicURL       string
+	Client          *http.Client
+	now             func() time.Time
+}
+
+// s3UnsignedPayload stands for the payload hash of presigned links.
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3MaxPresignTTL is the longest validity S3 accepts for presigned links.
+const s3MaxPresignTTL = 7 * 24 * time.Hour
+
+// objectURL returns the URL of a key's object.
+func (s *S3BlobStore) objectURL(key string) (*url.URL, error) {
+	if !validBlobKey(key) {
+		return nil, errInvalidBlobKey
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if s.PathStyle {
+		endpoint.Path += "/" + s.Bucket + "/" + key
+	} else {
+		endpoint.Host = s.Bucket + "." + endpoint.Host
+		endpoint.Path += "/" + key
+	}
+	return endpoint, nil
+}
+
+// clock returns the current time.
+func (s *S3BlobStore) clock() time.Time {
+	if s.now != nil {
+		return s.now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// do sends a request signed in its headers.
+func (s *S3BlobStore) do(ctx context.Context, method, key string, body []byte, contentType string) (*http.Response, error) {
+	target, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(body)
+	s.sign(req, hex.EncodeToString(sum[:]))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// s3Error turns an unexpected response into an error.
+func s3Error(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, bytes.TrimSpace(message))
+}
+
+// Put uploads the blob as an object.
+func (s *S3BlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// Get downloads the blob's object.
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, errBlobNotFound
+	}
+	defer resp.Body.Close()
+	return nil, s3Error(resp)
+}
+
+// Delete removes the blob's object.
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// URL returns a presigned link when ttl is positive and otherwise the
+// object's public URL.
+func (s *S3BlobStore) URL(key string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		if s.PublicURL != "" {
+			if !validBlobKey(key) {
+				return "", errInvalidBlobKey
+			}
+			return strings.TrimSuffix(s.PublicURL, "/") + "/" + s3Escape(key, false), nil
+		}
+		target, err := s.objectURL(key)
+		if err != nil {
+			return "", err
+		}
+		return target.String(), nil
+	}
+	return s.presign(http.MethodGet, key, min(ttl, s3MaxPresignTTL))
+}
+
+// presign returns a link authorizing a request for ttl through its query.
+func (s *S3BlobStore) presign(method, key string, ttl time.Duration) (string, error) {
+	target, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	now := s.clock()
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(ttl.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	canonicalQuery := s3CanonicalQuery(query)
+
+	request := strings.Join([]string{
+		method,
+		s3Escape(target.Path, false),
+		canonicalQuery,
+		"host:" + target.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+	signature := s.signature(now, request)
+	target.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return target.String(), nil
+}
+
+// sign adds Signature Version 4 authorization headers to a request.
+func (s *S3BlobStore) sign(req *http.Request, payloadHash string) {
+	now := s.clock()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	request := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, false),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, s.scope(now), signedHeaders, s.signature(now, request)))
+}
+
+// scope returns the credential scope of requests signed at t.
+func (s *S3BlobStore) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+}
+
+// signature signs a canonical request made at t.
+func (s *S3BlobStore) signature(t time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + t.Format("20060102T150405Z") + "\n" + s.scope(t) + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{t.Format("20060102"), s.Region, "s3", "aws4_request", stringToSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	return hex.EncodeToString(key)
+}
+
+// s3CanonicalQuery encodes query parameters sorted by name, as signatures
+// require.
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(name, true)+"="+s3Escape(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes all but unreserved characters, keeping slashes
+// unless encodeSlash is set.
+func s3Escape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+// collections.go
+package internal
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCollections handles the GET /me/collections endpoint.
+func GetCollections(c *gin.Context) {
+	var collections []Collection
+	if err := DB.Scopes(ownerScope(c)).Order("name").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve collections"})
+		return
+	}
+	c.JSON(http.StatusOK, collections)
+}
+
+// GetCollection handles the GET /me/collections/:id endpoint. Recipes the
+// caller can no longer read are left out.
+func GetCollection(c *gin.Context) {
+	var collection Collection
+	if err := DB.Preload("Recipes", visibleRecipes(c)).Scopes(ownerScope(c)).First(&collection, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// CreateCollection handles the POST /me/collections endpoint.
+// Collections are shared with the user's household unless private is set.
+func CreateCollection(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Private     bool   `json:"private"`
+		Public      bool   `json:"public"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection := Collection{
+		UserID:      userID,
+		Name:        input.Name,
+		Description: input.Description,
+		Public:      input.Public,
+	}
+	if !input.Private {
+		collection.HouseholdID = currentHouseholdID(c)
+	}
+	if err := DB.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+	if collection.Public {
+		recordActivityLogged(collection.UserID, ActivityCollection, collection.ID)
+	}
+	c.JSON(http.StatusCreated, collection)
+}
+
+// UpdateCollection handles the PUT /me/collections/:id endpoint.
+func UpdateCollection(c *gin.Context) {
+	var collection Collection
+	if err := DB.Scopes(ownerScope(c)).First(&collection, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Public      *bool  `json:"public"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := Collection{Name: input.Name, Description: input.Description}
+	if err := DB.Model(&collection).Updates(updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+		return
+	}
+	// Public is only changed when sent, since false is its zero value.
+	if input.Public != nil {
+		collection.Public = *input.Public
+		if err := DB.Model(&collection).Update("public", collection.Public).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+			return
+		}
+		if collection.Public {
+			recordActivityLogged(collection.UserID, ActivityCollection, collection.ID)
+		}
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// DeleteCollection handles the DELETE /me/collections/:id endpoint.
+func DeleteCollection(c *gin.Context) {
+	var collection Collection
+	if err := DB.Scopes(ownerScope(c)).First(&collection, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	if err := DB.Select("Recipes").Delete(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Collection deleted"})
+}
+
+// AddCollectionRecipe handles the POST /me/collections/:id/recipes endpoint.
+func AddCollectionRecipe(c *gin.Context) {
+	var collection Collection
+	if err := DB.Scopes(ownerScope(c)).First(&collection, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	var input struct {
+		RecipeID uint `json:"recipe_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, input.RecipeID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	if err := DB.Model(&collection).Association("Recipes").Append(&recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add recipe to collection"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Recipe added to collection"})
+}
+
+// RemoveCollectionRecipe handles the DELETE /me/collections/:id/recipes/:recipeId endpoint.
+func RemoveCollectionRecipe(c *gin.Context) {
+	var collection Collection
+	if err := DB.Scopes(ownerScope(c)).First(&collection, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	var recipe Recipe
+	if err := DB.First(&recipe, c.Param("recipeId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	if err := DB.Model(&collection).Association("Recipes").Delete(&recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove recipe from collection"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Recipe removed from collection"})
+}
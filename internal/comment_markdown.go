@@ -0,0 +1,172 @@
+// comment_markdown.go
+package internal
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// commentInlinePattern matches the inline Markdown comments support, by
+// group: code spans, links, bold, italics and @username mentions.
+var commentInlinePattern = regexp.MustCompile("`([^`\n]+)`" +
+	`|\[([^\]\n]+)\]\(([^)\s]+)\)` +
+	`|\*\*([^*\n]+)\*\*` +
+	`|\*([^*\n]+)\*` +
+	`|@([A-Za-z0-9_](?:[A-Za-z0-9_.-]*[A-Za-z0-9_])?)`)
+
+// commentListPattern matches a list item line.
+var commentListPattern = regexp.MustCompile(`^\s*[-*+]\s+`)
+
+// mentionLinker renders a mentioned username as HTML, reporting false when
+// no such user exists.
+type mentionLinker func(username string) (string, bool)
+
+// RenderCommentMarkdown renders the Markdown of a comment as HTML. Only a
+// small subset is supported: paragraphs, lists, quotes, code blocks and
+// spans, bold, italics, http(s) links and mentions. Everything else,
+// including any HTML in the comment, is escaped, so the result is safe to
+// embed in a page.
+func RenderCommentMarkdown(body string, mention mentionLinker) string {
+	var out strings.Builder
+	var block []string
+	kind := ""
+	flush := func() {
+		switch kind {
+		case "p":
+			out.WriteString("<p>" + renderCommentLines(block, mention) + "</p>")
+		case "quote":
+			out.WriteString("<blockquote><p>" + renderCommentLines(block, mention) + "</p></blockquote>")
+		case "list":
+			out.WriteString("<ul>")
+			for _, item := range block {
+				out.WriteString("<li>" + renderCommentInline(item, mention, true) + "</li>")
+			}
+			out.WriteString("</ul>")
+		case "code":
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(block, "\n")) + "</code></pre>")
+		}
+		block, kind = nil, ""
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		if kind == "code" {
+			if strings.HasPrefix(strings.TrimSpace(line), "```") {
+				flush()
+			} else {
+				block = append(block, line)
+			}
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		next, text := "p", trimmed
+		switch {
+		case trimmed == "":
+			flush()
+			continue
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			kind = "code"
+			continue
+		case strings.HasPrefix(trimmed, ">"):
+			next, text = "quote", strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))
+		case commentListPattern.MatchString(line):
+			next, text = "list", commentListPattern.ReplaceAllString(line, "")
+		}
+		if kind != next {
+			flush()
+			kind = next
+		}
+		block = append(block, text)
+	}
+	flush()
+	return out.String()
+}
+
+// renderCommentLines renders the lines of a paragraph, keeping its line
+// breaks.
+func renderCommentLines(lines []string, mention mentionLinker) string {
+	rendered := make([]string, len(lines))
+	for i, line := range lines {
+		rendered[i] = renderCommentInline(line, mention, true)
+	}
+	return strings.Join(rendered, "<br>")
+}
+
+// renderCommentInline renders inline Markdown, escaping the text between
+// matches. Links are not rendered inside link text.
+func renderCommentInline(text string, mention mentionLinker, links bool) string {
+	var out strings.Builder
+	last := 0
+	for _, m := range commentInlinePattern.FindAllStringSubmatchIndex(text, -1) {
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return text[m[2*i]:m[2*i+1]]
+		}
+		raw := text[m[0]:m[1]]
+		var rendered string
+		switch {
+		case m[2] >= 0:
+			rendered = "<code>" + html.EscapeString(group(1)) + "</code>"
+		case m[4] >= 0:
+			label := renderCommentInline(group(2), mention, false)
+			if target, ok := safeCommentURL(group(3)); ok && links {
+				rendered = `<a href="` + html.EscapeString(target) + `" rel="nofollow ugc noopener">` + label + "</a>"
+			} else {
+				rendered = html.EscapeString(raw)
+			}
+		case m[8] >= 0:
+			rendered = "<strong>" + renderCommentInline(group(4), mention, links) + "</strong>"
+		case m[10] >= 0:
+			rendered = "<em>" + renderCommentInline(group(5), mention, links) + "</em>"
+		default:
+			// An @ inside a word, as in an email address, is not a mention.
+			rendered = html.EscapeString(raw)
+			if links && (m[0] == 0 || !isMentionRune(text[m[0]-1])) {
+				if link, ok := mention(group(6)); ok {
+					rendered = link
+				}
+			}
+		}
+		out.WriteString(html.EscapeString(text[last:m[0]]))
+		out.WriteString(rendered)
+		last = m[1]
+	}
+	out.WriteString(html.EscapeString(text[last:]))
+	return out.String()
+}
+
+// isMentionRune reports whether b can be part of a username.
+func isMentionRune(b byte) bool {
+	return b == '_' || b == '.' || b == '-' || b == '@' ||
+		b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// safeCommentURL returns a link target if it is an absolute http(s) URL,
+// ruling out javascript: and other schemes.
+func safeCommentURL(raw string) (string, bool) {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return "", false
+	}
+	return target.String(), true
+}
+
+// commentMentions returns the usernames mentioned in a comment, in order
+// and without repeats, leaving out those in code.
+func commentMentions(body string) []string {
+	var names []string
+	seen := map[string]bool{}
+	RenderCommentMarkdown(body, func(username string) (string, bool) {
+		if !seen[username] {
+			seen[username] = true
+			names = append(names, username)
+		}
+		return "", false
+	})
+	return names
+}
@@ -0,0 +1,50 @@
+// comment_markdown_test.go
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// linkUsers links mentions of the given users.
+func linkUsers(users ...string) mentionLinker {
+	return func(username string) (string, bool) {
+		for _, user := range users {
+			if user == username {
+				return `<a href="/users/` + username + `">@` + username + "</a>", true
+			}
+		}
+		return "", false
+	}
+}
+
+func TestRenderCommentMarkdown(t *testing.T) {
+	html := RenderCommentMarkdown("Loved it, **really** *good*!\nThanks @ana and @nobody.\n\n- less sugar\n- more `lemon`\n\n> too sweet", linkUsers("ana"))
+	assert.Equal(t, "<p>Loved it, <strong>really</strong> <em>good</em>!<br>"+
+		`Thanks <a href="/users/ana">@ana</a> and @nobody.</p>`+
+		"<ul><li>less sugar</li><li>more <code>lemon</code></li></ul>"+
+		"<blockquote><p>too sweet</p></blockquote>", html)
+
+	code := RenderCommentMarkdown("```\n<b>@ana</b>\n```", linkUsers("ana"))
+	assert.Equal(t, "<pre><code>&lt;b&gt;@ana&lt;/b&gt;</code></pre>", code)
+}
+
+func TestRenderCommentMarkdownSanitizes(t *testing.T) {
+	for input, want := range map[string]string{
+		`<script>alert(1)</script>`:              "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+		`<img src=x onerror="alert(1)">`:         "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>",
+		`[click](javascript:alert(1))`:           "<p>[click](javascript:alert(1))</p>",
+		`[site](https://example.com/?a=1&b="2")`: `<p><a href="https://example.com/?a=1&amp;b=&#34;2&#34;" rel="nofollow ugc noopener">site</a></p>`,
+		`[**@ana**](https://example.com)`:        `<p><a href="https://example.com" rel="nofollow ugc noopener"><strong>@ana</strong></a></p>`,
+		`mail me at cook@ana.example`:            "<p>mail me at cook@ana.example</p>",
+		"`<i>` and *<i>*":                        "<p><code>&lt;i&gt;</code> and <em>&lt;i&gt;</em></p>",
+	} {
+		assert.Equal(t, want, RenderCommentMarkdown(input, linkUsers("ana")), input)
+	}
+}
+
+func TestCommentMentions(t *testing.T) {
+	assert.Equal(t, []string{"ana", "bo.b"}, commentMentions("@ana try it, @bo.b! @ana again, `@code` and x@mail.com"))
+	assert.Empty(t, commentMentions("no mentions here"))
+}
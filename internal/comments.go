@@ -0,0 +1,440 @@
+// comments.go
+package internal
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Statuses of comments.
+const (
+	CommentVisible = "visible"
+	CommentPending = "pending"
+)
+
+// Authors may edit a comment for commentEditWindow after posting it and
+// delete it for commentDeleteWindow. Recipe authors may delete comments on
+// their recipes at any time.
+const (
+	commentEditWindow   = 15 * time.Minute
+	commentDeleteWindow = 24 * time.Hour
+)
+
+// maxCommentMentions is how many users a comment can mention; further
+// mentions are left as plain text.
+const maxCommentMentions = 10
+
+// maxCommentDepth is how deeply replies nest. Replies to the deepest
+// comments are placed next to them instead of under them.
+const maxCommentDepth = 5
+
+// visibleComments limits comments to those shown to the caller: visible
+// ones not hidden by moderators and all of the caller's own.
+func visibleComments(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	userID, _ := currentUserID(c)
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("((comments.status = ? AND comments.hidden_at IS NULL) OR comments.user_id = ?)", CommentVisible, userID)
+	}
+}
+
+// prepareComment runs the content filter on a comment's body and, unless it
+// is rejected, sets the comment's status, mentions and HTML. The filter
+// failing holds the comment for moderation rather than losing it.
+func prepareComment(c *gin.Context, comment *Comment) (ContentVerdict, error) {
+	verdict, err := Filter.Check(c.Request.Context(), comment.Body)
+	if err != nil {
+		log.Printf("Failed to filter comment: %v", err)
+		verdict = ContentVerdict{Action: ContentHold, Reason: "Held for review by a moderator"}
+	}
+	if verdict.Action == ContentReject {
+		return verdict, nil
+	}
+	comment.Status = CommentVisible
+	if verdict.Action == ContentHold {
+		comment.Status = CommentPending
+	}
+
+	names := commentMentions(comment.Body)
+	if len(names) > maxCommentMentions {
+		names = names[:maxCommentMentions]
+	}
+	var users []User
+	if len(names) > 0 {
+		if err := DB.Select("id", "username").Where("username IN ?", names).Find(&users).Error; err != nil {
+			return verdict, err
+		}
+	}
+	mentioned := make(map[string]uint, len(users))
+	for _, user := range users {
+		mentioned[user.Username] = user.ID
+	}
+
+	comment.Mentions = []CommentMention{}
+	for _, name := range names {
+		if id, ok := mentioned[name]; ok {
+			comment.Mentions = append(comment.Mentions, CommentMention{UserID: id, Username: name})
+		}
+	}
+	comment.BodyHTML = RenderCommentMarkdown(comment.Body, func(username string) (string, bool) {
+		if _, ok := mentioned[username]; !ok {
+			return "", false
+		}
+		href := "/users/" + url.PathEscape(username)
+		return `<a href="` + html.EscapeString(href) + `" class="mention">@` + html.EscapeString(username) + "</a>", true
+	})
+	return verdict, nil
+}
+
+// attachCommentAuthors fills in the usernames of the authors of comments
+// that are not deleted.
+func attachCommentAuthors(comments []*Comment) error {
+	var userIDs []uint
+	for _, comment := range comments {
+		if comment.DeletedAt == nil {
+			userIDs = append(userIDs, comment.UserID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	var users []User
+	if err := DB.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+	for _, comment := range comments {
+		if comment.DeletedAt == nil {
+			comment.Username = usernames[comment.UserID]
+		}
+	}
+	return nil
+}
+
+// placeReply sets where a reply to parent goes in its thread: under the
+// parent, or next to it once the thread is maxCommentDepth deep.
+func placeReply(reply *Comment, parent Comment) {
+	reply.ParentID, reply.Depth = &parent.ID, parent.Depth+1
+	if parent.Depth >= maxCommentDepth && parent.ParentID != nil {
+		reply.ParentID, reply.Depth = parent.ParentID, parent.Depth
+	}
+	reply.RootID = &parent.ID
+	if parent.RootID != nil {
+		reply.RootID = parent.RootID
+	}
+}
+
+// buildCommentThreads nests replies under their parents, oldest first.
+// Replies whose parent is hidden from the caller are left out, as are
+// deleted comments without replies left.
+func buildCommentThreads(roots, replies []*Comment) []*Comment {
+	byID := make(map[uint]*Comment, len(roots)+len(replies))
+	for _, comment := range roots {
+		comment.Replies = []*Comment{}
+		byID[comment.ID] = comment
+	}
+	for _, reply := range replies {
+		reply.Replies = []*Comment{}
+		byID[reply.ID] = reply
+	}
+	for _, reply := range replies {
+		if reply.ParentID == nil {
+			continue
+		}
+		if parent, ok := byID[*reply.ParentID]; ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+
+	threads := make([]*Comment, 0, len(roots))
+	for _, root := range roots {
+		if pruneDeletedComments(root) {
+			threads = append(threads, root)
+		}
+	}
+	return threads
+}
+
+// pruneDeletedComments removes deleted comments without replies from a
+// thread, reporting whether anything of the comment is left.
+func pruneDeletedComments(comment *Comment) bool {
+	kept := comment.Replies[:0]
+	for _, reply := range comment.Replies {
+		if pruneDeletedComments(reply) {
+			kept = append(kept, reply)
+		}
+	}
+	comment.Replies = kept
+	return comment.DeletedAt == nil || len(kept) > 0
+}
+
+// loadCommentThreads loads the replies of top-level comments and returns
+// them as threads.
+func loadCommentThreads(c *gin.Context, roots []*Comment) ([]*Comment, error) {
+	rootIDs := make([]uint, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+	var replies []*Comment
+	if len(rootIDs) > 0 {
+		err := DB.Scopes(visibleComments(c)).Where("root_id IN ?", rootIDs).Order("id").Find(&replies).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := attachCommentAuthors(append(append([]*Comment{}, roots...), replies...)); err != nil {
+		return nil, err
+	}
+	return buildCommentThreads(roots, replies), nil
+}
+
+// findRecipeComment loads a comment on a recipe the caller may read,
+// responding with an error and returning false when there is none.
+func findRecipeComment(c *gin.Context, recipe *Recipe, comment *Comment) bool {
+	if err := findVisibleRecipe(c, DB, recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return false
+	}
+	err := DB.Scopes(visibleComments(c)).Where("recipe_id = ?", recipe.ID).First(comment, c.Param("commentId")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return false
+	}
+	return true
+}
+
+// GetComments handles the GET /recipes/:id/comments endpoint. Comments are
+// paged by thread, newest thread first, each with all its replies; pass
+// next_cursor back as cursor for the following page.
+func GetComments(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	limit := queryLimit(c, 20, 100)
+	query := DB.Scopes(visibleComments(c)).
+		Where("recipe_id = ? AND parent_id IS NULL", recipe.ID).
+		Where("(deleted_at IS NULL OR EXISTS (SELECT 1 FROM comments replies WHERE replies.root_id = comments.id AND replies.deleted_at IS NULL))")
+	cursor, err := queryCursor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+
+	var roots []*Comment
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&roots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+	var next *uint
+	if len(roots) > limit {
+		roots = roots[:limit]
+		next = &roots[limit-1].ID
+	}
+
+	threads, err := loadCommentThreads(c, roots)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"threads": threads, "next_cursor": next})
+}
+
+// GetComment handles the GET /recipes/:id/comments/:commentId endpoint,
+// returning the whole thread the comment belongs to.
+func GetComment(c *gin.Context) {
+	var recipe Recipe
+	var comment Comment
+	if !findRecipeComment(c, &recipe, &comment) {
+		return
+	}
+	root := &comment
+	if comment.RootID != nil {
+		root = &Comment{}
+		if err := DB.Scopes(visibleComments(c)).First(root, *comment.RootID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return
+		}
+	}
+	threads, err := loadCommentThreads(c, []*Comment{root})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve comments"})
+		return
+	}
+	if len(threads) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+		return
+	}
+	c.JSON(http.StatusOK, threads[0])
+}
+
+// CreateComment handles the POST /recipes/:id/comments endpoint, commenting
+// on a published recipe or replying to a comment on it; replies nest at
+// most maxCommentDepth deep. Comments the content filter holds are created
+// pending.
+func CreateComment(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if recipe.Status != RecipeStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only published recipes can be commented on"})
+		return
+	}
+
+	var input struct {
+		Body     string `json:"body" binding:"required,max=5000"`
+		ParentID *uint  `json:"parent_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	comment := Comment{RecipeID: recipe.ID, UserID: userID, Body: strings.TrimSpace(input.Body)}
+	if comment.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment cannot be empty"})
+		return
+	}
+
+	if input.ParentID != nil {
+		var parent Comment
+		err := DB.Scopes(visibleComments(c)).Where("recipe_id = ?", recipe.ID).First(&parent, *input.ParentID).Error
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		if parent.DeletedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot reply to a deleted comment"})
+			return
+		}
+		placeReply(&comment, parent)
+	}
+
+	verdict, err := prepareComment(c, &comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+	if verdict.Action == ContentReject {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": verdict.Reason})
+		return
+	}
+	if err := DB.Create(&comment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+	attachCommentAuthors([]*Comment{&comment})
+	comment.Replies = []*Comment{}
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateComment handles the PATCH /recipes/:id/comments/:commentId endpoint.
+// Authors can edit their comments for a short while after posting them.
+func UpdateComment(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var recipe Recipe
+	var comment Comment
+	if !findRecipeComment(c, &recipe, &comment) {
+		return
+	}
+	if comment.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own comments"})
+		return
+	}
+	if comment.DeletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Comment has been deleted"})
+		return
+	}
+	if time.Since(comment.CreatedAt) > commentEditWindow {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Comments can only be edited within %d minutes of posting", int(commentEditWindow.Minutes()))})
+		return
+	}
+
+	var input struct {
+		Body string `json:"body" binding:"required,max=5000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	comment.Body = strings.TrimSpace(input.Body)
+	if comment.Body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment cannot be empty"})
+		return
+	}
+
+	verdict, err := prepareComment(c, &comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	if verdict.Action == ContentReject {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": verdict.Reason})
+		return
+	}
+	now := time.Now()
+	comment.EditedAt = &now
+	err = DB.Model(&comment).Select("body", "body_html", "mentions", "status", "edited_at").Updates(&comment).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+	attachCommentAuthors([]*Comment{&comment})
+	comment.Replies = []*Comment{}
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment handles the DELETE /recipes/:id/comments/:commentId
+// endpoint. The comment's body is removed but the comment stays as a
+// placeholder so that replies to it keep their place in the thread.
+func DeleteComment(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var recipe Recipe
+	var comment Comment
+	if !findRecipeComment(c, &recipe, &comment) {
+		return
+	}
+	if comment.DeletedAt != nil {
+		c.JSON(http.StatusOK, gin.H{"status": "Comment deleted"})
+		return
+	}
+	switch {
+	case recipe.UserID == userID:
+	case comment.UserID != userID:
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own comments"})
+		return
+	case time.Since(comment.CreatedAt) > commentDeleteWindow:
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Comments can only be deleted within %d hours of posting", int(commentDeleteWindow.Hours()))})
+		return
+	}
+
+	if err := softDeleteComment(DB, &comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Comment deleted"})
+}
+
+// softDeleteComment removes a comment's content, keeping it in its thread.
+func softDeleteComment(db *gorm.DB, comment *Comment) error {
+	now := time.Now()
+	comment.Body, comment.BodyHTML, comment.Mentions, comment.DeletedAt = "", "", []CommentMention{}, &now
+	return db.Model(comment).Select("body", "body_html", "mentions", "deleted_at").Updates(comment).Error
+}
@@ -0,0 +1,131 @@
+// comments_test.go
+package internal
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestBuildCommentThreads verifies replies nest under their parents and
+// deleted comments are kept only while they have replies.
+func TestBuildCommentThreads(t *testing.T) {
+	id := func(n uint) *uint { return &n }
+	deleted := time.Now()
+	roots := []*Comment{
+		{ID: 1},
+		{ID: 2, DeletedAt: &deleted},
+		{ID: 3, DeletedAt: &deleted},
+	}
+	replies := []*Comment{
+		{ID: 4, ParentID: id(1), RootID: id(1)},
+		{ID: 5, ParentID: id(4), RootID: id(1), DeletedAt: &deleted},
+		{ID: 6, ParentID: id(5), RootID: id(1)},
+		{ID: 7, ParentID: id(2), RootID: id(2)},
+		{ID: 8, ParentID: id(3), RootID: id(3), DeletedAt: &deleted},
+		{ID: 9, ParentID: id(99), RootID: id(1)},
+	}
+
+	threads := buildCommentThreads(roots, replies)
+
+	// Deleted comments stay while they have replies; the fully deleted
+	// thread and the reply to a hidden comment are left out.
+	assert.Len(t, threads, 2)
+	assert.Equal(t, uint(1), threads[0].ID)
+	assert.Len(t, threads[0].Replies, 1)
+	deletedReply := threads[0].Replies[0].Replies[0]
+	assert.Equal(t, uint(5), deletedReply.ID)
+	assert.Equal(t, uint(6), deletedReply.Replies[0].ID)
+	assert.Equal(t, uint(2), threads[1].ID)
+	assert.Equal(t, uint(7), threads[1].Replies[0].ID)
+}
+
+// TestBuildCommentThreadsDeletedPlaceholders verifies a deleted comment
+// with replies stays in its place, without its body or author, and that
+// deleted branches without replies are pruned at any depth.
+func TestBuildCommentThreadsDeletedPlaceholders(t *testing.T) {
+	id := func(n uint) *uint { return &n }
+	deleted := time.Now()
+	parent := &Comment{ID: 2, ParentID: id(1), RootID: id(1), UserID: 5, DeletedAt: &deleted}
+	roots := []*Comment{{ID: 1, UserID: 4, Body: "First"}}
+	replies := []*Comment{
+		parent,
+		{ID: 3, ParentID: id(2), RootID: id(1), UserID: 6, Body: "Reply to deleted"},
+		{ID: 4, ParentID: id(1), RootID: id(1), DeletedAt: &deleted},
+		{ID: 5, ParentID: id(4), RootID: id(1), DeletedAt: &deleted},
+	}
+	assert.NoError(t, attachCommentAuthors([]*Comment{parent}))
+
+	threads := buildCommentThreads(roots, replies)
+	assert.Len(t, threads, 1)
+	assert.Len(t, threads[0].Replies, 1)
+	placeholder := threads[0].Replies[0]
+	assert.Equal(t, uint(2), placeholder.ID)
+	assert.Empty(t, placeholder.Body)
+	assert.Empty(t, placeholder.Username)
+	assert.Equal(t, uint(3), placeholder.Replies[0].ID)
+}
+
+// TestSoftDeleteComment verifies deleting a comment clears its content but
+// keeps the row, so that its replies keep their parent.
+func TestSoftDeleteComment(t *testing.T) {
+	db := DryRunDB(t)
+	var sql string
+	db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+
+	comment := Comment{ID: 7, Body: "Hello @ana", BodyHTML: "<p>Hello</p>", Mentions: []CommentMention{{UserID: 2, Username: "ana"}}}
+	assert.NoError(t, softDeleteComment(db, &comment))
+	assert.Empty(t, comment.Body)
+	assert.Empty(t, comment.BodyHTML)
+	assert.Empty(t, comment.Mentions)
+	assert.NotNil(t, comment.DeletedAt)
+	assert.Equal(t, `UPDATE "comments" SET "body"=$1,"body_html"=$2,"mentions"=$3,"deleted_at"=$4,"updated_at"=$5 WHERE "id" = $6`, sql)
+}
+
+// TestPlaceReply verifies replies nest under their parent until the thread
+// is maxCommentDepth deep and then continue next to the deepest comment.
+func TestPlaceReply(t *testing.T) {
+	root := Comment{ID: 1}
+	reply := Comment{ID: 2}
+	placeReply(&reply, root)
+	assert.Equal(t, uint(1), *reply.ParentID)
+	assert.Equal(t, uint(1), *reply.RootID)
+	assert.Equal(t, 1, reply.Depth)
+
+	parent := reply
+	for i := uint(3); parent.Depth < maxCommentDepth; i++ {
+		next := Comment{ID: i}
+		placeReply(&next, parent)
+		assert.Equal(t, parent.ID, *next.ParentID)
+		assert.Equal(t, parent.Depth+1, next.Depth)
+		parent = next
+	}
+
+	deepest := Comment{ID: 100}
+	placeReply(&deepest, parent)
+	assert.Equal(t, *parent.ParentID, *deepest.ParentID)
+	assert.Equal(t, maxCommentDepth, deepest.Depth)
+	assert.Equal(t, uint(1), *deepest.RootID)
+}
+
+// TestVisibleComments verifies comments hidden by moderators or held by the
+// content filter are shown only to their author.
+func TestVisibleComments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, userID := range []uint{0, 4} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if userID != 0 {
+			c.Set("userID", userID)
+		}
+		var comments []Comment
+		stmt := DryRunDB(t).Scopes(visibleComments(c)).Find(&comments).Statement
+		assert.Equal(t, `SELECT * FROM "comments" WHERE ((comments.status = $1 AND comments.hidden_at IS NULL) OR comments.user_id = $2)`, stmt.SQL.String())
+		assert.Equal(t, []interface{}{CommentVisible, userID}, stmt.Vars)
+	}
+}
@@ -0,0 +1,135 @@
+// content_filter.go
+package internal
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Actions a content filter can take on user-written text.
+const (
+	ContentAllow  = "allow"
+	ContentHold   = "hold"
+	ContentReject = "reject"
+)
+
+// ContentVerdict is a content filter's decision on a text. Held text is
+// stored but only shown to its author until a moderator approves it, and
+// rejected text is not stored at all. Reason is shown to the author.
+type ContentVerdict struct {
+	Action string
+	Reason string
+}
+
+// ContentFilter checks user-written text before it is stored. Filters are
+// pluggable: the local wordlist below, or e.g. a client of a moderation
+// service.
+type ContentFilter interface {
+	Check(ctx context.Context, text string) (ContentVerdict, error)
+}
+
+// Filter is the content filter run on comments. It allows everything until
+// InitContentFilter loads a wordlist.
+var Filter ContentFilter = &WordlistFilter{}
+
+// InitContentFilter loads the wordlist at CONTENT_FILTER_WORDLIST, if set.
+func InitContentFilter() {
+	name := getEnv("CONTENT_FILTER_WORDLIST", "")
+	if name == "" {
+		return
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		log.Fatalf("Failed to open content filter wordlist: %v", err)
+	}
+	defer file.Close()
+	filter, err := ParseWordlist(file)
+	if err != nil {
+		log.Fatalf("Failed to read content filter wordlist: %v", err)
+	}
+	Filter = filter
+	log.Printf("Content filter loaded with %d entries", len(filter.entries))
+}
+
+// wordlistEntry is a word or phrase of a wordlist, as its words.
+type wordlistEntry struct {
+	Words  []string
+	Action string
+}
+
+// WordlistFilter rejects or holds text containing listed words or phrases.
+// Matching ignores case, punctuation and spacing, and only matches whole
+// words, so "class" does not match "ass".
+type WordlistFilter struct {
+	entries []wordlistEntry
+}
+
+// ParseWordlist reads a wordlist with one word or phrase per line. Text
+// containing an entry is rejected, or held for moderation when the entry
+// starts with "?". Blank lines and lines starting with "#" are ignored.
+func ParseWordlist(r io.Reader) (*WordlistFilter, error) {
+	filter := &WordlistFilter{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		action := ContentReject
+		if rest, ok := strings.CutPrefix(line, "?"); ok {
+			action, line = ContentHold, rest
+		}
+		if words := filterWords(line); len(words) > 0 {
+			filter.entries = append(filter.entries, wordlistEntry{Words: words, Action: action})
+		}
+	}
+	return filter, scanner.Err()
+}
+
+// filterWords splits text into lower-case words, dropping punctuation.
+func filterWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Check rejects text containing a rejected entry, and otherwise holds text
+// containing a held one.
+func (f *WordlistFilter) Check(ctx context.Context, text string) (ContentVerdict, error) {
+	words := filterWords(text)
+	verdict := ContentVerdict{Action: ContentAllow}
+	for _, entry := range f.entries {
+		if verdict.Action == ContentHold && entry.Action == ContentHold {
+			continue
+		}
+		if containsWords(words, entry.Words) {
+			if entry.Action == ContentReject {
+				return ContentVerdict{Action: ContentReject, Reason: "Contains language that is not allowed"}, nil
+			}
+			verdict = ContentVerdict{Action: ContentHold, Reason: "Held for review by a moderator"}
+		}
+	}
+	return verdict, nil
+}
+
+// containsWords reports whether phrase occurs in words as a run.
+func containsWords(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, word := range phrase {
+			if words[i+j] != word {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+// content_filter_test.go
+package internal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWordlistFilter(t *testing.T) {
+	filter, err := ParseWordlist(strings.NewReader("# comment\nbadword\n?buy now\n\n  Spam Link  \n"))
+	assert.NoError(t, err)
+	assert.Len(t, filter.entries, 3)
+
+	for text, want := range map[string]string{
+		"A lovely recipe":               ContentAllow,
+		"what a BADWORD!":               ContentReject,
+		"badwords are fine":             ContentAllow,
+		"Buy   now, cheap pans":         ContentHold,
+		"buy it now":                    ContentAllow,
+		"spam-link here":                ContentReject,
+		"Buy now or see this spam link": ContentReject,
+	} {
+		verdict, err := filter.Check(context.Background(), text)
+		assert.NoError(t, err)
+		assert.Equal(t, want, verdict.Action, text)
+	}
+
+	verdict, _ := (&WordlistFilter{}).Check(context.Background(), "badword")
+	assert.Equal(t, ContentAllow, verdict.Action)
+}
@@ -0,0 +1,490 @@
+// cooking.go
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Cooking session and timer states.
+const (
+	SessionActive    = "active"
+	SessionFinished  = "finished"
+	SessionAbandoned = "abandoned"
+
+	TimerPending = "pending"
+	TimerRunning = "running"
+	TimerPaused  = "paused"
+	TimerExpired = "expired"
+)
+
+// cookingTickInterval is how often an event stream checks for expired timers.
+const cookingTickInterval = time.Second
+
+// CookingEvent is pushed to clients following a cooking session.
+type CookingEvent struct {
+	Type    string          `json:"type"`
+	Session *CookingSession `json:"session,omitempty"`
+	Timer   *CookingTimer   `json:"timer,omitempty"`
+	At      time.Time       `json:"at"`
+}
+
+// cookingBroker fans cooking events out to the event streams of a session.
+type cookingBroker struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan CookingEvent]struct{}
+}
+
+// cookingEvents is the process-wide cooking event broker.
+var cookingEvents = &cookingBroker{subscribers: make(map[uint]map[chan CookingEvent]struct{})}
+
+// subscribe registers a stream for a session's events.
+func (b *cookingBroker) subscribe(sessionID uint) chan CookingEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan CookingEvent, 16)
+	if b.subscribers[sessionID] == nil {
+		b.subscribers[sessionID] = make(map[chan CookingEvent]struct{})
+	}
+	b.subscribers[sessionID][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a stream registered with subscribe.
+func (b *cookingBroker) unsubscribe(sessionID uint, ch chan CookingEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers[sessionID], ch)
+	if len(b.subscribers[sessionID]) == 0 {
+		delete(b.subscribers, sessionID)
+	}
+}
+
+// publish sends an event to every stream of a session. Slow streams drop
+// events rather than block the publisher.
+func (b *cookingBroker) publish(sessionID uint, event CookingEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[sessionID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// remaining returns the seconds left on a timer at the given time.
+func (t CookingTimer) remaining(now time.Time) float64 {
+	elapsed := t.ElapsedSeconds
+	if t.Status == TimerRunning && t.RunningSince != nil {
+		elapsed += now.Sub(*t.RunningSince).Seconds()
+	}
+	return math.Max(0, float64(t.DurationSeconds)-elapsed)
+}
+
+// start runs a pending or paused timer.
+func (t *CookingTimer) start(now time.Time) error {
+	if t.Status == TimerRunning {
+		return nil
+	}
+	if t.Status == TimerExpired {
+		return errors.New("timer has already expired")
+	}
+	expires := now.Add(time.Duration(t.remaining(now) * float64(time.Second)))
+	t.Status, t.RunningSince, t.ExpiresAt = TimerRunning, &now, &expires
+	return nil
+}
+
+// pause stops a running timer, keeping its elapsed time.
+func (t *CookingTimer) pause(now time.Time) error {
+	if t.Status != TimerRunning {
+		return errors.New("timer is not running")
+	}
+	t.ElapsedSeconds = float64(t.DurationSeconds) - t.remaining(now)
+	t.Status, t.RunningSince, t.ExpiresAt = TimerPaused, nil, nil
+	return nil
+}
+
+// withRemaining fills in the remaining seconds of a session's timers.
+func (s *CookingSession) withRemaining(now time.Time) *CookingSession {
+	for i := range s.Timers {
+		s.Timers[i].RemainingSeconds = int(math.Ceil(s.Timers[i].remaining(now)))
+	}
+	return s
+}
+
+// moveStep moves the current step by delta, staying within the recipe.
+func (s *CookingSession) moveStep(delta int) error {
+	next := s.CurrentStep + delta
+	if next < 1 || next > s.StepCount {
+		return fmt.Errorf("step %d is out of range 1-%d", next, s.StepCount)
+	}
+	s.CurrentStep = next
+	return nil
+}
+
+// timerName derives a timer name from its step, e.g. "Step 2: Simmer the sauce".
+func timerName(step RecipeStep) string {
+	text := step.Text
+	if i := strings.IndexAny(text, ".,;"); i > 0 {
+		text = text[:i]
+	}
+	if len(text) > 40 {
+		text = strings.TrimSpace(text[:40]) + "…"
+	}
+	return fmt.Sprintf("Step %d: %s", step.Position, text)
+}
+
+// stepTimers prepares a pending timer for every step with a duration.
+func stepTimers(steps []RecipeStep) []CookingTimer {
+	timers := []CookingTimer{}
+	for _, step := range steps {
+		if step.DurationSeconds > 0 {
+			timers = append(timers, CookingTimer{
+				StepPosition:    step.Position,
+				Name:            timerName(step),
+				DurationSeconds: step.DurationSeconds,
+				Status:          TimerPending,
+			})
+		}
+	}
+	return timers
+}
+
+// findCookingSession loads one of the current user's cooking sessions.
+func findCookingSession(c *gin.Context) (CookingSession, error) {
+	var session CookingSession
+	userID, ok := currentUserID(c)
+	if !ok {
+		return session, gorm.ErrRecordNotFound
+	}
+	err := DB.Preload("Timers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_position, id")
+	}).Where("user_id = ?", userID).First(&session, c.Param("id")).Error
+	return session, err
+}
+
+// findActiveCookingSession loads a session and rejects finished ones.
+func findActiveCookingSession(c *gin.Context) (CookingSession, bool) {
+	session, err := findCookingSession(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cooking session not found"})
+		return session, false
+	}
+	if session.Status != SessionActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cooking session is no longer active"})
+		return session, false
+	}
+	return session, true
+}
+
+// findSessionTimer returns the timer of a session named by the timerId parameter.
+func findSessionTimer(c *gin.Context, session *CookingSession) (*CookingTimer, bool) {
+	for i := range session.Timers {
+		if fmt.Sprint(session.Timers[i].ID) == c.Param("timerId") {
+			return &session.Timers[i], true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Timer not found"})
+	return nil, false
+}
+
+// expireDueTimers marks a session's running timers whose time is up as
+// expired and publishes an event for each. The conditional update makes
+// sure concurrent streams report each expiry only once.
+func expireDueTimers(sessionID uint, now time.Time) error {
+	var due []CookingTimer
+	if err := DB.Where("session_id = ? AND status = ? AND expires_at <= ?", sessionID, TimerRunning, now).Find(&due).Error; err != nil {
+		return err
+	}
+	for _, timer := range due {
+		result := DB.Model(&CookingTimer{}).Where("id = ? AND status = ?", timer.ID, TimerRunning).Updates(map[string]interface{}{
+			"status":          TimerExpired,
+			"elapsed_seconds": float64(timer.DurationSeconds),
+			"running_since":   nil,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		timer.Status, timer.ElapsedSeconds, timer.RunningSince = TimerExpired, float64(timer.DurationSeconds), nil
+		cookingEvents.publish(sessionID, CookingEvent{Type: "timer.expired", Timer: &timer, At: now})
+	}
+	return nil
+}
+
+// respondCookingSession reloads a session, publishes it to its streams and
+// returns it to the client.
+func respondCookingSession(c *gin.Context, status int, eventType string, sessionID uint) {
+	now := time.Now()
+	if err := expireDueTimers(sessionID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timers"})
+		return
+	}
+	session, err := findCookingSession(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cooking session"})
+		return
+	}
+	session.withRemaining(now)
+	if eventType != "" {
+		cookingEvents.publish(session.ID, CookingEvent{Type: eventType, Session: &session, At: now})
+	}
+	c.JSON(status, session)
+}
+
+// StartCookingSession handles the POST /recipes/:id/cook endpoint.
+func StartCookingSession(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB.Scopes(recipeDetails), &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if len(recipe.Steps) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Recipe has no steps to cook"})
+		return
+	}
+
+	session := CookingSession{
+		UserID:      userID,
+		RecipeID:    recipe.ID,
+		CurrentStep: 1,
+		StepCount:   len(recipe.Steps),
+		Status:      SessionActive,
+		StartedAt:   time.Now(),
+		Timers:      stepTimers(recipe.Steps),
+	}
+	if err := DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start cooking session"})
+		return
+	}
+	c.JSON(http.StatusCreated, session.withRemaining(time.Now()))
+}
+
+// GetCookingSessions handles the GET /me/cooking-sessions endpoint, listing
+// active sessions so another device can resume them.
+func GetCookingSessions(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var sessions []CookingSession
+	err := DB.Preload("Timers").Where("user_id = ? AND status = ?", userID, SessionActive).
+		Order("started_at DESC").Find(&sessions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cooking sessions"})
+		return
+	}
+	now := time.Now()
+	for i := range sessions {
+		sessions[i].withRemaining(now)
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// GetCookingSession handles the GET /me/cooking-sessions/:id endpoint.
+func GetCookingSession(c *gin.Context) {
+	session, err := findCookingSession(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cooking session not found"})
+		return
+	}
+	respondCookingSession(c, http.StatusOK, "", session.ID)
+}
+
+// moveCookingStep advances or rewinds a session by delta steps.
+func moveCookingStep(c *gin.Context, delta int) {
+	session, ok := findActiveCookingSession(c)
+	if !ok {
+		return
+	}
+	if err := session.moveStep(delta); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := DB.Model(&session).Update("current_step", session.CurrentStep).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update cooking session"})
+		return
+	}
+	respondCookingSession(c, http.StatusOK, "step.changed", session.ID)
+}
+
+// NextCookingStep handles the POST /me/cooking-sessions/:id/next endpoint.
+func NextCookingStep(c *gin.Context) {
+	moveCookingStep(c, 1)
+}
+
+// PreviousCookingStep handles the POST /me/cooking-sessions/:id/previous endpoint.
+func PreviousCookingStep(c *gin.Context) {
+	moveCookingStep(c, -1)
+}
+
+// CreateCookingTimer handles the POST /me/cooking-sessions/:id/timers endpoint.
+// It adds a named timer, for the current step unless another is given, and
+// starts it right away.
+func CreateCookingTimer(c *gin.Context) {
+	session, ok := findActiveCookingSession(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name            string `json:"name" binding:"required"`
+		DurationSeconds int    `json:"duration_seconds" binding:"required,min=1"`
+		Step            int    `json:"step" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Step == 0 {
+		input.Step = session.CurrentStep
+	}
+
+	timer := CookingTimer{
+		SessionID:       session.ID,
+		StepPosition:    input.Step,
+		Name:            input.Name,
+		DurationSeconds: input.DurationSeconds,
+		Status:          TimerPending,
+	}
+	_ = timer.start(time.Now())
+	if err := DB.Create(&timer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create timer"})
+		return
+	}
+	respondCookingSession(c, http.StatusCreated, "timer.started", session.ID)
+}
+
+// updateCookingTimer applies a state change to one of a session's timers.
+func updateCookingTimer(c *gin.Context, eventType string, change func(*CookingTimer, time.Time) error) {
+	session, ok := findActiveCookingSession(c)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if err := expireDueTimers(session.ID, now); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timers"})
+		return
+	}
+	if session, ok = findActiveCookingSession(c); !ok {
+		return
+	}
+	timer, ok := findSessionTimer(c, &session)
+	if !ok {
+		return
+	}
+	if err := change(timer, now); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	err := DB.Model(timer).Select("status", "elapsed_seconds", "running_since", "expires_at").Updates(timer).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timer"})
+		return
+	}
+	respondCookingSession(c, http.StatusOK, eventType, session.ID)
+}
+
+// StartCookingTimer handles the POST /me/cooking-sessions/:id/timers/:timerId/start endpoint.
+func StartCookingTimer(c *gin.Context) {
+	updateCookingTimer(c, "timer.started", (*CookingTimer).start)
+}
+
+// PauseCookingTimer handles the POST /me/cooking-sessions/:id/timers/:timerId/pause endpoint.
+func PauseCookingTimer(c *gin.Context) {
+	updateCookingTimer(c, "timer.paused", (*CookingTimer).pause)
+}
+
+// FinishCookingSession handles the POST /me/cooking-sessions/:id/finish
+// endpoint. It records a cooked event, which decrements the pantry.
+func FinishCookingSession(c *gin.Context) {
+	session, ok := findActiveCookingSession(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		event, err := recordCookEvent(tx, session.UserID, currentHouseholdID(c), session.RecipeID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&CookingTimer{}).Where("session_id = ? AND status = ?", session.ID, TimerRunning).
+			Updates(map[string]interface{}{"status": TimerPaused, "running_since": nil, "expires_at": nil}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"status":        SessionFinished,
+			"finished_at":   now,
+			"cook_event_id": event.ID,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to finish cooking session"})
+		return
+	}
+	respondCookingSession(c, http.StatusOK, "session.finished", session.ID)
+}
+
+// AbandonCookingSession handles the DELETE /me/cooking-sessions/:id endpoint.
+func AbandonCookingSession(c *gin.Context) {
+	session, ok := findActiveCookingSession(c)
+	if !ok {
+		return
+	}
+	if err := DB.Model(&session).Updates(map[string]interface{}{"status": SessionAbandoned, "finished_at": time.Now()}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to abandon cooking session"})
+		return
+	}
+	respondCookingSession(c, http.StatusOK, "session.abandoned", session.ID)
+}
+
+// StreamCookingSession handles the GET /me/cooking-sessions/:id/events
+// endpoint. It sends the current session state, then pushes session changes
+// and timer expiries as Server-Sent Events until the client disconnects.
+func StreamCookingSession(c *gin.Context) {
+	session, err := findCookingSession(c)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cooking session not found"})
+		return
+	}
+
+	events := cookingEvents.subscribe(session.ID)
+	defer cookingEvents.unsubscribe(session.ID, events)
+	ticker := time.NewTicker(cookingTickInterval)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("session.state", CookingEvent{Type: "session.state", Session: session.withRemaining(time.Now()), At: time.Now()})
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event := <-events:
+			c.SSEvent(event.Type, event)
+			return event.Type != "session.finished" && event.Type != "session.abandoned"
+		case now := <-ticker.C:
+			if err := expireDueTimers(session.ID, now); err != nil {
+				c.SSEvent("error", gin.H{"error": "Failed to update timers"})
+				return false
+			}
+			return true
+		}
+	})
+}
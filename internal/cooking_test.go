@@ -0,0 +1,63 @@
+// cooking_test.go
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCookingTimer verifies timers keep elapsed time across pauses.
+func TestCookingTimer(t *testing.T) {
+	start := time.Date(2024, 3, 4, 18, 0, 0, 0, time.UTC)
+	timer := CookingTimer{DurationSeconds: 600, Status: TimerPending}
+
+	assert.NoError(t, timer.start(start))
+	assert.Equal(t, start.Add(10*time.Minute), *timer.ExpiresAt)
+	assert.Equal(t, 480.0, timer.remaining(start.Add(2*time.Minute)))
+
+	assert.NoError(t, timer.pause(start.Add(2*time.Minute)))
+	assert.Equal(t, 120.0, timer.ElapsedSeconds)
+	assert.Equal(t, 480.0, timer.remaining(start.Add(time.Hour)))
+	assert.Error(t, timer.pause(start.Add(time.Hour)))
+
+	resume := start.Add(time.Hour)
+	assert.NoError(t, timer.start(resume))
+	assert.Equal(t, resume.Add(8*time.Minute), *timer.ExpiresAt)
+	assert.Equal(t, 0.0, timer.remaining(resume.Add(time.Hour)))
+
+	timer.Status = TimerExpired
+	assert.Error(t, timer.start(resume))
+}
+
+// TestCookingSessionSteps verifies step navigation and timers prepared from step durations.
+func TestCookingSessionSteps(t *testing.T) {
+	steps := SplitInstructions("Chop the onions.\nSimmer the sauce, stirring, for 20 minutes.\nServe.")
+	timers := stepTimers(steps)
+	assert.Len(t, timers, 1)
+	assert.Equal(t, "Step 2: Simmer the sauce", timers[0].Name)
+	assert.Equal(t, 1200, timers[0].DurationSeconds)
+
+	session := CookingSession{CurrentStep: 1, StepCount: len(steps)}
+	assert.Error(t, session.moveStep(-1))
+	assert.NoError(t, session.moveStep(1))
+	assert.NoError(t, session.moveStep(1))
+	assert.Error(t, session.moveStep(1))
+	assert.Equal(t, 3, session.CurrentStep)
+}
+
+// TestCookingBroker verifies events reach only the session's subscribers.
+func TestCookingBroker(t *testing.T) {
+	broker := &cookingBroker{subscribers: make(map[uint]map[chan CookingEvent]struct{})}
+	first := broker.subscribe(1)
+	other := broker.subscribe(2)
+
+	broker.publish(1, CookingEvent{Type: "timer.expired"})
+	assert.Equal(t, "timer.expired", (<-first).Type)
+	assert.Len(t, other, 0)
+
+	broker.unsubscribe(1, first)
+	broker.unsubscribe(2, other)
+	assert.Empty(t, broker.subscribers)
+}
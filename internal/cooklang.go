@@ -0,0 +1,682 @@
+// cooklang.go
+package internal
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// cooklangExtension is the file extension of Cooklang recipes.
+const cooklangExtension = ".cook"
+
+// cooklangBlockCommentPattern matches block comments such as "[- note -]".
+var cooklangBlockCommentPattern = regexp.MustCompile(`(?s)\[-.*?-\]`)
+
+// cooklangMetadataPattern matches a metadata line such as ">> servings: 4".
+var cooklangMetadataPattern = regexp.MustCompile(`^>>\s*([^:]+?)\s*:\s*(.*)$`)
+
+// cooklangSectionPattern matches a section line such as "== Dough ==".
+var cooklangSectionPattern = regexp.MustCompile(`^=+\s*(.*?)\s*=*$`)
+
+// cooklangEscaper escapes text that would otherwise read as Cooklang markup.
+var cooklangEscaper = strings.NewReplacer(
+	`\`, `\\`, "@", `\@`, "#", `\#`, "~", `\~`, "{", `\{`, "}", `\}`, "--", `-\-`, "[-", `[\-`,
+)
+
+// cooklangItem is an ingredient, cookware or timer marked up in a step.
+type cooklangItem struct {
+	Name     string
+	Quantity string
+	Unit     string
+	Note     string
+}
+
+// amount returns the item's quantity and unit as one text, e.g. "2 cups".
+func (i cooklangItem) amount() string {
+	return strings.TrimSpace(i.Quantity + " " + i.Unit)
+}
+
+// seconds returns the length of a timer, reading units by their first
+// letter and defaulting to minutes.
+func (i cooklangItem) seconds() int {
+	amount, ok := parseNumber(i.Quantity)
+	if !ok {
+		return 0
+	}
+	switch unit := strings.ToLower(strings.TrimSpace(i.Unit)); {
+	case strings.HasPrefix(unit, "d"):
+		amount *= 86400
+	case strings.HasPrefix(unit, "h"):
+		amount *= 3600
+	case strings.HasPrefix(unit, "s"):
+	default:
+		amount *= 60
+	}
+	return int(math.Round(amount))
+}
+
+// ParseCooklang reads a Cooklang recipe. Each paragraph becomes a step whose
+// ingredients, cookware and timers come from its "@", "#" and "~" markup;
+// metadata comes from YAML front matter or ">>" lines, and ">" notes become
+// a final "Notes" section. name, usually the file path, titles recipes
+// without a title and identifies the recipe for later imports of the same
+// file.
+func ParseCooklang(r io.Reader, name string) (ImportedRecipe, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImportBytes))
+	if err != nil {
+		return ImportedRecipe{}, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = cooklangBlockCommentPattern.ReplaceAllString(text, "")
+	lines := strings.Split(strings.TrimPrefix(text, "\ufeff"), "\n")
+
+	metadata := map[string]string{}
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for end := 1; end < len(lines); end++ {
+			if strings.TrimSpace(lines[end]) == "---" {
+				parseCooklangFrontMatter(lines[1:end], metadata)
+				lines = lines[end+1:]
+				break
+			}
+		}
+	}
+
+	imported := ImportedRecipe{
+		Ingredients: []ImportedIngredient{},
+		Steps:       []recipeStepInput{},
+		Equipment:   []string{},
+		Tags:        []string{},
+		Images:      []string{},
+		Format:      ImportFormatCooklang,
+	}
+	var ingredients []cooklangItem
+	seenEquipment := map[string]bool{}
+	var section string
+	var paragraph, notes []string
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		text, items, cookware, seconds := parseCooklangStep(strings.Join(paragraph, " "))
+		paragraph = nil
+		step := recipeStepInput{Section: section, Text: text, Ingredients: []string{}}
+		for _, item := range items {
+			step.Ingredients = append(step.Ingredients, item.Name)
+			ingredients = mergeCooklangIngredient(ingredients, item)
+		}
+		for _, item := range cookware {
+			if key := strings.ToLower(item); !seenEquipment[key] {
+				seenEquipment[key] = true
+				imported.Equipment = append(imported.Equipment, item)
+			}
+		}
+		if seconds > 0 {
+			step.DurationSeconds = &seconds
+		}
+		if step.Text != "" {
+			imported.Steps = append(imported.Steps, step)
+		}
+	}
+	for _, line := range lines {
+		line = strings.TrimSpace(stripCooklangComment(line))
+		switch {
+		case line == "":
+			flush()
+		case cooklangMetadataPattern.MatchString(line):
+			flush()
+			m := cooklangMetadataPattern.FindStringSubmatch(line)
+			metadata[strings.ToLower(m[1])] = cooklangValue(m[2])
+		case strings.HasPrefix(line, ">"):
+			flush()
+			notes = append(notes, strings.TrimSpace(line[1:]))
+		case strings.HasPrefix(line, "="):
+			flush()
+			section = cooklangSectionPattern.FindStringSubmatch(line)[1]
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	for _, note := range notes {
+		if note != "" {
+			imported.Steps = append(imported.Steps, recipeStepInput{Section: "Notes", Text: note})
+		}
+	}
+
+	for _, item := range ingredients {
+		ingredient := ImportedIngredient{Name: item.Name, Text: strings.TrimSpace(item.amount() + " " + item.Name)}
+		if quantity, ok := ParseQuantity(item.amount()); ok {
+			ingredient.Quantity = quantity.String()
+		}
+		if item.Note != "" {
+			ingredient.Text += ", " + item.Note
+		}
+		imported.Ingredients = append(imported.Ingredients, ingredient)
+	}
+
+	applyCooklangMetadata(&imported, metadata)
+	if imported.Title == "" {
+		imported.Title = strings.TrimSuffix(path.Base(name), cooklangExtension)
+		if imported.Title == "." || imported.Title == "/" {
+			imported.Title = ""
+		}
+	}
+	if name != "" {
+		imported.ExternalID = "cooklang:" + path.Clean(name)
+	}
+	return imported, nil
+}
+
+// parseCooklangFrontMatter reads the subset of YAML used for Cooklang front
+// matter: "key: value" lines, lists of "- item" lines and one level of
+// nested keys, stored as "parent.key". Lists are joined with commas.
+func parseCooklangFrontMatter(lines []string, metadata map[string]string) {
+	parent, last := "", ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok {
+			if last != "" {
+				metadata[last] = strings.TrimPrefix(metadata[last]+", "+cooklangValue(item), ", ")
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		if line[0] == ' ' || line[0] == '\t' {
+			if parent != "" {
+				key = parent + "." + key
+			}
+		} else {
+			parent = key
+		}
+		last = key
+		metadata[key] = cooklangValue(value)
+	}
+}
+
+// cooklangValue unquotes a metadata value and unwraps inline lists such as
+// "[a, b]" into comma-separated text.
+func cooklangValue(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		items := strings.Split(value[1:len(value)-1], ",")
+		for i, item := range items {
+			items[i] = cooklangValue(item)
+		}
+		return strings.Join(items, ", ")
+	}
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	}
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	return value
+}
+
+// applyCooklangMetadata maps Cooklang metadata keys onto a recipe.
+func applyCooklangMetadata(imported *ImportedRecipe, metadata map[string]string) {
+	get := func(keys ...string) string {
+		for _, key := range keys {
+			if value := strings.TrimSpace(metadata[key]); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+	list := func(keys ...string) []string {
+		var items []string
+		for _, item := range strings.Split(get(keys...), ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+
+	imported.Title = get("title", "name")
+	if servings := get("servings", "serves"); servings != "" {
+		_, _, imported.Servings = schemaYield(servings)
+	}
+	if yield := get("yield", "makes"); yield != "" {
+		amount, unit, servings := schemaYield(yield)
+		if unit != "servings" {
+			imported.YieldAmount, imported.YieldUnit = amount, unit
+		} else if imported.Servings == 0 {
+			imported.Servings = servings
+		}
+	}
+	if imported.Servings == 0 {
+		imported.Servings = 1
+	}
+	imported.PrepTimeMinutes = durationMinutes(get("prep time", "prep_time", "time.prep"))
+	imported.CookTimeMinutes = durationMinutes(get("cook time", "cook_time", "time.cook"))
+	imported.TotalTimeMinutes = durationMinutes(get("time", "total time", "total_time", "duration", "time required", "time.total"))
+	if imported.TotalTimeMinutes == 0 {
+		imported.TotalTimeMinutes = imported.PrepTimeMinutes + imported.CookTimeMinutes
+	}
+	imported.Difficulty = strings.ToLower(get("difficulty"))
+	imported.Cuisine = normalizeCuisine(get("cuisine"))
+	imported.Course = schemaCourse(list("course", "category"))
+	for _, tag := range list("tags", "tag") {
+		if len(imported.Tags) < 20 {
+			imported.Tags = append(imported.Tags, tag)
+		}
+	}
+	if source := get("source", "source.url", "url", "source_url"); strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		imported.SourceURL = source
+	}
+	for _, image := range list("image", "images", "picture") {
+		if strings.HasPrefix(image, "http://") || strings.HasPrefix(image, "https://") {
+			imported.Images = append(imported.Images, image)
+		}
+	}
+}
+
+// stripCooklangComment removes a "--" line comment, honoring escapes.
+func stripCooklangComment(line string) string {
+	for i := 0; i+1 < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+		case line[i] == '-' && line[i+1] == '-':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseCooklangStep reads the markup of a step, returning its plain text,
+// the ingredients and cookware it names and the total of its timers in
+// seconds.
+func parseCooklangStep(text string) (string, []cooklangItem, []string, int) {
+	var plain strings.Builder
+	var ingredients []cooklangItem
+	var cookware []string
+	seconds := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\\' && i+1 < len(runes) {
+			i++
+			plain.WriteRune(runes[i])
+			continue
+		}
+		if r != '@' && r != '#' && r != '~' {
+			plain.WriteRune(r)
+			continue
+		}
+		item, end, ok := readCooklangItem(runes, i+1, r)
+		if !ok {
+			plain.WriteRune(r)
+			continue
+		}
+		i = end - 1
+		switch r {
+		case '@':
+			plain.WriteString(item.Name)
+			ingredients = append(ingredients, item)
+		case '#':
+			plain.WriteString(item.Name)
+			cookware = append(cookware, item.Name)
+		case '~':
+			if item.Quantity != "" {
+				plain.WriteString(item.amount())
+			} else {
+				plain.WriteString(item.Name)
+			}
+			seconds += item.seconds()
+		}
+	}
+	return strings.Join(strings.Fields(plain.String()), " "), ingredients, cookware, seconds
+}
+
+// readCooklangItem reads the component after a "@", "#" or "~" starting at
+// runes[start]: a name of several words closed by "{quantity%unit}", or a
+// single word. Ingredients may be followed by a "(note)". It returns the
+// index after the component, or false when the sigil starts no component.
+func readCooklangItem(runes []rune, start int, sigil rune) (cooklangItem, int, bool) {
+	i := start
+	if sigil == '@' {
+		// Modifiers of newer Cooklang versions: optional, reference, hidden, new.
+		for i < len(runes) && strings.ContainsRune("?&-+", runes[i]) {
+			i++
+		}
+	}
+
+	var item cooklangItem
+	end := i
+	braced := false
+	if brace := indexCooklangBrace(runes, i); brace >= 0 {
+		closing := -1
+		for j := brace + 1; j < len(runes); j++ {
+			if runes[j] == '}' {
+				closing = j
+				break
+			}
+		}
+		if closing < 0 {
+			return item, 0, false
+		}
+		item.Name = strings.TrimSpace(string(runes[i:brace]))
+		quantity, unit, _ := strings.Cut(string(runes[brace+1:closing]), "%")
+		item.Quantity, item.Unit = strings.TrimSpace(quantity), strings.TrimSpace(unit)
+		end, braced = closing+1, true
+	} else {
+		for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+			end++
+		}
+		item.Name = string(runes[i:end])
+	}
+	if item.Name == "" && (sigil != '~' || !braced) {
+		return item, 0, false
+	}
+
+	if sigil == '@' && braced && end < len(runes) && runes[end] == '(' {
+		for j := end + 1; j < len(runes); j++ {
+			if runes[j] == ')' {
+				item.Note = strings.TrimSpace(string(runes[end+1 : j]))
+				end = j + 1
+				break
+			}
+		}
+	}
+	return item, end, true
+}
+
+// indexCooklangBrace returns the index of the "{" closing a multi-word name
+// starting at runes[start], or -1 when the name is a single word: the brace
+// must come before any markup or sentence punctuation.
+func indexCooklangBrace(runes []rune, start int) int {
+	for j := start; j < len(runes); j++ {
+		switch r := runes[j]; {
+		case r == '{':
+			return j
+		case strings.ContainsRune("@#~}.,;:!?()", r):
+			return -1
+		}
+	}
+	return -1
+}
+
+// mergeCooklangIngredient adds an ingredient mention to the ingredient list.
+// Mentions of an ingredient already listed add to it: without a quantity
+// they are references, and quantities in the same unit are summed.
+func mergeCooklangIngredient(ingredients []cooklangItem, item cooklangItem) []cooklangItem {
+	for i, existing := range ingredients {
+		if !strings.EqualFold(existing.Name, item.Name) {
+			continue
+		}
+		if item.Quantity == "" {
+			return ingredients
+		}
+		if existing.Quantity == "" {
+			ingredients[i].Quantity, ingredients[i].Unit = item.Quantity, item.Unit
+			return ingredients
+		}
+		a, okA := parseNumber(existing.Quantity)
+		b, okB := parseNumber(item.Quantity)
+		if okA && okB && strings.EqualFold(existing.Unit, item.Unit) {
+			ingredients[i].Quantity = FormatAmount(a + b)
+			return ingredients
+		}
+	}
+	return append(ingredients, item)
+}
+
+// ParseCooklangArchive reads the .cook files of a zip archive, such as a
+// snapshot of a recipe repository. Each recipe is identified by its path.
+func ParseCooklangArchive(r io.ReaderAt, size int64) ([]ImportedRecipe, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipes []ImportedRecipe
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, cooklangExtension) || strings.HasPrefix(file.Name, "__MACOSX/") {
+			continue
+		}
+		if len(recipes) == maxLibraryItems {
+			return nil, fmt.Errorf("archive holds more than %d recipes", maxLibraryItems)
+		}
+		if file.UncompressedSize64 > maxLibraryEntryBytes {
+			return nil, fmt.Errorf("%s: file is larger than %d bytes", file.Name, maxLibraryEntryBytes)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		recipe, err := ParseCooklang(rc, file.Name)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		recipes = append(recipes, recipe)
+	}
+	if len(recipes) == 0 {
+		return nil, errEmptyLibrary
+	}
+	return recipes, nil
+}
+
+// cooklangMark is markup replacing text[start:end] of a step.
+type cooklangMark struct {
+	start, end int
+	markup     string
+}
+
+// Cooklang returns the recipe as a Cooklang document. Ingredients and
+// cookware are marked up where a step first names them and timers where a
+// step states its duration; ingredients no step names are gathered into a
+// first step so that none is lost.
+func (e RecipeExport) Cooklang() string {
+	recipe := e.Recipe
+	var b strings.Builder
+	b.WriteString("---\n")
+	meta := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, cooklangMetaValue(value))
+		}
+	}
+	meta("title", recipe.Title)
+	if recipe.Servings > 0 {
+		meta("servings", strconv.Itoa(recipe.Servings))
+	}
+	meta("yield", e.yield())
+	for _, duration := range []struct {
+		key     string
+		minutes int
+	}{{"prep time", recipe.PrepTimeMinutes}, {"cook time", recipe.CookTimeMinutes}, {"time", recipe.TotalTimeMinutes}} {
+		if duration.minutes > 0 {
+			meta(duration.key, fmt.Sprintf("%d minutes", duration.minutes))
+		}
+	}
+	meta("difficulty", recipe.Difficulty)
+	meta("course", recipe.Course)
+	meta("cuisine", recipe.Cuisine)
+	if len(recipe.Tags) > 0 {
+		names := make([]string, 0, len(recipe.Tags))
+		for _, tag := range recipe.Tags {
+			names = append(names, cooklangMetaValue(tag.Name))
+		}
+		fmt.Fprintf(&b, "tags: [%s]\n", strings.Join(names, ", "))
+	}
+	meta("author", e.Author)
+	meta("source", recipe.SourceURL)
+	if len(recipe.ImageURLs) > 0 {
+		meta("image", recipe.ImageURLs[0])
+	}
+	b.WriteString("---\n")
+
+	ingredients := e.Ingredients
+	if len(ingredients) == 0 {
+		for _, line := range e.ingredientLines() {
+			quantity, name := ParseIngredientLine(line)
+			ingredient := Ingredient{Name: name}
+			if quantity.Amount > 0 {
+				ingredient.Quantity = quantity.String()
+			}
+			ingredients = append(ingredients, ingredient)
+		}
+	}
+	marked := make([]bool, len(ingredients))
+	steps := e.steps()
+	paragraphs := make([]string, len(steps))
+	for i, step := range steps {
+		paragraphs[i] = cooklangStep(step, ingredients, marked, recipe.Equipment)
+	}
+
+	var unmarked []string
+	for i, ingredient := range ingredients {
+		if !marked[i] && strings.TrimSpace(ingredient.Name) != "" {
+			unmarked = append(unmarked, cooklangIngredient(ingredient.Name, ingredient.Quantity))
+		}
+	}
+	if len(unmarked) > 0 {
+		fmt.Fprintf(&b, "\nHave ready: %s.\n", strings.Join(unmarked, ", "))
+	}
+
+	section := ""
+	for i, step := range steps {
+		if step.Section != section {
+			section = step.Section
+			if section != "" {
+				fmt.Fprintf(&b, "\n== %s ==\n", cooklangEscaper.Replace(section))
+			}
+		}
+		fmt.Fprintf(&b, "\n%s\n", paragraphs[i])
+	}
+	return b.String()
+}
+
+// cooklangMetaValue quotes a front matter value that YAML would otherwise
+// misread.
+func cooklangMetaValue(value string) string {
+	if value == "" || strings.ContainsAny(value, ":#,[]{}\"'\n") || strings.ContainsAny(value[:1], "-?!&*|>%@` ") || strings.HasSuffix(value, " ") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// cooklangIngredient returns the markup of an ingredient, such as
+// "@olive oil{2%tbsp}".
+func cooklangIngredient(name, quantity string) string {
+	amount := ""
+	if q, ok := ParseQuantity(quantity); ok {
+		amount = FormatAmount(q.Amount)
+		if q.Unit != "" {
+			amount += "%" + q.Unit
+		}
+	} else {
+		amount = strings.NewReplacer("%", "", "{", "", "}", "").Replace(strings.TrimSpace(quantity))
+	}
+	return "@" + name + "{" + amount + "}"
+}
+
+// cooklangStep renders a step as Cooklang, marking up the ingredients it
+// first names, the first mention of each piece of equipment and its
+// durations. marked records the ingredients marked up so far.
+func cooklangStep(step RecipeStep, ingredients []Ingredient, marked []bool, equipment []string) string {
+	text := step.Text
+	var marks []cooklangMark
+	overlaps := func(start, end int) bool {
+		for _, mark := range marks {
+			if start < mark.end && mark.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(start, end int, markup string) bool {
+		if overlaps(start, end) {
+			return false
+		}
+		marks = append(marks, cooklangMark{start, end, markup})
+		return true
+	}
+	find := func(name string) []int {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil
+		}
+		pattern, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(name) + `\b`)
+		if err != nil {
+			return nil
+		}
+		return pattern.FindStringIndex(text)
+	}
+
+	for i, ingredient := range ingredients {
+		if marked[i] {
+			continue
+		}
+		if loc := find(ingredient.Name); loc != nil {
+			marked[i] = add(loc[0], loc[1], cooklangIngredient(text[loc[0]:loc[1]], ingredient.Quantity))
+		}
+	}
+	for _, item := range equipment {
+		if loc := find(item); loc != nil {
+			add(loc[0], loc[1], "#"+text[loc[0]:loc[1]]+"{}")
+		}
+	}
+
+	// Durations in the text become timers when they add up to the step's
+	// duration. Ranges have no timer markup, so steps with one get a timer
+	// for the whole duration after the text instead.
+	timed := false
+	if step.DurationSeconds > 0 && DetectStepDuration(text) == step.DurationSeconds {
+		matches := durationPattern.FindAllStringSubmatchIndex(text, -1)
+		timed = true
+		for _, m := range matches {
+			if m[4] >= 0 || overlaps(m[0], m[1]) {
+				timed = false
+			}
+		}
+		for _, m := range matches {
+			if timed {
+				add(m[0], m[1], "~{"+text[m[2]:m[3]]+"%"+text[m[6]:m[7]]+"}")
+			}
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for len(marks) > 0 {
+		next := 0
+		for i, mark := range marks {
+			if mark.start < marks[next].start {
+				next = i
+			}
+		}
+		mark := marks[next]
+		marks = append(marks[:next], marks[next+1:]...)
+		b.WriteString(cooklangEscaper.Replace(text[last:mark.start]))
+		b.WriteString(mark.markup)
+		last = mark.end
+	}
+	b.WriteString(cooklangEscaper.Replace(text[last:]))
+	if step.DurationSeconds > 0 && !timed {
+		if step.DurationSeconds%60 == 0 {
+			fmt.Fprintf(&b, " ~{%d%%minutes}", step.DurationSeconds/60)
+		} else {
+			fmt.Fprintf(&b, " ~{%d%%seconds}", step.DurationSeconds)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,169 @@
+// cooklang_test.go
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const cooklangSample = `---
+title: Pancakes
+servings: 4
+tags: [breakfast, "quick"]
+time:
+  prep: 10 minutes
+  cook: 15 minutes
+source: https://example.com/pancakes
+---
+
+-- Family recipe.
+Crack @eggs{3} into a #large bowl{}, add @flour{125%g} and @milk{250%ml}.
+Whisk until smooth. [- no lumps -]
+
+== Cooking ==
+>> difficulty: Easy
+
+Heat @butter{1%tbsp} in a #frying pan{} and cook for ~{2%minutes}, then flip
+and cook ~{1%minute} more.
+
+Dust with @sea salt{} and more @flour{25%g}, costs \@ 2 euros.
+
+> Batter keeps for a day.
+`
+
+func TestParseCooklang(t *testing.T) {
+	recipe, err := ParseCooklang(strings.NewReader(cooklangSample), "breakfast/pancakes.cook")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Pancakes", recipe.Title)
+	assert.Equal(t, "cooklang:breakfast/pancakes.cook", recipe.ExternalID)
+	assert.Equal(t, 4, recipe.Servings)
+	assert.Equal(t, 10, recipe.PrepTimeMinutes)
+	assert.Equal(t, 15, recipe.CookTimeMinutes)
+	assert.Equal(t, 25, recipe.TotalTimeMinutes)
+	assert.Equal(t, "easy", recipe.Difficulty)
+	assert.Equal(t, "https://example.com/pancakes", recipe.SourceURL)
+	assert.Equal(t, []string{"breakfast", "quick"}, recipe.Tags)
+	assert.Equal(t, []string{"large bowl", "frying pan"}, recipe.Equipment)
+
+	assert.Equal(t, []ImportedIngredient{
+		{Text: "3 eggs", Name: "eggs", Quantity: "3"},
+		{Text: "150 g flour", Name: "flour", Quantity: "150 g"},
+		{Text: "250 ml milk", Name: "milk", Quantity: "250 ml"},
+		{Text: "1 tbsp butter", Name: "butter", Quantity: "1 tbsp"},
+		{Text: "sea salt", Name: "sea salt"},
+	}, recipe.Ingredients)
+
+	assert.Len(t, recipe.Steps, 4)
+	assert.Equal(t, "Crack eggs into a large bowl, add flour and milk. Whisk until smooth.", recipe.Steps[0].Text)
+	assert.Equal(t, []string{"eggs", "flour", "milk"}, recipe.Steps[0].Ingredients)
+	assert.Nil(t, recipe.Steps[0].DurationSeconds)
+	assert.Equal(t, "Cooking", recipe.Steps[1].Section)
+	assert.Equal(t, "Heat butter in a frying pan and cook for 2 minutes, then flip and cook 1 minute more.", recipe.Steps[1].Text)
+	if assert.NotNil(t, recipe.Steps[1].DurationSeconds) {
+		assert.Equal(t, 180, *recipe.Steps[1].DurationSeconds)
+	}
+	assert.Equal(t, "Dust with sea salt and more flour, costs @ 2 euros.", recipe.Steps[2].Text)
+	assert.Equal(t, recipeStepInput{Section: "Notes", Text: "Batter keeps for a day."}, recipe.Steps[3])
+}
+
+func TestParseCooklangDefaults(t *testing.T) {
+	recipe, err := ParseCooklang(strings.NewReader(">> servings: 2\nBoil @water for ~eggs{6%min}.\n"), "soft-eggs.cook")
+	assert.NoError(t, err)
+	assert.Equal(t, "soft-eggs", recipe.Title)
+	assert.Equal(t, 2, recipe.Servings)
+	assert.Equal(t, "Boil water for 6 min.", recipe.Steps[0].Text)
+	assert.Equal(t, 360, *recipe.Steps[0].DurationSeconds)
+	assert.Equal(t, []ImportedIngredient{{Text: "water", Name: "water"}}, recipe.Ingredients)
+}
+
+func TestRecipeCooklang(t *testing.T) {
+	export := exportFixture()
+	export.Recipe.Steps[0].Text = "Press the dough into the baking pan."
+	export.Recipe.Steps[1].DurationSeconds = 1200
+	export.Recipe.Steps = append(export.Recipe.Steps, RecipeStep{Section: "Filling", Text: "Bake until set.", DurationSeconds: 900})
+	doc := export.Cooklang()
+
+	assert.Contains(t, doc, "---\ntitle: Lemon *Bars*\nservings: 12\nyield: 24 bars\n")
+	assert.Contains(t, doc, "tags: [citrus, bake sale]\n")
+	assert.Contains(t, doc, "\nHave ready: @flour{2%cup}, @lemons{}.\n")
+	assert.Contains(t, doc, "\n== Crust ==\n\nPress the dough into the #baking pan{}.\n")
+	assert.Contains(t, doc, "\nBake for ~{20%minutes}.\n")
+	assert.Contains(t, doc, "\n== Filling ==\n\nWhisk eggs, sugar and lemon juice.\n")
+	assert.Contains(t, doc, "\nBake until set. ~{15%minutes}\n")
+}
+
+func TestCooklangRoundTrip(t *testing.T) {
+	parsed, err := ParseCooklang(strings.NewReader(cooklangSample), "pancakes.cook")
+	assert.NoError(t, err)
+
+	library := libraryRecipeFromImported(parsed)
+	recipe := Recipe{
+		Title:            library.Title,
+		Servings:         library.Servings,
+		PrepTimeMinutes:  library.PrepTimeMinutes,
+		CookTimeMinutes:  library.CookTimeMinutes,
+		TotalTimeMinutes: library.TotalTimeMinutes,
+		Difficulty:       library.Difficulty,
+		Equipment:        library.Equipment,
+		SourceURL:        library.SourceURL,
+	}
+	for _, step := range library.Steps {
+		recipe.Steps = append(recipe.Steps, RecipeStep{Section: step.Section, Text: step.Text, DurationSeconds: step.DurationSeconds})
+	}
+	var ingredients []Ingredient
+	for _, row := range library.IngredientRows {
+		ingredients = append(ingredients, Ingredient{Name: row.Name, Quantity: row.Quantity})
+	}
+
+	doc := RecipeExport{Recipe: recipe, Ingredients: ingredients}.Cooklang()
+	again, err := ParseCooklang(strings.NewReader(doc), "pancakes.cook")
+	assert.NoError(t, err)
+	assert.Equal(t, parsed.Title, again.Title)
+	assert.Equal(t, parsed.TotalTimeMinutes, again.TotalTimeMinutes)
+	assert.Equal(t, parsed.Equipment, again.Equipment)
+	for i := range parsed.Ingredients {
+		assert.Equal(t, parsed.Ingredients[i].Name, again.Ingredients[i].Name)
+		assert.Equal(t, parsed.Ingredients[i].Quantity, again.Ingredients[i].Quantity)
+	}
+	assert.Len(t, again.Steps, len(parsed.Steps))
+	for i := range parsed.Steps {
+		assert.Equal(t, parsed.Steps[i].Section, again.Steps[i].Section)
+		assert.Equal(t, parsed.Steps[i].Text, again.Steps[i].Text)
+		assert.Equal(t, parsed.Steps[i].DurationSeconds, again.Steps[i].DurationSeconds)
+	}
+}
+
+func TestParseCooklangArchive(t *testing.T) {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, body := range map[string]string{
+		"recipes/pancakes.cook": cooklangSample,
+		"recipes/toast.cook":    "Toast @bread{2%slices}.\n",
+		"README.md":             "# Recipes\n",
+	} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(body))
+	}
+	assert.NoError(t, zw.Close())
+
+	assert.Equal(t, ImportFormatCooklang, detectLibraryFormat(archive.Bytes(), ""))
+	assert.Equal(t, ImportFormatCooklang, detectLibraryFormat([]byte(cooklangSample), "pancakes.cook"))
+
+	library, format, err := parseLibraryUpload(archive.Bytes(), "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, ImportFormatCooklang, format)
+	ids := map[string]string{}
+	for _, recipe := range library.Recipes {
+		ids[recipe.Title] = recipe.ExternalID
+	}
+	assert.Equal(t, map[string]string{
+		"Pancakes": "cooklang:recipes/pancakes.cook",
+		"toast":    "cooklang:recipes/toast.cook",
+	}, ids)
+}
@@ -30,12 +30,48 @@ func InitDB() {
 	}
 	log.Println("Database connection established")
 
+	// Recipe tags use a custom join table that records who applied the tag
+	if err := DB.SetupJoinTable(&Recipe{}, "Tags", &RecipeTag{}); err != nil {
+		log.Fatalf("Failed to set up recipe tags: %v", err)
+	}
+
 	// Automatically migrate your models
-	err = DB.AutoMigrate(&User{}, &Recipe{}, &Ingredient{}, &SavedRecipe{}, &UserPreference{})
+	err = DB.AutoMigrate(
+		&User{}, &Recipe{}, &Ingredient{}, &SavedRecipe{}, &UserPreference{},
+		&MealPlanEntry{}, &ShoppingList{}, &ShoppingListItem{}, &PantryItem{}, &CookEvent{},
+		&Product{}, &Household{}, &HouseholdMember{}, &HouseholdInvite{}, &Collection{},
+		&SKUMapping{}, &PreferredBrand{}, &Food{}, &RecipeNutrition{}, &NutritionOverride{}, &FoodLogEntry{},
+		&RecipeStep{}, &CookingSession{}, &CookingTimer{}, &Tag{}, &RecipeTag{}, &RecipeRevision{},
+		&ImportJob{}, &CookbookJob{}, &RecipeImage{}, &RecipeReview{}, &Comment{},
+		&Follow{}, &Activity{}, &FeedItem{}, &ModerationCase{}, &Report{}, &ModerationAction{},
+	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database schemas: %v", err)
 	}
 	log.Println("Database migration completed")
+
+	// Recipes from before the draft workflow were published when created
+	err = DB.Model(&Recipe{}).
+		Where("status = ? AND published_at IS NULL", RecipeStatusPublished).
+		UpdateColumn("published_at", gorm.Expr("created_at")).Error
+	if err != nil {
+		log.Fatalf("Failed to backfill recipe publish dates: %v", err)
+	}
+
+	// Imports and cookbooks run in the server process and do not survive a restart
+	for _, model := range []interface{}{&ImportJob{}, &CookbookJob{}} {
+		err = DB.Model(model).
+			Where("status IN ?", []string{JobPending, JobRunning}).
+			Updates(map[string]interface{}{"status": JobFailed, "finished_at": gorm.Expr("NOW()")}).Error
+		if err != nil {
+			log.Fatalf("Failed to fail interrupted jobs: %v", err)
+		}
+	}
+
+	// Split legacy instruction text into structured steps
+	if err := backfillRecipeSteps(DB); err != nil {
+		log.Fatalf("Failed to split recipe instructions into steps: %v", err)
+	}
 }
 
 // getEnv retrieves environment variables or returns a default value
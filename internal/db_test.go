@@ -25,13 +25,24 @@ func SetupTestDB() (*gorm.DB, error) {
 	return db, nil
 }
 
+// DryRunDB returns a database that builds SQL without connecting, for
+// tests that check the generated queries. Writes run outside transactions
+// so that nothing needs a connection.
+func DryRunDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(postgres.Open("host=localhost sslmode=disable"), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatalf("Failed to open dry-run database: %v", err)
+	}
+	return db
+}
+
 func TestCreateAndGetRecipe(t *testing.T) {
 	db, err := SetupTestDB()
 	assert.NoError(t, err, "Failed to connect to the test database")
 
 	// Create a new recipe
 	newRecipe := Recipe{
-		Name:         "Test Recipe",
+		Title:        "Test Recipe",
 		Ingredients:  "Test Ingredients",
 		Instructions: "Test Instructions",
 		Calories:     250,
@@ -45,7 +56,7 @@ func TestCreateAndGetRecipe(t *testing.T) {
 	var retrievedRecipe Recipe
 	result = db.First(&retrievedRecipe, newRecipe.ID)
 	assert.NoError(t, result.Error, "Failed to retrieve the recipe")
-	assert.Equal(t, newRecipe.Name, retrievedRecipe.Name, "Recipe name does not match")
+	assert.Equal(t, newRecipe.Title, retrievedRecipe.Title, "Recipe title does not match")
 	assert.Equal(t, newRecipe.Ingredients, retrievedRecipe.Ingredients, "Recipe ingredients do not match")
 	assert.Equal(t, newRecipe.Instructions, retrievedRecipe.Instructions, "Recipe instructions do not match")
 	assert.Equal(t, newRecipe.Calories, retrievedRecipe.Calories, "Recipe calories do not match")
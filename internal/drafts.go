@@ -0,0 +1,343 @@
+// drafts.go
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// Recipe statuses. Drafts are only visible to their owner.
+const (
+	RecipeStatusDraft     = "draft"
+	RecipeStatusPublished = "published"
+)
+
+// RecipeDraft holds recipe edits that are saved but not published. Fields
+// left nil are unchanged from the recipe. Only formats are validated when
+// saving; required fields are checked on publish.
+type RecipeDraft struct {
+	Title        *string           `json:"title,omitempty" binding:"omitempty,max=255"`
+	Ingredients  *string           `json:"ingredients,omitempty"`
+	Instructions *string           `json:"instructions,omitempty"`
+	Steps        []recipeStepInput `json:"steps,omitempty" binding:"omitempty,dive"`
+	Calories     *int              `json:"calories,omitempty" binding:"omitempty,min=0"`
+	Servings     *int              `json:"servings,omitempty" binding:"omitempty,min=1"`
+	Tags         []string          `json:"tags,omitempty" binding:"omitempty,dive,max=64"`
+	Visibility   *string           `json:"visibility,omitempty" binding:"omitempty,oneof=private unlisted public"`
+	recipeMetadataInput
+	SavedAt time.Time `json:"saved_at"`
+}
+
+// merge overlays the fields set in an autosave onto the draft.
+func (d *RecipeDraft) merge(update RecipeDraft) {
+	if update.Title != nil {
+		d.Title = update.Title
+	}
+	if update.Ingredients != nil {
+		d.Ingredients = update.Ingredients
+	}
+	if update.Instructions != nil {
+		d.Instructions = update.Instructions
+		d.Steps = nil
+	}
+	if update.Steps != nil {
+		d.Steps = update.Steps
+	}
+	if update.Calories != nil {
+		d.Calories = update.Calories
+	}
+	if update.Servings != nil {
+		d.Servings = update.Servings
+	}
+	if update.Tags != nil {
+		d.Tags = update.Tags
+	}
+	if update.Visibility != nil {
+		d.Visibility = update.Visibility
+	}
+	d.recipeMetadataInput.merge(update.recipeMetadataInput)
+}
+
+// validateRecipeDraft runs the checks of CreateRecipe against a recipe with
+// its draft applied, listing every missing field at once.
+func validateRecipeDraft(recipe Recipe, draft RecipeDraft) error {
+	if err := binding.Validator.ValidateStruct(draft); err != nil {
+		return err
+	}
+
+	title, ingredients, instructions := recipe.Title, recipe.Ingredients, recipe.Instructions
+	if draft.Title != nil {
+		title = *draft.Title
+	}
+	if draft.Ingredients != nil {
+		ingredients = *draft.Ingredients
+	}
+	if draft.Instructions != nil {
+		instructions = *draft.Instructions
+	}
+	if len(draft.Steps) > 0 {
+		instructions = RenderInstructions(buildRecipeSteps(draft.Steps, nil))
+	}
+
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"title", title},
+		{"ingredients", ingredients},
+		{"instructions", instructions},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("cannot publish without %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// publishRecipeDraft applies a draft to a recipe and marks it published.
+// The first publish records PublishedAt and, unless the draft sets one,
+// gives the recipe its owner's default visibility.
+func publishRecipeDraft(tx *gorm.DB, recipe *Recipe, draft RecipeDraft) error {
+	columns := []string{"status", "published_at", "draft"}
+	if draft.Title != nil {
+		recipe.Title = strings.TrimSpace(*draft.Title)
+		columns = append(columns, "title")
+	}
+	if draft.Ingredients != nil {
+		recipe.Ingredients = *draft.Ingredients
+		columns = append(columns, "ingredients")
+	}
+	if draft.Instructions != nil {
+		recipe.Instructions = *draft.Instructions
+		columns = append(columns, "instructions")
+	}
+	if draft.Calories != nil {
+		recipe.Calories, recipe.CaloriesManual = *draft.Calories, true
+		columns = append(columns, "calories", "calories_manual")
+	}
+	if draft.Servings != nil {
+		recipe.Servings = *draft.Servings
+		columns = append(columns, "servings")
+	}
+	if metadata := draft.columns(); len(metadata) > 0 {
+		draft.apply(recipe)
+		columns = append(columns, metadata...)
+	}
+	if draft.Visibility != nil {
+		recipe.Visibility = *draft.Visibility
+		columns = append(columns, "visibility")
+	} else if recipe.Status == RecipeStatusDraft {
+		recipe.Visibility = defaultVisibility(recipe.UserID)
+		columns = append(columns, "visibility")
+	}
+
+	recipe.Status = RecipeStatusPublished
+	if recipe.PublishedAt == nil {
+		now := time.Now()
+		recipe.PublishedAt = &now
+	}
+	recipe.Draft = nil
+	if err := tx.Model(recipe).Select(columns).Updates(recipe).Error; err != nil {
+		return err
+	}
+
+	if err := saveRecipeTags(tx, *recipe, draft.Tags); err != nil {
+		return err
+	}
+	if draft.Steps != nil || draft.Instructions != nil {
+		return saveRecipeSteps(tx, recipe, draft.Steps)
+	}
+	return nil
+}
+
+// recipeDraftResponse is a recipe's pending draft as returned to its owner.
+type recipeDraftResponse struct {
+	RecipeID    uint         `json:"recipe_id"`
+	Title       string       `json:"title"`
+	Status      string       `json:"status"`
+	PublishedAt *time.Time   `json:"published_at"`
+	Draft       *RecipeDraft `json:"draft"`
+}
+
+// newRecipeDraftResponse describes a recipe's draft, titled as it will be
+// once published.
+func newRecipeDraftResponse(recipe Recipe) recipeDraftResponse {
+	response := recipeDraftResponse{
+		RecipeID:    recipe.ID,
+		Title:       recipe.Title,
+		Status:      recipe.Status,
+		PublishedAt: recipe.PublishedAt,
+		Draft:       recipe.Draft,
+	}
+	if recipe.Draft != nil && recipe.Draft.Title != nil {
+		response.Title = *recipe.Draft.Title
+	}
+	return response
+}
+
+// CreateRecipeDraft handles the POST /recipes/drafts endpoint. Drafts may
+// leave out any field; they stay private until published.
+func CreateRecipeDraft(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var draft RecipeDraft
+	if err := c.ShouldBindJSON(&draft); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	draft.SavedAt = time.Now()
+
+	recipe := Recipe{
+		Servings:   1,
+		Equipment:  []string{},
+		Visibility: VisibilityPrivate,
+		Status:     RecipeStatusDraft,
+		UserID:     userID,
+		Draft:      &draft,
+	}
+	if draft.Title != nil {
+		recipe.Title = strings.TrimSpace(*draft.Title)
+	}
+	if err := DB.Create(&recipe).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create draft"})
+		return
+	}
+	c.JSON(http.StatusCreated, newRecipeDraftResponse(recipe))
+}
+
+// GetRecipeDraft handles the GET /recipes/:id/draft endpoint. The draft is
+// null when there are no unpublished edits.
+func GetRecipeDraft(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+	c.JSON(http.StatusOK, newRecipeDraftResponse(recipe))
+}
+
+// SaveRecipeDraft handles the PATCH /recipes/:id/draft endpoint. Autosaves
+// send only the fields that changed and are merged into the saved draft;
+// the published recipe is untouched until the draft is published.
+func SaveRecipeDraft(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+
+	var update RecipeDraft
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if recipe.Draft == nil {
+		recipe.Draft = &RecipeDraft{}
+	}
+	recipe.Draft.merge(update)
+	recipe.Draft.SavedAt = time.Now()
+
+	// An unpublished recipe is listed under its draft title.
+	columns := []string{"draft"}
+	if recipe.Status == RecipeStatusDraft && update.Title != nil {
+		recipe.Title = strings.TrimSpace(*update.Title)
+		columns = append(columns, "title")
+	}
+	if err := DB.Model(&recipe).Select(columns).Updates(&recipe).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save draft"})
+		return
+	}
+	c.JSON(http.StatusOK, newRecipeDraftResponse(recipe))
+}
+
+// DiscardRecipeDraft handles the DELETE /recipes/:id/draft endpoint,
+// dropping unpublished edits to a published recipe.
+func DiscardRecipeDraft(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+	if recipe.Status == RecipeStatusDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "Recipe has never been published; delete it instead"})
+		return
+	}
+
+	recipe.Draft = nil
+	if err := DB.Model(&recipe).Select("draft").Updates(&recipe).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discard draft"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Draft discarded"})
+}
+
+// PublishRecipe handles the POST /recipes/:id/publish endpoint. The draft
+// is validated like a new recipe and applied in one transaction, so readers
+// never see a half-applied edit.
+func PublishRecipe(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+	if recipe.Status == RecipeStatusPublished && recipe.Draft == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Recipe has no unpublished changes"})
+		return
+	}
+
+	var draft RecipeDraft
+	if recipe.Draft != nil {
+		draft = *recipe.Draft
+	}
+	if err := validateRecipeDraft(recipe, draft); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keep the published state in history before replacing it.
+	userID, _ := currentUserID(c)
+	if recipe.Status == RecipeStatusPublished {
+		if _, err := saveRecipeRevision(DB, recipe.ID, userID, "Snapshot before edit"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record recipe revision"})
+			return
+		}
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error { return publishRecipeDraft(tx, &recipe, draft) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to publish recipe"})
+		return
+	}
+	saveRecipeRevisionLogged(recipe.ID, userID, "Published recipe")
+	refreshRecipeNutritionLogged(recipe.ID)
+	recordRecipeActivity(recipe)
+
+	DB.Scopes(recipeDetails).First(&recipe, recipe.ID)
+	c.JSON(http.StatusOK, recipe)
+}
+
+// GetMyDrafts handles the GET /me/drafts endpoint, listing the caller's
+// unpublished recipes and recipes with unpublished edits.
+func GetMyDrafts(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var recipes []Recipe
+	err := DB.Where("user_id = ? AND (status = ? OR draft IS NOT NULL)", userID, RecipeStatusDraft).
+		Order("updated_at DESC").
+		Find(&recipes).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve drafts"})
+		return
+	}
+
+	drafts := make([]recipeDraftResponse, 0, len(recipes))
+	for _, recipe := range recipes {
+		drafts = append(drafts, newRecipeDraftResponse(recipe))
+	}
+	c.JSON(http.StatusOK, drafts)
+}
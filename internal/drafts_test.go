@@ -0,0 +1,51 @@
+// drafts_test.go
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRecipeDraftMerge verifies autosaves only replace the fields they send.
+func TestRecipeDraftMerge(t *testing.T) {
+	title, instructions, servings, cuisine := "Soup", "Simmer.", 4, "thai"
+	draft := RecipeDraft{Title: &title, Steps: []recipeStepInput{{Text: "Chop."}}}
+
+	draft.merge(RecipeDraft{Servings: &servings})
+	assert.Equal(t, "Soup", *draft.Title)
+	assert.Len(t, draft.Steps, 1)
+	assert.Equal(t, 4, *draft.Servings)
+
+	draft.merge(RecipeDraft{Instructions: &instructions, recipeMetadataInput: recipeMetadataInput{Cuisine: &cuisine}})
+	assert.Equal(t, "Simmer.", *draft.Instructions)
+	assert.Nil(t, draft.Steps, "new instruction text replaces earlier steps")
+	assert.Equal(t, "thai", *draft.Cuisine)
+}
+
+// TestValidateRecipeDraft verifies publishing requires the fields CreateRecipe does.
+func TestValidateRecipeDraft(t *testing.T) {
+	title, ingredients := "Soup", "1 onion"
+	err := validateRecipeDraft(Recipe{}, RecipeDraft{Title: &title})
+	assert.EqualError(t, err, "cannot publish without ingredients, instructions")
+
+	draft := RecipeDraft{Title: &title, Ingredients: &ingredients, Steps: []recipeStepInput{{Text: "Simmer the onion."}}}
+	assert.NoError(t, validateRecipeDraft(Recipe{}, draft))
+
+	// Pending edits to a published recipe only need to keep it complete.
+	published := Recipe{Title: "Soup", Ingredients: "1 onion", Instructions: "Simmer.", Status: RecipeStatusPublished}
+	assert.NoError(t, validateRecipeDraft(published, RecipeDraft{}))
+	empty := ""
+	assert.Error(t, validateRecipeDraft(published, RecipeDraft{Title: &empty}))
+
+	difficulty := "extreme"
+	assert.Error(t, validateRecipeDraft(published, RecipeDraft{recipeMetadataInput: recipeMetadataInput{Difficulty: &difficulty}}))
+}
+
+// TestCanViewDraftRecipe verifies drafts are only visible to their owner.
+func TestCanViewDraftRecipe(t *testing.T) {
+	draft := Recipe{UserID: 1, Visibility: VisibilityPublic, Status: RecipeStatusDraft}
+	assert.True(t, canViewRecipe(visibilityContext("/recipes/1", 1), draft))
+	assert.False(t, canViewRecipe(visibilityContext("/recipes/1", 2), draft))
+	assert.False(t, canViewRecipe(visibilityContext("/recipes/1", 0), draft))
+}
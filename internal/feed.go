@@ -0,0 +1,217 @@
+// feed.go
+package internal
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of activities shown in feeds.
+const (
+	ActivityRecipe     = "recipe"
+	ActivityReview     = "review"
+	ActivityCollection = "collection"
+)
+
+// feedFanOutLimit is the most followers a user can have for their
+// activities to be copied into each follower's feed. Beyond it, writing a
+// feed item per follower costs more than merging the user's activities into
+// feeds as they are read.
+const feedFanOutLimit = 1000
+
+// feedBackfill is how many recent activities of a user are copied into the
+// feed of a new follower.
+const feedBackfill = 20
+
+// FeedEntry is an activity as shown in a feed, with what it is about.
+type FeedEntry struct {
+	ID         uint          `json:"id"`
+	Kind       string        `json:"kind"`
+	Actor      UserSummary   `json:"actor"`
+	Recipe     *Recipe       `json:"recipe,omitempty"`
+	Review     *RecipeReview `json:"review,omitempty"`
+	Collection *Collection   `json:"collection,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+// recordActivity records an activity once per subject, copying it into the
+// feeds of the actor's followers unless they are too many.
+func recordActivity(actorID uint, kind string, subjectID uint) error {
+	var followers int64
+	if err := DB.Model(&Follow{}).Where("followee_id = ?", actorID).Count(&followers).Error; err != nil {
+		return err
+	}
+	activity := Activity{ActorID: actorID, Kind: kind, SubjectID: subjectID, FannedOut: followers <= feedFanOutLimit}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&activity)
+		if result.Error != nil || result.RowsAffected == 0 || !activity.FannedOut {
+			return result.Error
+		}
+		return tx.Exec(
+			"INSERT INTO feed_items (user_id, activity_id, created_at) SELECT follower_id, ?, ? FROM follows WHERE followee_id = ?",
+			activity.ID, activity.CreatedAt, actorID,
+		).Error
+	})
+}
+
+// recordActivityLogged records an activity, logging failures: feeds are
+// secondary to what the activity is about.
+func recordActivityLogged(actorID uint, kind string, subjectID uint) {
+	if err := recordActivity(actorID, kind, subjectID); err != nil {
+		log.Printf("Failed to record %s activity %d of user %d: %v", kind, subjectID, actorID, err)
+	}
+}
+
+// recordRecipeActivity records a recipe becoming public, the first time it
+// is both published and public.
+func recordRecipeActivity(recipe Recipe) {
+	if recipeIsPublic(recipe) {
+		recordActivityLogged(recipe.UserID, ActivityRecipe, recipe.ID)
+	}
+}
+
+// backfillFeed copies a user's recent fanned-out activities into the feed
+// of a new follower. Activities that were not fanned out need no copying.
+func backfillFeed(tx *gorm.DB, followerID, followeeID uint) error {
+	return tx.Exec(
+		`INSERT INTO feed_items (user_id, activity_id, created_at)
+		SELECT ?, id, created_at FROM activities WHERE actor_id = ? AND fanned_out
+		ORDER BY id DESC LIMIT ?
+		ON CONFLICT DO NOTHING`,
+		followerID, followeeID, feedBackfill,
+	).Error
+}
+
+// feedEntries turns activities into feed entries, leaving out those whose
+// subject has since been deleted or is no longer public.
+func feedEntries(activities []Activity) ([]FeedEntry, error) {
+	var actorIDs []uint
+	subjectIDs := map[string][]uint{}
+	for _, activity := range activities {
+		actorIDs = append(actorIDs, activity.ActorID)
+		subjectIDs[activity.Kind] = append(subjectIDs[activity.Kind], activity.SubjectID)
+	}
+	actors, err := userSummaries(actorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var reviews []RecipeReview
+	if ids := subjectIDs[ActivityReview]; len(ids) > 0 {
+		if err := DB.Preload("Photos", reviewPhotos).Where("id IN ? AND hidden_at IS NULL", ids).Find(&reviews).Error; err != nil {
+			return nil, err
+		}
+	}
+	reviewsByID := make(map[uint]*RecipeReview, len(reviews))
+	recipeIDs := subjectIDs[ActivityRecipe]
+	for i := range reviews {
+		reviewsByID[reviews[i].ID] = &reviews[i]
+		recipeIDs = append(recipeIDs, reviews[i].RecipeID)
+	}
+
+	var recipes []Recipe
+	if len(recipeIDs) > 0 {
+		err := DB.Scopes(publicRecipes).Preload("Tags").Preload("Images", recipePhotos).
+			Where("id IN ?", recipeIDs).
+			Find(&recipes).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	recipesByID := make(map[uint]*Recipe, len(recipes))
+	for i := range recipes {
+		if err := attachRecipeImages(&recipes[i]); err != nil {
+			return nil, err
+		}
+		recipesByID[recipes[i].ID] = &recipes[i]
+	}
+
+	var collections []Collection
+	if ids := subjectIDs[ActivityCollection]; len(ids) > 0 {
+		err := DB.Preload("Recipes", publicRecipes).
+			Where("id IN ? AND public", ids).
+			Find(&collections).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	collectionsByID := make(map[uint]*Collection, len(collections))
+	for i := range collections {
+		collectionsByID[collections[i].ID] = &collections[i]
+	}
+
+	entries := []FeedEntry{}
+	for _, activity := range activities {
+		entry := FeedEntry{ID: activity.ID, Kind: activity.Kind, Actor: actors[activity.ActorID], CreatedAt: activity.CreatedAt}
+		switch activity.Kind {
+		case ActivityRecipe:
+			entry.Recipe = recipesByID[activity.SubjectID]
+		case ActivityReview:
+			if entry.Review = reviewsByID[activity.SubjectID]; entry.Review != nil {
+				entry.Recipe = recipesByID[entry.Review.RecipeID]
+			}
+		case ActivityCollection:
+			entry.Collection = collectionsByID[activity.SubjectID]
+		}
+		if entry.Recipe == nil && entry.Collection == nil {
+			continue
+		}
+		if entry.Review != nil {
+			reviews := []RecipeReview{*entry.Review}
+			if err := attachReviewDetails(*entry.Recipe, reviews); err != nil {
+				return nil, err
+			}
+			entry.Review = &reviews[0]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetFeed handles the GET /me/feed endpoint: what the users the caller
+// follows have published, reviewed and collected, newest first. Pass
+// next_cursor back as cursor for the following page. Pages can hold fewer
+// entries than the limit when activities are no longer public.
+func GetFeed(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	limit := queryLimit(c, 20, 100)
+
+	// Activities copied into the caller's feed, merged with those of
+	// followed users who have too many followers to copy them to.
+	query := DB.Where(
+		`(id IN (SELECT activity_id FROM feed_items WHERE user_id = ?)
+		OR (NOT fanned_out AND actor_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)))`,
+		userID, userID,
+	)
+	cursor, err := queryCursor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+
+	var activities []Activity
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feed"})
+		return
+	}
+	var next *uint
+	if len(activities) > limit {
+		activities = activities[:limit]
+		next = &activities[limit-1].ID
+	}
+
+	entries, err := feedEntries(activities)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve feed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": entries, "next_cursor": next})
+}
@@ -0,0 +1,452 @@
+// food_log.go
+package internal
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// NutritionProgress compares what was eaten with the daily goals. Remaining
+// and Percent are zero for nutrients without a goal.
+type NutritionProgress struct {
+	Date      string         `json:"date,omitempty"`
+	Consumed  NutritionFacts `json:"consumed"`
+	Goals     NutritionFacts `json:"goals"`
+	Remaining NutritionFacts `json:"remaining"`
+	Percent   NutritionFacts `json:"percent"`
+}
+
+// NutritionSummary is the food log for a range of days with the daily average.
+type NutritionSummary struct {
+	From    string              `json:"from"`
+	To      string              `json:"to"`
+	Days    []NutritionProgress `json:"days"`
+	Average NutritionProgress   `json:"average"`
+}
+
+// nutritionProgress compares consumed facts against goals.
+func nutritionProgress(consumed, goals NutritionFacts) NutritionProgress {
+	remaining := func(goal, eaten float64) float64 {
+		if goal <= 0 {
+			return 0
+		}
+		return math.Round((goal-eaten)*10) / 10
+	}
+	percent := func(goal, eaten float64) float64 {
+		if goal <= 0 {
+			return 0
+		}
+		return math.Round(eaten / goal * 100)
+	}
+	return NutritionProgress{
+		Consumed: consumed,
+		Goals:    goals,
+		Remaining: NutritionFacts{
+			Calories: remaining(goals.Calories, consumed.Calories),
+			ProteinG: remaining(goals.ProteinG, consumed.ProteinG),
+			FatG:     remaining(goals.FatG, consumed.FatG),
+			CarbsG:   remaining(goals.CarbsG, consumed.CarbsG),
+			FiberG:   remaining(goals.FiberG, consumed.FiberG),
+			SugarG:   remaining(goals.SugarG, consumed.SugarG),
+			SodiumMg: remaining(goals.SodiumMg, consumed.SodiumMg),
+		},
+		Percent: NutritionFacts{
+			Calories: percent(goals.Calories, consumed.Calories),
+			ProteinG: percent(goals.ProteinG, consumed.ProteinG),
+			FatG:     percent(goals.FatG, consumed.FatG),
+			CarbsG:   percent(goals.CarbsG, consumed.CarbsG),
+			FiberG:   percent(goals.FiberG, consumed.FiberG),
+			SugarG:   percent(goals.SugarG, consumed.SugarG),
+			SodiumMg: percent(goals.SodiumMg, consumed.SodiumMg),
+		},
+	}
+}
+
+// SummarizeFoodLog totals log entries per day over an inclusive date range
+// and averages them across every day in the range, including empty ones.
+func SummarizeFoodLog(entries []FoodLogEntry, goals NutritionFacts, from, to time.Time) NutritionSummary {
+	totals := make(map[string]NutritionFacts)
+	for _, entry := range entries {
+		day := entry.Date.Format(dateLayout)
+		totals[day] = totals[day].add(entry.NutritionFacts)
+	}
+
+	summary := NutritionSummary{From: from.Format(dateLayout), To: to.Format(dateLayout), Days: []NutritionProgress{}}
+	var sum NutritionFacts
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(dateLayout)
+		consumed := totals[key].divide(1)
+		progress := nutritionProgress(consumed, goals)
+		progress.Date = key
+		summary.Days = append(summary.Days, progress)
+		sum = sum.add(consumed)
+	}
+	if len(summary.Days) > 0 {
+		summary.Average = nutritionProgress(sum.divide(float64(len(summary.Days))), goals)
+	}
+	return summary
+}
+
+// weekRange returns the Monday-to-Sunday week containing a date.
+func weekRange(date time.Time) (time.Time, time.Time) {
+	offset := (int(date.Weekday()) + 6) % 7
+	from := date.AddDate(0, 0, -offset)
+	return from, from.AddDate(0, 0, 6)
+}
+
+// userPreference returns the user's preference row, creating it if needed.
+func userPreference(userID uint) (UserPreference, error) {
+	var pref UserPreference
+	err := DB.Where("user_id = ?", userID).Order("id").FirstOrCreate(&pref, UserPreference{UserID: userID}).Error
+	return pref, err
+}
+
+// recipeServingNutrition returns the per-serving nutrition of a recipe,
+// computing it if it has not been yet. Manually entered calories win over
+// the computed value.
+func recipeServingNutrition(recipe Recipe) (NutritionFacts, error) {
+	var nutrition RecipeNutrition
+	err := DB.Where("recipe_id = ?", recipe.ID).First(&nutrition).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err = refreshRecipeNutrition(DB, recipe.ID); err == nil {
+			err = DB.Where("recipe_id = ?", recipe.ID).First(&nutrition).Error
+		}
+	}
+	if err != nil {
+		return NutritionFacts{}, err
+	}
+	facts := nutrition.PerServing
+	if recipe.CaloriesManual {
+		facts.Calories = float64(recipe.Calories)
+	}
+	return facts, nil
+}
+
+// recipeLogEntry builds a food log entry for servings of a recipe.
+func recipeLogEntry(userID uint, recipe Recipe, servings float64) (FoodLogEntry, error) {
+	perServing, err := recipeServingNutrition(recipe)
+	if err != nil {
+		return FoodLogEntry{}, err
+	}
+	return FoodLogEntry{
+		UserID:         userID,
+		Name:           recipe.Title,
+		RecipeID:       &recipe.ID,
+		Servings:       servings,
+		NutritionFacts: perServing.multiply(servings),
+	}, nil
+}
+
+// GetNutritionGoals handles the GET /me/nutrition/goals endpoint.
+func GetNutritionGoals(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	pref, err := userPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve nutrition goals"})
+		return
+	}
+	c.JSON(http.StatusOK, pref.DailyGoals)
+}
+
+// UpdateNutritionGoals handles the PUT /me/nutrition/goals endpoint.
+// Omitted nutrients keep their goal; zero clears it.
+func UpdateNutritionGoals(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Calories *float64 `json:"calories" binding:"omitempty,min=0"`
+		ProteinG *float64 `json:"protein_g" binding:"omitempty,min=0"`
+		FatG     *float64 `json:"fat_g" binding:"omitempty,min=0"`
+		CarbsG   *float64 `json:"carbs_g" binding:"omitempty,min=0"`
+		FiberG   *float64 `json:"fiber_g" binding:"omitempty,min=0"`
+		SugarG   *float64 `json:"sugar_g" binding:"omitempty,min=0"`
+		SodiumMg *float64 `json:"sodium_mg" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pref, err := userPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve nutrition goals"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	for column, value := range map[string]*float64{
+		"goal_calories":  input.Calories,
+		"goal_protein_g": input.ProteinG,
+		"goal_fat_g":     input.FatG,
+		"goal_carbs_g":   input.CarbsG,
+		"goal_fiber_g":   input.FiberG,
+		"goal_sugar_g":   input.SugarG,
+		"goal_sodium_mg": input.SodiumMg,
+	} {
+		if value != nil {
+			updates[column] = *value
+		}
+	}
+	if len(updates) > 0 {
+		if err := DB.Model(&pref).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update nutrition goals"})
+			return
+		}
+	}
+
+	DB.First(&pref, pref.ID)
+	c.JSON(http.StatusOK, pref.DailyGoals)
+}
+
+// GetFoodLog handles the GET /me/food-log endpoint.
+// The date query parameter defaults to today.
+func GetFoodLog(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	date, _, err := parseDateRange(c.DefaultQuery("date", time.Now().Format(dateLayout)), "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []FoodLogEntry
+	if err := DB.Where("user_id = ? AND date = ?", userID, date).Order("created_at").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve food log"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// CreateFoodLogEntry handles the POST /me/food-log endpoint. An entry is
+// either servings of a recipe, grams of a nutrient database food, or an
+// ad-hoc item with a name and its nutrition.
+func CreateFoodLogEntry(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Date      string          `json:"date"`
+		Meal      string          `json:"meal" binding:"omitempty,oneof=breakfast lunch dinner snack"`
+		RecipeID  *uint           `json:"recipe_id"`
+		Servings  float64         `json:"servings" binding:"omitempty,gt=0"`
+		FoodID    *uint           `json:"food_id"`
+		Grams     float64         `json:"grams" binding:"omitempty,gt=0"`
+		Name      string          `json:"name"`
+		Nutrition *NutritionFacts `json:"nutrition"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Date == "" {
+		input.Date = time.Now().Format(dateLayout)
+	}
+	date, err := time.Parse(dateLayout, input.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+	if input.Servings == 0 {
+		input.Servings = 1
+	}
+
+	var entry FoodLogEntry
+	switch {
+	case input.RecipeID != nil:
+		var recipe Recipe
+		if err := findVisibleRecipe(c, DB, &recipe, *input.RecipeID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+			return
+		}
+		if entry, err = recipeLogEntry(userID, recipe, input.Servings); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute recipe nutrition"})
+			return
+		}
+	case input.FoodID != nil:
+		if input.Grams == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "grams is required when logging a food"})
+			return
+		}
+		var food Food
+		if err := DB.First(&food, *input.FoodID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Food not found"})
+			return
+		}
+		entry = FoodLogEntry{
+			UserID:         userID,
+			Name:           food.Description,
+			FoodID:         &food.ID,
+			Servings:       input.Servings,
+			Grams:          input.Grams,
+			NutritionFacts: scaleFacts(food.NutritionFacts, input.Grams*input.Servings).divide(1),
+		}
+	case input.Name != "" && input.Nutrition != nil:
+		entry = FoodLogEntry{
+			UserID:         userID,
+			Name:           input.Name,
+			Servings:       input.Servings,
+			NutritionFacts: input.Nutrition.multiply(input.Servings),
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide recipe_id, food_id with grams, or name with nutrition"})
+		return
+	}
+	if input.Name != "" {
+		entry.Name = input.Name
+	}
+	entry.Date = date
+	entry.Meal = input.Meal
+
+	if err := DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create food log entry"})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteFoodLogEntry handles the DELETE /me/food-log/:id endpoint.
+func DeleteFoodLogEntry(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var entry FoodLogEntry
+	if err := DB.Where("user_id = ?", userID).First(&entry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Food log entry not found"})
+		return
+	}
+
+	if err := DB.Delete(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete food log entry"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Food log entry deleted"})
+}
+
+// LogMealPlanEntry handles the POST /me/meal-plan/:id/eaten endpoint,
+// logging a planned meal as eaten by the current user. The planned servings
+// can be overridden with a servings field.
+func LogMealPlanEntry(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var planned MealPlanEntry
+	if err := DB.Scopes(ownerScope(c)).First(&planned, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Meal plan entry not found"})
+		return
+	}
+
+	var input struct {
+		Servings float64 `json:"servings" binding:"omitempty,gt=0"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.Servings == 0 {
+		input.Servings = planned.Servings
+	}
+
+	var existing int64
+	if err := DB.Model(&FoodLogEntry{}).Where("user_id = ? AND meal_plan_entry_id = ?", userID, planned.ID).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check food log"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Meal already logged as eaten"})
+		return
+	}
+
+	var recipe Recipe
+	if err := DB.First(&recipe, planned.RecipeID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	entry, err := recipeLogEntry(userID, recipe, input.Servings)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute recipe nutrition"})
+		return
+	}
+	entry.Date = planned.Date
+	entry.Meal = planned.Meal
+	entry.MealPlanEntryID = &planned.ID
+
+	if err := DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create food log entry"})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// nutritionSummary loads the food log and goals for a range and summarizes it.
+func nutritionSummary(c *gin.Context, from, to time.Time) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	pref, err := userPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve nutrition goals"})
+		return
+	}
+	var entries []FoodLogEntry
+	if err := DB.Where("user_id = ? AND date BETWEEN ? AND ?", userID, from, to).Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve food log"})
+		return
+	}
+	c.JSON(http.StatusOK, SummarizeFoodLog(entries, pref.DailyGoals, from, to))
+}
+
+// GetDailyNutrition handles the GET /me/nutrition/daily endpoint.
+// The date query parameter defaults to today.
+func GetDailyNutrition(c *gin.Context) {
+	date, _, err := parseDateRange(c.DefaultQuery("date", time.Now().Format(dateLayout)), "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	nutritionSummary(c, date, date)
+}
+
+// GetWeeklyNutrition handles the GET /me/nutrition/weekly endpoint, covering
+// the Monday-to-Sunday week containing the date query parameter (default today).
+func GetWeeklyNutrition(c *gin.Context) {
+	date, _, err := parseDateRange(c.DefaultQuery("date", time.Now().Format(dateLayout)), "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	from, to := weekRange(date)
+	nutritionSummary(c, from, to)
+}
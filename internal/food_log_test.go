@@ -0,0 +1,44 @@
+// food_log_test.go
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSummarizeFoodLog verifies daily totals, averages and progress against goals.
+func TestSummarizeFoodLog(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse(dateLayout, s)
+		return d
+	}
+	entries := []FoodLogEntry{
+		{Date: day("2024-03-04"), NutritionFacts: NutritionFacts{Calories: 600, ProteinG: 30}},
+		{Date: day("2024-03-04"), NutritionFacts: NutritionFacts{Calories: 900, ProteinG: 50}},
+		{Date: day("2024-03-05"), NutritionFacts: NutritionFacts{Calories: 1500}},
+	}
+	goals := NutritionFacts{Calories: 2000, ProteinG: 100}
+
+	summary := SummarizeFoodLog(entries, goals, day("2024-03-04"), day("2024-03-06"))
+
+	assert.Len(t, summary.Days, 3)
+	first := summary.Days[0]
+	assert.Equal(t, "2024-03-04", first.Date)
+	assert.Equal(t, 1500.0, first.Consumed.Calories)
+	assert.Equal(t, 500.0, first.Remaining.Calories)
+	assert.Equal(t, 75.0, first.Percent.Calories)
+	assert.Equal(t, 80.0, first.Percent.ProteinG)
+	assert.Equal(t, 0.0, first.Percent.FatG)
+	assert.Equal(t, 0.0, summary.Days[2].Consumed.Calories)
+	assert.Equal(t, 1000.0, summary.Average.Consumed.Calories)
+}
+
+// TestWeekRange verifies weeks run Monday to Sunday.
+func TestWeekRange(t *testing.T) {
+	sunday, _ := time.Parse(dateLayout, "2024-03-10")
+	from, to := weekRange(sunday)
+	assert.Equal(t, "2024-03-04", from.Format(dateLayout))
+	assert.Equal(t, "2024-03-10", to.Format(dateLayout))
+}
@@ -0,0 +1,285 @@
+// forks.go
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxLineageDepth bounds how far lineage walks up and down a fork tree.
+const maxLineageDepth = 20
+
+// RecipeSummary identifies a recipe and its author for attribution and
+// lineage. Deleted is set for recipes removed since they were forked, and
+// Hidden for recipes the caller may not read, whose title is left out.
+type RecipeSummary struct {
+	ID           uint      `json:"id"`
+	Title        string    `json:"title"`
+	UserID       uint      `json:"user_id"`
+	Username     string    `json:"username"`
+	ForkedFromID *uint     `json:"forked_from_id,omitempty"`
+	Deleted      bool      `json:"deleted,omitempty"`
+	Hidden       bool      `json:"hidden,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// LineageNode is a recipe with the forks made from it.
+type LineageNode struct {
+	RecipeSummary
+	Forks []*LineageNode `json:"forks"`
+}
+
+// recipeSummaries loads summaries of the given recipes, including deleted
+// and hidden ones, keyed by recipe ID.
+func recipeSummaries(c *gin.Context, ids []uint) (map[uint]RecipeSummary, error) {
+	summaries := make(map[uint]RecipeSummary, len(ids))
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+	var rows []struct {
+		RecipeSummary
+		Visibility string
+		Status     string
+		ShareToken *string
+		HiddenAt   *time.Time
+		DeletedAt  gorm.DeletedAt
+	}
+	err := DB.Unscoped().Model(&Recipe{}).
+		Select("recipes.id, recipes.title, recipes.user_id, users.username, recipes.forked_from_id, recipes.created_at, recipes.visibility, recipes.status, recipes.share_token, recipes.hidden_at, recipes.deleted_at").
+		Joins("LEFT JOIN users ON users.id = recipes.user_id").
+		Where("recipes.id IN ?", ids).
+		Order("recipes.created_at, recipes.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		row.RecipeSummary.Deleted = row.DeletedAt.Valid
+		visible := Recipe{UserID: row.UserID, Visibility: row.Visibility, Status: row.Status, ShareToken: row.ShareToken, HiddenAt: row.HiddenAt}
+		if !canViewRecipe(c, visible) {
+			row.RecipeSummary = RecipeSummary{ID: row.ID, ForkedFromID: row.ForkedFromID, Deleted: row.DeletedAt.Valid, Hidden: true, CreatedAt: row.CreatedAt}
+		}
+		summaries[row.ID] = row.RecipeSummary
+	}
+	return summaries, nil
+}
+
+// attachForkAttribution fills in the attribution of a forked recipe.
+func attachForkAttribution(c *gin.Context, recipe *Recipe) error {
+	if recipe.ForkedFromID == nil {
+		return nil
+	}
+	summaries, err := recipeSummaries(c, []uint{*recipe.ForkedFromID})
+	if err != nil {
+		return err
+	}
+	if summary, ok := summaries[*recipe.ForkedFromID]; ok {
+		recipe.ForkedFrom = &summary
+	}
+	return nil
+}
+
+// forkIDs returns the IDs of the direct forks of the given recipes.
+func forkIDs(parentIDs []uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Unscoped().Model(&Recipe{}).Where("forked_from_id IN ?", parentIDs).Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// ForkRecipe handles the POST /recipes/:id/fork endpoint. The fork is a
+// copy owned by the caller, with its own history starting from the
+// original's current state.
+func ForkRecipe(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var original Recipe
+	if err := findVisibleRecipe(c, DB, &original, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	var input struct {
+		Title string `json:"title" binding:"max=255"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	snapshot, err := snapshotRecipe(DB, original.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read recipe"})
+		return
+	}
+	if input.Title != "" {
+		snapshot.Title = input.Title
+	}
+
+	now := time.Now()
+	fork := Recipe{
+		Title:        snapshot.Title,
+		Servings:     1,
+		Equipment:    []string{},
+		Visibility:   defaultVisibility(userID),
+		Status:       RecipeStatusPublished,
+		PublishedAt:  &now,
+		UserID:       userID,
+		ForkedFromID: &original.ID,
+	}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&fork).Error; err != nil {
+			return err
+		}
+		return restoreSnapshot(tx, &fork, snapshot)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fork recipe"})
+		return
+	}
+	saveRecipeRevisionLogged(fork.ID, userID, fmt.Sprintf("Forked from recipe %d", original.ID))
+	refreshRecipeNutritionLogged(fork.ID)
+	recordRecipeActivity(fork)
+
+	DB.Scopes(recipeDetails).First(&fork, fork.ID)
+	attachForkAttribution(c, &fork)
+	c.JSON(http.StatusCreated, fork)
+}
+
+// GetRecipeForks handles the GET /recipes/:id/forks endpoint, listing the
+// direct forks of a recipe.
+func GetRecipeForks(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	var forks []RecipeSummary
+	err := DB.Model(&Recipe{}).
+		Select("recipes.id, recipes.title, recipes.user_id, users.username, recipes.forked_from_id, recipes.created_at").
+		Joins("LEFT JOIN users ON users.id = recipes.user_id").
+		Scopes(visibleRecipes(c)).
+		Where("recipes.forked_from_id = ?", recipe.ID).
+		Order("recipes.created_at DESC").
+		Scan(&forks).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve forks"})
+		return
+	}
+	c.JSON(http.StatusOK, forks)
+}
+
+// GetRecipeLineage handles the GET /recipes/:id/lineage endpoint. It
+// returns the chain of originals the recipe descends from, oldest first,
+// and the tree of forks rooted at the oldest original. Deleted recipes stay
+// in the tree so that their forks remain connected.
+func GetRecipeLineage(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	ancestors := []RecipeSummary{}
+	rootID, parentID := recipe.ID, recipe.ForkedFromID
+	for depth := 0; parentID != nil && depth < maxLineageDepth; depth++ {
+		summaries, err := recipeSummaries(c, []uint{*parentID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve lineage"})
+			return
+		}
+		parent, ok := summaries[*parentID]
+		if !ok {
+			break
+		}
+		ancestors = append([]RecipeSummary{parent}, ancestors...)
+		rootID, parentID = parent.ID, parent.ForkedFromID
+	}
+
+	tree, err := lineageTree(c, rootID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve lineage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recipe_id": recipe.ID, "ancestors": ancestors, "tree": tree})
+}
+
+// lineageTree loads the fork tree below a recipe, one level at a time.
+func lineageTree(c *gin.Context, rootID uint) (*LineageNode, error) {
+	summaries, err := recipeSummaries(c, []uint{rootID})
+	if err != nil {
+		return nil, err
+	}
+	root := &LineageNode{RecipeSummary: summaries[rootID], Forks: []*LineageNode{}}
+	nodes := map[uint]*LineageNode{rootID: root}
+
+	level := []uint{rootID}
+	for depth := 0; len(level) > 0 && depth < maxLineageDepth; depth++ {
+		ids, err := forkIDs(level)
+		if err != nil {
+			return nil, err
+		}
+		if summaries, err = recipeSummaries(c, ids); err != nil {
+			return nil, err
+		}
+		level = level[:0]
+		for _, id := range ids {
+			summary := summaries[id]
+			parent := nodes[*summary.ForkedFromID]
+			if _, seen := nodes[id]; seen || parent == nil {
+				continue
+			}
+			node := &LineageNode{RecipeSummary: summary, Forks: []*LineageNode{}}
+			parent.Forks = append(parent.Forks, node)
+			nodes[id] = node
+			level = append(level, id)
+		}
+	}
+	return root, nil
+}
+
+// CompareWithOriginal handles the GET /recipes/:id/compare endpoint,
+// diffing a fork against the current state of its original.
+func CompareWithOriginal(c *gin.Context) {
+	var fork Recipe
+	if err := findVisibleRecipe(c, DB, &fork, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if fork.ForkedFromID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Recipe is not a fork"})
+		return
+	}
+
+	var original Recipe
+	if err := findVisibleRecipe(c, DB, &original, *fork.ForkedFromID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Original recipe no longer exists"})
+		return
+	}
+	from, err := snapshotRecipe(DB, original.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read original recipe"})
+		return
+	}
+	to, err := snapshotRecipe(DB, fork.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read recipe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recipe_id":   fork.ID,
+		"original_id": original.ID,
+		"changes":     DiffSnapshots(from, to),
+	})
+}
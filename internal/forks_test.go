@@ -0,0 +1,42 @@
+// forks_test.go
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestForkRecipeRequiresUser verifies forking is refused without a signed-in user.
+func TestForkRecipeRequiresUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/recipes/1/fork", nil)
+
+	ForkRecipe(c)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestRecipeForkAttributionJSON verifies forks carry their attribution and
+// originals leave it out.
+func TestRecipeForkAttributionJSON(t *testing.T) {
+	originalID := uint(7)
+	fork := Recipe{
+		Title:        "Spicier Chili",
+		ForkedFromID: &originalID,
+		ForkedFrom:   &RecipeSummary{ID: originalID, Title: "Chili", Username: "ana"},
+	}
+	encoded, err := json.Marshal(fork)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"forked_from_id":7`)
+	assert.Contains(t, string(encoded), `"forked_from":{"id":7,"title":"Chili","user_id":0,"username":"ana"`)
+
+	encoded, err = json.Marshal(Recipe{Title: "Chili"})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encoded), "forked_from")
+}
@@ -0,0 +1,527 @@
+// grocery.go
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetailProduct is a product a grocery retailer sells under a SKU.
+type RetailProduct struct {
+	SKU           string  `json:"sku"`
+	Name          string  `json:"name"`
+	Brand         string  `json:"brand"`
+	PackageAmount float64 `json:"package_amount"`
+	PackageUnit   string  `json:"package_unit"`
+	PriceCents    int     `json:"price_cents"`
+}
+
+// CartLine is a shopping list item matched to a retailer product.
+// Packages is the number of product units to buy.
+type CartLine struct {
+	Item     ShoppingListItem `json:"item"`
+	Product  RetailProduct    `json:"product"`
+	Packages int              `json:"packages"`
+}
+
+// Cart is a provider-specific cart ready to hand to a retailer.
+type Cart struct {
+	Provider    string             `json:"provider"`
+	ContentType string             `json:"-"`
+	Body        []byte             `json:"-"`
+	Lines       []CartLine         `json:"lines"`
+	Unmatched   []ShoppingListItem `json:"unmatched"`
+}
+
+// GroceryProvider maps shopping list items to retailer SKUs and builds carts.
+type GroceryProvider interface {
+	// Name identifies the provider in requests and persisted SKU mappings.
+	Name() string
+	// Search returns the retailer products that could satisfy an item.
+	Search(ctx context.Context, item ShoppingListItem) ([]RetailProduct, error)
+	// BuildCart renders matched lines in the provider's cart format.
+	// Unmatched items are carried along so the shopper can buy them manually.
+	BuildCart(ctx context.Context, lines []CartLine, unmatched []ShoppingListItem) (Cart, error)
+}
+
+var (
+	groceryProvidersMu sync.RWMutex
+	groceryProviders   = map[string]GroceryProvider{}
+)
+
+// RegisterGroceryProvider makes a provider available to the cart endpoint.
+func RegisterGroceryProvider(provider GroceryProvider) {
+	groceryProvidersMu.Lock()
+	defer groceryProvidersMu.Unlock()
+	groceryProviders[provider.Name()] = provider
+}
+
+// lookupGroceryProvider returns the registered provider with the given name.
+func lookupGroceryProvider(name string) (GroceryProvider, bool) {
+	groceryProvidersMu.RLock()
+	defer groceryProvidersMu.RUnlock()
+	provider, ok := groceryProviders[name]
+	return provider, ok
+}
+
+func init() {
+	RegisterGroceryProvider(GenericProvider{Format: "json"})
+	RegisterGroceryProvider(GenericProvider{Format: "csv"})
+}
+
+// PackagesNeeded returns how many packages of the product cover the item,
+// rounding up to whole packages. It reports false when the units cannot be
+// compared (e.g. a volume item against a product sold by weight).
+func PackagesNeeded(item ShoppingListItem, product RetailProduct) (int, bool) {
+	need := Quantity{Amount: item.Amount, Unit: item.Unit}
+	pack := Quantity{Amount: product.PackageAmount, Unit: product.PackageUnit}
+	if need.Amount <= 0 || pack.Amount <= 0 {
+		return 1, true
+	}
+	if need.Dimension() != pack.Dimension() {
+		return 0, false
+	}
+	if need.Dimension() == DimensionCount && need.Unit != pack.Unit && need.Unit != "" && pack.Unit != "" {
+		return 0, false
+	}
+	return int(math.Ceil(need.Base()/pack.Base() - 1e-9)), true
+}
+
+// MatchProduct picks the best candidate for an item: compatible package
+// units first, then a preferred brand, then the least leftover after rounding
+// up to whole packages, then the lowest total price.
+func MatchProduct(item ShoppingListItem, candidates []RetailProduct, preferredBrands []string) (CartLine, bool) {
+	preferred := make(map[string]bool, len(preferredBrands))
+	for _, brand := range preferredBrands {
+		preferred[strings.ToLower(brand)] = true
+	}
+
+	type scored struct {
+		line      CartLine
+		preferred bool
+		waste     float64
+		cost      int
+	}
+	var options []scored
+	for _, product := range candidates {
+		packages, ok := PackagesNeeded(item, product)
+		if !ok {
+			continue
+		}
+		bought := Quantity{Amount: product.PackageAmount * float64(packages), Unit: product.PackageUnit}
+		options = append(options, scored{
+			line:      CartLine{Item: item, Product: product, Packages: packages},
+			preferred: preferred[strings.ToLower(product.Brand)],
+			waste:     bought.Base() - Quantity{Amount: item.Amount, Unit: item.Unit}.Base(),
+			cost:      product.PriceCents * packages,
+		})
+	}
+	if len(options) == 0 {
+		return CartLine{}, false
+	}
+	sort.SliceStable(options, func(i, j int) bool {
+		a, b := options[i], options[j]
+		if a.preferred != b.preferred {
+			return a.preferred
+		}
+		if math.Abs(a.waste-b.waste) > 1e-9 {
+			return a.waste < b.waste
+		}
+		return a.cost < b.cost
+	})
+	return options[0].line, true
+}
+
+// GenericProvider emits a retailer-neutral cart without SKU lookups; every
+// item is passed through as-is so the cart can be imported anywhere.
+type GenericProvider struct {
+	// Format is "json" or "csv".
+	Format string
+}
+
+// Name implements GroceryProvider.
+func (p GenericProvider) Name() string {
+	if p.Format == "csv" {
+		return "generic-csv"
+	}
+	return "generic"
+}
+
+// Search implements GroceryProvider by echoing the item as its own product.
+func (p GenericProvider) Search(ctx context.Context, item ShoppingListItem) ([]RetailProduct, error) {
+	return []RetailProduct{{Name: item.Name, PackageAmount: item.Amount, PackageUnit: item.Unit}}, nil
+}
+
+// BuildCart implements GroceryProvider.
+func (p GenericProvider) BuildCart(ctx context.Context, lines []CartLine, unmatched []ShoppingListItem) (Cart, error) {
+	cart := Cart{Provider: p.Name(), Lines: lines, Unmatched: unmatched}
+	if p.Format != "csv" {
+		body, err := json.Marshal(cart)
+		if err != nil {
+			return cart, err
+		}
+		cart.ContentType, cart.Body = "application/json", body
+		return cart, nil
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"item", "category", "amount", "unit", "sku", "product", "brand", "packages"})
+	for _, line := range lines {
+		_ = writer.Write([]string{
+			line.Item.Name,
+			line.Item.Category,
+			FormatAmount(line.Item.Amount),
+			line.Item.Unit,
+			line.Product.SKU,
+			line.Product.Name,
+			line.Product.Brand,
+			strconv.Itoa(line.Packages),
+		})
+	}
+	for _, item := range unmatched {
+		_ = writer.Write([]string{item.Name, item.Category, FormatAmount(item.Amount), item.Unit, "", "", "", ""})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return cart, err
+	}
+	cart.ContentType, cart.Body = "text/csv", buf.Bytes()
+	return cart, nil
+}
+
+// FakeProvider is an in-memory retailer backed by a local product catalog,
+// used in tests and local development.
+type FakeProvider struct {
+	Products []RetailProduct
+}
+
+// Name implements GroceryProvider.
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Search implements GroceryProvider by matching the item name against
+// product names.
+func (p *FakeProvider) Search(ctx context.Context, item ShoppingListItem) ([]RetailProduct, error) {
+	var matches []RetailProduct
+	for _, product := range p.Products {
+		if ingredientNamesMatch(product.Name, item.Name) {
+			matches = append(matches, product)
+		}
+	}
+	return matches, nil
+}
+
+// BuildCart implements GroceryProvider, returning a JSON cart.
+func (p *FakeProvider) BuildCart(ctx context.Context, lines []CartLine, unmatched []ShoppingListItem) (Cart, error) {
+	cart := Cart{Provider: p.Name(), Lines: lines, Unmatched: unmatched}
+	body, err := json.Marshal(cart)
+	if err != nil {
+		return cart, err
+	}
+	cart.ContentType, cart.Body = "application/json", body
+	return cart, nil
+}
+
+// BuildGroceryCart matches each item to a product, preferring the user's
+// remembered SKU mappings, and builds the provider cart. Items nothing
+// matches are reported in Cart.Unmatched.
+func BuildGroceryCart(ctx context.Context, provider GroceryProvider, items []ShoppingListItem, mappings map[string]SKUMapping, preferredBrands []string) (Cart, error) {
+	var lines []CartLine
+	var unmatched []ShoppingListItem
+	for _, item := range items {
+		if mapping, ok := mappings[NormalizeIngredientName(item.Name)]; ok {
+			product := RetailProduct{
+				SKU:           mapping.SKU,
+				Name:          mapping.ProductName,
+				Brand:         mapping.Brand,
+				PackageAmount: mapping.PackageAmount,
+				PackageUnit:   mapping.PackageUnit,
+				PriceCents:    mapping.PriceCents,
+			}
+			if packages, ok := PackagesNeeded(item, product); ok {
+				lines = append(lines, CartLine{Item: item, Product: product, Packages: packages})
+				continue
+			}
+		}
+
+		candidates, err := provider.Search(ctx, item)
+		if err != nil {
+			return Cart{}, fmt.Errorf("search %q: %w", item.Name, err)
+		}
+		line, ok := MatchProduct(item, candidates, preferredBrands)
+		if !ok {
+			unmatched = append(unmatched, item)
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return provider.BuildCart(ctx, lines, unmatched)
+}
+
+// BuildShoppingListCart handles the POST /me/shopping-lists/:id/cart endpoint.
+// Unchecked items are matched to the provider's products and the resulting
+// SKU choices are remembered for next time.
+func BuildShoppingListCart(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	list, err := findShoppingList(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list not found"})
+		return
+	}
+
+	var input struct {
+		Provider string `json:"provider"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Provider == "" {
+		input.Provider = "generic"
+	}
+	provider, ok := lookupGroceryProvider(input.Provider)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown grocery provider"})
+		return
+	}
+
+	var mappingRows []SKUMapping
+	if err := DB.Where("user_id = ? AND provider = ?", userID, provider.Name()).Find(&mappingRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve SKU mappings"})
+		return
+	}
+	mappings := make(map[string]SKUMapping, len(mappingRows))
+	for _, mapping := range mappingRows {
+		mappings[mapping.ItemName] = mapping
+	}
+	var brands []string
+	if err := DB.Model(&PreferredBrand{}).Where("user_id = ?", userID).Pluck("brand", &brands).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve preferred brands"})
+		return
+	}
+
+	var items []ShoppingListItem
+	for _, item := range list.Items {
+		if !item.Checked {
+			items = append(items, item)
+		}
+	}
+
+	cart, err := BuildGroceryCart(c.Request.Context(), provider, items, mappings, brands)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to build cart"})
+		return
+	}
+
+	remembered := cartSKUMappings(userID, provider.Name(), cart.Lines)
+	if len(remembered) > 0 {
+		err := DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "provider"}, {Name: "item_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"sku", "product_name", "brand", "package_amount", "package_unit", "price_cents", "updated_at"}),
+		}).Create(&remembered).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save SKU mappings"})
+			return
+		}
+	}
+
+	c.Header("X-Unmatched-Items", strconv.Itoa(len(cart.Unmatched)))
+	c.Data(http.StatusOK, cart.ContentType, cart.Body)
+}
+
+// cartSKUMappings returns the SKU mappings to remember from the lines of a
+// cart. Lines whose items normalize to the same name, such as "Tomatoes"
+// and "tomato", are remembered once, as the later line, since one upsert
+// cannot update the same mapping twice.
+func cartSKUMappings(userID uint, provider string, lines []CartLine) []SKUMapping {
+	type mappingKey struct{ provider, itemName string }
+	index := map[mappingKey]int{}
+	var mappings []SKUMapping
+	for _, line := range lines {
+		if line.Product.SKU == "" {
+			continue
+		}
+		mapping := SKUMapping{
+			UserID:        userID,
+			Provider:      provider,
+			ItemName:      NormalizeIngredientName(line.Item.Name),
+			SKU:           line.Product.SKU,
+			ProductName:   line.Product.Name,
+			Brand:         line.Product.Brand,
+			PackageAmount: line.Product.PackageAmount,
+			PackageUnit:   line.Product.PackageUnit,
+			PriceCents:    line.Product.PriceCents,
+		}
+		key := mappingKey{mapping.Provider, mapping.ItemName}
+		if i, ok := index[key]; ok {
+			mappings[i] = mapping
+			continue
+		}
+		index[key] = len(mappings)
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
+
+// GetSKUMappings handles the GET /me/grocery/mappings endpoint.
+func GetSKUMappings(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	query := DB.Where("user_id = ?", userID)
+	if provider := c.Query("provider"); provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	var mappings []SKUMapping
+	if err := query.Order("provider, item_name").Find(&mappings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve SKU mappings"})
+		return
+	}
+	c.JSON(http.StatusOK, mappings)
+}
+
+// PutSKUMapping handles the PUT /me/grocery/mappings endpoint, pinning an
+// item to a specific retailer product.
+func PutSKUMapping(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Provider      string  `json:"provider" binding:"required"`
+		ItemName      string  `json:"item_name" binding:"required"`
+		SKU           string  `json:"sku" binding:"required"`
+		ProductName   string  `json:"product_name"`
+		Brand         string  `json:"brand"`
+		PackageAmount float64 `json:"package_amount" binding:"min=0"`
+		PackageUnit   string  `json:"package_unit"`
+		PriceCents    int     `json:"price_cents" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	mapping := SKUMapping{
+		UserID:        userID,
+		Provider:      input.Provider,
+		ItemName:      NormalizeIngredientName(input.ItemName),
+		SKU:           input.SKU,
+		ProductName:   input.ProductName,
+		Brand:         input.Brand,
+		PackageAmount: input.PackageAmount,
+		PackageUnit:   canonicalOrSelf(input.PackageUnit),
+		PriceCents:    input.PriceCents,
+	}
+	err := DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "provider"}, {Name: "item_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"sku", "product_name", "brand", "package_amount", "package_unit", "price_cents", "updated_at"}),
+	}).Create(&mapping).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save SKU mapping"})
+		return
+	}
+	c.JSON(http.StatusOK, mapping)
+}
+
+// DeleteSKUMapping handles the DELETE /me/grocery/mappings/:id endpoint.
+func DeleteSKUMapping(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	result := DB.Where("user_id = ?", userID).Delete(&SKUMapping{}, c.Param("id"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete SKU mapping"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SKU mapping not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "SKU mapping deleted"})
+}
+
+// GetPreferredBrands handles the GET /me/grocery/brands endpoint.
+func GetPreferredBrands(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	brands := []string{}
+	if err := DB.Model(&PreferredBrand{}).Where("user_id = ?", userID).Order("brand").Pluck("brand", &brands).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve preferred brands"})
+		return
+	}
+	c.JSON(http.StatusOK, brands)
+}
+
+// PutPreferredBrands handles the PUT /me/grocery/brands endpoint, replacing
+// the user's preferred brand list.
+func PutPreferredBrands(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Brands []string `json:"brands"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	brands := make([]PreferredBrand, 0, len(input.Brands))
+	for _, brand := range input.Brands {
+		if brand = strings.TrimSpace(brand); brand != "" {
+			brands = append(brands, PreferredBrand{UserID: userID, Brand: brand})
+		}
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&PreferredBrand{}).Error; err != nil {
+			return err
+		}
+		if len(brands) == 0 {
+			return nil
+		}
+		return tx.Create(&brands).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save preferred brands"})
+		return
+	}
+	c.JSON(http.StatusOK, input.Brands)
+}
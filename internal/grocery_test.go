@@ -0,0 +1,100 @@
+// grocery_test.go
+package internal
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testCatalog is the fake retailer's local product catalog.
+var testCatalog = []RetailProduct{
+	{SKU: "MILK-1L", Name: "Whole Milk", Brand: "Dairyco", PackageAmount: 1, PackageUnit: "l", PriceCents: 129},
+	{SKU: "MILK-2L", Name: "Whole Milk", Brand: "Dairyco", PackageAmount: 2, PackageUnit: "l", PriceCents: 219},
+	{SKU: "FLOUR-1KG", Name: "Flour", Brand: "Millers", PackageAmount: 1, PackageUnit: "kg", PriceCents: 199},
+	{SKU: "FLOUR-500G", Name: "Flour", Brand: "Organic Co", PackageAmount: 500, PackageUnit: "g", PriceCents: 249},
+	{SKU: "EGG-12", Name: "Eggs", Brand: "Farm", PackageAmount: 12, PriceCents: 399},
+}
+
+// TestPackagesNeeded verifies rounding up to whole packages across units.
+func TestPackagesNeeded(t *testing.T) {
+	packages, ok := PackagesNeeded(ShoppingListItem{Name: "milk", Amount: 6, Unit: "cup"}, testCatalog[0])
+	assert.True(t, ok)
+	assert.Equal(t, 2, packages)
+
+	packages, ok = PackagesNeeded(ShoppingListItem{Name: "eggs", Amount: 3}, testCatalog[4])
+	assert.True(t, ok)
+	assert.Equal(t, 1, packages)
+
+	_, ok = PackagesNeeded(ShoppingListItem{Name: "flour", Amount: 2, Unit: "cup"}, testCatalog[2])
+	assert.False(t, ok, "volume cannot be matched against weight")
+}
+
+// TestMatchProduct verifies least-waste selection and brand preference.
+func TestMatchProduct(t *testing.T) {
+	item := ShoppingListItem{Name: "flour", Amount: 400, Unit: "g"}
+
+	line, ok := MatchProduct(item, testCatalog[2:4], nil)
+	assert.True(t, ok)
+	assert.Equal(t, "FLOUR-500G", line.Product.SKU)
+
+	line, ok = MatchProduct(item, testCatalog[2:4], []string{"millers"})
+	assert.True(t, ok)
+	assert.Equal(t, "FLOUR-1KG", line.Product.SKU)
+}
+
+// TestBuildGroceryCart verifies remembered mappings win and unmatched items are reported.
+func TestBuildGroceryCart(t *testing.T) {
+	provider := &FakeProvider{Products: testCatalog}
+	items := []ShoppingListItem{
+		{Name: "milk", Amount: 1.5, Unit: "l"},
+		{Name: "eggs", Amount: 18},
+		{Name: "saffron", Amount: 1, Unit: "g"},
+	}
+	mappings := map[string]SKUMapping{
+		"milk": {ItemName: "milk", SKU: "MILK-1L", ProductName: "Whole Milk", PackageAmount: 1, PackageUnit: "l"},
+	}
+
+	cart, err := BuildGroceryCart(context.Background(), provider, items, mappings, nil)
+	assert.NoError(t, err)
+	assert.Len(t, cart.Lines, 2)
+	assert.Equal(t, "MILK-1L", cart.Lines[0].Product.SKU)
+	assert.Equal(t, 2, cart.Lines[0].Packages)
+	assert.Equal(t, "EGG-12", cart.Lines[1].Product.SKU)
+	assert.Equal(t, 2, cart.Lines[1].Packages)
+	assert.Len(t, cart.Unmatched, 1)
+	assert.Equal(t, "application/json", cart.ContentType)
+}
+
+// TestGenericProviderCSV verifies the retailer-neutral CSV cart.
+func TestGenericProviderCSV(t *testing.T) {
+	provider := GenericProvider{Format: "csv"}
+	items := []ShoppingListItem{{Name: "flour", Amount: 2, Unit: "cup", Category: "dry goods"}}
+
+	cart, err := BuildGroceryCart(context.Background(), provider, items, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv", cart.ContentType)
+	lines := strings.Split(strings.TrimSpace(string(cart.Body)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "flour,dry goods,2,cup,,flour,,1", lines[1])
+}
+
+// TestCartSKUMappings verifies lines for the same normalized item are
+// remembered once, and lines without a product not at all.
+func TestCartSKUMappings(t *testing.T) {
+	lines := []CartLine{
+		{Item: ShoppingListItem{Name: "Tomatoes"}, Product: RetailProduct{SKU: "TOM-1"}},
+		{Item: ShoppingListItem{Name: "milk"}, Product: RetailProduct{SKU: "MILK-1L"}},
+		{Item: ShoppingListItem{Name: "tomato"}, Product: RetailProduct{SKU: "TOM-2"}},
+		{Item: ShoppingListItem{Name: "saffron"}},
+	}
+
+	mappings := cartSKUMappings(3, "generic", lines)
+	assert.Len(t, mappings, 2)
+	assert.Equal(t, "tomato", mappings[0].ItemName)
+	assert.Equal(t, "TOM-2", mappings[0].SKU)
+	assert.Equal(t, "milk", mappings[1].ItemName)
+	assert.Equal(t, uint(3), mappings[1].UserID)
+}
@@ -0,0 +1,498 @@
+// household.go
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Household member roles, from most to least privileged.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// defaultInviteTTL is how long an invite link stays valid when no expiry is given.
+const defaultInviteTTL = 7 * 24 * time.Hour
+
+// householdTables lists the tables whose rows can be shared with a household.
+var householdTables = []string{"pantry_items", "shopping_lists", "meal_plan_entries", "collections"}
+
+// randomToken returns a URL-safe random hex token of n random bytes.
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HouseholdMiddleware loads the authenticated user's household membership,
+// if any, into the context. It must run after JWTMiddleware.
+func HouseholdMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var member HouseholdMember
+		err := DB.Where("user_id = ?", userID).First(&member).Error
+		if err == nil {
+			c.Set("householdMember", member)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve household"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// currentHousehold returns the membership loaded by HouseholdMiddleware.
+func currentHousehold(c *gin.Context) (HouseholdMember, bool) {
+	value, exists := c.Get("householdMember")
+	if !exists {
+		return HouseholdMember{}, false
+	}
+	member, ok := value.(HouseholdMember)
+	return member, ok
+}
+
+// currentHouseholdID returns the user's household ID, or nil outside a household.
+func currentHouseholdID(c *gin.Context) *uint {
+	member, ok := currentHousehold(c)
+	if !ok {
+		return nil
+	}
+	id := member.HouseholdID
+	return &id
+}
+
+// ownedBy restricts a query to rows owned by the user or shared with their household.
+func ownedBy(userID uint, householdID *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if householdID == nil {
+			return db.Where("user_id = ?", userID)
+		}
+		return db.Where("user_id = ? OR household_id = ?", userID, *householdID)
+	}
+}
+
+// ownerScope restricts a query to rows visible to the authenticated user.
+func ownerScope(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	userID, _ := currentUserID(c)
+	return ownedBy(userID, currentHouseholdID(c))
+}
+
+// requireHouseholdRole checks that the user belongs to a household with one
+// of the given roles, writing an error response if not.
+func requireHouseholdRole(c *gin.Context, roles ...string) (HouseholdMember, bool) {
+	member, ok := currentHousehold(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not in a household"})
+		return member, false
+	}
+	for _, role := range roles {
+		if member.Role == role {
+			return member, true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient household role"})
+	return member, false
+}
+
+// GetHousehold handles the GET /me/household endpoint.
+func GetHousehold(c *gin.Context) {
+	member, ok := currentHousehold(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not in a household"})
+		return
+	}
+
+	var household Household
+	if err := DB.Preload("Members.User").First(&household, member.HouseholdID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve household"})
+		return
+	}
+	c.JSON(http.StatusOK, household)
+}
+
+// CreateHousehold handles the POST /me/household endpoint.
+func CreateHousehold(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if _, inHousehold := currentHousehold(c); inHousehold {
+		c.JSON(http.StatusConflict, gin.H{"error": "You are already in a household"})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	household := Household{
+		Name:    input.Name,
+		OwnerID: userID,
+		Members: []HouseholdMember{{UserID: userID, Role: RoleOwner}},
+	}
+	if err := DB.Create(&household).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create household"})
+		return
+	}
+	c.JSON(http.StatusCreated, household)
+}
+
+// GetHouseholdInvites handles the GET /me/household/invites endpoint.
+func GetHouseholdInvites(c *gin.Context) {
+	member, ok := requireHouseholdRole(c, RoleOwner, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var invites []HouseholdInvite
+	if err := DB.Where("household_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", member.HouseholdID, time.Now()).
+		Order("created_at DESC").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invites"})
+		return
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+// CreateHouseholdInvite handles the POST /me/household/invites endpoint.
+// The response includes the join path to share as an invite link.
+func CreateHouseholdInvite(c *gin.Context) {
+	member, ok := requireHouseholdRole(c, RoleOwner, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role           string `json:"role" binding:"omitempty,oneof=admin member"`
+		ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=720"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate invite token"})
+		return
+	}
+	role := input.Role
+	if role == "" {
+		role = RoleMember
+	}
+	ttl := defaultInviteTTL
+	if input.ExpiresInHours > 0 {
+		ttl = time.Duration(input.ExpiresInHours) * time.Hour
+	}
+
+	invite := HouseholdInvite{
+		HouseholdID: member.HouseholdID,
+		Token:       token,
+		Role:        role,
+		CreatedByID: member.UserID,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := DB.Create(&invite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"invite": invite, "join_path": "/me/household/join/" + token})
+}
+
+// RevokeHouseholdInvite handles the DELETE /me/household/invites/:id endpoint.
+func RevokeHouseholdInvite(c *gin.Context) {
+	member, ok := requireHouseholdRole(c, RoleOwner, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var invite HouseholdInvite
+	if err := DB.Where("household_id = ?", member.HouseholdID).First(&invite, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	if err := DB.Model(&invite).Update("revoked_at", time.Now()).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Invite revoked"})
+}
+
+// JoinHousehold handles the POST /me/household/join/:token endpoint.
+func JoinHousehold(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if _, inHousehold := currentHousehold(c); inHousehold {
+		c.JSON(http.StatusConflict, gin.H{"error": "Leave your current household before joining another"})
+		return
+	}
+
+	var invite HouseholdInvite
+	if err := DB.Where("token = ?", c.Param("token")).First(&invite).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+	if invite.AcceptedAt != nil || invite.RevokedAt != nil || time.Now().After(invite.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invite is no longer valid"})
+		return
+	}
+
+	member := HouseholdMember{HouseholdID: invite.HouseholdID, UserID: userID, Role: invite.Role}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		// Guard against two users accepting the same invite concurrently.
+		result := tx.Model(&HouseholdInvite{}).
+			Where("id = ? AND accepted_at IS NULL", invite.ID).
+			Updates(map[string]interface{}{"accepted_by_id": userID, "accepted_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Create(&member).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusGone, gin.H{"error": "Invite is no longer valid"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join household"})
+		return
+	}
+	c.JSON(http.StatusCreated, member)
+}
+
+// UpdateHouseholdMember handles the PUT /me/household/members/:userId endpoint.
+// Only the owner may change roles; assigning "owner" transfers ownership.
+func UpdateHouseholdMember(c *gin.Context) {
+	owner, ok := requireHouseholdRole(c, RoleOwner)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Role string `json:"role" binding:"required,oneof=owner admin member"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var member HouseholdMember
+	if err := DB.Where("household_id = ? AND user_id = ?", owner.HouseholdID, c.Param("userId")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if member.UserID == owner.UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transfer ownership to another member instead"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if input.Role == RoleOwner {
+			if err := tx.Model(&owner).Update("role", RoleAdmin).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&Household{}).Where("id = ?", owner.HouseholdID).Update("owner_id", member.UserID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&member).Update("role", input.Role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveHouseholdMember handles the DELETE /me/household/members/:userId endpoint.
+// Shared data created by the removed member stays with the household.
+func RemoveHouseholdMember(c *gin.Context) {
+	admin, ok := requireHouseholdRole(c, RoleOwner, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	var member HouseholdMember
+	if err := DB.Where("household_id = ? AND user_id = ?", admin.HouseholdID, c.Param("userId")).First(&member).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if member.Role == RoleOwner || (member.Role == RoleAdmin && admin.Role != RoleOwner) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient household role"})
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error { return leaveHousehold(tx, member, false) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Member removed"})
+}
+
+// LeaveHousehold handles the POST /me/household/leave endpoint.
+// With mode "transfer" (the default) the user's shared data stays with the
+// household; with mode "fork" the user also keeps private copies of
+// everything the household shares.
+func LeaveHousehold(c *gin.Context) {
+	member, ok := currentHousehold(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "You are not in a household"})
+		return
+	}
+
+	var input struct {
+		Mode string `json:"mode" binding:"omitempty,oneof=transfer fork"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error { return leaveHousehold(tx, member, input.Mode == "fork") }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave household"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Left household"})
+}
+
+// leaveHousehold removes a member from their household. If the member is the
+// last one the household is dissolved and its data becomes theirs. Otherwise
+// ownership passes to the longest-standing admin (or member) when the owner
+// leaves, and rows the member created are handed to the owner so the
+// household keeps them. When fork is set, the leaving member first receives
+// private copies of all shared data.
+func leaveHousehold(tx *gorm.DB, member HouseholdMember, fork bool) error {
+	var others []HouseholdMember
+	if err := tx.Where("household_id = ? AND user_id <> ?", member.HouseholdID, member.UserID).
+		Order("created_at").Find(&others).Error; err != nil {
+		return err
+	}
+
+	if len(others) == 0 {
+		for _, table := range householdTables {
+			if err := tx.Table(table).Where("household_id = ?", member.HouseholdID).
+				Updates(map[string]interface{}{"household_id": nil, "user_id": member.UserID}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("household_id = ?", member.HouseholdID).Delete(&HouseholdInvite{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&Household{}, member.HouseholdID).Error
+	}
+
+	var household Household
+	if err := tx.First(&household, member.HouseholdID).Error; err != nil {
+		return err
+	}
+	if household.OwnerID == member.UserID {
+		heir := others[0]
+		for _, other := range others {
+			if other.Role == RoleAdmin {
+				heir = other
+				break
+			}
+		}
+		if err := tx.Model(&heir).Update("role", RoleOwner).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&household).Update("owner_id", heir.UserID).Error; err != nil {
+			return err
+		}
+	}
+
+	if fork {
+		if err := forkHouseholdData(tx, member.HouseholdID, member.UserID); err != nil {
+			return err
+		}
+	}
+	for _, table := range householdTables {
+		if err := tx.Table(table).Where("household_id = ? AND user_id = ?", member.HouseholdID, member.UserID).
+			Update("user_id", household.OwnerID).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&member).Error
+}
+
+// forkHouseholdData gives the user private copies of every row shared with the household.
+func forkHouseholdData(tx *gorm.DB, householdID, userID uint) error {
+	var pantry []PantryItem
+	if err := tx.Where("household_id = ?", householdID).Find(&pantry).Error; err != nil {
+		return err
+	}
+	for _, item := range pantry {
+		item.ID, item.UserID, item.HouseholdID = 0, userID, nil
+		if err := tx.Create(&item).Error; err != nil {
+			return err
+		}
+	}
+
+	var lists []ShoppingList
+	if err := tx.Preload("Items").Where("household_id = ?", householdID).Find(&lists).Error; err != nil {
+		return err
+	}
+	for _, list := range lists {
+		list.ID, list.UserID, list.HouseholdID = 0, userID, nil
+		for i := range list.Items {
+			list.Items[i].ID, list.Items[i].ShoppingListID = 0, 0
+		}
+		if err := tx.Create(&list).Error; err != nil {
+			return err
+		}
+	}
+
+	var entries []MealPlanEntry
+	if err := tx.Where("household_id = ?", householdID).Find(&entries).Error; err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entry.ID, entry.UserID, entry.HouseholdID = 0, userID, nil
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	var collections []Collection
+	if err := tx.Preload("Recipes").Where("household_id = ?", householdID).Find(&collections).Error; err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		collection.ID, collection.UserID, collection.HouseholdID = 0, userID, nil
+		if err := tx.Omit("Recipes.*").Create(&collection).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// household_test.go
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRequireHouseholdRole verifies role checks against the membership in context.
+func TestRequireHouseholdRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	_, ok := requireHouseholdRole(c, RoleOwner)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Set("householdMember", HouseholdMember{HouseholdID: 7, UserID: 3, Role: RoleMember})
+	_, ok = requireHouseholdRole(c, RoleOwner, RoleAdmin)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Set("householdMember", HouseholdMember{HouseholdID: 7, UserID: 3, Role: RoleAdmin})
+	member, ok := requireHouseholdRole(c, RoleOwner, RoleAdmin)
+	assert.True(t, ok)
+	assert.Equal(t, uint(7), member.HouseholdID)
+	assert.Equal(t, uint(7), *currentHouseholdID(c))
+}
+
+// TestRandomToken verifies invite tokens are unique hex strings.
+func TestRandomToken(t *testing.T) {
+	a, err := randomToken(24)
+	assert.NoError(t, err)
+	b, err := randomToken(24)
+	assert.NoError(t, err)
+	assert.Len(t, a, 48)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,619 @@
+// images.go
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limits for uploaded images. Pixels are bounded as well as bytes, since a
+// small file can decode to a huge image.
+const (
+	maxImageBytes  = 10 << 20
+	maxImagePixels = 40_000_000
+	maxStepPhotos  = 5
+)
+
+// imageURLTTL is how long signed links to images of non-public recipes work.
+const imageURLTTL = time.Hour
+
+// originalImageSize names the blob of the full-size image.
+const originalImageSize = "original"
+
+// thumbnailSizes are the thumbnails made of every image, by the length of
+// their longest side in pixels. Images are never enlarged.
+var thumbnailSizes = []struct {
+	Name   string
+	Pixels int
+}{
+	{"small", 160},
+	{"medium", 480},
+	{"large", 1280},
+}
+
+// errUnsupportedImage is returned for uploads that are not JPEG, PNG or GIF.
+var errUnsupportedImage = errors.New("unsupported image type, expected JPEG, PNG or GIF")
+
+// errImageTooLarge is returned for images with too many pixels.
+var errImageTooLarge = fmt.Errorf("image is larger than %d megapixels", maxImagePixels/1_000_000)
+
+// processedImage is an upload ready for storage, re-encoded without its
+// metadata. JPEGs stay JPEG; PNGs and GIFs become PNG.
+type processedImage struct {
+	Format     string
+	Width      int
+	Height     int
+	Original   []byte
+	Thumbnails map[string][]byte
+}
+
+// ProcessImage checks an upload by its content, turns it upright by its
+// EXIF orientation and re-encodes it and its thumbnails. Re-encoding drops
+// EXIF and other metadata such as the location a photo was taken at.
+func ProcessImage(data []byte) (processedImage, error) {
+	var result processedImage
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		result.Format = "jpeg"
+	case "image/png", "image/gif":
+		result.Format = "png"
+	default:
+		return result, errUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return result, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxImagePixels {
+		return result, errImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return result, err
+	}
+	if result.Format == "jpeg" {
+		img = orientImage(img, jpegOrientation(data))
+	}
+
+	result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	if result.Original, err = encodeImage(img, result.Format); err != nil {
+		return result, err
+	}
+	result.Thumbnails = make(map[string][]byte, len(thumbnailSizes))
+	for _, size := range thumbnailSizes {
+		if result.Thumbnails[size.Name], err = encodeImage(resizeImage(img, size.Pixels), result.Format); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// encodeImage encodes an image as JPEG or PNG.
+func encodeImage(img image.Image, format string) ([]byte, error) {
+	var out bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&out, img, &jpeg.Options{Quality: 88})
+	} else {
+		err = png.Encode(&out, img)
+	}
+	return out.Bytes(), err
+}
+
+// toRGBA returns an image as RGBA with its origin at 0,0.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// resizeImage scales an image down to fit maxSide by averaging the pixels
+// each new pixel covers. Smaller images are returned unchanged.
+func resizeImage(img image.Image, maxSide int) image.Image {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	dw, dh := maxSide, maxSide
+	if w >= h {
+		dh = max(1, int(math.Round(float64(h)*float64(maxSide)/float64(w))))
+	} else {
+		dw = max(1, int(math.Round(float64(w)*float64(maxSide)/float64(h))))
+	}
+
+	// RGBA is premultiplied, so plain averages blend transparent pixels
+	// correctly.
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := y * h / dh
+		y1 := max((y+1)*h/dh, y0+1)
+		for x := 0; x < dw; x++ {
+			x0 := x * w / dw
+			x1 := max((x+1)*w/dw, x0+1)
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			i := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[i+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// orientImage turns an image upright by its EXIF orientation: 1 is upright,
+// 2 to 4 are mirrored or upside down and 5 to 8 are turned on their side.
+func orientImage(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], src.Pix[src.PixOffset(sx, sy):src.PixOffset(sx, sy)+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, or 1 when it has
+// none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			i++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD7:
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// Metadata comes before the image data.
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+		if marker == 0xE1 {
+			if orientation := exifOrientation(data[i+4 : end]); orientation != 0 {
+				return orientation
+			}
+		}
+		i = end
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag of an EXIF APP1 segment,
+// returning 0 when there is none.
+func exifOrientation(segment []byte) int {
+	tiff, ok := bytes.CutPrefix(segment, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(tiff[2:]) != 42 {
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	for entry, count := ifd+2, int(order.Uint16(tiff[ifd:])); count > 0 && entry+12 <= len(tiff); entry, count = entry+12, count-1 {
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// key returns the blob key of the image in a size.
+func (img *RecipeImage) key(size string) string {
+	return img.Prefix + "/" + size + "." + img.Extension
+}
+
+// keys returns the blob keys of the image in every size.
+func (img *RecipeImage) keys() []string {
+	keys := []string{img.key(originalImageSize)}
+	for _, size := range img.Sizes {
+		keys = append(keys, img.key(size))
+	}
+	return keys
+}
+
+// withURLs fills in links to the image and its thumbnails, signed so that
+// they expire when signed is set.
+func (img *RecipeImage) withURLs(signed bool) error {
+	ttl := time.Duration(0)
+	if signed {
+		ttl = imageURLTTL
+	}
+	var err error
+	if img.URL, err = Blobs.URL(img.key(originalImageSize), ttl); err != nil {
+		return err
+	}
+	img.Thumbnails = make(map[string]string, len(img.Sizes))
+	for _, size := range img.Sizes {
+		if img.Thumbnails[size], err = Blobs.URL(img.key(size), ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipeIsPublic reports whether anyone may read a recipe, in which case
+// links to its images need no signature.
+func recipeIsPublic(recipe Recipe) bool {
+	return recipe.Visibility == VisibilityPublic && recipe.Status == RecipeStatusPublished && recipe.HiddenAt == nil
+}
+
+// attachRecipeImages fills in the links of a recipe's loaded images.
+func attachRecipeImages(recipe *Recipe) error {
+	for i := range recipe.Images {
+		if err := recipe.Images[i].withURLs(!recipeIsPublic(*recipe)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipePhotos selects the images of a recipe itself, hero image first,
+// leaving out the photos of its reviews.
+func recipePhotos(db *gorm.DB) *gorm.DB {
+	return db.Where("review_id IS NULL").Order("step_position NULLS FIRST, id")
+}
+
+// deleteImageBlobs removes the blobs of an image, logging failures: the
+// image is gone either way and leftover blobs are only wasted space.
+func deleteImageBlobs(ctx context.Context, img RecipeImage) {
+	for _, key := range img.keys() {
+		if err := Blobs.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
+
+// storeRecipeImage stores a processed upload and records it as img, whose
+// recipe, user and step or review are already set.
+func storeRecipeImage(ctx context.Context, img RecipeImage, processed processedImage) (RecipeImage, error) {
+	token, err := randomToken(12)
+	if err != nil {
+		return img, err
+	}
+	img.Prefix = fmt.Sprintf("recipes/%d/%s", img.RecipeID, token)
+	img.Extension = "png"
+	img.ContentType = "image/" + processed.Format
+	img.Width, img.Height = processed.Width, processed.Height
+	img.Size = len(processed.Original)
+	if processed.Format == "jpeg" {
+		img.Extension = "jpg"
+	}
+
+	stored := RecipeImage{Prefix: img.Prefix, Extension: img.Extension}
+	if err := Blobs.Put(ctx, img.key(originalImageSize), processed.Original, img.ContentType); err != nil {
+		return img, err
+	}
+	for _, size := range thumbnailSizes {
+		if err := Blobs.Put(ctx, img.key(size.Name), processed.Thumbnails[size.Name], img.ContentType); err != nil {
+			deleteImageBlobs(ctx, stored)
+			return img, err
+		}
+		stored.Sizes = append(stored.Sizes, size.Name)
+	}
+	img.Sizes = stored.Sizes
+
+	if err := DB.Create(&img).Error; err != nil {
+		deleteImageBlobs(ctx, stored)
+		return img, err
+	}
+	return img, nil
+}
+
+// readImageUpload reads and processes an uploaded image, responding with
+// an error and returning false when it is unusable.
+func readImageUpload(c *gin.Context) (processedImage, bool) {
+	// Leave room for the multipart framing around the file.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageBytes+64<<10)
+	upload, err := importDocument(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+		return processedImage{}, false
+	}
+	defer upload.Close()
+	data, err := io.ReadAll(io.LimitReader(upload, maxImageBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded image"})
+		return processedImage{}, false
+	}
+	if len(data) > maxImageBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Images are limited to %d MB", maxImageBytes>>20)})
+		return processedImage{}, false
+	}
+
+	processed, err := ProcessImage(data)
+	switch {
+	case errors.Is(err, errUnsupportedImage):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+		return processed, false
+	case errors.Is(err, errImageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return processed, false
+	case err != nil:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to decode image"})
+		return processed, false
+	}
+	return processed, true
+}
+
+// UploadRecipeImage handles the PUT /recipes/:id/image endpoint, setting
+// the recipe's hero image from the "file" field of a multipart form or the
+// raw body. The previous hero image is deleted.
+func UploadRecipeImage(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+	processed, ok := readImageUpload(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := currentUserID(c)
+	img, err := storeRecipeImage(c.Request.Context(), RecipeImage{RecipeID: recipe.ID, UserID: userID}, processed)
+	if err != nil {
+		log.Printf("Failed to store image of recipe %d: %v", recipe.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+
+	var previous []RecipeImage
+	DB.Where("recipe_id = ? AND step_position IS NULL AND review_id IS NULL AND id <> ?", recipe.ID, img.ID).Find(&previous)
+	for _, old := range previous {
+		if err := DB.Delete(&old).Error; err == nil {
+			deleteImageBlobs(c.Request.Context(), old)
+		}
+	}
+
+	if err := img.withURLs(!recipeIsPublic(recipe)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	c.JSON(http.StatusCreated, img)
+}
+
+// UploadStepPhoto handles the POST /recipes/:id/steps/:position/photos
+// endpoint, adding a photo to the step at a position. Photos stay with the
+// position when the steps are edited.
+func UploadStepPhoto(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+	position, err := strconv.Atoi(c.Param("position"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Step not found"})
+		return
+	}
+	if err := DB.Where("recipe_id = ? AND position = ?", recipe.ID, position).First(&RecipeStep{}).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Step not found"})
+		return
+	}
+	var count int64
+	if err := DB.Model(&RecipeImage{}).Where("recipe_id = ? AND step_position = ?", recipe.ID, position).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if count >= maxStepPhotos {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Steps are limited to %d photos", maxStepPhotos)})
+		return
+	}
+	processed, ok := readImageUpload(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := currentUserID(c)
+	img, err := storeRecipeImage(c.Request.Context(), RecipeImage{RecipeID: recipe.ID, UserID: userID, StepPosition: &position}, processed)
+	if err != nil {
+		log.Printf("Failed to store step photo of recipe %d: %v", recipe.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if err := img.withURLs(!recipeIsPublic(recipe)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	c.JSON(http.StatusCreated, img)
+}
+
+// GetRecipeImages handles the GET /recipes/:id/images endpoint, listing the
+// hero image first and then the step photos. Links to images of recipes
+// that are not public are signed and expire.
+func GetRecipeImages(c *gin.Context) {
+	var recipe Recipe
+	query := DB.Preload("Images", recipePhotos)
+	if err := findVisibleRecipe(c, query, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if err := attachRecipeImages(&recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve images"})
+		return
+	}
+	c.JSON(http.StatusOK, recipe.Images)
+}
+
+// DeleteRecipeImage handles the DELETE /recipes/:id/images/:imageId endpoint.
+func DeleteRecipeImage(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+	var img RecipeImage
+	if err := DB.Where("recipe_id = ?", recipe.ID).First(&img, c.Param("imageId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err := DB.Delete(&img).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+	deleteImageBlobs(c.Request.Context(), img)
+	c.JSON(http.StatusOK, gin.H{"status": "Image deleted"})
+}
+
+// canViewImage reports whether the caller may see an image of a recipe,
+// and of a review when it is a review photo. A link signed by the local
+// store stands in for a share link, but stops working once moderators hide
+// the content or the recipe is made private or deleted.
+func canViewImage(c *gin.Context, recipe Recipe, review *RecipeReview, signed bool) bool {
+	userID, _ := currentUserID(c)
+	if review != nil && review.HiddenAt != nil && review.UserID != userID {
+		return false
+	}
+	if signed && recipe.UserID != userID {
+		return recipe.HiddenAt == nil && recipe.Status == RecipeStatusPublished && recipe.Visibility != VisibilityPrivate
+	}
+	return canViewRecipe(c, recipe)
+}
+
+// ServeBlob handles the GET /blobs/*key endpoint, serving stored images of
+// content the caller may see. A link signed by the local store is honored
+// until it expires, unless the content has been hidden or made private
+// since. Links presigned by S3 bypass this and expire after imageURLTTL.
+func ServeBlob(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if !validBlobKey(key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	var img RecipeImage
+	var recipe Recipe
+	if err := DB.Where("prefix = ?", path.Dir(key)).First(&img).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err := DB.First(&recipe, img.RecipeID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	var review *RecipeReview
+	if img.ReviewID != nil {
+		review = &RecipeReview{}
+		if err := DB.First(review, *img.ReviewID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+	}
+	local, isLocal := Blobs.(*LocalBlobStore)
+	signed := isLocal && local.VerifyURL(key, c.Query("expires"), c.Query("signature"))
+	if !canViewImage(c, recipe, review, signed) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+
+	cache := "public, max-age=86400"
+	switch {
+	case signed:
+		cache = "private, max-age=" + strconv.Itoa(int(imageURLTTL.Seconds()))
+	case !recipeIsPublic(recipe) || (review != nil && review.HiddenAt != nil):
+		cache = "private, max-age=300"
+	}
+
+	blob, err := Blobs.Get(c.Request.Context(), key)
+	if errors.Is(err, errBlobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read image"})
+		return
+	}
+	defer blob.Close()
+
+	contentType := "image/png"
+	if strings.HasSuffix(key, ".jpg") {
+		contentType = "image/jpeg"
+	}
+	c.DataFromReader(http.StatusOK, -1, contentType, blob, map[string]string{
+		"Cache-Control":          cache,
+		"X-Content-Type-Options": "nosniff",
+	})
+}
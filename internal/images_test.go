@@ -0,0 +1,143 @@
+// images_test.go
+package internal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exifSegment returns an APP1 segment with a big-endian EXIF orientation.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testJPEG returns a w by h JPEG, red on its left half, with an EXIF
+// orientation.
+func testJPEG(t *testing.T, w, h int, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < w/2 {
+				img.Set(x, y, color.RGBA{255, 0, 0, 255})
+			} else {
+				img.Set(x, y, color.RGBA{0, 0, 255, 255})
+			}
+		}
+	}
+	var out bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&out, img, nil))
+	data := out.Bytes()
+	return append(append(append([]byte{}, data[:2]...), exifSegment(orientation)...), data[2:]...)
+}
+
+func TestProcessImageJPEG(t *testing.T) {
+	data := testJPEG(t, 400, 200, 6)
+	assert.Equal(t, 6, jpegOrientation(data))
+
+	processed, err := ProcessImage(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", processed.Format)
+
+	// Orientation 6 is turned a quarter clockwise, so the red left half
+	// ends up on top, and the EXIF block is gone.
+	assert.Equal(t, 200, processed.Width)
+	assert.Equal(t, 400, processed.Height)
+	assert.NotContains(t, string(processed.Original), "Exif")
+	assert.Equal(t, 1, jpegOrientation(processed.Original))
+	img, err := jpeg.Decode(bytes.NewReader(processed.Original))
+	assert.NoError(t, err)
+	r, _, b, _ := img.At(100, 50).RGBA()
+	assert.Greater(t, r, b)
+
+	for name, want := range map[string][2]int{"small": {80, 160}, "medium": {200, 400}, "large": {200, 400}} {
+		config, err := jpeg.DecodeConfig(bytes.NewReader(processed.Thumbnails[name]))
+		assert.NoError(t, err)
+		assert.Equal(t, want, [2]int{config.Width, config.Height}, name)
+	}
+}
+
+func TestProcessImagePNG(t *testing.T) {
+	var out bytes.Buffer
+	assert.NoError(t, png.Encode(&out, image.NewNRGBA(image.Rect(0, 0, 30, 20))))
+	processed, err := ProcessImage(out.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "png", processed.Format)
+	assert.Equal(t, 30, processed.Width)
+	assert.Len(t, processed.Thumbnails, len(thumbnailSizes))
+
+	_, err = ProcessImage([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+	assert.ErrorIs(t, err, errUnsupportedImage)
+	_, err = ProcessImage([]byte("just some text"))
+	assert.ErrorIs(t, err, errUnsupportedImage)
+}
+
+func TestProcessImageTooLarge(t *testing.T) {
+	// A header claiming a huge image is refused before decoding.
+	var out bytes.Buffer
+	assert.NoError(t, png.Encode(&out, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := out.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 10000)
+	binary.BigEndian.PutUint32(data[20:], 10000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	_, err := ProcessImage(data)
+	assert.ErrorIs(t, err, errImageTooLarge)
+}
+
+func TestResizeImage(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x += 2 {
+		src.Set(x, 0, color.RGBA{200, 100, 0, 255})
+	}
+	resized := resizeImage(src, 2).(*image.RGBA)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), resized.Rect)
+	// Each new pixel averages one coloured and three transparent pixels.
+	assert.Equal(t, color.RGBA{50, 25, 0, 64}, resized.RGBAAt(0, 0))
+
+	assert.Same(t, src, resizeImage(src, 10))
+}
+
+// TestCanViewImage verifies signed links stop working once the content is
+// hidden or made private, while owners keep seeing their images.
+func TestCanViewImage(t *testing.T) {
+	hiddenAt := time.Now()
+	public := Recipe{UserID: 1, Visibility: VisibilityPublic, Status: RecipeStatusPublished}
+	unlisted := Recipe{UserID: 1, Visibility: VisibilityUnlisted, Status: RecipeStatusPublished}
+	private := Recipe{UserID: 1, Visibility: VisibilityPrivate, Status: RecipeStatusPublished}
+	hidden := Recipe{UserID: 1, Visibility: VisibilityPublic, Status: RecipeStatusPublished, HiddenAt: &hiddenAt}
+	review := &RecipeReview{UserID: 2}
+	hiddenReview := &RecipeReview{UserID: 2, HiddenAt: &hiddenAt}
+
+	anonymous := visibilityContext("/blobs/recipes/1/a/original.jpg", 0)
+	assert.True(t, canViewImage(anonymous, public, nil, false))
+	assert.False(t, canViewImage(anonymous, unlisted, nil, false))
+	assert.True(t, canViewImage(anonymous, unlisted, nil, true))
+	assert.False(t, canViewImage(anonymous, private, nil, true))
+	assert.False(t, canViewImage(anonymous, hidden, nil, true))
+	assert.True(t, canViewImage(anonymous, public, review, true))
+	assert.False(t, canViewImage(anonymous, public, hiddenReview, true))
+
+	owner := visibilityContext("/blobs/recipes/1/a/original.jpg", 1)
+	assert.True(t, canViewImage(owner, private, nil, true))
+	assert.True(t, canViewImage(owner, hidden, nil, false))
+	assert.False(t, canViewImage(owner, public, hiddenReview, false))
+
+	reviewer := visibilityContext("/blobs/recipes/1/a/original.jpg", 2)
+	assert.True(t, canViewImage(reviewer, public, hiddenReview, false))
+}
@@ -0,0 +1,1010 @@
+// library.go
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// A library archive is a zip file holding a user's recipes, collections and
+// preferences as JSON:
+//
+//	manifest.json         LibraryManifest, naming the format and its version
+//	preferences.json      LibraryPreferences
+//	collections.json      a list of LibraryCollection
+//	recipes/000001.json   one LibraryRecipe per file
+//	images/000001-1.jpg   the uploaded photos of a recipe, at full size
+//
+// Recipes and collections carry a stable external_id. Importing an archive
+// matches on it and updates the records it finds instead of duplicating
+// them, so repeating an import is harmless. Uploaded hero and step photos
+// are restored for recipes that have none; photos of reviews are not part
+// of a library. Other images are referenced by URL.
+const (
+	libraryFormatName    = "recipe-book-library"
+	libraryFormatVersion = 1
+)
+
+// Size limits for uploaded libraries. Entries may hold embedded photos.
+const (
+	maxLibraryBytes      = 100 << 20
+	maxLibraryEntryBytes = 20 << 20
+	maxLibraryItems      = 10000
+	maxLibraryImages     = 20000
+	maxImportJobErrors   = 50
+)
+
+// Library upload formats.
+const (
+	ImportFormatLibrary    = "library"
+	ImportFormatPaprika    = "paprika"
+	ImportFormatMealMaster = "mealmaster"
+	ImportFormatCooklang   = "cooklang"
+)
+
+// Statuses of background jobs: library imports and cookbooks.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// errUnknownLibraryFormat is returned for uploads in none of the supported formats.
+var errUnknownLibraryFormat = errors.New("unrecognized format; expected a library export, a Paprika archive, MealMaster text or Cooklang files")
+
+// errEmptyLibrary is returned for uploads without any recipe.
+var errEmptyLibrary = errors.New("no recipes found in the upload")
+
+// errRecipeRemoved is returned for library recipes a moderator removed,
+// which importing does not bring back.
+var errRecipeRemoved = errors.New("recipe was removed by a moderator")
+
+// LibraryManifest describes a library archive.
+type LibraryManifest struct {
+	Format      string    `json:"format"`
+	Version     int       `json:"version"`
+	ExportedAt  time.Time `json:"exported_at"`
+	Username    string    `json:"username"`
+	Recipes     int       `json:"recipes"`
+	Collections int       `json:"collections"`
+}
+
+// LibraryRecipe is a recipe in a library archive: its editable state as
+// recorded in revisions, plus the settings that are not. Tags are names.
+type LibraryRecipe struct {
+	ExternalID  string         `json:"external_id"`
+	Visibility  string         `json:"visibility"`
+	Status      string         `json:"status"`
+	SourceURL   string         `json:"source_url,omitempty"`
+	ImageURLs   []string       `json:"image_urls,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	PublishedAt *time.Time     `json:"published_at,omitempty"`
+	Images      []LibraryImage `json:"images,omitempty"`
+	RecipeSnapshot
+}
+
+// LibraryImage is an uploaded photo of a library recipe: the hero image, or
+// a photo of the step at StepPosition. File names its entry in the archive.
+type LibraryImage struct {
+	File         string `json:"file"`
+	StepPosition *int   `json:"step_position,omitempty"`
+}
+
+// LibraryCollection is a collection in a library archive. Recipes lists the
+// external IDs of its recipes; recipes by other users are left out.
+type LibraryCollection struct {
+	ExternalID  string   `json:"external_id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Recipes     []string `json:"recipes"`
+}
+
+// LibraryPreferences are the user preferences in a library archive.
+type LibraryPreferences struct {
+	Preference        string         `json:"preference"`
+	DefaultVisibility string         `json:"default_visibility"`
+	DailyGoals        NutritionFacts `json:"daily_goals"`
+}
+
+// Library is the content of a library archive.
+type Library struct {
+	Manifest    LibraryManifest
+	Preferences *LibraryPreferences
+	Collections []LibraryCollection
+	Recipes     []LibraryRecipe
+	Images      map[string][]byte
+}
+
+// items returns the number of records importing the library touches.
+func (l Library) items() int {
+	items := len(l.Recipes) + len(l.Collections)
+	if l.Preferences != nil {
+		items++
+	}
+	return items
+}
+
+// WriteLibrary writes a library archive.
+func WriteLibrary(w io.Writer, library Library) error {
+	library.Manifest.Format, library.Manifest.Version = libraryFormatName, libraryFormatVersion
+	library.Manifest.Recipes, library.Manifest.Collections = len(library.Recipes), len(library.Collections)
+	if library.Collections == nil {
+		library.Collections = []LibraryCollection{}
+	}
+
+	type entry struct {
+		name  string
+		value interface{}
+	}
+	entries := []entry{{"manifest.json", library.Manifest}}
+	if library.Preferences != nil {
+		entries = append(entries, entry{"preferences.json", library.Preferences})
+	}
+	entries = append(entries, entry{"collections.json", library.Collections})
+	for i, recipe := range library.Recipes {
+		entries = append(entries, entry{fmt.Sprintf("recipes/%06d.json", i+1), recipe})
+	}
+
+	archive := zip.NewWriter(w)
+	for _, entry := range entries {
+		file, err := archive.Create(entry.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entry.value); err != nil {
+			return err
+		}
+	}
+
+	// Photos are compressed already, so they are stored as they are.
+	names := make([]string, 0, len(library.Images))
+	for name := range library.Images {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(library.Images[name]); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// ReadLibrary reads a library archive written by WriteLibrary.
+func ReadLibrary(r io.ReaderAt, size int64) (Library, error) {
+	var library Library
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return library, err
+	}
+	if len(archive.File) > maxLibraryItems+maxLibraryImages+3 {
+		return library, fmt.Errorf("archive holds more than %d files", maxLibraryItems+maxLibraryImages+3)
+	}
+
+	found := false
+	for _, file := range archive.File {
+		switch {
+		case file.Name == "manifest.json":
+			found = true
+			err = readZipJSON(file, &library.Manifest)
+		case file.Name == "preferences.json":
+			library.Preferences = &LibraryPreferences{}
+			err = readZipJSON(file, library.Preferences)
+		case file.Name == "collections.json":
+			err = readZipJSON(file, &library.Collections)
+		case strings.HasPrefix(file.Name, "recipes/") && strings.HasSuffix(file.Name, ".json"):
+			var recipe LibraryRecipe
+			err = readZipJSON(file, &recipe)
+			library.Recipes = append(library.Recipes, recipe)
+		case strings.HasPrefix(file.Name, "images/"):
+			var data []byte
+			data, err = readZipImage(file)
+			if library.Images == nil {
+				library.Images = map[string][]byte{}
+			}
+			library.Images[file.Name] = data
+		}
+		if err != nil {
+			return library, fmt.Errorf("%s: %w", file.Name, err)
+		}
+	}
+
+	if !found || library.Manifest.Format != libraryFormatName {
+		return library, errUnknownLibraryFormat
+	}
+	if library.Manifest.Version > libraryFormatVersion {
+		return library, fmt.Errorf("library format version %d is newer than the supported version %d", library.Manifest.Version, libraryFormatVersion)
+	}
+	return library, nil
+}
+
+// readZipJSON decodes a JSON file of an archive.
+func readZipJSON(file *zip.File, v interface{}) error {
+	if file.UncompressedSize64 > maxLibraryEntryBytes {
+		return fmt.Errorf("file is larger than %d bytes", maxLibraryEntryBytes)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return json.NewDecoder(io.LimitReader(rc, maxLibraryEntryBytes)).Decode(v)
+}
+
+// readZipImage reads a photo of an archive.
+func readZipImage(file *zip.File) ([]byte, error) {
+	if file.UncompressedSize64 > maxImageBytes {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxImageBytes)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxImageBytes))
+}
+
+// detectLibraryFormat guesses the format of an upload from its content and
+// file name, returning "" when it is none of the supported formats.
+func detectLibraryFormat(data []byte, name string) string {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return ""
+		}
+		for _, file := range archive.File {
+			if file.Name == "manifest.json" {
+				return ImportFormatLibrary
+			}
+			if strings.HasSuffix(file.Name, ".paprikarecipe") {
+				return ImportFormatPaprika
+			}
+			if strings.HasSuffix(file.Name, cooklangExtension) {
+				return ImportFormatCooklang
+			}
+		}
+		return ""
+	}
+	if mealMasterHeaderPattern.Match(data) {
+		return ImportFormatMealMaster
+	}
+	if strings.HasSuffix(name, cooklangExtension) {
+		return ImportFormatCooklang
+	}
+	return ""
+}
+
+// parseLibraryUpload reads an upload in the given format, detecting the
+// format when none is given. Recipes from other apps become a library
+// holding only recipes. name is the upload's file name, if any.
+func parseLibraryUpload(data []byte, format, name string) (Library, string, error) {
+	if format == "" {
+		format = detectLibraryFormat(data, name)
+	}
+
+	var imported []ImportedRecipe
+	var err error
+	switch format {
+	case ImportFormatLibrary:
+		library, err := ReadLibrary(bytes.NewReader(data), int64(len(data)))
+		return library, format, err
+	case ImportFormatPaprika:
+		imported, err = ParsePaprikaArchive(bytes.NewReader(data), int64(len(data)))
+	case ImportFormatMealMaster:
+		imported, err = ParseMealMaster(bytes.NewReader(data))
+	case ImportFormatCooklang:
+		if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+			imported, err = ParseCooklangArchive(bytes.NewReader(data), int64(len(data)))
+			break
+		}
+		var recipe ImportedRecipe
+		recipe, err = ParseCooklang(bytes.NewReader(data), name)
+		imported = []ImportedRecipe{recipe}
+	default:
+		return Library{}, format, errUnknownLibraryFormat
+	}
+	if err != nil {
+		return Library{}, format, err
+	}
+
+	library := Library{Recipes: make([]LibraryRecipe, 0, len(imported))}
+	for _, recipe := range imported {
+		library.Recipes = append(library.Recipes, libraryRecipeFromImported(recipe))
+	}
+	return library, format, nil
+}
+
+// libraryRecipeFromImported converts a recipe read from another app.
+func libraryRecipeFromImported(imported ImportedRecipe) LibraryRecipe {
+	lines := make([]string, 0, len(imported.Ingredients))
+	names := make([]string, 0, len(imported.Ingredients))
+	rows := make([]SnapshotIngredient, 0, len(imported.Ingredients))
+	for _, ingredient := range imported.Ingredients {
+		lines = append(lines, ingredient.Text)
+		names = append(names, NormalizeIngredientName(ingredient.Name))
+		rows = append(rows, SnapshotIngredient{Name: ingredient.Name, Quantity: ingredient.Quantity})
+	}
+	steps := buildRecipeSteps(imported.Steps, names)
+
+	recipe := LibraryRecipe{
+		ExternalID: imported.ExternalID,
+		SourceURL:  imported.SourceURL,
+		ImageURLs:  imported.Images,
+		RecipeSnapshot: RecipeSnapshot{
+			Title:            imported.Title,
+			Ingredients:      strings.Join(lines, "\n"),
+			Instructions:     RenderInstructions(steps),
+			Servings:         imported.Servings,
+			PrepTimeMinutes:  imported.PrepTimeMinutes,
+			CookTimeMinutes:  imported.CookTimeMinutes,
+			TotalTimeMinutes: imported.TotalTimeMinutes,
+			YieldAmount:      imported.YieldAmount,
+			YieldUnit:        imported.YieldUnit,
+			Difficulty:       imported.Difficulty,
+			Cuisine:          imported.Cuisine,
+			Course:           imported.Course,
+			Equipment:        normalizeEquipment(imported.Equipment),
+			IngredientRows:   rows,
+			Steps:            snapshotSteps(steps),
+			Tags:             imported.Tags,
+		},
+	}
+	if imported.Nutrition != nil && imported.Nutrition.Calories > 0 {
+		recipe.Calories, recipe.CaloriesManual = int(math.Round(imported.Nutrition.Calories)), true
+	}
+	return recipe
+}
+
+// contentExternalID derives an external ID from content, for records that
+// come without one.
+func contentExternalID(prefix string, parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\n")))
+	return prefix + ":" + hex.EncodeToString(sum[:])
+}
+
+// libraryExternalID returns an external ID that fits its column: the given
+// one, a hash of it when it is too long, or a hash of the content when it
+// is missing.
+func libraryExternalID(id string, content ...string) string {
+	id = strings.TrimSpace(id)
+	switch {
+	case id == "":
+		return contentExternalID("content", content...)
+	case len(id) > 64:
+		return contentExternalID("sha1", id)
+	}
+	return id
+}
+
+// normalize checks an uploaded recipe and replaces values the API would
+// reject with defaults. An empty visibility stands for the user's default.
+func (r *LibraryRecipe) normalize() error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" {
+		return errors.New("missing title")
+	}
+	if len(r.Title) > 255 {
+		return errors.New("title is longer than 255 characters")
+	}
+	r.ExternalID = libraryExternalID(r.ExternalID, r.Title, r.Ingredients)
+
+	if checkEnum("visibility", []string{r.Visibility}, []string{VisibilityPrivate, VisibilityUnlisted, VisibilityPublic}) != nil {
+		r.Visibility = ""
+	}
+	if r.Status != RecipeStatusDraft {
+		r.Status = RecipeStatusPublished
+	}
+	if r.Servings < 1 {
+		r.Servings = 1
+	}
+	if checkEnum("difficulty", []string{r.Difficulty}, recipeDifficulties) != nil {
+		r.Difficulty = ""
+	}
+	if checkEnum("course", []string{r.Course}, recipeCourses) != nil {
+		r.Course = ""
+	}
+	r.Cuisine = normalizeCuisine(r.Cuisine)
+	r.Equipment = normalizeEquipment(r.Equipment)
+	if len(r.Steps) == 0 && strings.TrimSpace(r.Instructions) != "" {
+		r.Steps = snapshotSteps(SplitInstructions(r.Instructions))
+	}
+	return nil
+}
+
+// comparable returns the recipe's state in the form snapshotRecipe reads
+// it back, with tags as sorted slugs.
+func (r LibraryRecipe) comparable() RecipeSnapshot {
+	snapshot := r.RecipeSnapshot
+	snapshot.Tags = nil
+	for _, name := range r.Tags {
+		if slug := Slugify(name); slug != "" && !slices.Contains(snapshot.Tags, slug) {
+			snapshot.Tags = append(snapshot.Tags, slug)
+		}
+	}
+	sort.Strings(snapshot.Tags)
+	return snapshot
+}
+
+// applySettings copies the settings outside the recipe snapshot.
+func (r LibraryRecipe) applySettings(recipe *Recipe) {
+	recipe.Visibility = r.Visibility
+	recipe.Status = r.Status
+	recipe.SourceURL = r.SourceURL
+	recipe.ImageURLs = r.ImageURLs
+	if recipe.Status == RecipeStatusPublished && recipe.PublishedAt == nil {
+		now := time.Now()
+		recipe.PublishedAt = &now
+		if r.PublishedAt != nil {
+			recipe.PublishedAt = r.PublishedAt
+		}
+	}
+}
+
+// sameSettings reports whether a recipe already has the recipe's settings.
+func (r LibraryRecipe) sameSettings(recipe Recipe) bool {
+	return recipe.Visibility == r.Visibility && recipe.Status == r.Status &&
+		recipe.SourceURL == r.SourceURL && slices.Equal(recipe.ImageURLs, r.ImageURLs)
+}
+
+// importOutcome is what importing one item of a library did.
+type importOutcome int
+
+const (
+	importCreated importOutcome = iota
+	importUpdated
+	importUnchanged
+)
+
+// importLibraryRecipe creates or updates the user's recipe with the
+// external ID of the library recipe, taking its photos from images. A
+// recipe deleted since it was exported is restored, unless a moderator
+// removed it.
+func importLibraryRecipe(userID uint, item LibraryRecipe, images map[string][]byte) (importOutcome, error) {
+	if err := item.normalize(); err != nil {
+		return 0, err
+	}
+	if item.Visibility == "" {
+		item.Visibility = defaultVisibility(userID)
+	}
+
+	var recipe Recipe
+	err := DB.Unscoped().Where("user_id = ? AND external_id = ?", userID, item.ExternalID).First(&recipe).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		recipeID, err := createLibraryRecipe(userID, item)
+		if err != nil {
+			return 0, err
+		}
+		_, err = restoreLibraryImages(recipeID, userID, item, images)
+		return importCreated, err
+	}
+	if err != nil {
+		return 0, err
+	}
+	if recipe.RemovedAt != nil {
+		return 0, errRecipeRemoved
+	}
+
+	if !recipe.DeletedAt.Valid {
+		current, err := snapshotRecipe(DB, recipe.ID)
+		if err != nil {
+			return 0, err
+		}
+		if len(DiffSnapshots(current, item.comparable())) == 0 && item.sameSettings(recipe) {
+			restored, err := restoreLibraryImages(recipe.ID, userID, item, images)
+			if restored {
+				return importUpdated, err
+			}
+			return importUnchanged, err
+		}
+		if _, err := saveRecipeRevision(DB, recipe.ID, userID, "Snapshot before import"); err != nil {
+			return 0, err
+		}
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		recipe.DeletedAt = gorm.DeletedAt{}
+		item.applySettings(&recipe)
+		err := tx.Unscoped().Model(&recipe).
+			Select("visibility", "status", "published_at", "source_url", "image_urls", "deleted_at").
+			Updates(&recipe).Error
+		if err != nil {
+			return err
+		}
+		return restoreSnapshot(tx, &recipe, item.RecipeSnapshot)
+	})
+	if err != nil {
+		return 0, err
+	}
+	saveRecipeRevisionLogged(recipe.ID, userID, "Imported recipe")
+	refreshRecipeNutritionLogged(recipe.ID)
+	_, err = restoreLibraryImages(recipe.ID, userID, item, images)
+	return importUpdated, err
+}
+
+// createLibraryRecipe stores a library recipe the user does not have yet,
+// keeping its original creation time, and returns its ID.
+func createLibraryRecipe(userID uint, item LibraryRecipe) (uint, error) {
+	externalID := item.ExternalID
+	recipe := Recipe{
+		Title:      item.Title,
+		Servings:   1,
+		Equipment:  []string{},
+		UserID:     userID,
+		ExternalID: &externalID,
+		CreatedAt:  item.CreatedAt,
+	}
+	item.applySettings(&recipe)
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&recipe).Error; err != nil {
+			return err
+		}
+		return restoreSnapshot(tx, &recipe, item.RecipeSnapshot)
+	})
+	if err != nil {
+		return 0, err
+	}
+	saveRecipeRevisionLogged(recipe.ID, userID, "Imported recipe")
+	refreshRecipeNutritionLogged(recipe.ID)
+	return recipe.ID, nil
+}
+
+// restoreLibraryImages stores the photos of a library recipe for a recipe
+// without photos of its own, reporting whether it stored any. Recipes that
+// have photos keep them, so importing again does not duplicate them.
+// Photos missing from the archive or failing to decode are skipped.
+func restoreLibraryImages(recipeID, userID uint, item LibraryRecipe, images map[string][]byte) (bool, error) {
+	if len(item.Images) == 0 {
+		return false, nil
+	}
+	var count int64
+	if err := DB.Model(&RecipeImage{}).Where("recipe_id = ? AND review_id IS NULL", recipeID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+
+	restored := false
+	for _, image := range item.Images {
+		data, ok := images[image.File]
+		if !ok {
+			continue
+		}
+		processed, err := ProcessImage(data)
+		if err != nil {
+			log.Printf("Skipping image %s of recipe %d: %v", image.File, recipeID, err)
+			continue
+		}
+		img := RecipeImage{RecipeID: recipeID, UserID: userID, StepPosition: image.StepPosition}
+		if _, err := storeRecipeImage(context.Background(), img, processed); err != nil {
+			return restored, err
+		}
+		restored = true
+	}
+	return restored, nil
+}
+
+// libraryRecipeIDs maps the external IDs of the user's recipes to their
+// IDs. Recipes removed by moderators are left out, so that collections
+// cannot link them again.
+func libraryRecipeIDs(userID uint) (map[string]uint, error) {
+	var rows []struct {
+		ID         uint
+		ExternalID string
+	}
+	err := DB.Model(&Recipe{}).
+		Select("id, external_id").
+		Where("user_id = ? AND external_id IS NOT NULL AND removed_at IS NULL", userID).
+		Scan(&rows).Error
+	ids := make(map[string]uint, len(rows))
+	for _, row := range rows {
+		ids[row.ExternalID] = row.ID
+	}
+	return ids, err
+}
+
+// importLibraryCollection creates or updates the user's collection with the
+// external ID of the library collection. Recipes the user does not have,
+// including those removed by moderators, are left out.
+func importLibraryCollection(userID uint, item LibraryCollection, recipeIDs map[string]uint) (importOutcome, error) {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return 0, errors.New("missing name")
+	}
+	item.ExternalID = libraryExternalID(item.ExternalID, item.Name)
+
+	ids := []uint{}
+	for _, externalID := range item.Recipes {
+		if id, ok := recipeIDs[externalID]; ok && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	outcome := importUpdated
+	var collection Collection
+	err := DB.Unscoped().Where("user_id = ? AND external_id = ?", userID, item.ExternalID).First(&collection).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		collection = Collection{UserID: userID, ExternalID: &item.ExternalID}
+		outcome = importCreated
+	case err != nil:
+		return 0, err
+	default:
+		var current []uint
+		err := DB.Table("collection_recipes").Where("collection_id = ?", collection.ID).Order("recipe_id").Pluck("recipe_id", &current).Error
+		if err != nil {
+			return 0, err
+		}
+		if !collection.DeletedAt.Valid && collection.Name == item.Name &&
+			collection.Description == item.Description && slices.Equal(current, ids) {
+			return importUnchanged, nil
+		}
+	}
+
+	collection.Name, collection.Description = item.Name, item.Description
+	collection.DeletedAt = gorm.DeletedAt{}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Save(&collection).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return tx.Model(&collection).Association("Recipes").Clear()
+		}
+		var recipes []Recipe
+		if err := tx.Find(&recipes, ids).Error; err != nil {
+			return err
+		}
+		return tx.Model(&collection).Association("Recipes").Replace(recipes)
+	})
+	return outcome, err
+}
+
+// importLibraryPreferences applies library preferences to the user's.
+func importLibraryPreferences(userID uint, item LibraryPreferences) (importOutcome, error) {
+	pref, err := userPreference(userID)
+	if err != nil {
+		return 0, err
+	}
+	if checkEnum("default_visibility", []string{item.DefaultVisibility}, []string{VisibilityPrivate, VisibilityUnlisted, VisibilityPublic}) != nil {
+		item.DefaultVisibility = pref.DefaultVisibility
+	}
+	if pref.Preference == item.Preference && pref.DefaultVisibility == item.DefaultVisibility && pref.DailyGoals == item.DailyGoals {
+		return importUnchanged, nil
+	}
+
+	pref.Preference, pref.DefaultVisibility, pref.DailyGoals = item.Preference, item.DefaultVisibility, item.DailyGoals
+	err = DB.Model(&pref).Select(
+		"preference", "default_visibility", "goal_calories", "goal_protein_g", "goal_fat_g",
+		"goal_carbs_g", "goal_fiber_g", "goal_sugar_g", "goal_sodium_mg",
+	).Updates(&pref).Error
+	return importUpdated, err
+}
+
+// record counts the outcome of importing one item of the upload.
+func (j *ImportJob) record(outcome importOutcome, err error, item string) {
+	j.Processed++
+	if err != nil {
+		j.Failed++
+		if len(j.Errors) < maxImportJobErrors {
+			j.Errors = append(j.Errors, fmt.Sprintf("%s: %v", item, err))
+		}
+		return
+	}
+	switch outcome {
+	case importCreated:
+		j.Created++
+	case importUpdated:
+		j.Updated++
+	case importUnchanged:
+		j.Unchanged++
+	}
+}
+
+// withProgress fills in the fraction of the upload processed so far.
+func (j *ImportJob) withProgress() *ImportJob {
+	switch {
+	case j.Total > 0:
+		j.Progress = float64(j.Processed) / float64(j.Total)
+	case j.Status == JobCompleted:
+		j.Progress = 1
+	}
+	return j
+}
+
+// saveImportJob stores the progress of an import job.
+func saveImportJob(job *ImportJob) {
+	if err := DB.Save(job).Error; err != nil {
+		log.Printf("Failed to save import job %d: %v", job.ID, err)
+	}
+}
+
+// runImportJob imports a library for the job's user, recording progress
+// after every item. Recipes go first so that collections can refer to them.
+func runImportJob(job ImportJob, library Library) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Import job %d failed: %v", job.ID, r)
+			now := time.Now()
+			job.Status, job.FinishedAt = JobFailed, &now
+			saveImportJob(&job)
+		}
+	}()
+
+	now := time.Now()
+	job.Status, job.StartedAt = JobRunning, &now
+	saveImportJob(&job)
+
+	for _, item := range library.Recipes {
+		outcome, err := importLibraryRecipe(job.UserID, item, library.Images)
+		job.record(outcome, err, fmt.Sprintf("recipe %q", item.Title))
+		saveImportJob(&job)
+	}
+
+	recipeIDs, idsErr := libraryRecipeIDs(job.UserID)
+	for _, item := range library.Collections {
+		outcome, err := importOutcome(0), idsErr
+		if err == nil {
+			outcome, err = importLibraryCollection(job.UserID, item, recipeIDs)
+		}
+		job.record(outcome, err, fmt.Sprintf("collection %q", item.Name))
+		saveImportJob(&job)
+	}
+
+	if library.Preferences != nil {
+		outcome, err := importLibraryPreferences(job.UserID, *library.Preferences)
+		job.record(outcome, err, "preferences")
+	}
+
+	finished := time.Now()
+	job.Status, job.FinishedAt = JobCompleted, &finished
+	saveImportJob(&job)
+}
+
+// assignExternalIDs gives the user's recipes and collections that have
+// none a random external ID, which they keep from then on.
+func assignExternalIDs(userID uint) error {
+	for _, model := range []interface{}{&Recipe{}, &Collection{}} {
+		var ids []uint
+		if err := DB.Model(model).Where("user_id = ? AND external_id IS NULL", userID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		for _, id := range ids {
+			token, err := randomToken(16)
+			if err != nil {
+				return err
+			}
+			if err := DB.Model(model).Where("id = ?", id).UpdateColumn("external_id", token).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadLibrary reads everything a user's library archive holds, including
+// the uploaded photos of the user's recipes. Pending draft edits and the
+// photos of reviews are not part of it.
+func loadLibrary(ctx context.Context, userID uint) (Library, error) {
+	if err := assignExternalIDs(userID); err != nil {
+		return Library{}, err
+	}
+
+	var user User
+	if err := DB.First(&user, userID).Error; err != nil {
+		return Library{}, err
+	}
+	library := Library{
+		Manifest: LibraryManifest{ExportedAt: time.Now().UTC(), Username: user.Username},
+		Images:   map[string][]byte{},
+	}
+
+	pref, err := userPreference(userID)
+	if err != nil {
+		return library, err
+	}
+	library.Preferences = &LibraryPreferences{
+		Preference:        pref.Preference,
+		DefaultVisibility: pref.DefaultVisibility,
+		DailyGoals:        pref.DailyGoals,
+	}
+
+	var recipes []Recipe
+	if err := DB.Where("user_id = ?", userID).Order("id").Find(&recipes).Error; err != nil {
+		return library, err
+	}
+	for i, recipe := range recipes {
+		snapshot, err := snapshotRecipe(DB, recipe.ID)
+		if err != nil {
+			return library, err
+		}
+		// Tags are exported by name so that they read well where they
+		// do not exist yet.
+		err = DB.Model(&Tag{}).
+			Joins("JOIN recipe_tags ON recipe_tags.tag_id = tags.id").
+			Where("recipe_tags.recipe_id = ? AND recipe_tags.user_id IS NOT NULL", recipe.ID).
+			Order("tags.slug").
+			Pluck("tags.name", &snapshot.Tags).Error
+		if err != nil {
+			return library, err
+		}
+		item := LibraryRecipe{
+			ExternalID:     *recipe.ExternalID,
+			Visibility:     recipe.Visibility,
+			Status:         recipe.Status,
+			SourceURL:      recipe.SourceURL,
+			ImageURLs:      recipe.ImageURLs,
+			CreatedAt:      recipe.CreatedAt,
+			PublishedAt:    recipe.PublishedAt,
+			RecipeSnapshot: snapshot,
+		}
+
+		var images []RecipeImage
+		if err := DB.Scopes(recipePhotos).Where("recipe_id = ?", recipe.ID).Find(&images).Error; err != nil {
+			return library, err
+		}
+		for j, img := range images {
+			data, err := readBlob(ctx, img.key(originalImageSize))
+			if errors.Is(err, errBlobNotFound) {
+				continue
+			}
+			if err != nil {
+				return library, err
+			}
+			name := fmt.Sprintf("images/%06d-%d.%s", i+1, j+1, img.Extension)
+			library.Images[name] = data
+			item.Images = append(item.Images, LibraryImage{File: name, StepPosition: img.StepPosition})
+		}
+		library.Recipes = append(library.Recipes, item)
+	}
+
+	var collections []Collection
+	err = DB.Preload("Recipes", func(db *gorm.DB) *gorm.DB {
+		return db.Where("recipes.user_id = ?", userID).Order("recipes.id")
+	}).Where("user_id = ?", userID).Order("id").Find(&collections).Error
+	if err != nil {
+		return library, err
+	}
+	for _, collection := range collections {
+		item := LibraryCollection{
+			ExternalID:  *collection.ExternalID,
+			Name:        collection.Name,
+			Description: collection.Description,
+			Recipes:     []string{},
+		}
+		for _, recipe := range collection.Recipes {
+			item.Recipes = append(item.Recipes, *recipe.ExternalID)
+		}
+		library.Collections = append(library.Collections, item)
+	}
+	return library, nil
+}
+
+// ExportLibrary handles the GET /me/export endpoint, downloading the
+// caller's library archive.
+func ExportLibrary(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	library, err := loadLibrary(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export library"})
+		return
+	}
+
+	var archive bytes.Buffer
+	if err := WriteLibrary(&archive, library); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export library"})
+		return
+	}
+	filename := fmt.Sprintf("recipe-library-%s.zip", library.Manifest.ExportedAt.Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// ImportLibrary handles the POST /me/import endpoint. The upload is a
+// library archive, a Paprika .paprikarecipes archive, MealMaster text, or a
+// Cooklang .cook file or zip of them, detected from its content unless the
+// format query parameter names one. Cooklang recipes are identified by
+// their path, so uploading a recipe repository again updates its recipes.
+// It is checked before responding and imported in the background; the
+// returned job reports progress at GET /me/imports/:id.
+func ImportLibrary(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	format := c.Query("format")
+	if format != "" {
+		if err := checkEnum("format", []string{format}, []string{ImportFormatLibrary, ImportFormatPaprika, ImportFormatMealMaster, ImportFormatCooklang}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxLibraryBytes)
+	upload, err := importDocument(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded library"})
+		return
+	}
+	defer upload.Close()
+	data, err := io.ReadAll(io.LimitReader(upload, maxLibraryBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded library"})
+		return
+	}
+
+	library, format, err := parseLibraryUpload(data, format, uploadName(c))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if library.items() > maxLibraryItems {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Libraries are limited to %d items", maxLibraryItems)})
+		return
+	}
+
+	job := ImportJob{
+		UserID: userID,
+		Format: format,
+		Status: JobPending,
+		Total:  library.items(),
+		Errors: []string{},
+	}
+	if err := DB.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		return
+	}
+	go runImportJob(job, library)
+
+	c.Header("Location", fmt.Sprintf("/me/imports/%d", job.ID))
+	c.JSON(http.StatusAccepted, job.withProgress())
+}
+
+// GetImportJobs handles the GET /me/imports endpoint, listing the caller's
+// recent imports.
+func GetImportJobs(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var jobs []ImportJob
+	if err := DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(20).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve imports"})
+		return
+	}
+	for i := range jobs {
+		jobs[i].withProgress()
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetImportJob handles the GET /me/imports/:id endpoint, reporting the
+// progress of an import.
+func GetImportJob(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var job ImportJob
+	if err := DB.Where("user_id = ?", userID).First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job.withProgress())
+}
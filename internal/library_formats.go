@@ -0,0 +1,351 @@
+// library_formats.go
+package internal
+
+import (
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// mealMasterHeaderPattern matches the line starting a MealMaster recipe.
+var mealMasterHeaderPattern = regexp.MustCompile(`(?im)^(?:M{5}|-{5}).*meal-master`)
+
+// mealMasterEndPattern matches the line ending a MealMaster recipe.
+var mealMasterEndPattern = regexp.MustCompile(`^(?:M{5}|-{5,})\s*$`)
+
+// mealMasterSectionPattern matches a divider line naming a section, such as
+// "MMMMM-----FILLING-----".
+var mealMasterSectionPattern = regexp.MustCompile(`^(?:M{5}|-{5})-*\s*([^-]*?)\s*-*\s*$`)
+
+// mealMasterQuantityPattern matches the quantity column of an ingredient line.
+var mealMasterQuantityPattern = regexp.MustCompile(`^[\d/. -]*$`)
+
+// paprikaCaloriesPattern finds the calories in Paprika's free-form nutrition notes.
+var paprikaCaloriesPattern = regexp.MustCompile(`(?i)calories\W*(\d+(?:\.\d+)?)`)
+
+// mealMasterUnits maps MealMaster unit codes to unit names. Codes mapping
+// to "" count items.
+var mealMasterUnits = map[string]string{
+	"x": "", "ea": "",
+	"sm": "small", "md": "medium", "lg": "large",
+	"cn": "can", "pk": "package", "ct": "carton", "bn": "bunch", "sl": "slice",
+	"pn": "pinch", "dr": "drop", "ds": "dash",
+	"t": "tsp", "ts": "tsp", "T": "tbsp", "tb": "tbsp",
+	"fl": "fl oz", "c": "cup", "pt": "pint", "qt": "quart", "ga": "gallon",
+	"ml": "ml", "cb": "ml", "cl": "cl", "dl": "dl", "l": "l",
+	"oz": "oz", "lb": "lb", "mg": "mg", "cg": "cg", "dg": "dg", "g": "g", "kg": "kg",
+}
+
+// paprikaRecipe is the JSON of a recipe in a Paprika export.
+type paprikaRecipe struct {
+	UID             string   `json:"uid"`
+	Name            string   `json:"name"`
+	Ingredients     string   `json:"ingredients"`
+	Directions      string   `json:"directions"`
+	Description     string   `json:"description"`
+	Notes           string   `json:"notes"`
+	Servings        string   `json:"servings"`
+	PrepTime        string   `json:"prep_time"`
+	CookTime        string   `json:"cook_time"`
+	TotalTime       string   `json:"total_time"`
+	Difficulty      string   `json:"difficulty"`
+	Categories      []string `json:"categories"`
+	SourceURL       string   `json:"source_url"`
+	ImageURL        string   `json:"image_url"`
+	NutritionalInfo string   `json:"nutritional_info"`
+}
+
+// durationMinutes reads a duration written as ISO 8601, as a bare number of
+// minutes or as text such as "1 hr 30 mins".
+func durationMinutes(s string) int {
+	s = strings.TrimSpace(s)
+	if d, ok := ParseISODuration(s); ok {
+		return int(d.Minutes())
+	}
+	if minutes, err := strconv.Atoi(s); err == nil && minutes > 0 {
+		return minutes
+	}
+	return DetectStepDuration(s) / 60
+}
+
+// ParsePaprikaArchive reads the recipes of a Paprika .paprikarecipes
+// export: a zip archive of gzipped JSON recipes.
+func ParsePaprikaArchive(r io.ReaderAt, size int64) ([]ImportedRecipe, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipes []ImportedRecipe
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, ".paprikarecipe") {
+			continue
+		}
+		if len(recipes) == maxLibraryItems {
+			return nil, fmt.Errorf("archive holds more than %d recipes", maxLibraryItems)
+		}
+		recipe, err := readPaprikaRecipe(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		recipes = append(recipes, recipe)
+	}
+	if len(recipes) == 0 {
+		return nil, errEmptyLibrary
+	}
+	return recipes, nil
+}
+
+// readPaprikaRecipe decodes one recipe of a Paprika archive.
+func readPaprikaRecipe(file *zip.File) (ImportedRecipe, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return ImportedRecipe{}, err
+	}
+	defer rc.Close()
+	compressed, err := gzip.NewReader(rc)
+	if err != nil {
+		return ImportedRecipe{}, err
+	}
+	defer compressed.Close()
+
+	var recipe paprikaRecipe
+	if err := json.NewDecoder(io.LimitReader(compressed, maxLibraryEntryBytes)).Decode(&recipe); err != nil {
+		return ImportedRecipe{}, err
+	}
+	return recipe.imported(), nil
+}
+
+// imported maps a Paprika recipe onto an imported recipe. Paprika's
+// description and notes have no field of their own and become a final
+// "Notes" section of the steps. Embedded photos are not imported.
+func (p paprikaRecipe) imported() ImportedRecipe {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(p.Ingredients, "\r\n", "\n"), "\n") {
+		// Ingredient headings are plain lines ending in a colon.
+		if line = strings.TrimSpace(line); line != "" && !strings.HasSuffix(line, ":") {
+			lines = append(lines, line)
+		}
+	}
+
+	imported := ImportedRecipe{
+		Title:            strings.TrimSpace(p.Name),
+		Ingredients:      importedIngredients(lines),
+		Steps:            []recipeStepInput{},
+		PrepTimeMinutes:  durationMinutes(p.PrepTime),
+		CookTimeMinutes:  durationMinutes(p.CookTime),
+		TotalTimeMinutes: durationMinutes(p.TotalTime),
+		Difficulty:       strings.ToLower(strings.TrimSpace(p.Difficulty)),
+		Course:           schemaCourse(p.Categories),
+		Tags:             []string{},
+		Images:           []string{},
+		SourceURL:        strings.TrimSpace(p.SourceURL),
+		Format:           ImportFormatPaprika,
+	}
+	if p.UID != "" {
+		imported.ExternalID = "paprika:" + p.UID
+	}
+	imported.YieldAmount, imported.YieldUnit, imported.Servings = schemaYield(p.Servings)
+	if imported.Servings == 0 {
+		imported.Servings = 1
+	}
+	if imported.TotalTimeMinutes == 0 {
+		imported.TotalTimeMinutes = imported.PrepTimeMinutes + imported.CookTimeMinutes
+	}
+	for _, category := range p.Categories {
+		if category = strings.TrimSpace(category); category != "" && len(imported.Tags) < 20 {
+			imported.Tags = append(imported.Tags, category)
+		}
+	}
+	if url := strings.TrimSpace(p.ImageURL); url != "" {
+		imported.Images = append(imported.Images, url)
+	}
+
+	for _, step := range SplitInstructions(p.Directions) {
+		imported.Steps = append(imported.Steps, recipeStepInput{Section: step.Section, Text: step.Text})
+	}
+	for _, text := range []string{p.Description, p.Notes} {
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				imported.Steps = append(imported.Steps, recipeStepInput{Section: "Notes", Text: line})
+			}
+		}
+	}
+
+	if m := paprikaCaloriesPattern.FindStringSubmatch(p.NutritionalInfo); m != nil {
+		calories, _ := strconv.ParseFloat(m[1], 64)
+		imported.Nutrition = &NutritionFacts{Calories: calories}
+	}
+	return imported
+}
+
+// ParseMealMaster reads the recipes of a MealMaster text export. Text
+// outside the recipe markers is ignored.
+func ParseMealMaster(r io.Reader) ([]ImportedRecipe, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var recipes []ImportedRecipe
+	var lines []string
+	inRecipe := false
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		switch {
+		case mealMasterHeaderPattern.MatchString(line):
+			// A recipe missing its end marker ends at the next header.
+			if inRecipe {
+				recipes = append(recipes, parseMealMasterRecipe(lines))
+			}
+			inRecipe, lines = true, nil
+		case inRecipe && mealMasterEndPattern.MatchString(line):
+			recipes = append(recipes, parseMealMasterRecipe(lines))
+			inRecipe = false
+		case inRecipe:
+			lines = append(lines, line)
+		}
+		if len(recipes) > maxLibraryItems {
+			return nil, fmt.Errorf("file holds more than %d recipes", maxLibraryItems)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inRecipe {
+		recipes = append(recipes, parseMealMasterRecipe(lines))
+	}
+	if len(recipes) == 0 {
+		return nil, errEmptyLibrary
+	}
+	return recipes, nil
+}
+
+// mealMasterIngredient reads a fixed-column MealMaster ingredient line: a
+// seven-character quantity, a two-character unit code and the text.
+func mealMasterIngredient(line string) (quantity, unit, text string, ok bool) {
+	if len(line) < 12 || line[7] != ' ' || line[10] != ' ' || !mealMasterQuantityPattern.MatchString(line[:7]) {
+		return "", "", "", false
+	}
+	unit, known := mealMasterUnits[strings.TrimSpace(line[8:10])]
+	if !known && strings.TrimSpace(line[8:10]) != "" {
+		return "", "", "", false
+	}
+	text = strings.TrimSpace(line[11:])
+	return strings.TrimSpace(line[:7]), unit, text, text != ""
+}
+
+// mealMasterColumns splits the lines of two-column ingredient lists, whose
+// second column starts at position 41.
+func mealMasterColumns(line string) []string {
+	if len(line) >= 41+12 && strings.TrimSpace(line[39:41]) == "" {
+		if _, _, _, ok := mealMasterIngredient(line[41:]); ok {
+			return []string{strings.TrimRight(line[:41], " "), line[41:]}
+		}
+	}
+	return []string{line}
+}
+
+// parseMealMasterRecipe reads the lines between a recipe's markers: the
+// Title, Categories and Yield or Servings fields, then ingredient lines,
+// then the directions. Ingredients have no sections here, so ingredient
+// dividers are dropped; dividers in the directions become step sections.
+func parseMealMasterRecipe(lines []string) ImportedRecipe {
+	imported := ImportedRecipe{Tags: []string{}, Images: []string{}, Format: ImportFormatMealMaster}
+	var categories, ingredients, directions []string
+
+	i := 0
+header:
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(line, ":")
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "title":
+			imported.Title = strings.TrimSpace(value)
+		case "categories":
+			for _, category := range strings.Split(value, ",") {
+				if category = strings.TrimSpace(category); category != "" && !strings.EqualFold(category, "none") {
+					categories = append(categories, category)
+				}
+			}
+		case "yield", "servings":
+			imported.YieldAmount, imported.YieldUnit, imported.Servings = schemaYield(strings.TrimSpace(value))
+		default:
+			break header
+		}
+	}
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || mealMasterSectionPattern.MatchString(line) {
+			continue
+		}
+		columns := mealMasterColumns(line)
+		if _, _, _, ok := mealMasterIngredient(columns[0]); !ok {
+			break
+		}
+		for _, column := range columns {
+			quantity, unit, text, _ := mealMasterIngredient(column)
+			// Continuation lines start with a dash in the text column.
+			if quantity == "" && unit == "" && strings.HasPrefix(text, "-") && len(ingredients) > 0 {
+				ingredients[len(ingredients)-1] += " " + strings.TrimSpace(strings.TrimLeft(text, "-"))
+				continue
+			}
+			ingredients = append(ingredients, strings.Join(strings.Fields(quantity+" "+unit+" "+text), " "))
+		}
+	}
+
+	// Direction paragraphs are wrapped over several lines; a blank line or
+	// a numbered line starts the next step.
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			directions = append(directions, strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if m := mealMasterSectionPattern.FindStringSubmatch(lines[i]); m != nil {
+			flush()
+			if m[1] != "" {
+				directions = append(directions, m[1]+":")
+			}
+			continue
+		}
+		switch {
+		case line == "":
+			flush()
+		case stepNumberPattern.MatchString(line):
+			flush()
+			paragraph = []string{line}
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+
+	imported.Ingredients = importedIngredients(ingredients)
+	imported.Steps = []recipeStepInput{}
+	for _, step := range SplitInstructions(strings.Join(directions, "\n")) {
+		imported.Steps = append(imported.Steps, recipeStepInput{Section: step.Section, Text: step.Text})
+	}
+	if imported.Servings == 0 {
+		imported.Servings = 1
+	}
+	imported.Course = schemaCourse(categories)
+	for _, category := range categories {
+		if len(imported.Tags) < 20 {
+			imported.Tags = append(imported.Tags, category)
+		}
+	}
+	imported.ExternalID = contentExternalID("mealmaster", append([]string{imported.Title}, ingredients...)...)
+	return imported
+}
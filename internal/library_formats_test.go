@@ -0,0 +1,181 @@
+// library_formats_test.go
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// paprikaArchive builds a .paprikarecipes archive of the given recipes.
+func paprikaArchive(t *testing.T, recipes map[string]string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, recipe := range recipes {
+		file, err := archive.Create(name + ".paprikarecipe")
+		assert.NoError(t, err)
+		compressed := gzip.NewWriter(file)
+		compressed.Write([]byte(recipe))
+		assert.NoError(t, compressed.Close())
+	}
+	assert.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+// TestDurationMinutes verifies the duration spellings of other apps.
+func TestDurationMinutes(t *testing.T) {
+	for input, want := range map[string]int{
+		"":             0,
+		"15":           15,
+		"15 mins":      15,
+		"1 hr 30 mins": 90,
+		"2 hours":      120,
+		"PT45M":        45,
+		"overnight":    0,
+	} {
+		assert.Equal(t, want, durationMinutes(input), input)
+	}
+}
+
+// TestParsePaprikaArchive verifies Paprika recipes are mapped, with notes
+// kept as a final section.
+func TestParsePaprikaArchive(t *testing.T) {
+	data := paprikaArchive(t, map[string]string{"Lemon Bars": `{
+		"uid": "5A1B-77",
+		"name": "Lemon Bars",
+		"ingredients": "Crust:\n2 cups flour\n\n1/2 cup butter\n4 eggs",
+		"directions": "Press the dough into the pan.\nBake for 20 minutes at 350 F.",
+		"notes": "Keeps for three days.",
+		"servings": "24 bars",
+		"prep_time": "20 mins",
+		"cook_time": "1 hr",
+		"total_time": "",
+		"difficulty": "Easy",
+		"categories": ["Dessert", "Bake Sale"],
+		"source_url": "https://example.com/lemon-bars",
+		"image_url": "https://example.com/lemon-bars.jpg",
+		"nutritional_info": "Calories: 210\nFat: 9g",
+		"photo_data": "aGVsbG8="
+	}`})
+
+	recipes, err := ParsePaprikaArchive(bytes.NewReader(data), int64(len(data)))
+	assert.NoError(t, err)
+	assert.Len(t, recipes, 1)
+	recipe := recipes[0]
+	assert.Equal(t, "Lemon Bars", recipe.Title)
+	assert.Equal(t, "paprika:5A1B-77", recipe.ExternalID)
+	assert.Len(t, recipe.Ingredients, 3)
+	assert.Equal(t, "flour", recipe.Ingredients[0].Name)
+	assert.Equal(t, 24.0, recipe.YieldAmount)
+	assert.Equal(t, "bars", recipe.YieldUnit)
+	assert.Equal(t, 1, recipe.Servings)
+	assert.Equal(t, 20, recipe.PrepTimeMinutes)
+	assert.Equal(t, 60, recipe.CookTimeMinutes)
+	assert.Equal(t, 80, recipe.TotalTimeMinutes)
+	assert.Equal(t, "easy", recipe.Difficulty)
+	assert.Equal(t, "dessert", recipe.Course)
+	assert.Equal(t, []string{"Dessert", "Bake Sale"}, recipe.Tags)
+	assert.Equal(t, []string{"https://example.com/lemon-bars.jpg"}, recipe.Images)
+	assert.Equal(t, 210.0, recipe.Nutrition.Calories)
+	assert.Len(t, recipe.Steps, 3)
+	assert.Equal(t, recipeStepInput{Section: "Notes", Text: "Keeps for three days."}, recipe.Steps[2])
+
+	library, format, err := parseLibraryUpload(data, "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, ImportFormatPaprika, format)
+	imported := library.Recipes[0]
+	assert.Equal(t, "paprika:5A1B-77", imported.ExternalID)
+	assert.Equal(t, 210, imported.Calories)
+	assert.True(t, imported.CaloriesManual)
+	assert.Equal(t, 1200, imported.Steps[1].DurationSeconds)
+	assert.Equal(t, "1. Press the dough into the pan.\n2. Bake for 20 minutes at 350 F.\n\nNotes:\n3. Keeps for three days.", imported.Instructions)
+
+	empty := paprikaArchive(t, map[string]string{})
+	_, err = ParsePaprikaArchive(bytes.NewReader(empty), int64(len(empty)))
+	assert.ErrorIs(t, err, errEmptyLibrary)
+}
+
+// mealMasterSample holds two MealMaster recipes in both marker styles.
+const mealMasterSample = `Exported from a recipe program
+
+MMMMM----- Recipe via Meal-Master (tm) v8.05
+
+      Title: Lemon Bars
+ Categories: Desserts, Cookies
+      Yield: 24 bars
+
+      2 c  All-purpose flour                 1/2 c  Butter
+MMMMM--------------------------FILLING-------------------------
+      4    Eggs
+      2 tb Lemon juice
+           -freshly squeezed
+
+  Press the dough into the pan and bake
+  for 20 minutes.
+
+  Whisk the filling and pour it over the crust.
+  Bake 25 minutes more.
+
+MMMMM
+
+---------- Recipe via Meal-Master (tm) v8.02
+
+      Title: Garlic Bread
+ Categories: Breads
+   Servings: 4
+
+      1 lg Baguette
+      3    Garlic cloves, minced
+
+  1. Mix garlic with butter. 
+  2. Spread on bread and bake.
+-----
+`
+
+// TestParseMealMaster verifies MealMaster headers, ingredient columns,
+// continuation lines and wrapped directions.
+func TestParseMealMaster(t *testing.T) {
+	assert.Equal(t, ImportFormatMealMaster, detectLibraryFormat([]byte(mealMasterSample), ""))
+
+	recipes, err := ParseMealMaster(strings.NewReader(mealMasterSample))
+	assert.NoError(t, err)
+	assert.Len(t, recipes, 2)
+
+	bars := recipes[0]
+	assert.Equal(t, "Lemon Bars", bars.Title)
+	assert.Equal(t, []string{"Desserts", "Cookies"}, bars.Tags)
+	assert.Equal(t, "dessert", bars.Course)
+	assert.Equal(t, 24.0, bars.YieldAmount)
+	assert.Equal(t, "bars", bars.YieldUnit)
+	var lines []string
+	for _, ingredient := range bars.Ingredients {
+		lines = append(lines, ingredient.Text)
+	}
+	assert.Equal(t, []string{"2 cup All-purpose flour", "1/2 cup Butter", "4 Eggs", "2 tbsp Lemon juice freshly squeezed"}, lines)
+	assert.Equal(t, "2 tbsp", bars.Ingredients[3].Quantity)
+	assert.Equal(t, []recipeStepInput{
+		{Text: "Press the dough into the pan and bake for 20 minutes."},
+		{Text: "Whisk the filling and pour it over the crust. Bake 25 minutes more."},
+	}, bars.Steps)
+	assert.True(t, strings.HasPrefix(bars.ExternalID, "mealmaster:"))
+
+	bread := recipes[1]
+	assert.Equal(t, "Garlic Bread", bread.Title)
+	assert.Equal(t, 4, bread.Servings)
+	assert.Len(t, bread.Ingredients, 2)
+	assert.Equal(t, "1 large Baguette", bread.Ingredients[0].Text)
+	assert.Equal(t, []recipeStepInput{
+		{Text: "Mix garlic with butter."},
+		{Text: "Spread on bread and bake."},
+	}, bread.Steps)
+
+	again, _ := ParseMealMaster(strings.NewReader(mealMasterSample))
+	assert.Equal(t, bars.ExternalID, again[0].ExternalID)
+
+	_, err = ParseMealMaster(strings.NewReader("no recipes here"))
+	assert.ErrorIs(t, err, errEmptyLibrary)
+}
@@ -0,0 +1,195 @@
+// library_test.go
+package internal
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestLibraryRoundTrip verifies an archive reads back as it was written.
+func TestLibraryRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	step := 1
+	library := Library{
+		Manifest:    LibraryManifest{ExportedAt: created, Username: "ana"},
+		Preferences: &LibraryPreferences{Preference: "vegetarian", DefaultVisibility: VisibilityPrivate, DailyGoals: NutritionFacts{Calories: 2000}},
+		Collections: []LibraryCollection{{ExternalID: "c1", Name: "Weeknight", Recipes: []string{"r1"}}},
+		Recipes: []LibraryRecipe{{
+			ExternalID: "r1",
+			Visibility: VisibilityPublic,
+			Status:     RecipeStatusPublished,
+			ImageURLs:  []string{"https://example.com/soup.jpg"},
+			CreatedAt:  created,
+			Images:     []LibraryImage{{File: "images/000001-1.jpg"}, {File: "images/000001-2.png", StepPosition: &step}},
+			RecipeSnapshot: RecipeSnapshot{
+				Title:          "Soup",
+				Servings:       4,
+				IngredientRows: []SnapshotIngredient{{Name: "leek", Quantity: "2"}},
+				Steps:          []SnapshotStep{{Text: "Simmer for 20 minutes.", DurationSeconds: 1200}},
+				Tags:           []string{"Comfort Food"},
+			},
+		}},
+		Images: map[string][]byte{
+			"images/000001-1.jpg": []byte("\xff\xd8\xffhero"),
+			"images/000001-2.png": []byte("\x89PNGstep"),
+		},
+	}
+
+	var archive bytes.Buffer
+	assert.NoError(t, WriteLibrary(&archive, library))
+	assert.Equal(t, ImportFormatLibrary, detectLibraryFormat(archive.Bytes(), ""))
+
+	read, err := ReadLibrary(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, libraryFormatName, read.Manifest.Format)
+	assert.Equal(t, 1, read.Manifest.Recipes)
+	assert.Equal(t, "ana", read.Manifest.Username)
+	assert.Equal(t, library.Preferences, read.Preferences)
+	assert.Equal(t, library.Collections, read.Collections)
+	assert.Equal(t, library.Recipes, read.Recipes)
+	assert.Equal(t, library.Images, read.Images)
+	assert.Equal(t, 3, read.items())
+}
+
+// TestReadLibraryRejectsOtherArchives verifies archives without a known
+// manifest are refused.
+func TestReadLibraryRejectsOtherArchives(t *testing.T) {
+	write := func(files map[string]string) []byte {
+		var buf bytes.Buffer
+		archive := zip.NewWriter(&buf)
+		for name, content := range files {
+			file, _ := archive.Create(name)
+			file.Write([]byte(content))
+		}
+		archive.Close()
+		return buf.Bytes()
+	}
+
+	data := write(map[string]string{"notes.txt": "hello"})
+	_, err := ReadLibrary(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, errUnknownLibraryFormat)
+	assert.Equal(t, "", detectLibraryFormat(data, ""))
+
+	data = write(map[string]string{"manifest.json": `{"format":"recipe-book-library","version":2}`})
+	_, err = ReadLibrary(bytes.NewReader(data), int64(len(data)))
+	assert.EqualError(t, err, "library format version 2 is newer than the supported version 1")
+
+	data = write(map[string]string{"manifest.json": `{"format":"recipe-book-library","version":1}`, "recipes/000001.json": `{"title":`})
+	_, err = ReadLibrary(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorContains(t, err, "recipes/000001.json")
+}
+
+// TestLibraryRecipeNormalize verifies uploaded recipes are checked and
+// values the API would reject are replaced.
+func TestLibraryRecipeNormalize(t *testing.T) {
+	recipe := LibraryRecipe{
+		Visibility: "friends",
+		Status:     "archived",
+		RecipeSnapshot: RecipeSnapshot{
+			Title:        "  Toast ",
+			Instructions: "1. Slice the bread.\n2. Toast for 3 minutes.",
+			Difficulty:   "trivial",
+			Course:       "breakfast",
+			Cuisine:      " French ",
+		},
+	}
+	assert.NoError(t, recipe.normalize())
+	assert.Equal(t, "Toast", recipe.Title)
+	assert.Equal(t, "", recipe.Visibility)
+	assert.Equal(t, RecipeStatusPublished, recipe.Status)
+	assert.Equal(t, 1, recipe.Servings)
+	assert.Equal(t, "", recipe.Difficulty)
+	assert.Equal(t, "breakfast", recipe.Course)
+	assert.Equal(t, "french", recipe.Cuisine)
+	assert.True(t, strings.HasPrefix(recipe.ExternalID, "content:"))
+	assert.Len(t, recipe.Steps, 2)
+	assert.Equal(t, 180, recipe.Steps[1].DurationSeconds)
+
+	recipe.ExternalID = strings.Repeat("x", 65)
+	assert.NoError(t, recipe.normalize())
+	assert.True(t, strings.HasPrefix(recipe.ExternalID, "sha1:"))
+	assert.LessOrEqual(t, len(recipe.ExternalID), 64)
+
+	assert.EqualError(t, (&LibraryRecipe{}).normalize(), "missing title")
+}
+
+// TestLibraryRecipeComparable verifies tags compare as sorted slugs, the
+// way snapshots read them back.
+func TestLibraryRecipeComparable(t *testing.T) {
+	recipe := LibraryRecipe{RecipeSnapshot: RecipeSnapshot{Title: "Stew", Tags: []string{"Winter", "Comfort Food", "winter"}}}
+	assert.Equal(t, []string{"comfort-food", "winter"}, recipe.comparable().Tags)
+	assert.Equal(t, []string{"Winter", "Comfort Food", "winter"}, recipe.Tags)
+}
+
+// TestImportJobProgress verifies outcomes are counted and errors capped.
+func TestImportJobProgress(t *testing.T) {
+	job := ImportJob{Total: maxImportJobErrors + 4, Errors: []string{}}
+	job.record(importCreated, nil, "recipe a")
+	job.record(importUpdated, nil, "recipe b")
+	job.record(importUnchanged, nil, "recipe c")
+	for i := 0; i < maxImportJobErrors+1; i++ {
+		job.record(0, errUnknownLibraryFormat, "recipe d")
+	}
+	assert.Equal(t, 1, job.Created)
+	assert.Equal(t, 1, job.Updated)
+	assert.Equal(t, 1, job.Unchanged)
+	assert.Equal(t, maxImportJobErrors+1, job.Failed)
+	assert.Len(t, job.Errors, maxImportJobErrors)
+	assert.Equal(t, "recipe d: "+errUnknownLibraryFormat.Error(), job.Errors[0])
+	assert.InDelta(t, float64(maxImportJobErrors+4)/float64(job.Total), job.withProgress().Progress, 0.001)
+
+	empty := ImportJob{Status: JobCompleted}
+	assert.Equal(t, 1.0, empty.withProgress().Progress)
+}
+
+// TestImportLibraryRejectsUnknownUploads verifies uploads are checked
+// before a job is started.
+func TestImportLibraryRejectsUnknownUploads(t *testing.T) {
+	c := visibilityContext("/me/import?format=csv", 1)
+	ImportLibrary(c)
+	assert.Equal(t, http.StatusBadRequest, c.Writer.Status())
+
+	recorder := httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(recorder)
+	c.Set("userID", uint(1))
+	c.Request = httptest.NewRequest(http.MethodPost, "/me/import", strings.NewReader("just some text"))
+	ImportLibrary(c)
+	assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "unrecognized format")
+}
+
+// TestImportSkipsRemovedRecipes verifies importing does not restore a
+// recipe a moderator removed.
+func TestImportSkipsRemovedRecipes(t *testing.T) {
+	saved := DB
+	defer func() { DB = saved }()
+	DB = DryRunDB(t)
+	removed := time.Now()
+	DB.Callback().Query().After("gorm:query").Register("test:removed", func(tx *gorm.DB) {
+		if recipe, ok := tx.Statement.Dest.(*Recipe); ok {
+			externalID := "r1"
+			*recipe = Recipe{ID: 7, UserID: 3, ExternalID: &externalID, RemovedAt: &removed}
+			recipe.DeletedAt = gorm.DeletedAt{Time: removed, Valid: true}
+		}
+	})
+	var writes []string
+	capture := func(tx *gorm.DB) {
+		writes = append(writes, tx.Statement.SQL.String())
+	}
+	DB.Callback().Create().After("gorm:create").Register("test:capture", capture)
+	DB.Callback().Update().After("gorm:update").Register("test:capture", capture)
+
+	item := LibraryRecipe{ExternalID: "r1", Visibility: VisibilityPublic, RecipeSnapshot: RecipeSnapshot{Title: "Soup"}}
+	_, err := importLibraryRecipe(3, item, nil)
+	assert.ErrorIs(t, err, errRecipeRemoved)
+	assert.Empty(t, writes)
+}
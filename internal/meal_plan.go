@@ -0,0 +1,125 @@
+// meal_plan.go
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dateLayout is the format used for calendar dates in requests.
+const dateLayout = "2006-01-02"
+
+// parseDateRange parses an inclusive YYYY-MM-DD range. A missing bound
+// defaults to the other one, so a single date selects that day.
+func parseDateRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	if fromStr == "" {
+		fromStr = toStr
+	}
+	if toStr == "" {
+		toStr = fromStr
+	}
+	from, err := time.Parse(dateLayout, fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", fromStr)
+	}
+	to, err := time.Parse(dateLayout, toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", toStr)
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to date must not be before from date")
+	}
+	return from, to, nil
+}
+
+// GetMealPlan handles the GET /me/meal-plan endpoint.
+// The from and to query parameters default to today.
+func GetMealPlan(c *gin.Context) {
+	fromStr, toStr := c.Query("from"), c.Query("to")
+	if fromStr == "" && toStr == "" {
+		fromStr = time.Now().Format(dateLayout)
+	}
+	from, to, err := parseDateRange(fromStr, toStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entries []MealPlanEntry
+	if err := DB.Scopes(ownerScope(c)).Where("date BETWEEN ? AND ?", from, to).Order("date, meal").Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve meal plan"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// CreateMealPlanEntry handles the POST /me/meal-plan endpoint.
+func CreateMealPlanEntry(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		RecipeID uint    `json:"recipe_id" binding:"required"`
+		Date     string  `json:"date" binding:"required"`
+		Meal     string  `json:"meal" binding:"omitempty,oneof=breakfast lunch dinner snack"`
+		Servings float64 `json:"servings" binding:"omitempty,gt=0"`
+		Private  bool    `json:"private"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	date, err := time.Parse(dateLayout, input.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date, expected YYYY-MM-DD"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, input.RecipeID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	servings := input.Servings
+	if servings == 0 {
+		servings = 1
+	}
+	entry := MealPlanEntry{
+		UserID:   userID,
+		RecipeID: recipe.ID,
+		Date:     date,
+		Meal:     input.Meal,
+		Servings: servings,
+	}
+	if !input.Private {
+		entry.HouseholdID = currentHouseholdID(c)
+	}
+	if err := DB.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create meal plan entry"})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// DeleteMealPlanEntry handles the DELETE /me/meal-plan/:id endpoint.
+func DeleteMealPlanEntry(c *gin.Context) {
+	var entry MealPlanEntry
+	if err := DB.Scopes(ownerScope(c)).First(&entry, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Meal plan entry not found"})
+		return
+	}
+
+	if err := DB.Delete(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete meal plan entry"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Meal plan entry deleted"})
+}
@@ -7,29 +7,79 @@ import (
 	"gorm.io/gorm"
 )
 
-// User represents a user in the system
+// User represents a user in the system.
+// SuspendedUntil is set while moderators have suspended the user.
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Username  string         `gorm:"unique;not null" json:"username"`
-	Email     string         `gorm:"unique;not null" json:"email"`
-	Password  string         `gorm:"not null" json:"-"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Username       string         `gorm:"unique;not null" json:"username"`
+	Email          string         `gorm:"unique;not null" json:"email"`
+	Password       string         `gorm:"not null" json:"-"`
+	IsAdmin        bool           `gorm:"not null;default:false" json:"is_admin"`
+	SuspendedUntil *time.Time     `json:"suspended_until,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 	// Add additional fields as needed
 }
 
-// Recipe represents a recipe in the system
+// Recipe represents a recipe in the system.
+// CaloriesManual is set when Calories was typed by the user rather than
+// derived from the ingredients. Times are in minutes and zero when unknown;
+// the yield is e.g. 12 "cookies", alongside the servings it feeds.
+// SourceURL and ImageURLs record where an imported recipe came from;
+// Images are the photos uploaded for the recipe and its steps.
+// ForkedFromID points at the recipe this one was forked from, if any.
+// Visibility is private, unlisted or public; ShareToken is the secret of
+// the share link for unlisted recipes.
+// Status is draft until first published; Draft holds edits saved but not
+// yet published, and is only shown to the owner.
+// ExternalID identifies the recipe across library exports and imports.
+// RatingAverage and RatingCount summarize its reviews, and RatingScore is
+// their Bayesian average that recipes are ranked by; all are zero when the
+// recipe has no reviews.
+// HiddenAt is set while moderators hide the recipe from everyone but its
+// owner; RemovedAt is set when a moderator deletes it, so that the owner
+// cannot bring it back.
 type Recipe struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Title        string         `gorm:"not null" json:"title"`
-	Ingredients  string         `gorm:"type:text" json:"ingredients"`
-	Instructions string         `gorm:"type:text" json:"instructions"`
-	Calories     int            `json:"calories"`
-	UserID       uint           `gorm:"not null" json:"user_id"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uint             `gorm:"primaryKey" json:"id"`
+	Title            string           `gorm:"not null" json:"title"`
+	Ingredients      string           `gorm:"type:text" json:"ingredients"`
+	Instructions     string           `gorm:"type:text" json:"instructions"`
+	Calories         int              `json:"calories"`
+	CaloriesManual   bool             `gorm:"not null;default:false" json:"calories_manual"`
+	Servings         int              `gorm:"not null;default:1" json:"servings"`
+	PrepTimeMinutes  int              `gorm:"not null;default:0" json:"prep_time_minutes"`
+	CookTimeMinutes  int              `gorm:"not null;default:0" json:"cook_time_minutes"`
+	TotalTimeMinutes int              `gorm:"not null;default:0;index" json:"total_time_minutes"`
+	YieldAmount      float64          `json:"yield_amount"`
+	YieldUnit        string           `gorm:"size:50" json:"yield_unit"`
+	Difficulty       string           `gorm:"size:10;index" json:"difficulty"`
+	Cuisine          string           `gorm:"size:50;index" json:"cuisine"`
+	Course           string           `gorm:"size:20;index" json:"course"`
+	Equipment        []string         `gorm:"type:jsonb;serializer:json" json:"equipment"`
+	SourceURL        string           `gorm:"size:2048" json:"source_url,omitempty"`
+	ImageURLs        []string         `gorm:"type:jsonb;serializer:json" json:"image_urls,omitempty"`
+	Images           []RecipeImage    `json:"images,omitempty"`
+	Nutrition        *RecipeNutrition `json:"nutrition,omitempty"`
+	Steps            []RecipeStep     `json:"steps,omitempty"`
+	Tags             []Tag            `gorm:"many2many:recipe_tags" json:"tags,omitempty"`
+	ForkedFromID     *uint            `gorm:"index" json:"forked_from_id,omitempty"`
+	ForkedFrom       *RecipeSummary   `gorm:"-" json:"forked_from,omitempty"`
+	Visibility       string           `gorm:"size:10;not null;default:public;index" json:"visibility"`
+	ShareToken       *string          `gorm:"size:64;uniqueIndex" json:"-"`
+	Status           string           `gorm:"size:10;not null;default:published;index" json:"status"`
+	PublishedAt      *time.Time       `json:"published_at"`
+	HiddenAt         *time.Time       `json:"hidden_at,omitempty"`
+	RemovedAt        *time.Time       `json:"removed_at,omitempty"`
+	Draft            *RecipeDraft     `gorm:"type:jsonb;serializer:json" json:"-"`
+	ExternalID       *string          `gorm:"size:64;uniqueIndex:idx_recipe_external_id" json:"external_id,omitempty"`
+	UserID           uint             `gorm:"not null;uniqueIndex:idx_recipe_external_id" json:"user_id"`
+	RatingAverage    float64          `gorm:"not null;default:0" json:"rating_average"`
+	RatingCount      int              `gorm:"not null;default:0" json:"rating_count"`
+	RatingScore      float64          `gorm:"not null;default:0;index" json:"rating_score"`
+	CreatedAt        time.Time        `json:"created_at"`
+	UpdatedAt        time.Time        `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt   `gorm:"index" json:"-"`
 	// Add additional fields as needed
 }
 
@@ -44,6 +94,24 @@ type Ingredient struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// RecipeStep represents one ordered instruction step of a recipe.
+// Section groups steps under a heading such as "For the sauce", Ingredients
+// holds the normalized names of the ingredients used in the step, and a
+// zero duration or temperature means none applies.
+type RecipeStep struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	RecipeID        uint      `gorm:"not null;index" json:"recipe_id"`
+	Position        int       `gorm:"not null" json:"position"`
+	Section         string    `json:"section,omitempty"`
+	Text            string    `gorm:"type:text;not null" json:"text"`
+	DurationSeconds int       `json:"duration_seconds"`
+	Temperature     float64   `json:"temperature,omitempty"`
+	TemperatureUnit string    `gorm:"size:1" json:"temperature_unit,omitempty"`
+	Ingredients     []string  `gorm:"serializer:json" json:"ingredients"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
 // SavedRecipe represents a user's saved recipe
 type SavedRecipe struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
@@ -53,12 +121,505 @@ type SavedRecipe struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-// UserPreference represents a user's preferences
+// UserPreference represents a user's preferences.
+// DailyGoals holds daily nutrition targets; zero means no target is set.
 type UserPreference struct {
-	ID         uint           `gorm:"primaryKey" json:"id"`
-	UserID     uint           `gorm:"not null" json:"user_id"`
-	Preference string         `gorm:"not null" json:"preference"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	UserID            uint           `gorm:"not null" json:"user_id"`
+	Preference        string         `gorm:"not null" json:"preference"`
+	DailyGoals        NutritionFacts `gorm:"embedded;embeddedPrefix:goal_" json:"daily_goals"`
+	DefaultVisibility string         `gorm:"size:10;not null;default:public" json:"default_visibility"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// MealPlanEntry represents a recipe scheduled for a meal on a given day
+type MealPlanEntry struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	HouseholdID *uint          `gorm:"index" json:"household_id"`
+	RecipeID    uint           `gorm:"not null" json:"recipe_id"`
+	Date        time.Time      `gorm:"type:date;not null;index" json:"date"`
+	Meal        string         `json:"meal"`
+	Servings    float64        `gorm:"not null;default:1" json:"servings"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// ShoppingList represents a persisted shopping list
+type ShoppingList struct {
+	ID          uint               `gorm:"primaryKey" json:"id"`
+	UserID      uint               `gorm:"not null;index" json:"user_id"`
+	HouseholdID *uint              `gorm:"index" json:"household_id"`
+	Name        string             `gorm:"not null" json:"name"`
+	Items       []ShoppingListItem `json:"items,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt     `gorm:"index" json:"-"`
+}
+
+// ShoppingListItem represents a single line on a shopping list
+type ShoppingListItem struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	ShoppingListID uint           `gorm:"not null;index" json:"shopping_list_id"`
+	Name           string         `gorm:"not null" json:"name"`
+	Amount         float64        `json:"amount"`
+	Unit           string         `json:"unit"`
+	Category       string         `gorm:"index" json:"category"`
+	Checked        bool           `gorm:"not null;default:false" json:"checked"`
+	AdHoc          bool           `gorm:"not null;default:false" json:"ad_hoc"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// PantryItem represents an ingredient a user has in stock
+type PantryItem struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	HouseholdID *uint          `gorm:"index" json:"household_id"`
+	Name        string         `gorm:"not null" json:"name"`
+	Amount      float64        `json:"amount"`
+	Unit        string         `json:"unit"`
+	Location    string         `gorm:"not null;default:pantry" json:"location"`
+	PurchasedAt *time.Time     `gorm:"type:date" json:"purchased_at"`
+	ExpiresAt   *time.Time     `gorm:"type:date;index" json:"expires_at"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CookEvent records that a user cooked a recipe
+type CookEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	RecipeID  uint      `gorm:"not null;index" json:"recipe_id"`
+	CookedAt  time.Time `gorm:"not null" json:"cooked_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Product represents a packaged product in the barcode catalog
+type Product struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	Barcode        string         `gorm:"uniqueIndex;size:14;not null" json:"barcode"`
+	Name           string         `gorm:"not null" json:"name"`
+	Brand          string         `json:"brand"`
+	IngredientName string         `gorm:"not null" json:"ingredient_name"`
+	PackageAmount  float64        `json:"package_amount"`
+	PackageUnit    string         `json:"package_unit"`
+	Category       string         `json:"category"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Household represents a group of users sharing pantry, lists and plans
+type Household struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	Name      string            `gorm:"not null" json:"name"`
+	OwnerID   uint              `gorm:"not null" json:"owner_id"`
+	Members   []HouseholdMember `json:"members,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	DeletedAt gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+// HouseholdMember represents a user's membership and role in a household
+type HouseholdMember struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	HouseholdID uint      `gorm:"not null;index" json:"household_id"`
+	UserID      uint      `gorm:"not null;uniqueIndex" json:"user_id"`
+	Role        string    `gorm:"not null;default:member" json:"role"`
+	User        *User     `json:"user,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// HouseholdInvite represents a tokenized invitation to join a household
+type HouseholdInvite struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	HouseholdID  uint       `gorm:"not null;index" json:"household_id"`
+	Token        string     `gorm:"uniqueIndex;not null" json:"token"`
+	Role         string     `gorm:"not null;default:member" json:"role"`
+	CreatedByID  uint       `gorm:"not null" json:"created_by_id"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedByID *uint      `json:"accepted_by_id"`
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Collection represents a named group of recipes.
+// Public collections are listed on their owner's profile and shown in
+// followers' feeds, with only the public recipes in them.
+type Collection struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	UserID      uint           `gorm:"not null;index;uniqueIndex:idx_collection_external_id" json:"user_id"`
+	HouseholdID *uint          `gorm:"index" json:"household_id"`
+	ExternalID  *string        `gorm:"size:64;uniqueIndex:idx_collection_external_id" json:"external_id,omitempty"`
+	Name        string         `gorm:"not null" json:"name"`
+	Description string         `gorm:"type:text" json:"description"`
+	Public      bool           `gorm:"not null;default:false" json:"public"`
+	Recipes     []Recipe       `gorm:"many2many:collection_recipes" json:"recipes,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// SKUMapping remembers which retailer product a user buys for an ingredient
+type SKUMapping struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	UserID        uint      `gorm:"not null;uniqueIndex:idx_sku_mapping" json:"user_id"`
+	Provider      string    `gorm:"not null;uniqueIndex:idx_sku_mapping" json:"provider"`
+	ItemName      string    `gorm:"not null;uniqueIndex:idx_sku_mapping" json:"item_name"`
+	SKU           string    `gorm:"not null" json:"sku"`
+	ProductName   string    `json:"product_name"`
+	Brand         string    `json:"brand"`
+	PackageAmount float64   `json:"package_amount"`
+	PackageUnit   string    `json:"package_unit"`
+	PriceCents    int       `json:"price_cents"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PreferredBrand represents a brand a user prefers when matching products
+type PreferredBrand struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Brand     string    `gorm:"not null" json:"brand"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NutritionFacts holds the nutrient amounts tracked for foods and recipes
+type NutritionFacts struct {
+	Calories float64 `json:"calories"`
+	ProteinG float64 `json:"protein_g"`
+	FatG     float64 `json:"fat_g"`
+	CarbsG   float64 `json:"carbs_g"`
+	FiberG   float64 `json:"fiber_g"`
+	SugarG   float64 `json:"sugar_g"`
+	SodiumMg float64 `json:"sodium_mg"`
+}
+
+// Food represents an entry in the nutrient database with values per 100 g.
+// GramsPerMl and GramsPerUnit convert volume and whole-item measures to
+// weight and are zero when unknown.
+type Food struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	FDCID          int    `gorm:"uniqueIndex" json:"fdc_id"`
+	Description    string `gorm:"not null;index" json:"description"`
+	NutritionFacts `gorm:"embedded"`
+	GramsPerMl     float64   `json:"grams_per_ml"`
+	GramsPerUnit   float64   `json:"grams_per_unit"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// RecipeNutrition holds the nutrition computed from a recipe's ingredients
+type RecipeNutrition struct {
+	ID         uint                  `gorm:"primaryKey" json:"id"`
+	RecipeID   uint                  `gorm:"uniqueIndex;not null" json:"recipe_id"`
+	Servings   int                   `json:"servings"`
+	PerServing NutritionFacts        `gorm:"embedded" json:"per_serving"`
+	Total      NutritionFacts        `gorm:"embedded;embeddedPrefix:total_" json:"total"`
+	Confidence float64               `json:"confidence"`
+	Breakdown  []IngredientNutrition `gorm:"serializer:json" json:"breakdown"`
+	ComputedAt time.Time             `json:"computed_at"`
+}
+
+// NutritionOverride pins the food match or weight used for one ingredient of a recipe
+type NutritionOverride struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	RecipeID       uint      `gorm:"not null;uniqueIndex:idx_nutrition_override" json:"recipe_id"`
+	IngredientName string    `gorm:"not null;uniqueIndex:idx_nutrition_override" json:"ingredient_name"`
+	FoodID         *uint     `json:"food_id"`
+	Grams          *float64  `json:"grams"`
+	Exclude        bool      `gorm:"not null;default:false" json:"exclude"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// FoodLogEntry represents something a user ate on a given day: servings of a
+// recipe, a weight of a nutrient database food, or an ad-hoc item. The
+// nutrition is the total for the entry, captured when it was logged.
+type FoodLogEntry struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	UserID          uint      `gorm:"not null;index" json:"user_id"`
+	Date            time.Time `gorm:"type:date;not null;index" json:"date"`
+	Meal            string    `json:"meal"`
+	Name            string    `gorm:"not null" json:"name"`
+	RecipeID        *uint     `json:"recipe_id"`
+	FoodID          *uint     `json:"food_id"`
+	MealPlanEntryID *uint     `gorm:"index" json:"meal_plan_entry_id"`
+	Servings        float64   `gorm:"not null;default:1" json:"servings"`
+	Grams           float64   `json:"grams"`
+	NutritionFacts  `gorm:"embedded" json:"nutrition"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CookingSession represents a user cooking a recipe step by step.
+// CurrentStep is the 1-based position of the step in progress and Status is
+// active, finished or abandoned.
+type CookingSession struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	UserID      uint           `gorm:"not null;index" json:"user_id"`
+	RecipeID    uint           `gorm:"not null;index" json:"recipe_id"`
+	CurrentStep int            `gorm:"not null;default:1" json:"current_step"`
+	StepCount   int            `gorm:"not null" json:"step_count"`
+	Status      string         `gorm:"not null;default:active;index" json:"status"`
+	CookEventID *uint          `json:"cook_event_id"`
+	StartedAt   time.Time      `gorm:"not null" json:"started_at"`
+	FinishedAt  *time.Time     `json:"finished_at"`
+	Timers      []CookingTimer `gorm:"foreignKey:SessionID" json:"timers"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// CookingTimer is a named countdown within a cooking session. Status is
+// pending, running, paused or expired. ElapsedSeconds accumulates across
+// pauses; while running, RunningSince marks the start of the current run.
+type CookingTimer struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	SessionID        uint       `gorm:"not null;index" json:"session_id"`
+	StepPosition     int        `json:"step_position"`
+	Name             string     `gorm:"not null" json:"name"`
+	DurationSeconds  int        `gorm:"not null" json:"duration_seconds"`
+	ElapsedSeconds   float64    `gorm:"not null;default:0" json:"elapsed_seconds"`
+	Status           string     `gorm:"not null;default:pending" json:"status"`
+	RunningSince     *time.Time `json:"running_since"`
+	ExpiresAt        *time.Time `gorm:"index" json:"expires_at"`
+	RemainingSeconds int        `gorm:"-" json:"remaining_seconds"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// Tag represents a recipe tag identified by its normalized slug. System tags
+// are applied automatically from recipe metadata. A merged or alias tag
+// points at its canonical tag through AliasOfID.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Slug      string    `gorm:"size:64;not null;uniqueIndex" json:"slug"`
+	System    bool      `gorm:"not null;default:false" json:"system"`
+	AliasOfID *uint     `gorm:"index" json:"alias_of_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RecipeTag is the join row between recipes and tags. UserID is who applied
+// the tag and is nil for system-applied tags.
+type RecipeTag struct {
+	RecipeID  uint      `gorm:"primaryKey" json:"recipe_id"`
+	TagID     uint      `gorm:"primaryKey;index" json:"tag_id"`
+	UserID    *uint     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecipeRevision represents an immutable snapshot of a recipe, written on
+// every edit. Number counts up from 1 per recipe; UserID is the editor.
+type RecipeRevision struct {
+	ID        uint            `gorm:"primaryKey" json:"id"`
+	RecipeID  uint            `gorm:"not null;uniqueIndex:idx_recipe_revision" json:"recipe_id"`
+	Number    int             `gorm:"not null;uniqueIndex:idx_recipe_revision" json:"number"`
+	UserID    uint            `json:"user_id"`
+	Message   string          `gorm:"size:255" json:"message"`
+	Snapshot  *RecipeSnapshot `gorm:"type:jsonb;serializer:json" json:"snapshot,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ImportJob tracks a library import running in the background. Status is
+// pending, running, completed or failed; Processed counts the items of the
+// upload handled so far out of Total, and Errors lists the ones that failed.
+type ImportJob struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Format     string     `gorm:"size:20;not null" json:"format"`
+	Status     string     `gorm:"size:10;not null;default:pending;index" json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Created    int        `json:"created"`
+	Updated    int        `json:"updated"`
+	Unchanged  int        `json:"unchanged"`
+	Failed     int        `json:"failed"`
+	Errors     []string   `gorm:"type:jsonb;serializer:json" json:"errors"`
+	Progress   float64    `gorm:"-" json:"progress"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// CookbookJob tracks the printing of a collection as a PDF cookbook in the
+// background. Status is pending, running, completed or failed; the finished
+// PDF is kept with the job for download.
+type CookbookJob struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index" json:"user_id"`
+	CollectionID uint       `gorm:"not null;index" json:"collection_id"`
+	Title        string     `gorm:"not null" json:"title"`
+	Layout       string     `gorm:"size:10;not null" json:"layout"`
+	Status       string     `gorm:"size:10;not null;default:pending;index" json:"status"`
+	RecipeIDs    []uint     `gorm:"type:jsonb;serializer:json" json:"recipe_ids"`
+	Pages        int        `json:"pages"`
+	Error        string     `json:"error,omitempty"`
+	PDF          []byte     `gorm:"type:bytea" json:"-"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// RecipeImage is a photo uploaded for a recipe: its hero image, a photo of
+// the step at StepPosition, or an "I made this" photo of the review ReviewID.
+// Its blobs share Prefix: the original with
+// its metadata stripped, and a thumbnail for each of Sizes. URL and
+// Thumbnails link to them and are filled in when the image is returned.
+type RecipeImage struct {
+	ID           uint              `gorm:"primaryKey" json:"id"`
+	RecipeID     uint              `gorm:"not null;index" json:"recipe_id"`
+	StepPosition *int              `json:"step_position,omitempty"`
+	ReviewID     *uint             `gorm:"index" json:"review_id,omitempty"`
+	UserID       uint              `gorm:"not null" json:"user_id"`
+	Prefix       string            `gorm:"size:100;not null;uniqueIndex" json:"-"`
+	Extension    string            `gorm:"size:5;not null" json:"-"`
+	ContentType  string            `gorm:"size:20;not null" json:"content_type"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	Size         int               `json:"size"`
+	Sizes        []string          `gorm:"type:jsonb;serializer:json" json:"-"`
+	URL          string            `gorm:"-" json:"url"`
+	Thumbnails   map[string]string `gorm:"-" json:"thumbnails"`
+	CreatedAt    time.Time         `json:"created_at"`
+}
+
+// RecipeReview is a user's 1 to 5 star rating of a recipe with an optional
+// text review and "I made this" photos. Each user reviews a recipe at most
+// once and edits the review to change it. Reviews hidden by moderators are
+// only shown to their author and do not count towards the recipe's rating.
+type RecipeReview struct {
+	ID        uint          `gorm:"primaryKey" json:"id"`
+	RecipeID  uint          `gorm:"not null;uniqueIndex:idx_review_recipe_user" json:"recipe_id"`
+	UserID    uint          `gorm:"not null;uniqueIndex:idx_review_recipe_user;index" json:"user_id"`
+	Username  string        `gorm:"-" json:"username"`
+	Rating    int           `gorm:"not null" json:"rating"`
+	Body      string        `gorm:"type:text" json:"body"`
+	Photos    []RecipeImage `gorm:"foreignKey:ReviewID" json:"photos"`
+	HiddenAt  *time.Time    `json:"hidden_at,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Comment is a comment on a recipe, or a reply when ParentID is set; RootID
+// is the top-level comment of a reply's thread and Depth how many replies
+// deep it is, zero for top-level comments. Body is Markdown, BodyHTML
+// its sanitized rendering and Mentions the users it names as @username.
+// Comments held by the content filter are pending and only shown to their
+// author, as are comments hidden by moderators. Deleted comments keep their
+// place in the thread without a body.
+type Comment struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	RecipeID  uint             `gorm:"not null;index" json:"recipe_id"`
+	UserID    uint             `gorm:"not null;index" json:"user_id"`
+	Username  string           `gorm:"-" json:"username,omitempty"`
+	ParentID  *uint            `gorm:"index" json:"parent_id,omitempty"`
+	RootID    *uint            `gorm:"index" json:"root_id,omitempty"`
+	Depth     int              `gorm:"not null;default:0" json:"depth"`
+	Body      string           `gorm:"type:text;not null" json:"body"`
+	BodyHTML  string           `gorm:"type:text;not null" json:"body_html"`
+	Mentions  []CommentMention `gorm:"type:jsonb;serializer:json" json:"mentions"`
+	Status    string           `gorm:"size:10;not null;default:visible;index" json:"status"`
+	Replies   []*Comment       `gorm:"-" json:"replies"`
+	EditedAt  *time.Time       `json:"edited_at,omitempty"`
+	HiddenAt  *time.Time       `json:"hidden_at,omitempty"`
+	DeletedAt *time.Time       `json:"deleted_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// CommentMention is a user mentioned in a comment.
+type CommentMention struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Follow records that a user follows another user.
+type Follow struct {
+	FollowerID uint      `gorm:"primaryKey" json:"follower_id"`
+	FolloweeID uint      `gorm:"primaryKey;index" json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Activity is something a user did that shows in their followers' feeds:
+// publishing a public recipe, reviewing a recipe or making a collection
+// public. SubjectID is the recipe, review or collection. FannedOut is set
+// when the activity was copied into followers' feeds when it happened;
+// activities of users with many followers are not, and are merged into
+// feeds when they are read instead.
+type Activity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ActorID   uint      `gorm:"not null;index" json:"actor_id"`
+	Kind      string    `gorm:"size:20;not null;uniqueIndex:idx_activity_subject" json:"kind"`
+	SubjectID uint      `gorm:"not null;uniqueIndex:idx_activity_subject" json:"subject_id"`
+	FannedOut bool      `gorm:"not null;default:false" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FeedItem is an activity copied into the feed of a follower of its actor.
+type FeedItem struct {
+	UserID     uint      `gorm:"primaryKey" json:"user_id"`
+	ActivityID uint      `gorm:"primaryKey;index" json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ModerationCase gathers the reports about a recipe, review or comment for
+// moderators to resolve. A target has at most one open case; reports after
+// it is resolved open a new one. ClaimedByID is the moderator working on
+// the case. AutoHidden is set when enough users reported the target for it
+// to be hidden until a moderator resolves the case. Content is the target
+// as shown to moderators.
+type ModerationCase struct {
+	ID           uint               `gorm:"primaryKey" json:"id"`
+	TargetType   string             `gorm:"size:10;not null;uniqueIndex:idx_case_open_target,where:status = 'open'" json:"target_type"`
+	TargetID     uint               `gorm:"not null;uniqueIndex:idx_case_open_target,where:status = 'open'" json:"target_id"`
+	TargetUserID uint               `gorm:"not null;index" json:"target_user_id"`
+	RecipeID     uint               `gorm:"not null" json:"recipe_id"`
+	Status       string             `gorm:"size:10;not null;default:open;index" json:"status"`
+	ReportCount  int                `gorm:"not null;default:0" json:"report_count"`
+	AutoHidden   bool               `gorm:"not null;default:false" json:"auto_hidden"`
+	ClaimedByID  *uint              `json:"claimed_by_id,omitempty"`
+	ClaimedAt    *time.Time         `json:"claimed_at,omitempty"`
+	Resolution   string             `gorm:"size:10" json:"resolution,omitempty"`
+	ResolvedByID *uint              `json:"resolved_by_id,omitempty"`
+	ResolvedAt   *time.Time         `json:"resolved_at,omitempty"`
+	Reports      []Report           `gorm:"foreignKey:CaseID" json:"reports,omitempty"`
+	Actions      []ModerationAction `gorm:"foreignKey:CaseID" json:"actions,omitempty"`
+	Content      interface{}        `gorm:"-" json:"content,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at"`
+}
+
+// Report is a user flagging a recipe, review or comment to moderators. Each
+// user reports the target of a case at most once.
+type Report struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CaseID     uint      `gorm:"not null;uniqueIndex:idx_report_case_reporter" json:"case_id"`
+	ReporterID uint      `gorm:"not null;uniqueIndex:idx_report_case_reporter;index" json:"reporter_id"`
+	Reason     string    `gorm:"size:20;not null" json:"reason"`
+	Details    string    `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ModerationAction is an entry in the audit trail of moderation: what a
+// moderator did about a case, or the system when ModeratorID is nil.
+type ModerationAction struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CaseID      uint      `gorm:"not null;index" json:"case_id"`
+	ModeratorID *uint     `gorm:"index" json:"moderator_id"`
+	Action      string    `gorm:"size:20;not null" json:"action"`
+	Note        string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
 }
@@ -0,0 +1,658 @@
+// moderation.go
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of content users can report.
+const (
+	ReportTargetRecipe  = "recipe"
+	ReportTargetReview  = "review"
+	ReportTargetComment = "comment"
+)
+
+// Statuses of moderation cases.
+const (
+	CaseOpen     = "open"
+	CaseResolved = "resolved"
+)
+
+// Moderation actions, as recorded in the audit trail. Cases are resolved by
+// hiding or deleting their target, dismissing the reports, or suspending
+// the target's author; auto_hide is taken by the system when enough users
+// report the same content.
+const (
+	ModerationClaim    = "claim"
+	ModerationRelease  = "release"
+	ModerationHide     = "hide"
+	ModerationDelete   = "delete"
+	ModerationDismiss  = "dismiss"
+	ModerationSuspend  = "suspend"
+	ModerationAutoHide = "auto_hide"
+)
+
+// reportReasons are the reasons users can give for reporting content.
+var reportReasons = []string{"spam", "offensive", "harassment", "misinformation", "copyright", "other"}
+
+// moderationResolutions are the actions that resolve a case.
+var moderationResolutions = []string{ModerationHide, ModerationDelete, ModerationDismiss, ModerationSuspend}
+
+// reportHideThreshold is how many different users must report content for
+// it to be hidden until a moderator resolves its case.
+var reportHideThreshold = 3
+
+// A claim on a case lapses after moderationClaimTimeout, so that cases
+// left by a moderator return to the queue.
+const moderationClaimTimeout = time.Hour
+
+// Suspensions last defaultSuspensionDays unless the moderator says
+// otherwise, up to maxSuspensionDays.
+const (
+	defaultSuspensionDays = 7
+	maxSuspensionDays     = 365
+)
+
+var (
+	errCaseResolved = errors.New("case has already been resolved")
+	errCaseClaimed  = errors.New("case is claimed by another moderator")
+)
+
+// InitModeration reads the number of reports that hide content from
+// REPORT_HIDE_THRESHOLD, if set.
+func InitModeration() {
+	value := getEnv("REPORT_HIDE_THRESHOLD", "")
+	if value == "" {
+		return
+	}
+	threshold, err := strconv.Atoi(value)
+	if err != nil || threshold < 1 {
+		log.Fatalf("Invalid REPORT_HIDE_THRESHOLD %q: expected a positive number", value)
+	}
+	reportHideThreshold = threshold
+}
+
+// moderationTarget is reported content: its kind and ID, its author and the
+// recipe it is on.
+type moderationTarget struct {
+	Type     string
+	ID       uint
+	UserID   uint
+	RecipeID uint
+}
+
+// target returns the content a case is about.
+func (m ModerationCase) target() moderationTarget {
+	return moderationTarget{Type: m.TargetType, ID: m.TargetID, UserID: m.TargetUserID, RecipeID: m.RecipeID}
+}
+
+// targetModel returns the model of a kind of reported content.
+func targetModel(kind string) interface{} {
+	switch kind {
+	case ReportTargetRecipe:
+		return &Recipe{}
+	case ReportTargetReview:
+		return &RecipeReview{}
+	default:
+		return &Comment{}
+	}
+}
+
+// recordModeration adds an entry to the audit trail of a case.
+func recordModeration(tx *gorm.DB, caseID uint, moderatorID *uint, action, note string) error {
+	return tx.Create(&ModerationAction{CaseID: caseID, ModeratorID: moderatorID, Action: action, Note: note}).Error
+}
+
+// openModerationCase returns the open case about a target, opening one if
+// there is none. The case stays locked until the transaction ends.
+func openModerationCase(tx *gorm.DB, target moderationTarget) (ModerationCase, error) {
+	opened := ModerationCase{TargetType: target.Type, TargetID: target.ID, TargetUserID: target.UserID, RecipeID: target.RecipeID, Status: CaseOpen}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&opened)
+	if result.Error != nil || result.RowsAffected == 1 {
+		return opened, result.Error
+	}
+	var existing ModerationCase
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("target_type = ? AND target_id = ? AND status = ?", target.Type, target.ID, CaseOpen).
+		First(&existing).Error
+	return existing, err
+}
+
+// setTargetHidden hides or unhides reported content, reporting whether it
+// changed. The rating of a review's recipe is recomputed to match.
+func setTargetHidden(tx *gorm.DB, target moderationTarget, hidden bool) (bool, error) {
+	if target.Type == ReportTargetReview {
+		if err := lockRecipe(tx, target.RecipeID); err != nil {
+			return false, err
+		}
+	}
+	query := tx.Model(targetModel(target.Type)).Where("id = ?", target.ID)
+	var result *gorm.DB
+	if hidden {
+		result = query.Where("hidden_at IS NULL").UpdateColumn("hidden_at", time.Now())
+	} else {
+		result = query.Where("hidden_at IS NOT NULL").UpdateColumn("hidden_at", nil)
+	}
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+	if target.Type == ReportTargetReview {
+		return true, refreshRecipeRating(tx, target.RecipeID)
+	}
+	return true, nil
+}
+
+// deleteTarget deletes reported content the way its author would, returning
+// the photos whose blobs to delete once the transaction is committed.
+// Deleted recipes are marked as removed by a moderator, which tells the
+// deletion apart from the owner's and keeps library imports from undoing it.
+func deleteTarget(tx *gorm.DB, target moderationTarget) ([]RecipeImage, error) {
+	switch target.Type {
+	case ReportTargetRecipe:
+		err := tx.Model(&Recipe{}).Where("id = ?", target.ID).UpdateColumn("removed_at", time.Now()).Error
+		if err != nil {
+			return nil, err
+		}
+		return nil, tx.Delete(&Recipe{}, target.ID).Error
+	case ReportTargetReview:
+		if err := lockRecipe(tx, target.RecipeID); err != nil {
+			return nil, err
+		}
+		var photos []RecipeImage
+		if err := tx.Where("review_id = ?", target.ID).Find(&photos).Error; err != nil {
+			return nil, err
+		}
+		if err := deleteReview(tx, target.ID); err != nil {
+			return nil, err
+		}
+		return photos, refreshRecipeRating(tx, target.RecipeID)
+	default:
+		var comment Comment
+		if err := tx.First(&comment, target.ID).Error; err != nil || comment.DeletedAt != nil {
+			return nil, err
+		}
+		return nil, softDeleteComment(tx, &comment)
+	}
+}
+
+// reachedHideThreshold reports whether enough users have reported the
+// target of an open case for it to be hidden automatically.
+func (m ModerationCase) reachedHideThreshold() bool {
+	return m.ReportCount >= reportHideThreshold && !m.AutoHidden
+}
+
+// resolutionVisibility returns whether resolving a case with an action
+// changes the visibility of its target, and whether the target ends up
+// hidden. Dismissing reports only unhides content the reports hid, not
+// content a moderator hid before; deleting leaves visibility alone.
+func resolutionVisibility(action string, autoHidden bool) (change, hidden bool) {
+	switch action {
+	case ModerationHide, ModerationSuspend:
+		return true, true
+	case ModerationDismiss:
+		return autoHidden, false
+	}
+	return false, false
+}
+
+// suspendedAt reports whether the user is suspended at the given time.
+func (u User) suspendedAt(t time.Time) bool {
+	return u.SuspendedUntil != nil && u.SuspendedUntil.After(t)
+}
+
+// suspendUser suspends a user until the given time, keeping any longer
+// suspension they are already under.
+func suspendUser(tx *gorm.DB, userID uint, until time.Time) error {
+	return tx.Model(&User{}).Where("id = ?", userID).
+		UpdateColumn("suspended_until", gorm.Expr("GREATEST(suspended_until, ?)", until)).Error
+}
+
+// reportContent files the caller's report about a target, opening a case
+// for it unless one is open. Once reportHideThreshold different users have
+// reported the target, it is hidden until a moderator resolves the case.
+// Reporting the same content again while its case is open changes nothing.
+func reportContent(c *gin.Context, target moderationTarget) {
+	userID, _ := currentUserID(c)
+	if target.UserID == userID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "You cannot report your own content"})
+		return
+	}
+
+	var input struct {
+		Reason  string `json:"reason" binding:"required"`
+		Details string `json:"details" binding:"max=2000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkEnum("reason", []string{input.Reason}, reportReasons); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		moderationCase, err := openModerationCase(tx, target)
+		if err != nil {
+			return err
+		}
+		report := Report{CaseID: moderationCase.ID, ReporterID: userID, Reason: input.Reason, Details: strings.TrimSpace(input.Details)}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&report)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+
+		moderationCase.ReportCount++
+		if moderationCase.reachedHideThreshold() {
+			hidden, err := setTargetHidden(tx, target, true)
+			if err != nil {
+				return err
+			}
+			if hidden {
+				moderationCase.AutoHidden = true
+				note := fmt.Sprintf("Reported by %d users", moderationCase.ReportCount)
+				if err := recordModeration(tx, moderationCase.ID, nil, ModerationAutoHide, note); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&moderationCase).Select("report_count", "auto_hidden").Updates(&moderationCase).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report content"})
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"status": "Report received"})
+}
+
+// ReportRecipe handles the POST /recipes/:id/report endpoint.
+func ReportRecipe(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	reportContent(c, moderationTarget{Type: ReportTargetRecipe, ID: recipe.ID, UserID: recipe.UserID, RecipeID: recipe.ID})
+}
+
+// ReportReview handles the POST /recipes/:id/reviews/:reviewId/report
+// endpoint.
+func ReportReview(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	var review RecipeReview
+	err := DB.Where("recipe_id = ? AND (hidden_at IS NULL OR user_id = ?)", recipe.ID, userID).
+		First(&review, c.Param("reviewId")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return
+	}
+	reportContent(c, moderationTarget{Type: ReportTargetReview, ID: review.ID, UserID: review.UserID, RecipeID: recipe.ID})
+}
+
+// ReportComment handles the POST /recipes/:id/comments/:commentId/report
+// endpoint.
+func ReportComment(c *gin.Context) {
+	var recipe Recipe
+	var comment Comment
+	if !findRecipeComment(c, &recipe, &comment) {
+		return
+	}
+	if comment.DeletedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Comment has been deleted"})
+		return
+	}
+	reportContent(c, moderationTarget{Type: ReportTargetComment, ID: comment.ID, UserID: comment.UserID, RecipeID: recipe.ID})
+}
+
+// SuspensionMiddleware stops suspended users from posting content. It must
+// run after JWTMiddleware or OptionalJWTMiddleware; anonymous requests are
+// let through for the handler to deal with.
+func SuspensionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var user User
+		if err := DB.Select("id", "suspended_until").First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		if user.suspendedAt(time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":           "Your account is suspended",
+				"suspended_until": user.SuspendedUntil,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// caseEntries preloads the reports and actions of cases, oldest first.
+func caseEntries(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+// attachCaseContent fills in the content cases are about, including
+// content that has since been hidden or deleted.
+func attachCaseContent(cases []ModerationCase) error {
+	ids := map[string][]uint{}
+	for _, moderationCase := range cases {
+		ids[moderationCase.TargetType] = append(ids[moderationCase.TargetType], moderationCase.TargetID)
+	}
+
+	content := map[string]map[uint]interface{}{
+		ReportTargetRecipe:  {},
+		ReportTargetReview:  {},
+		ReportTargetComment: {},
+	}
+	if len(ids[ReportTargetRecipe]) > 0 {
+		var recipes []Recipe
+		if err := DB.Unscoped().Where("id IN ?", ids[ReportTargetRecipe]).Find(&recipes).Error; err != nil {
+			return err
+		}
+		for i := range recipes {
+			content[ReportTargetRecipe][recipes[i].ID] = &recipes[i]
+		}
+	}
+	if len(ids[ReportTargetReview]) > 0 {
+		var reviews []RecipeReview
+		if err := DB.Preload("Photos", reviewPhotos).Where("id IN ?", ids[ReportTargetReview]).Find(&reviews).Error; err != nil {
+			return err
+		}
+		for i := range reviews {
+			for j := range reviews[i].Photos {
+				if err := reviews[i].Photos[j].withURLs(true); err != nil {
+					return err
+				}
+			}
+			content[ReportTargetReview][reviews[i].ID] = &reviews[i]
+		}
+	}
+	if len(ids[ReportTargetComment]) > 0 {
+		var comments []Comment
+		if err := DB.Where("id IN ?", ids[ReportTargetComment]).Find(&comments).Error; err != nil {
+			return err
+		}
+		for i := range comments {
+			content[ReportTargetComment][comments[i].ID] = &comments[i]
+		}
+	}
+
+	for i := range cases {
+		if item, ok := content[cases[i].TargetType][cases[i].TargetID]; ok {
+			cases[i].Content = item
+		}
+	}
+	return nil
+}
+
+// GetModerationCases handles the GET /admin/moderation/cases endpoint. The
+// open queue lists content hidden by reports first, then the most reported.
+// The status query parameter lists resolved cases instead, most recently
+// resolved first, and target_type keeps only cases about one kind of
+// content. claimed=mine keeps the caller's claimed cases and
+// claimed=unclaimed those no one is working on.
+func GetModerationCases(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	status := c.DefaultQuery("status", CaseOpen)
+	if err := checkEnum("status", []string{status}, []string{CaseOpen, CaseResolved}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query := DB.Where("status = ?", status)
+	if status == CaseOpen {
+		query = query.Order("auto_hidden DESC, report_count DESC, id")
+	} else {
+		query = query.Order("resolved_at DESC, id DESC")
+	}
+
+	if targetType := c.Query("target_type"); targetType != "" {
+		if err := checkEnum("target_type", []string{targetType}, []string{ReportTargetRecipe, ReportTargetReview, ReportTargetComment}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Where("target_type = ?", targetType)
+	}
+	lapsed := time.Now().Add(-moderationClaimTimeout)
+	switch claimed := c.Query("claimed"); claimed {
+	case "":
+	case "mine":
+		query = query.Where("claimed_by_id = ? AND claimed_at > ?", userID, lapsed)
+	case "unclaimed":
+		query = query.Where("(claimed_by_id IS NULL OR claimed_at <= ?)", lapsed)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid claimed %q, expected mine or unclaimed", claimed)})
+		return
+	}
+
+	cases := []ModerationCase{}
+	if err := query.Preload("Reports", caseEntries).Limit(queryLimit(c, 50, 200)).Find(&cases).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cases"})
+		return
+	}
+	if err := attachCaseContent(cases); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cases"})
+		return
+	}
+	c.JSON(http.StatusOK, cases)
+}
+
+// GetModerationCase handles the GET /admin/moderation/cases/:id endpoint,
+// returning a case with its reports and audit trail.
+func GetModerationCase(c *gin.Context) {
+	var moderationCase ModerationCase
+	err := DB.Preload("Reports", caseEntries).Preload("Actions", caseEntries).
+		First(&moderationCase, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+		return
+	}
+	cases := []ModerationCase{moderationCase}
+	if err := attachCaseContent(cases); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve case"})
+		return
+	}
+	c.JSON(http.StatusOK, cases[0])
+}
+
+// changeModerationCase runs fn on an open case, locked, unless another
+// moderator's claim on it has not lapsed, and responds with the case.
+func changeModerationCase(c *gin.Context, failure string, fn func(tx *gorm.DB, moderationCase *ModerationCase) error) (ModerationCase, bool) {
+	userID, _ := currentUserID(c)
+	var moderationCase ModerationCase
+	if err := DB.First(&moderationCase, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Case not found"})
+		return moderationCase, false
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&moderationCase, moderationCase.ID).Error; err != nil {
+			return err
+		}
+		if moderationCase.Status != CaseOpen {
+			return errCaseResolved
+		}
+		if moderationCase.ClaimedByID != nil && *moderationCase.ClaimedByID != userID &&
+			time.Since(*moderationCase.ClaimedAt) < moderationClaimTimeout {
+			return errCaseClaimed
+		}
+		return fn(tx, &moderationCase)
+	})
+	switch {
+	case errors.Is(err, errCaseResolved), errors.Is(err, errCaseClaimed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return moderationCase, false
+	case err != nil:
+		log.Printf("Failed to change moderation case %d: %v", moderationCase.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failure})
+		return moderationCase, false
+	}
+	c.JSON(http.StatusOK, moderationCase)
+	return moderationCase, true
+}
+
+// ClaimModerationCase handles the POST /admin/moderation/cases/:id/claim
+// endpoint, marking the case as being worked on by the caller.
+func ClaimModerationCase(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	changeModerationCase(c, "Failed to claim case", func(tx *gorm.DB, moderationCase *ModerationCase) error {
+		renewed := moderationCase.ClaimedByID != nil && *moderationCase.ClaimedByID == userID
+		now := time.Now()
+		moderationCase.ClaimedByID, moderationCase.ClaimedAt = &userID, &now
+		if err := tx.Model(moderationCase).Select("claimed_by_id", "claimed_at").Updates(moderationCase).Error; err != nil {
+			return err
+		}
+		if renewed {
+			return nil
+		}
+		return recordModeration(tx, moderationCase.ID, &userID, ModerationClaim, "")
+	})
+}
+
+// ReleaseModerationCase handles the DELETE /admin/moderation/cases/:id/claim
+// endpoint, returning a case the caller claimed to the queue.
+func ReleaseModerationCase(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	changeModerationCase(c, "Failed to release case", func(tx *gorm.DB, moderationCase *ModerationCase) error {
+		if moderationCase.ClaimedByID == nil {
+			return nil
+		}
+		moderationCase.ClaimedByID, moderationCase.ClaimedAt = nil, nil
+		if err := tx.Model(moderationCase).Select("claimed_by_id", "claimed_at").Updates(moderationCase).Error; err != nil {
+			return err
+		}
+		return recordModeration(tx, moderationCase.ID, &userID, ModerationRelease, "")
+	})
+}
+
+// ResolveModerationCase handles the POST /admin/moderation/cases/:id/resolve
+// endpoint. The action hides or deletes the reported content, dismisses
+// the reports, unhiding content hidden by them, or suspends the content's
+// author for the given number of days and hides the content.
+func ResolveModerationCase(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var input struct {
+		Action string `json:"action" binding:"required"`
+		Note   string `json:"note" binding:"max=2000"`
+		Days   int    `json:"days" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkEnum("action", []string{input.Action}, moderationResolutions); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Days == 0 {
+		input.Days = defaultSuspensionDays
+	}
+	if input.Days > maxSuspensionDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Suspensions are limited to %d days", maxSuspensionDays)})
+		return
+	}
+
+	var photos []RecipeImage
+	_, ok := changeModerationCase(c, "Failed to resolve case", func(tx *gorm.DB, moderationCase *ModerationCase) error {
+		target := moderationCase.target()
+		note := strings.TrimSpace(input.Note)
+		if change, hidden := resolutionVisibility(input.Action, moderationCase.AutoHidden); change {
+			if _, err := setTargetHidden(tx, target, hidden); err != nil {
+				return err
+			}
+		}
+		var err error
+		switch input.Action {
+		case ModerationDelete:
+			photos, err = deleteTarget(tx, target)
+		case ModerationSuspend:
+			until := time.Now().AddDate(0, 0, input.Days)
+			err = suspendUser(tx, target.UserID, until)
+			note = strings.TrimSpace(fmt.Sprintf("Suspended until %s. %s", until.UTC().Format(time.RFC3339), note))
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		moderationCase.Status, moderationCase.Resolution = CaseResolved, input.Action
+		moderationCase.ResolvedByID, moderationCase.ResolvedAt = &userID, &now
+		err = tx.Model(moderationCase).Select("status", "resolution", "resolved_by_id", "resolved_at").Updates(moderationCase).Error
+		if err != nil {
+			return err
+		}
+		return recordModeration(tx, moderationCase.ID, &userID, input.Action, note)
+	})
+	if ok {
+		for _, photo := range photos {
+			deleteImageBlobs(c.Request.Context(), photo)
+		}
+	}
+}
+
+// GetModerationActions handles the GET /admin/moderation/actions endpoint,
+// the audit trail of moderation, newest first. The moderator_id and case_id
+// query parameters keep only the actions of one moderator or on one case.
+// Pass next_cursor back as cursor for the following page.
+func GetModerationActions(c *gin.Context) {
+	limit := queryLimit(c, 50, 200)
+	query := DB.Model(&ModerationAction{})
+	for _, filter := range []string{"moderator_id", "case_id"} {
+		if value := c.Query(filter); value != "" {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s", filter)})
+				return
+			}
+			query = query.Where(filter+" = ?", id)
+		}
+	}
+	cursor, err := queryCursor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+
+	actions := []ModerationAction{}
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve moderation actions"})
+		return
+	}
+	var next *uint
+	if len(actions) > limit {
+		actions = actions[:limit]
+		next = &actions[limit-1].ID
+	}
+	c.JSON(http.StatusOK, gin.H{"actions": actions, "next_cursor": next})
+}
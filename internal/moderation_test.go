@@ -0,0 +1,183 @@
+// moderation_test.go
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestModerationCaseTarget verifies a case acts on the content it is about.
+func TestModerationCaseTarget(t *testing.T) {
+	moderationCase := ModerationCase{TargetType: ReportTargetReview, TargetID: 7, TargetUserID: 3, RecipeID: 12}
+	assert.Equal(t, moderationTarget{Type: ReportTargetReview, ID: 7, UserID: 3, RecipeID: 12}, moderationCase.target())
+
+	assert.IsType(t, &Recipe{}, targetModel(ReportTargetRecipe))
+	assert.IsType(t, &RecipeReview{}, targetModel(ReportTargetReview))
+	assert.IsType(t, &Comment{}, targetModel(ReportTargetComment))
+}
+
+// TestReportOwnContent verifies users cannot report what they wrote.
+func TestReportOwnContent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/recipes/1/report", strings.NewReader(`{"reason":"spam"}`))
+	c.Set("userID", uint(4))
+
+	reportContent(c, moderationTarget{Type: ReportTargetRecipe, ID: 1, UserID: 4, RecipeID: 1})
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+// TestSuspensionMiddlewareAnonymous verifies anonymous requests are left
+// for the handler to deal with.
+func TestSuspensionMiddlewareAnonymous(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/recipes", SuspensionMiddleware(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/recipes", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+// TestReachedHideThreshold verifies content is hidden automatically once,
+// when the reports of different users reach the threshold.
+func TestReachedHideThreshold(t *testing.T) {
+	assert.False(t, ModerationCase{ReportCount: reportHideThreshold - 1}.reachedHideThreshold())
+	assert.True(t, ModerationCase{ReportCount: reportHideThreshold}.reachedHideThreshold())
+	assert.True(t, ModerationCase{ReportCount: reportHideThreshold + 1}.reachedHideThreshold())
+	assert.False(t, ModerationCase{ReportCount: reportHideThreshold + 1, AutoHidden: true}.reachedHideThreshold())
+}
+
+// TestResolutionVisibility verifies which resolutions hide or unhide the
+// reported content.
+func TestResolutionVisibility(t *testing.T) {
+	tests := []struct {
+		action         string
+		autoHidden     bool
+		change, hidden bool
+	}{
+		{ModerationHide, false, true, true},
+		{ModerationSuspend, false, true, true},
+		{ModerationSuspend, true, true, true},
+		{ModerationDismiss, true, true, false},
+		{ModerationDismiss, false, false, false},
+		{ModerationDelete, true, false, false},
+	}
+	for _, tt := range tests {
+		change, hidden := resolutionVisibility(tt.action, tt.autoHidden)
+		assert.Equal(t, tt.change, change, "%s auto_hidden=%v", tt.action, tt.autoHidden)
+		assert.Equal(t, tt.hidden, hidden, "%s auto_hidden=%v", tt.action, tt.autoHidden)
+	}
+}
+
+// TestSuspendUserKeepsLongerSuspension verifies a suspension never
+// shortens one the user is already under.
+func TestSuspendUserKeepsLongerSuspension(t *testing.T) {
+	db := DryRunDB(t)
+	var sql string
+	var vars []interface{}
+	db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		sql, vars = tx.Statement.SQL.String(), tx.Statement.Vars
+	})
+
+	until := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, suspendUser(db, 9, until))
+	assert.Equal(t, `UPDATE "users" SET "suspended_until"=GREATEST(suspended_until, $1) WHERE id = $2 AND "users"."deleted_at" IS NULL`, sql)
+	assert.Equal(t, []interface{}{until, uint(9)}, vars)
+
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+	assert.True(t, User{SuspendedUntil: &later}.suspendedAt(now))
+	assert.False(t, User{SuspendedUntil: &earlier}.suspendedAt(now))
+	assert.False(t, User{}.suspendedAt(now))
+}
+
+// TestSuspendedUsersCannotPost verifies suspended users are refused on
+// every route that creates or changes content other users can see.
+func TestSuspendedUsersCannotPost(t *testing.T) {
+	saved := DB
+	defer func() { DB = saved }()
+	DB = DryRunDB(t)
+	DB.Callback().Query().After("gorm:query").Register("test:suspended", func(tx *gorm.DB) {
+		if user, ok := tx.Statement.Dest.(*User); ok {
+			until := time.Now().Add(time.Hour)
+			user.SuspendedUntil = &until
+		}
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupRoutes(router)
+
+	routes := []struct{ method, path string }{
+		{http.MethodPost, "/recipes"},
+		{http.MethodPut, "/recipes/1"},
+		{http.MethodPost, "/recipes/import"},
+		{http.MethodPost, "/recipes/drafts"},
+		{http.MethodPatch, "/recipes/1/draft"},
+		{http.MethodPost, "/recipes/1/publish"},
+		{http.MethodPost, "/recipes/1/tags"},
+		{http.MethodPut, "/recipes/1/nutrition/overrides"},
+		{http.MethodPost, "/recipes/1/revisions/2/restore"},
+		{http.MethodPost, "/recipes/1/fork"},
+		{http.MethodPost, "/recipes/1/share"},
+		{http.MethodPut, "/recipes/1/image"},
+		{http.MethodPost, "/recipes/1/steps/1/photos"},
+		{http.MethodPut, "/recipes/1/review"},
+		{http.MethodPost, "/recipes/1/review/photos"},
+		{http.MethodPost, "/recipes/1/comments"},
+		{http.MethodPatch, "/recipes/1/comments/2"},
+		{http.MethodPost, "/recipes/1/report"},
+		{http.MethodPost, "/recipes/1/reviews/2/report"},
+		{http.MethodPost, "/recipes/1/comments/2/report"},
+		{http.MethodPost, "/me/import"},
+		{http.MethodPost, "/me/collections"},
+		{http.MethodPut, "/me/collections/1"},
+		{http.MethodPost, "/me/collections/1/recipes"},
+		{http.MethodPost, "/ingredients"},
+		{http.MethodPut, "/ingredients/1"},
+		{http.MethodDelete, "/ingredients/1"},
+	}
+	for _, route := range routes {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(route.method, route.path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+generateTestJWT(5))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, "%s %s", route.method, route.path)
+
+		// Leaving out the token must not get around the suspension.
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(route.method, route.path, strings.NewReader(`{}`)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "anonymous %s %s", route.method, route.path)
+	}
+}
+
+// TestDeleteTargetMarksRemovedRecipes verifies a recipe deleted by a
+// moderator is marked as removed before it is deleted.
+func TestDeleteTargetMarksRemovedRecipes(t *testing.T) {
+	db := DryRunDB(t)
+	var statements []string
+	capture := func(tx *gorm.DB) {
+		statements = append(statements, tx.Statement.SQL.String())
+	}
+	db.Callback().Update().After("gorm:update").Register("test:capture", capture)
+	db.Callback().Delete().After("gorm:delete").Register("test:capture", capture)
+
+	photos, err := deleteTarget(db, moderationTarget{Type: ReportTargetRecipe, ID: 7, UserID: 3, RecipeID: 7})
+	assert.NoError(t, err)
+	assert.Empty(t, photos)
+	assert.Equal(t, []string{
+		`UPDATE "recipes" SET "removed_at"=$1 WHERE id = $2 AND "recipes"."deleted_at" IS NULL`,
+		`UPDATE "recipes" SET "deleted_at"=$1 WHERE "recipes"."id" = $2 AND "recipes"."deleted_at" IS NULL`,
+	}, statements)
+}
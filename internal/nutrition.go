@@ -0,0 +1,426 @@
+// nutrition.go
+package internal
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// minFoodMatchScore is the lowest name similarity accepted as a food match.
+const minFoodMatchScore = 0.35
+
+// IngredientNutrition is the per-ingredient breakdown of a nutrition calculation.
+type IngredientNutrition struct {
+	Ingredient     string  `json:"ingredient"`
+	Quantity       string  `json:"quantity"`
+	FoodID         uint    `json:"food_id,omitempty"`
+	FoodName       string  `json:"food_name,omitempty"`
+	Grams          float64 `json:"grams"`
+	Confidence     float64 `json:"confidence"`
+	Overridden     bool    `json:"overridden"`
+	Excluded       bool    `json:"excluded"`
+	Note           string  `json:"note,omitempty"`
+	NutritionFacts `json:"nutrition"`
+}
+
+// FoodSource looks up foods in the nutrient database.
+type FoodSource interface {
+	// FoodsMatching returns candidate foods for an ingredient name.
+	FoodsMatching(name string) ([]Food, error)
+	// FoodByID returns a specific food, used by manual overrides.
+	FoodByID(id uint) (Food, error)
+}
+
+// dbFoodSource is the FoodSource backed by the foods table.
+type dbFoodSource struct {
+	db *gorm.DB
+}
+
+// FoodsMatching implements FoodSource by searching descriptions for the
+// ingredient's most specific word.
+func (s dbFoodSource) FoodsMatching(name string) ([]Food, error) {
+	tokens := nutritionTokens(name)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	longest := tokens[0]
+	for _, token := range tokens {
+		if len(token) > len(longest) {
+			longest = token
+		}
+	}
+	var foods []Food
+	err := s.db.Where("description ILIKE ?", "%"+longest+"%").Limit(100).Find(&foods).Error
+	return foods, err
+}
+
+// FoodByID implements FoodSource.
+func (s dbFoodSource) FoodByID(id uint) (Food, error) {
+	var food Food
+	err := s.db.First(&food, id).Error
+	return food, err
+}
+
+// nutritionStopWords are ignored when comparing ingredient and food names.
+var nutritionStopWords = map[string]bool{"and": true, "or": true, "with": true, "without": true, "of": true, "the": true, "a": true, "in": true}
+
+// nutritionTokens splits a name into singular, lower-case words.
+func nutritionTokens(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	seen := make(map[string]bool, len(fields))
+	var tokens []string
+	for _, field := range fields {
+		field = singularize(field)
+		if nutritionStopWords[field] || seen[field] {
+			continue
+		}
+		seen[field] = true
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+// FoodMatchScore rates how well a food description matches an ingredient
+// name from 0 to 1. Covering every ingredient word matters most; extra
+// words in the description ("whole", "raw") lower the score slightly.
+func FoodMatchScore(ingredient, description string) float64 {
+	ingredientTokens := nutritionTokens(NormalizeIngredientName(ingredient))
+	foodTokens := nutritionTokens(description)
+	if len(ingredientTokens) == 0 || len(foodTokens) == 0 {
+		return 0
+	}
+	foodSet := make(map[string]bool, len(foodTokens))
+	for _, token := range foodTokens {
+		foodSet[token] = true
+	}
+	shared := 0
+	for _, token := range ingredientTokens {
+		if foodSet[token] {
+			shared++
+		}
+	}
+	coverage := float64(shared) / float64(len(ingredientTokens))
+	precision := float64(shared) / float64(len(foodTokens))
+	return coverage * (0.6 + 0.4*precision)
+}
+
+// bestFood returns the highest scoring candidate for an ingredient.
+func bestFood(ingredient string, candidates []Food) (Food, float64) {
+	var best Food
+	bestScore := 0.0
+	for _, food := range candidates {
+		score := FoodMatchScore(ingredient, food.Description)
+		if score > bestScore || (score == bestScore && len(food.Description) < len(best.Description)) {
+			best, bestScore = food, score
+		}
+	}
+	return best, bestScore
+}
+
+// ingredientGrams converts a quantity of a food to grams. The returned factor
+// scales confidence down for estimated conversions.
+func ingredientGrams(quantity Quantity, food Food) (float64, float64, string) {
+	switch quantity.Dimension() {
+	case DimensionMass:
+		return quantity.Base(), 1, ""
+	case DimensionVolume:
+		if food.GramsPerMl > 0 {
+			return quantity.Base() * food.GramsPerMl, 0.95, ""
+		}
+		return quantity.Base(), 0.6, "density unknown, assumed 1 g/ml"
+	}
+	if quantity.Amount <= 0 {
+		return 0, 0, "no quantity"
+	}
+	if quantity.Unit == "" && food.GramsPerUnit > 0 {
+		return quantity.Amount * food.GramsPerUnit, 0.9, ""
+	}
+	return 0, 0, "cannot convert " + quantity.String() + " to grams"
+}
+
+// scaleFacts returns per-100 g facts scaled to the given weight.
+func scaleFacts(per100g NutritionFacts, grams float64) NutritionFacts {
+	f := grams / 100
+	return NutritionFacts{
+		Calories: per100g.Calories * f,
+		ProteinG: per100g.ProteinG * f,
+		FatG:     per100g.FatG * f,
+		CarbsG:   per100g.CarbsG * f,
+		FiberG:   per100g.FiberG * f,
+		SugarG:   per100g.SugarG * f,
+		SodiumMg: per100g.SodiumMg * f,
+	}
+}
+
+// add returns the sum of two sets of facts.
+func (n NutritionFacts) add(other NutritionFacts) NutritionFacts {
+	return NutritionFacts{
+		Calories: n.Calories + other.Calories,
+		ProteinG: n.ProteinG + other.ProteinG,
+		FatG:     n.FatG + other.FatG,
+		CarbsG:   n.CarbsG + other.CarbsG,
+		FiberG:   n.FiberG + other.FiberG,
+		SugarG:   n.SugarG + other.SugarG,
+		SodiumMg: n.SodiumMg + other.SodiumMg,
+	}
+}
+
+// multiply returns the facts scaled by a factor, rounded to one decimal.
+func (n NutritionFacts) multiply(by float64) NutritionFacts {
+	return n.divide(1 / by)
+}
+
+// divide returns the facts divided by n, rounded to one decimal.
+func (n NutritionFacts) divide(by float64) NutritionFacts {
+	round := func(v float64) float64 { return math.Round(v/by*10) / 10 }
+	return NutritionFacts{
+		Calories: round(n.Calories),
+		ProteinG: round(n.ProteinG),
+		FatG:     round(n.FatG),
+		CarbsG:   round(n.CarbsG),
+		FiberG:   round(n.FiberG),
+		SugarG:   round(n.SugarG),
+		SodiumMg: round(n.SodiumMg),
+	}
+}
+
+// CalculateNutrition derives recipe nutrition from its ingredients. Each
+// ingredient is matched to the best food by name unless an override (keyed
+// by normalized ingredient name) pins the food, weight or excludes it.
+func CalculateNutrition(needs []ShoppingNeed, servings int, source FoodSource, overrides map[string]NutritionOverride) (RecipeNutrition, error) {
+	if servings < 1 {
+		servings = 1
+	}
+	result := RecipeNutrition{Servings: servings, Breakdown: []IngredientNutrition{}}
+	confidenceSum := 0.0
+
+	for _, need := range needs {
+		entry := IngredientNutrition{Ingredient: need.Name, Quantity: need.Quantity.String()}
+		override, hasOverride := overrides[NormalizeIngredientName(need.Name)]
+		if hasOverride && override.Exclude {
+			entry.Excluded, entry.Overridden, entry.Confidence = true, true, 1
+			result.Breakdown = append(result.Breakdown, entry)
+			confidenceSum += entry.Confidence
+			continue
+		}
+
+		var food Food
+		score := 0.0
+		if hasOverride && override.FoodID != nil {
+			var err error
+			if food, err = source.FoodByID(*override.FoodID); err != nil {
+				return result, err
+			}
+			score, entry.Overridden = 1, true
+		} else {
+			candidates, err := source.FoodsMatching(need.Name)
+			if err != nil {
+				return result, err
+			}
+			food, score = bestFood(need.Name, candidates)
+		}
+		if score < minFoodMatchScore {
+			entry.Note = "no matching food"
+			result.Breakdown = append(result.Breakdown, entry)
+			continue
+		}
+		entry.FoodID, entry.FoodName = food.ID, food.Description
+
+		grams, factor, note := ingredientGrams(need.Quantity, food)
+		if hasOverride && override.Grams != nil {
+			grams, factor, note, entry.Overridden = *override.Grams, 1, "", true
+		}
+		entry.Grams = math.Round(grams*10) / 10
+		entry.Confidence = math.Round(score*factor*100) / 100
+		entry.Note = note
+		entry.NutritionFacts = scaleFacts(food.NutritionFacts, grams)
+		result.Total = result.Total.add(entry.NutritionFacts)
+		result.Breakdown = append(result.Breakdown, entry)
+		confidenceSum += entry.Confidence
+	}
+
+	if len(needs) > 0 {
+		result.Confidence = math.Round(confidenceSum/float64(len(needs))*100) / 100
+	}
+	result.PerServing = result.Total.divide(float64(servings))
+	result.Total = result.Total.divide(1)
+	result.ComputedAt = time.Now()
+	return result, nil
+}
+
+// refreshRecipeNutrition recomputes and stores a recipe's nutrition. Unless
+// the user typed the calories themselves, Recipe.Calories is updated to the
+// computed per-serving value.
+func refreshRecipeNutrition(db *gorm.DB, recipeID uint) error {
+	var recipe Recipe
+	if err := db.First(&recipe, recipeID).Error; err != nil {
+		return err
+	}
+	needsByRecipe, err := recipeIngredientNeeds(DB, []uint{recipe.ID})
+	if err != nil {
+		return err
+	}
+	var overrideRows []NutritionOverride
+	if err := db.Where("recipe_id = ?", recipe.ID).Find(&overrideRows).Error; err != nil {
+		return err
+	}
+	overrides := make(map[string]NutritionOverride, len(overrideRows))
+	for _, override := range overrideRows {
+		overrides[override.IngredientName] = override
+	}
+
+	nutrition, err := CalculateNutrition(needsByRecipe[recipe.ID], recipe.Servings, dbFoodSource{db: db}, overrides)
+	if err != nil {
+		return err
+	}
+	nutrition.RecipeID = recipe.ID
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "recipe_id"}},
+			UpdateAll: true,
+		}).Create(&nutrition).Error
+		if err != nil {
+			return err
+		}
+		if recipe.CaloriesManual {
+			return nil
+		}
+		return tx.Model(&recipe).UpdateColumn("calories", int(math.Round(nutrition.PerServing.Calories))).Error
+	})
+}
+
+// refreshRecipeNutritionLogged logs rather than fails when nutrition cannot be
+// recomputed, so recipe edits never fail because of the nutrient database.
+func refreshRecipeNutritionLogged(recipeID uint) {
+	if err := refreshRecipeNutrition(DB, recipeID); err != nil {
+		log.Printf("Failed to compute nutrition for recipe %d: %v", recipeID, err)
+	}
+}
+
+// GetRecipeNutrition handles the GET /recipes/:id/nutrition endpoint.
+func GetRecipeNutrition(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	// Compute on first request for recipes created before nutrition tracking.
+	var nutrition RecipeNutrition
+	if err := DB.Where("recipe_id = ?", recipe.ID).First(&nutrition).Error; err != nil {
+		if err := refreshRecipeNutrition(DB, recipe.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute nutrition"})
+			return
+		}
+		if err := DB.Where("recipe_id = ?", recipe.ID).First(&nutrition).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve nutrition"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, nutrition)
+}
+
+// PutNutritionOverrides handles the PUT /recipes/:id/nutrition/overrides endpoint.
+// The payload replaces all overrides for the recipe and the nutrition is
+// recomputed.
+func PutNutritionOverrides(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if recipe.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own recipes"})
+		return
+	}
+
+	var input struct {
+		Overrides []struct {
+			Ingredient string   `json:"ingredient" binding:"required"`
+			FoodID     *uint    `json:"food_id"`
+			Grams      *float64 `json:"grams" binding:"omitempty,min=0"`
+			Exclude    bool     `json:"exclude"`
+		} `json:"overrides" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	overrides := make([]NutritionOverride, 0, len(input.Overrides))
+	for _, o := range input.Overrides {
+		overrides = append(overrides, NutritionOverride{
+			RecipeID:       recipe.ID,
+			IngredientName: NormalizeIngredientName(o.Ingredient),
+			FoodID:         o.FoodID,
+			Grams:          o.Grams,
+			Exclude:        o.Exclude,
+		})
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("recipe_id = ?", recipe.ID).Delete(&NutritionOverride{}).Error; err != nil {
+			return err
+		}
+		if len(overrides) == 0 {
+			return nil
+		}
+		return tx.Create(&overrides).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save nutrition overrides"})
+		return
+	}
+
+	if err := refreshRecipeNutrition(DB, recipe.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute nutrition"})
+		return
+	}
+	var nutrition RecipeNutrition
+	if err := DB.Where("recipe_id = ?", recipe.ID).First(&nutrition).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve nutrition"})
+		return
+	}
+	c.JSON(http.StatusOK, nutrition)
+}
+
+// SearchFoods handles the GET /foods endpoint, returning the best nutrient
+// database matches for the q parameter (used to pick override foods).
+func SearchFoods(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	candidates, err := dbFoodSource{db: DB}.FoodsMatching(q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search foods"})
+		return
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return FoodMatchScore(q, candidates[i].Description) > FoodMatchScore(q, candidates[j].Description)
+	})
+	if len(candidates) > 20 {
+		candidates = candidates[:20]
+	}
+	c.JSON(http.StatusOK, candidates)
+}
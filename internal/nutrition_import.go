@@ -0,0 +1,228 @@
+// nutrition_import.go
+package internal
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FoodData Central nutrient IDs for the facts we track.
+const (
+	fdcNutrientEnergy  = 1008
+	fdcNutrientProtein = 1003
+	fdcNutrientFat     = 1004
+	fdcNutrientCarbs   = 1005
+	fdcNutrientFiber   = 1079
+	fdcNutrientSugar   = 2000
+	fdcNutrientSodium  = 1093
+)
+
+// csvTable reads a CSV file with a header row into a column-name lookup.
+type csvTable struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// newCSVTable reads the header of a CSV file.
+func newCSVTable(r io.Reader) (*csvTable, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	return &csvTable{reader: reader, columns: columns}, nil
+}
+
+// each calls fn with a field accessor for every data row.
+func (t *csvTable) each(fn func(field func(string) string) error) error {
+	for {
+		row, err := t.reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		field := func(name string) string {
+			if i, ok := t.columns[name]; ok && i < len(row) {
+				return row[i]
+			}
+			return ""
+		}
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+}
+
+// FoodDataCentralFiles holds the FoodData Central CSV files used by
+// ReadFoodDataCentral. Portion and measure unit files are optional and
+// provide volume densities and whole-item weights.
+type FoodDataCentralFiles struct {
+	Food         io.Reader
+	FoodNutrient io.Reader
+	FoodPortion  io.Reader
+	MeasureUnit  io.Reader
+}
+
+// ReadFoodDataCentral builds Food rows from USDA FoodData Central style CSV
+// files (food.csv, food_nutrient.csv and optionally food_portion.csv and
+// measure_unit.csv).
+func ReadFoodDataCentral(files FoodDataCentralFiles) ([]Food, error) {
+	foods := make(map[int]*Food)
+	var order []int
+
+	table, err := newCSVTable(files.Food)
+	if err != nil {
+		return nil, fmt.Errorf("food.csv: %w", err)
+	}
+	err = table.each(func(field func(string) string) error {
+		id, err := strconv.Atoi(field("fdc_id"))
+		if err != nil {
+			return nil
+		}
+		foods[id] = &Food{FDCID: id, Description: field("description")}
+		order = append(order, id)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("food.csv: %w", err)
+	}
+
+	table, err = newCSVTable(files.FoodNutrient)
+	if err != nil {
+		return nil, fmt.Errorf("food_nutrient.csv: %w", err)
+	}
+	err = table.each(func(field func(string) string) error {
+		id, err := strconv.Atoi(field("fdc_id"))
+		if err != nil {
+			return nil
+		}
+		food, ok := foods[id]
+		if !ok {
+			return nil
+		}
+		nutrient, _ := strconv.Atoi(field("nutrient_id"))
+		amount, err := strconv.ParseFloat(field("amount"), 64)
+		if err != nil {
+			return nil
+		}
+		switch nutrient {
+		case fdcNutrientEnergy:
+			food.Calories = amount
+		case fdcNutrientProtein:
+			food.ProteinG = amount
+		case fdcNutrientFat:
+			food.FatG = amount
+		case fdcNutrientCarbs:
+			food.CarbsG = amount
+		case fdcNutrientFiber:
+			food.FiberG = amount
+		case fdcNutrientSugar:
+			food.SugarG = amount
+		case fdcNutrientSodium:
+			food.SodiumMg = amount
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("food_nutrient.csv: %w", err)
+	}
+
+	if files.FoodPortion != nil {
+		if err := readFoodPortions(files, foods); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]Food, 0, len(order))
+	for _, id := range order {
+		result = append(result, *foods[id])
+	}
+	return result, nil
+}
+
+// readFoodPortions derives grams per ml from volume portions ("1 cup = 120 g")
+// and grams per unit from whole-item portions ("1 large = 50 g").
+func readFoodPortions(files FoodDataCentralFiles, foods map[int]*Food) error {
+	measureUnits := make(map[string]string)
+	if files.MeasureUnit != nil {
+		table, err := newCSVTable(files.MeasureUnit)
+		if err != nil {
+			return fmt.Errorf("measure_unit.csv: %w", err)
+		}
+		err = table.each(func(field func(string) string) error {
+			measureUnits[field("id")] = field("name")
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("measure_unit.csv: %w", err)
+		}
+	}
+
+	table, err := newCSVTable(files.FoodPortion)
+	if err != nil {
+		return fmt.Errorf("food_portion.csv: %w", err)
+	}
+	err = table.each(func(field func(string) string) error {
+		id, err := strconv.Atoi(field("fdc_id"))
+		if err != nil {
+			return nil
+		}
+		food, ok := foods[id]
+		if !ok {
+			return nil
+		}
+		grams, err := strconv.ParseFloat(field("gram_weight"), 64)
+		if err != nil || grams <= 0 {
+			return nil
+		}
+		amount, err := strconv.ParseFloat(field("amount"), 64)
+		if err != nil || amount <= 0 {
+			amount = 1
+		}
+
+		unit := measureUnits[field("measure_unit_id")]
+		if unit == "" || unit == "undetermined" {
+			unit = field("modifier")
+		}
+		quantity := Quantity{Amount: amount, Unit: CanonicalUnit(unit)}
+		switch {
+		case quantity.Dimension() == DimensionVolume && food.GramsPerMl == 0:
+			food.GramsPerMl = grams / quantity.Base()
+		case quantity.Unit == "" && food.GramsPerUnit == 0:
+			food.GramsPerUnit = grams / amount
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("food_portion.csv: %w", err)
+	}
+	return nil
+}
+
+// ImportFoods upserts foods into the nutrient database by FDC ID in batches.
+func ImportFoods(db *gorm.DB, foods []Food) error {
+	if len(foods) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "fdc_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"description", "calories", "protein_g", "fat_g", "carbs_g", "fiber_g", "sugar_g", "sodium_mg",
+			"grams_per_ml", "grams_per_unit", "updated_at",
+		}),
+	}).CreateInBatches(foods, 500).Error
+}
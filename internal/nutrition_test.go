@@ -0,0 +1,103 @@
+// nutrition_test.go
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryFoodSource is an in-memory FoodSource for tests.
+type memoryFoodSource []Food
+
+func (s memoryFoodSource) FoodsMatching(name string) ([]Food, error) {
+	return s, nil
+}
+
+func (s memoryFoodSource) FoodByID(id uint) (Food, error) {
+	for _, food := range s {
+		if food.ID == id {
+			return food, nil
+		}
+	}
+	return Food{}, errors.New("food not found")
+}
+
+// TestFoodMatchScore verifies exact matches outrank looser descriptions.
+func TestFoodMatchScore(t *testing.T) {
+	exact := FoodMatchScore("Flour (sifted)", "Flour")
+	loose := FoodMatchScore("flour", "Wheat flour, white, all-purpose")
+	assert.InDelta(t, 1.0, exact, 0.001)
+	assert.Greater(t, exact, loose)
+	assert.Greater(t, loose, minFoodMatchScore)
+	assert.Equal(t, 0.0, FoodMatchScore("butter", "Sugars, granulated"))
+}
+
+// TestCalculateNutrition verifies totals, per-serving values, conversions and overrides.
+func TestCalculateNutrition(t *testing.T) {
+	source := memoryFoodSource{
+		{ID: 1, Description: "Sugars, granulated", NutritionFacts: NutritionFacts{Calories: 400, CarbsG: 100}, GramsPerMl: 0.85},
+		{ID: 2, Description: "Egg, whole, raw", NutritionFacts: NutritionFacts{Calories: 140, ProteinG: 12}, GramsPerUnit: 50},
+		{ID: 3, Description: "Butter, salted", NutritionFacts: NutritionFacts{Calories: 700, FatG: 80, SodiumMg: 600}},
+		{ID: 4, Description: "Salt, table", NutritionFacts: NutritionFacts{SodiumMg: 38000}},
+	}
+	needs := []ShoppingNeed{
+		{Name: "sugar", Quantity: Quantity{Amount: 100, Unit: "g"}},
+		{Name: "eggs", Quantity: Quantity{Amount: 2}},
+		{Name: "butter", Quantity: Quantity{Amount: 1, Unit: "tbsp"}},
+		{Name: "salt", Quantity: Quantity{Amount: 1, Unit: "pinch"}},
+		{Name: "saffron", Quantity: Quantity{Amount: 1, Unit: "g"}},
+	}
+
+	grams := 10.0
+	overrides := map[string]NutritionOverride{
+		"butter": {IngredientName: "butter", Grams: &grams},
+		"salt":   {IngredientName: "salt", Exclude: true},
+	}
+	result, err := CalculateNutrition(needs, 2, source, overrides)
+	assert.NoError(t, err)
+	assert.Len(t, result.Breakdown, 5)
+
+	// 100 g sugar + 2 eggs at 50 g + 10 g butter.
+	assert.InDelta(t, 400+140+70, result.Total.Calories, 0.01)
+	assert.InDelta(t, 12, result.Total.ProteinG, 0.01)
+	assert.InDelta(t, 305, result.PerServing.Calories, 0.01)
+
+	assert.Equal(t, uint(2), result.Breakdown[1].FoodID)
+	assert.InDelta(t, 100, result.Breakdown[1].Grams, 0.01)
+	assert.True(t, result.Breakdown[2].Overridden)
+	assert.True(t, result.Breakdown[3].Excluded)
+	assert.Equal(t, uint(0), result.Breakdown[4].FoodID)
+	assert.Equal(t, "no matching food", result.Breakdown[4].Note)
+	assert.Less(t, result.Confidence, 1.0)
+}
+
+// TestReadFoodDataCentral verifies nutrients and portion weights are read from FDC CSVs.
+func TestReadFoodDataCentral(t *testing.T) {
+	food := "\"fdc_id\",\"data_type\",\"description\"\n\"100\",\"sr_legacy_food\",\"Egg, whole, raw\"\n\"200\",\"sr_legacy_food\",\"Milk, whole\"\n"
+	nutrients := "\"id\",\"fdc_id\",\"nutrient_id\",\"amount\"\n" +
+		"\"1\",\"100\",\"1008\",\"143\"\n\"2\",\"100\",\"1003\",\"12.6\"\n\"3\",\"100\",\"1093\",\"142\"\n" +
+		"\"4\",\"200\",\"1008\",\"61\"\n\"5\",\"999\",\"1008\",\"1\"\n"
+	portions := "\"id\",\"fdc_id\",\"amount\",\"measure_unit_id\",\"modifier\",\"gram_weight\"\n" +
+		"\"1\",\"100\",\"1\",\"9999\",\"large\",\"50\"\n\"2\",\"200\",\"1\",\"1000\",\"\",\"244\"\n"
+	measureUnits := "\"id\",\"name\"\n\"1000\",\"cup\"\n\"9999\",\"undetermined\"\n"
+
+	foods, err := ReadFoodDataCentral(FoodDataCentralFiles{
+		Food:         strings.NewReader(food),
+		FoodNutrient: strings.NewReader(nutrients),
+		FoodPortion:  strings.NewReader(portions),
+		MeasureUnit:  strings.NewReader(measureUnits),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, foods, 2)
+
+	assert.Equal(t, 100, foods[0].FDCID)
+	assert.Equal(t, 143.0, foods[0].Calories)
+	assert.Equal(t, 12.6, foods[0].ProteinG)
+	assert.Equal(t, 142.0, foods[0].SodiumMg)
+	assert.Equal(t, 50.0, foods[0].GramsPerUnit)
+	assert.Equal(t, 61.0, foods[1].Calories)
+	assert.InDelta(t, 1.03, foods[1].GramsPerMl, 0.01)
+}
@@ -0,0 +1,451 @@
+// pantry.go
+package internal
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultExpiringDays is the look-ahead window for "expiring soon" queries.
+const defaultExpiringDays = 3
+
+// PantryRecipeSuggestion is a recipe ranked by how many soon-to-expire
+// pantry items it would use up.
+type PantryRecipeSuggestion struct {
+	Recipe       Recipe   `json:"recipe"`
+	MatchedItems []string `json:"matched_items"`
+	Score        float64  `json:"score"`
+}
+
+// pantryStock converts pantry items into stock for shopping list consolidation.
+func pantryStock(items []PantryItem) []ShoppingNeed {
+	stock := make([]ShoppingNeed, 0, len(items))
+	for _, item := range items {
+		stock = append(stock, ShoppingNeed{Name: item.Name, Quantity: Quantity{Amount: item.Amount, Unit: item.Unit}})
+	}
+	return stock
+}
+
+// ingredientNamesMatch reports whether two ingredient names refer to the same
+// thing, allowing one to be a more specific form of the other
+// ("chicken" matches "chicken breast").
+func ingredientNamesMatch(a, b string) bool {
+	a, b = NormalizeIngredientName(a), NormalizeIngredientName(b)
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	return strings.Contains(" "+a+" ", " "+b+" ") || strings.Contains(" "+b+" ", " "+a+" ")
+}
+
+// sortByExpiry orders pantry items soonest-expiring first; items without an
+// expiry date go last.
+func sortByExpiry(items []PantryItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		ei, ej := items[i].ExpiresAt, items[j].ExpiresAt
+		switch {
+		case ei == nil:
+			return false
+		case ej == nil:
+			return true
+		}
+		return ei.Before(*ej)
+	})
+}
+
+// DecrementPantry subtracts the recipe needs from matching pantry items,
+// consuming the soonest-expiring stock first. It returns the items whose
+// amounts changed; items at or below zero should be removed by the caller.
+func DecrementPantry(items []PantryItem, needs []ShoppingNeed) []PantryItem {
+	sortByExpiry(items)
+	changed := make(map[int]bool)
+	for _, need := range needs {
+		remaining := need.Quantity.Base()
+		if remaining <= 0 {
+			continue
+		}
+		for i := range items {
+			if remaining <= 0 {
+				break
+			}
+			stock := Quantity{Amount: items[i].Amount, Unit: items[i].Unit}
+			if stock.Amount <= 0 || !ingredientNamesMatch(items[i].Name, need.Name) {
+				continue
+			}
+			if stock.Dimension() != need.Quantity.Dimension() {
+				continue
+			}
+			if stock.Dimension() == DimensionCount && stock.Unit != need.Quantity.Unit {
+				continue
+			}
+			take := math.Min(stock.Base(), remaining)
+			factor := 1.0
+			if info, ok := units[stock.Unit]; ok {
+				factor = info.base
+			}
+			items[i].Amount -= take / factor
+			remaining -= take
+			changed[i] = true
+		}
+	}
+
+	result := make([]PantryItem, 0, len(changed))
+	for i := range items {
+		if changed[i] {
+			result = append(result, items[i])
+		}
+	}
+	return result
+}
+
+// RankRecipesForPantry scores recipes by how many of the expiring items they
+// use. Items closer to expiry weigh slightly more so that ties favour
+// recipes rescuing the most urgent food.
+func RankRecipesForPantry(recipes []Recipe, needsByRecipe map[uint][]ShoppingNeed, expiring []PantryItem, now time.Time) []PantryRecipeSuggestion {
+	var suggestions []PantryRecipeSuggestion
+	for _, recipe := range recipes {
+		suggestion := PantryRecipeSuggestion{Recipe: recipe, MatchedItems: []string{}}
+		for _, item := range expiring {
+			for _, need := range needsByRecipe[recipe.ID] {
+				if !ingredientNamesMatch(item.Name, need.Name) {
+					continue
+				}
+				urgency := 0.0
+				if item.ExpiresAt != nil {
+					days := math.Max(item.ExpiresAt.Sub(now).Hours()/24, 0)
+					urgency = 1 / (days + 2)
+				}
+				suggestion.MatchedItems = append(suggestion.MatchedItems, item.Name)
+				suggestion.Score += 1 + urgency
+				break
+			}
+		}
+		if len(suggestion.MatchedItems) > 0 {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	return suggestions
+}
+
+// recordCookEvent stores a cooked event for the recipe and decrements the
+// pantry visible to the user (their own and their household's) by the
+// recipe's ingredients. Everything is read through tx, with the pantry rows
+// locked, so that concurrent cooks do not decrement stale amounts.
+func recordCookEvent(tx *gorm.DB, userID uint, householdID *uint, recipeID uint) (CookEvent, error) {
+	event := CookEvent{UserID: userID, RecipeID: recipeID, CookedAt: time.Now()}
+	if err := tx.Create(&event).Error; err != nil {
+		return event, err
+	}
+
+	needs, err := recipeShoppingNeeds(tx, []uint{recipeID})
+	if err != nil {
+		return event, err
+	}
+	var items []PantryItem
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(ownedBy(userID, householdID)).Find(&items).Error; err != nil {
+		return event, err
+	}
+	for _, item := range DecrementPantry(items, needs) {
+		if item.Amount <= 0.0001 {
+			if err := tx.Delete(&item).Error; err != nil {
+				return event, err
+			}
+			continue
+		}
+		if err := tx.Model(&item).Update("amount", item.Amount).Error; err != nil {
+			return event, err
+		}
+	}
+	return event, nil
+}
+
+// parseOptionalDate parses a YYYY-MM-DD date, returning nil for an empty string.
+func parseOptionalDate(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// expiringDays reads the days query parameter, falling back to the default window.
+func expiringDays(c *gin.Context) int {
+	days, err := strconv.Atoi(c.Query("days"))
+	if err != nil || days < 0 {
+		return defaultExpiringDays
+	}
+	return days
+}
+
+// GetPantryItems handles the GET /me/pantry endpoint.
+func GetPantryItems(c *gin.Context) {
+	query := DB.Scopes(ownerScope(c))
+	if location := c.Query("location"); location != "" {
+		query = query.Where("location = ?", location)
+	}
+
+	var items []PantryItem
+	if err := query.Order("name").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pantry items"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// GetPantryItem handles the GET /me/pantry/:id endpoint.
+func GetPantryItem(c *gin.Context) {
+	var item PantryItem
+	if err := DB.Scopes(ownerScope(c)).First(&item, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pantry item not found"})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// CreatePantryItem handles the POST /me/pantry endpoint.
+func CreatePantryItem(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Name        string  `json:"name" binding:"required"`
+		Amount      float64 `json:"amount" binding:"min=0"`
+		Unit        string  `json:"unit"`
+		Location    string  `json:"location" binding:"omitempty,oneof=fridge freezer pantry"`
+		PurchasedAt string  `json:"purchased_at"`
+		ExpiresAt   string  `json:"expires_at"`
+		Private     bool    `json:"private"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchasedAt, err := parseOptionalDate(input.PurchasedAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchased_at date, expected YYYY-MM-DD"})
+		return
+	}
+	expiresAt, err := parseOptionalDate(input.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_at date, expected YYYY-MM-DD"})
+		return
+	}
+
+	location := input.Location
+	if location == "" {
+		location = "pantry"
+	}
+	item := PantryItem{
+		UserID:      userID,
+		Name:        input.Name,
+		Amount:      input.Amount,
+		Unit:        canonicalOrSelf(input.Unit),
+		Location:    location,
+		PurchasedAt: purchasedAt,
+		ExpiresAt:   expiresAt,
+	}
+	if !input.Private {
+		item.HouseholdID = currentHouseholdID(c)
+	}
+	if err := DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pantry item"})
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdatePantryItem handles the PUT /me/pantry/:id endpoint.
+// Only the fields present in the payload are changed.
+func UpdatePantryItem(c *gin.Context) {
+	var item PantryItem
+	if err := DB.Scopes(ownerScope(c)).First(&item, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pantry item not found"})
+		return
+	}
+
+	var input struct {
+		Name        *string  `json:"name"`
+		Amount      *float64 `json:"amount" binding:"omitempty,min=0"`
+		Unit        *string  `json:"unit"`
+		Location    *string  `json:"location" binding:"omitempty,oneof=fridge freezer pantry"`
+		PurchasedAt *string  `json:"purchased_at"`
+		ExpiresAt   *string  `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		updates["name"] = *input.Name
+	}
+	if input.Amount != nil {
+		updates["amount"] = *input.Amount
+	}
+	if input.Unit != nil {
+		updates["unit"] = canonicalOrSelf(*input.Unit)
+	}
+	if input.Location != nil {
+		updates["location"] = *input.Location
+	}
+	if input.PurchasedAt != nil {
+		purchasedAt, err := parseOptionalDate(*input.PurchasedAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purchased_at date, expected YYYY-MM-DD"})
+			return
+		}
+		updates["purchased_at"] = purchasedAt
+	}
+	if input.ExpiresAt != nil {
+		expiresAt, err := parseOptionalDate(*input.ExpiresAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires_at date, expected YYYY-MM-DD"})
+			return
+		}
+		updates["expires_at"] = expiresAt
+	}
+
+	if err := DB.Model(&item).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pantry item"})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// DeletePantryItem handles the DELETE /me/pantry/:id endpoint.
+func DeletePantryItem(c *gin.Context) {
+	var item PantryItem
+	if err := DB.Scopes(ownerScope(c)).First(&item, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pantry item not found"})
+		return
+	}
+
+	if err := DB.Delete(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete pantry item"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Pantry item deleted"})
+}
+
+// expiringPantryItems loads the visible items expiring within the given number
+// of days, including items that have already expired.
+func expiringPantryItems(c *gin.Context, days int) ([]PantryItem, error) {
+	cutoff := time.Now().AddDate(0, 0, days)
+	var items []PantryItem
+	err := DB.Scopes(ownerScope(c)).Where("expires_at IS NOT NULL AND expires_at <= ?", cutoff).
+		Order("expires_at").Find(&items).Error
+	return items, err
+}
+
+// GetExpiringPantryItems handles the GET /me/pantry/expiring endpoint.
+func GetExpiringPantryItems(c *gin.Context) {
+	items, err := expiringPantryItems(c, expiringDays(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pantry items"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// useItUpCandidates narrows recipes in SQL to those the caller may see that
+// mention any expiring item. The item conditions are grouped so that the
+// visibility scope applies to all of them.
+func useItUpCandidates(c *gin.Context, db *gorm.DB, expiring []PantryItem) *gorm.DB {
+	mentions := db.Session(&gorm.Session{NewDB: true})
+	for i, item := range expiring {
+		pattern := "%" + NormalizeIngredientName(item.Name) + "%"
+		condition := "recipes.ingredients ILIKE ? OR recipes.id IN (?)"
+		subquery := db.Session(&gorm.Session{NewDB: true}).Model(&Ingredient{}).Select("recipe_id").Where("name ILIKE ?", pattern)
+		if i == 0 {
+			mentions = mentions.Where(condition, pattern, subquery)
+		} else {
+			mentions = mentions.Or(condition, pattern, subquery)
+		}
+	}
+	return db.Model(&Recipe{}).Where(mentions).Scopes(visibleRecipes(c))
+}
+
+// GetUseItUpSuggestions handles the GET /me/pantry/use-it-up endpoint.
+func GetUseItUpSuggestions(c *gin.Context) {
+	expiring, err := expiringPantryItems(c, expiringDays(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pantry items"})
+		return
+	}
+	if len(expiring) == 0 {
+		c.JSON(http.StatusOK, []PantryRecipeSuggestion{})
+		return
+	}
+
+	var recipes []Recipe
+	if err := useItUpCandidates(c, DB, expiring).Limit(200).Find(&recipes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipes"})
+		return
+	}
+
+	recipeIDs := make([]uint, 0, len(recipes))
+	for _, recipe := range recipes {
+		recipeIDs = append(recipeIDs, recipe.ID)
+	}
+	needsByRecipe, err := recipeIngredientNeeds(DB, recipeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipe ingredients"})
+		return
+	}
+
+	suggestions := RankRecipesForPantry(recipes, needsByRecipe, expiring, time.Now())
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err == nil && limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
+
+// MarkRecipeCooked handles the POST /recipes/:id/cooked endpoint.
+// It records the cook and decrements the user's pantry.
+func MarkRecipeCooked(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	var event CookEvent
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		event, err = recordCookEvent(tx, userID, currentHouseholdID(c), recipe.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record cooked recipe"})
+		return
+	}
+	c.JSON(http.StatusCreated, event)
+}
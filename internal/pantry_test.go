@@ -0,0 +1,105 @@
+// pantry_test.go
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// TestDecrementPantry verifies soonest-expiring stock is consumed first with unit conversion.
+func TestDecrementPantry(t *testing.T) {
+	soon := time.Now().AddDate(0, 0, 1)
+	later := time.Now().AddDate(0, 0, 10)
+	items := []PantryItem{
+		{ID: 1, Name: "Milk", Amount: 1, Unit: "l", ExpiresAt: &later},
+		{ID: 2, Name: "milk", Amount: 1, Unit: "cup", ExpiresAt: &soon},
+		{ID: 3, Name: "Eggs", Amount: 6},
+		{ID: 4, Name: "Flour", Amount: 500, Unit: "g"},
+	}
+	needs := []ShoppingNeed{
+		{Name: "whole milk", Quantity: Quantity{Amount: 2, Unit: "cup"}},
+		{Name: "egg", Quantity: Quantity{Amount: 2}},
+		{Name: "flour", Quantity: Quantity{Amount: 1, Unit: "cup"}},
+	}
+
+	changed := DecrementPantry(items, needs)
+
+	byID := map[uint]PantryItem{}
+	for _, item := range changed {
+		byID[item.ID] = item
+	}
+	assert.Len(t, changed, 3)
+	assert.InDelta(t, 0, byID[2].Amount, 0.0001, "the soonest-expiring milk is used up first")
+	assert.InDelta(t, 1-0.236588, byID[1].Amount, 0.0001)
+	assert.InDelta(t, 4, byID[3].Amount, 0.0001)
+	assert.NotContains(t, byID, uint(4), "volume needs do not consume mass stock")
+}
+
+// TestRankRecipesForPantry verifies recipes using more expiring items rank first.
+func TestRankRecipesForPantry(t *testing.T) {
+	now := time.Now()
+	tomorrow := now.AddDate(0, 0, 1)
+	expiring := []PantryItem{
+		{Name: "spinach", ExpiresAt: &tomorrow},
+		{Name: "feta cheese", ExpiresAt: &tomorrow},
+	}
+	recipes := []Recipe{{ID: 1, Title: "Salad"}, {ID: 2, Title: "Spanakopita"}, {ID: 3, Title: "Toast"}}
+	needs := map[uint][]ShoppingNeed{
+		1: {{Name: "baby spinach"}},
+		2: {{Name: "spinach"}, {Name: "feta cheese"}, {Name: "filo"}},
+		3: {{Name: "bread"}},
+	}
+
+	suggestions := RankRecipesForPantry(recipes, needs, expiring, now)
+
+	assert.Len(t, suggestions, 2)
+	assert.Equal(t, uint(2), suggestions[0].Recipe.ID)
+	assert.ElementsMatch(t, []string{"spinach", "feta cheese"}, suggestions[0].MatchedItems)
+	assert.Equal(t, uint(1), suggestions[1].Recipe.ID)
+}
+
+// TestUseItUpCandidatesVisibility verifies the visibility scope applies to
+// every expiring item's condition, not just the last one.
+func TestUseItUpCandidatesVisibility(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/me/pantry/use-it-up", nil)
+	c.Set("userID", uint(5))
+	expiring := []PantryItem{{Name: "Spinach"}, {Name: "Milk"}}
+
+	var recipes []Recipe
+	stmt := useItUpCandidates(c, DryRunDB(t), expiring).Find(&recipes).Statement
+	mentions := `(recipes.ingredients ILIKE $%d OR recipes.id IN (SELECT "recipe_id" FROM "ingredients" WHERE name ILIKE $%d AND "ingredients"."deleted_at" IS NULL))`
+	assert.Equal(t, `SELECT * FROM "recipes" WHERE (`+
+		fmt.Sprintf(mentions, 1, 2)+" OR "+fmt.Sprintf(mentions, 3, 4)+
+		`) AND (((recipes.visibility = $5 AND recipes.status = $6 AND recipes.hidden_at IS NULL) OR recipes.user_id = $7))`+
+		` AND "recipes"."deleted_at" IS NULL`, stmt.SQL.String())
+	assert.Equal(t, []interface{}{"%spinach%", "%spinach%", "%milk%", "%milk%", VisibilityPublic, RecipeStatusPublished, uint(5)}, stmt.Vars)
+}
+
+// TestRecordCookEventUsesTransaction verifies cooking reads everything
+// through the transaction it is given and locks the pantry rows.
+func TestRecordCookEventUsesTransaction(t *testing.T) {
+	saved := DB
+	defer func() { DB = saved }()
+	DB = nil
+
+	tx := DryRunDB(t)
+	var queries []string
+	tx.Callback().Query().After("gorm:query").Register("test:capture", func(db *gorm.DB) {
+		queries = append(queries, db.Statement.SQL.String())
+	})
+
+	_, err := recordCookEvent(tx, 4, nil, 9)
+	assert.NoError(t, err)
+	assert.Len(t, queries, 3)
+	assert.True(t, strings.HasSuffix(queries[2], "FOR UPDATE"), queries[2])
+}
@@ -0,0 +1,238 @@
+// pdf.go
+package internal
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// pdfFont is one of the standard PDF fonts. Every PDF reader has them, so
+// documents need no embedded fonts.
+type pdfFont int
+
+// Fonts used in printed recipes.
+const (
+	pdfRegular pdfFont = iota
+	pdfBold
+	pdfItalic
+)
+
+// pdfFontNames are the base font names of the fonts.
+var pdfFontNames = [...]string{"Helvetica", "Helvetica-Bold", "Helvetica-Oblique"}
+
+// helveticaWidths and helveticaBoldWidths are the glyph widths of the
+// printable ASCII characters, in thousandths of the font size. The oblique
+// font has the regular widths.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// winAnsiSpecials maps the characters of the WinAnsi encoding outside
+// Latin-1 to their codes.
+var winAnsiSpecials = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// winAnsiWidths are the widths of the non-ASCII characters that differ
+// from the default width of 556.
+var winAnsiWidths = map[byte]int{
+	0x85: 1000, 0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333, 0x95: 350, 0x97: 1000,
+	0x99: 1000, 0xA0: 278, 0xB0: 400, 0xBC: 834, 0xBD: 834, 0xBE: 834, 0xD7: 584,
+}
+
+// pdfTextReplacer spells out characters the standard fonts cannot show.
+var pdfTextReplacer = strings.NewReplacer(
+	"⅓", "1/3", "⅔", "2/3", "⅛", "1/8", "⅜", "3/8", "⅝", "5/8", "⅞", "7/8", "⅕", "1/5", "\t", " ",
+)
+
+// encodeWinAnsi converts text to the WinAnsi encoding of the standard
+// fonts. Characters it lacks become "?".
+func encodeWinAnsi(s string) []byte {
+	s = pdfTextReplacer.Replace(s)
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= 0x20 && r < 0x7F, r >= 0xA0 && r <= 0xFF:
+			encoded = append(encoded, byte(r))
+		case winAnsiSpecials[r] != 0:
+			encoded = append(encoded, winAnsiSpecials[r])
+		case r == '\n' || r == '\r':
+			encoded = append(encoded, ' ')
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+// pdfTextWidth returns the width of text set in a font, in points.
+func pdfTextWidth(s string, font pdfFont, size float64) float64 {
+	widths := &helveticaWidths
+	if font == pdfBold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, b := range encodeWinAnsi(s) {
+		switch {
+		case b >= 0x20 && b < 0x7F:
+			total += widths[b-0x20]
+		case winAnsiWidths[b] != 0:
+			total += winAnsiWidths[b]
+		default:
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrapText breaks text into lines no wider than width, breaking within
+// words only when a word alone is too wide.
+func wrapText(s string, font pdfFont, size, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if pdfTextWidth(candidate, font, size) <= width {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+		line = word
+		for pdfTextWidth(line, font, size) > width {
+			runes := []rune(line)
+			cut := len(runes) - 1
+			for cut > 1 && pdfTextWidth(string(runes[:cut]), font, size) > width {
+				cut--
+			}
+			lines = append(lines, string(runes[:cut]))
+			line = string(runes[cut:])
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfDocument is a PDF of equally sized pages holding text and lines.
+// Positions are in points from the top left corner of the page.
+type pdfDocument struct {
+	Title    string
+	width    float64
+	height   float64
+	pages    []*bytes.Buffer
+	compress bool
+}
+
+// newPDFDocument returns an empty document with pages of the given size.
+func newPDFDocument(width, height float64) *pdfDocument {
+	return &pdfDocument{width: width, height: height, compress: true}
+}
+
+// addPage appends a blank page, returning its index.
+func (d *pdfDocument) addPage() int {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	return len(d.pages) - 1
+}
+
+// text draws text with its baseline at y.
+func (d *pdfDocument) text(page int, x, y float64, font pdfFont, size float64, s string) {
+	fmt.Fprintf(d.pages[page], "BT /F%d %.2f Tf %.2f %.2f Td %s Tj ET\n", font+1, size, x, d.height-y, pdfString(s))
+}
+
+// line draws a straight line.
+func (d *pdfDocument) line(page int, x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.pages[page], "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, d.height-y1, x2, d.height-y2)
+}
+
+// WriteTo writes the document as a PDF file.
+func (d *pdfDocument) WriteTo(w io.Writer) (int64, error) {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 and 2 are the catalog and page tree, 3 to 5 the fonts and
+	// 6 the document information; each page then takes two objects.
+	const firstPage = 7
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>",
+		strings.Join(kids, " "), len(d.pages), d.width, d.height))
+	for _, name := range pdfFontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+	object(fmt.Sprintf("<< /Title %s /Producer (Recipe Book API) >>", pdfString(d.Title)))
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R /F2 4 0 R /F3 5 0 R >> >> /Contents %d 0 R >>", firstPage+2*i+1))
+		stream, filter := content.Bytes(), ""
+		if d.compress {
+			var compressed bytes.Buffer
+			zw := zlib.NewWriter(&compressed)
+			zw.Write(stream)
+			zw.Close()
+			stream, filter = compressed.Bytes(), " /Filter /FlateDecode"
+		}
+		object(fmt.Sprintf("<< /Length %d%s >>\nstream\n%s\nendstream", len(stream), filter, stream))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.WriteTo(w)
+}
+
+// Bytes returns the document as a PDF file.
+func (d *pdfDocument) Bytes() []byte {
+	var out bytes.Buffer
+	d.WriteTo(&out)
+	return out.Bytes()
+}
+
+// pdfString encodes text as a PDF string literal.
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range encodeWinAnsi(s) {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,62 @@
+// pdf_test.go
+package internal
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeWinAnsi(t *testing.T) {
+	assert.Equal(t, []byte("Cr\xe8me br\xfbl\xe9e \x96 \x95 \xb0"), encodeWinAnsi("Crème brûlée – • °"))
+	assert.Equal(t, []byte("1/3 cup ?"), encodeWinAnsi("⅓ cup 中"))
+}
+
+func TestPDFTextWidth(t *testing.T) {
+	assert.InDelta(t, 9.44, pdfTextWidth("Hi", pdfRegular, 10), 0.01)
+	assert.InDelta(t, 10.0, pdfTextWidth("Hi", pdfBold, 10), 0.01)
+	assert.Equal(t, pdfTextWidth("Hi", pdfRegular, 10), pdfTextWidth("Hi", pdfItalic, 10))
+}
+
+func TestWrapText(t *testing.T) {
+	lines := wrapText("Whisk the eggs with the sugar until pale", pdfRegular, 10, 100)
+	assert.Greater(t, len(lines), 1)
+	assert.Equal(t, "Whisk the eggs with the sugar until pale", strings.Join(lines, " "))
+	for _, line := range lines {
+		assert.LessOrEqual(t, pdfTextWidth(line, pdfRegular, 10), 100.0)
+	}
+
+	long := wrapText(strings.Repeat("a", 60), pdfRegular, 10, 100)
+	assert.Greater(t, len(long), 1)
+	assert.Equal(t, strings.Repeat("a", 60), strings.Join(long, ""))
+}
+
+func TestPDFDocument(t *testing.T) {
+	doc := newPDFDocument(200, 100)
+	doc.compress = false
+	doc.Title = "Pie (best)"
+	doc.text(doc.addPage(), 10, 20, pdfBold, 12, `Apple \ (pie)`)
+	doc.line(doc.addPage(), 10, 10, 190, 10, 1)
+	data := doc.Bytes()
+
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, string(data), "/Count 2 /MediaBox [0 0 200.00 100.00]")
+	assert.Contains(t, string(data), "/Title (Pie \\(best\\))")
+	assert.Contains(t, string(data), "BT /F2 12.00 Tf 10.00 80.00 Td (Apple \\\\ \\(pie\\)) Tj ET")
+
+	// Every cross-reference entry points at its object.
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	offset, _ := strconv.Atoi(string(startxref[1]))
+	assert.True(t, bytes.HasPrefix(data[offset:], []byte("xref\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[offset:], -1)
+	assert.Len(t, entries, 10)
+	for i, entry := range entries {
+		position, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(data[position:], []byte(strconv.Itoa(i+1)+" 0 obj\n")))
+	}
+}
@@ -0,0 +1,214 @@
+// profiles.go
+package internal
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserSummary is the public identity of a user.
+type UserSummary struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// UserProfile is a user's public profile page: who they are, their
+// follower counts and a page of their public recipes, newest first.
+// Following is set when the caller follows the user.
+type UserProfile struct {
+	ID             uint         `json:"id"`
+	Username       string       `json:"username"`
+	JoinedAt       time.Time    `json:"joined_at"`
+	RecipeCount    int64        `json:"recipe_count"`
+	FollowerCount  int64        `json:"follower_count"`
+	FollowingCount int64        `json:"following_count"`
+	Following      bool         `json:"following"`
+	Recipes        []Recipe     `json:"recipes"`
+	Collections    []Collection `json:"collections"`
+	NextCursor     *uint        `json:"next_cursor"`
+}
+
+// userSummaries returns the public identities of users by ID.
+func userSummaries(ids []uint) (map[uint]UserSummary, error) {
+	summaries := make(map[uint]UserSummary, len(ids))
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+	var users []UserSummary
+	if err := DB.Model(&User{}).Select("id", "username").Where("id IN ?", ids).Scan(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		summaries[user.ID] = user
+	}
+	return summaries, nil
+}
+
+// findProfileUser loads the user named in the path, responding with an
+// error and returning false when there is none.
+func findProfileUser(c *gin.Context, user *User) bool {
+	if err := DB.Where("username = ?", c.Param("username")).First(user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return false
+	}
+	return true
+}
+
+// publicRecipes limits recipes to published public ones not hidden by
+// moderators.
+func publicRecipes(db *gorm.DB) *gorm.DB {
+	return db.Where("recipes.visibility = ? AND recipes.status = ? AND recipes.hidden_at IS NULL", VisibilityPublic, RecipeStatusPublished)
+}
+
+// GetUserProfile handles the GET /users/:username endpoint. Pass
+// next_cursor back as cursor for the next page of recipes.
+func GetUserProfile(c *gin.Context) {
+	var user User
+	if !findProfileUser(c, &user) {
+		return
+	}
+	profile := UserProfile{ID: user.ID, Username: user.Username, JoinedAt: user.CreatedAt, Recipes: []Recipe{}, Collections: []Collection{}}
+
+	counts := []struct {
+		count *int64
+		query *gorm.DB
+	}{
+		{&profile.RecipeCount, DB.Model(&Recipe{}).Scopes(publicRecipes).Where("user_id = ?", user.ID)},
+		{&profile.FollowerCount, DB.Model(&Follow{}).Where("followee_id = ?", user.ID)},
+		{&profile.FollowingCount, DB.Model(&Follow{}).Where("follower_id = ?", user.ID)},
+	}
+	for _, count := range counts {
+		if err := count.query.Count(count.count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profile"})
+			return
+		}
+	}
+	if callerID, ok := currentUserID(c); ok && callerID != user.ID {
+		var follows int64
+		DB.Model(&Follow{}).Where("follower_id = ? AND followee_id = ?", callerID, user.ID).Count(&follows)
+		profile.Following = follows > 0
+	}
+
+	limit := queryLimit(c, 20, 100)
+	query := DB.Scopes(publicRecipes).Preload("Tags").Preload("Images", recipePhotos).Where("user_id = ?", user.ID)
+	cursor, err := queryCursor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if cursor > 0 {
+		query = query.Where("id < ?", cursor)
+	}
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&profile.Recipes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profile"})
+		return
+	}
+	if len(profile.Recipes) > limit {
+		profile.Recipes = profile.Recipes[:limit]
+		profile.NextCursor = &profile.Recipes[limit-1].ID
+	}
+	for i := range profile.Recipes {
+		if err := attachRecipeImages(&profile.Recipes[i]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profile"})
+			return
+		}
+	}
+
+	err = DB.Preload("Recipes", publicRecipes).
+		Where("user_id = ? AND public", user.ID).
+		Order("name").
+		Find(&profile.Collections).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve profile"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// FollowUser handles the POST /users/:username/follow endpoint. Following
+// is idempotent, and the user's recent activities are added to the
+// caller's feed.
+func FollowUser(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var user User
+	if !findProfileUser(c, &user) {
+		return
+	}
+	if user.ID == userID {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "You cannot follow yourself"})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Follow{FollowerID: userID, FolloweeID: user.ID})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return backfillFeed(tx, userID, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to follow user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Following " + user.Username})
+}
+
+// UnfollowUser handles the DELETE /users/:username/follow endpoint,
+// removing the user's activities from the caller's feed.
+func UnfollowUser(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var user User
+	if !findProfileUser(c, &user) {
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("follower_id = ? AND followee_id = ?", userID, user.ID).Delete(&Follow{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND activity_id IN (SELECT id FROM activities WHERE actor_id = ?)", userID, user.ID).
+			Delete(&FeedItem{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unfollow user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Unfollowed " + user.Username})
+}
+
+// listFollows responds with the users in the listed column of the follows
+// that have the profile's user in the other column, most recent first.
+func listFollows(c *gin.Context, listed, other string) {
+	var user User
+	if !findProfileUser(c, &user) {
+		return
+	}
+
+	users := []UserSummary{}
+	err := DB.Model(&Follow{}).
+		Select("users.id, users.username").
+		Joins("JOIN users ON users.id = follows."+listed+" AND users.deleted_at IS NULL").
+		Where("follows."+other+" = ?", user.ID).
+		Order("follows.created_at DESC").
+		Limit(queryLimit(c, 50, 200)).
+		Scan(&users).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// GetFollowers handles the GET /users/:username/followers endpoint.
+func GetFollowers(c *gin.Context) {
+	listFollows(c, "follower_id", "followee_id")
+}
+
+// GetFollowing handles the GET /users/:username/following endpoint.
+func GetFollowing(c *gin.Context) {
+	listFollows(c, "followee_id", "follower_id")
+}
@@ -0,0 +1,342 @@
+// quantity.go
+package internal
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Measurement dimensions a unit can belong to.
+const (
+	DimensionVolume = "volume"
+	DimensionMass   = "mass"
+	DimensionCount  = "count"
+)
+
+// Quantity is a parsed ingredient amount with a canonical unit.
+// An empty Unit means a plain count (e.g. "3 eggs").
+type Quantity struct {
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+}
+
+// unitInfo describes a canonical unit and its size in the dimension's base unit
+// (millilitres for volume, grams for mass).
+type unitInfo struct {
+	dimension string
+	base      float64
+}
+
+// units maps canonical unit names to their dimension and base factor.
+var units = map[string]unitInfo{
+	"ml":     {DimensionVolume, 1},
+	"cl":     {DimensionVolume, 10},
+	"dl":     {DimensionVolume, 100},
+	"l":      {DimensionVolume, 1000},
+	"tsp":    {DimensionVolume, 4.92892},
+	"tbsp":   {DimensionVolume, 14.7868},
+	"fl oz":  {DimensionVolume, 29.5735},
+	"cup":    {DimensionVolume, 236.588},
+	"pint":   {DimensionVolume, 473.176},
+	"quart":  {DimensionVolume, 946.353},
+	"gallon": {DimensionVolume, 3785.41},
+	"mg":     {DimensionMass, 0.001},
+	"g":      {DimensionMass, 1},
+	"kg":     {DimensionMass, 1000},
+	"oz":     {DimensionMass, 28.3495},
+	"lb":     {DimensionMass, 453.592},
+}
+
+// unitAliases maps spellings found in recipes to canonical unit names.
+var unitAliases = map[string]string{
+	"ml": "ml", "milliliter": "ml", "milliliters": "ml", "millilitre": "ml", "millilitres": "ml",
+	"cl": "cl", "centiliter": "cl", "centiliters": "cl",
+	"dl": "dl", "deciliter": "dl", "deciliters": "dl",
+	"l": "l", "liter": "l", "liters": "l", "litre": "l", "litres": "l",
+	"tsp": "tsp", "tsps": "tsp", "teaspoon": "tsp", "teaspoons": "tsp",
+	"tbsp": "tbsp", "tbsps": "tbsp", "tbs": "tbsp", "tbl": "tbsp", "tablespoon": "tbsp", "tablespoons": "tbsp",
+	"fl oz": "fl oz", "fl. oz": "fl oz", "fluid ounce": "fl oz", "fluid ounces": "fl oz",
+	"cup": "cup", "cups": "cup", "c": "cup",
+	"pint": "pint", "pints": "pint", "pt": "pint",
+	"quart": "quart", "quarts": "quart", "qt": "quart",
+	"gallon": "gallon", "gallons": "gallon", "gal": "gallon",
+	"mg": "mg", "milligram": "mg", "milligrams": "mg",
+	"g": "g", "gr": "g", "gram": "g", "grams": "g",
+	"kg": "kg", "kilo": "kg", "kilos": "kg", "kilogram": "kg", "kilograms": "kg",
+	"oz": "oz", "ounce": "oz", "ounces": "oz",
+	"lb": "lb", "lbs": "lb", "pound": "lb", "pounds": "lb",
+	"pinch": "pinch", "pinches": "pinch",
+	"dash": "dash", "dashes": "dash",
+	"clove": "clove", "cloves": "clove",
+	"can": "can", "cans": "can",
+	"package": "package", "packages": "package", "pkg": "package",
+	"bunch": "bunch", "bunches": "bunch",
+	"slice": "slice", "slices": "slice",
+	"piece": "piece", "pieces": "piece",
+	"stick": "stick", "sticks": "stick",
+	"head": "head", "heads": "head",
+	"sprig": "sprig", "sprigs": "sprig",
+}
+
+// unicodeFractions maps vulgar fraction characters to their values.
+var unicodeFractions = map[rune]float64{
+	'¼': 0.25, '½': 0.5, '¾': 0.75,
+	'⅓': 1.0 / 3, '⅔': 2.0 / 3,
+	'⅛': 0.125, '⅜': 0.375, '⅝': 0.625, '⅞': 0.875,
+}
+
+// amountPattern matches a leading amount: whole numbers, decimals, fractions,
+// mixed numbers ("1 1/2") and ranges ("1-2", "1 to 2").
+var amountPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?(?:\s+\d+/\d+|/\d+)?)(?:\s*(?:-|–|to)\s*(\d+(?:\.\d+)?(?:\s+\d+/\d+|/\d+)?))?`)
+
+// CanonicalUnit returns the canonical name for a unit spelling, or "" if unknown.
+func CanonicalUnit(unit string) string {
+	unit = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(unit)), ".")
+	return unitAliases[unit]
+}
+
+// Dimension reports whether the quantity measures volume, mass or a count.
+func (q Quantity) Dimension() string {
+	if info, ok := units[q.Unit]; ok {
+		return info.dimension
+	}
+	return DimensionCount
+}
+
+// Base returns the amount expressed in the dimension's base unit
+// (ml for volume, g for mass). Counts are returned unchanged.
+func (q Quantity) Base() float64 {
+	if info, ok := units[q.Unit]; ok {
+		return q.Amount * info.base
+	}
+	return q.Amount
+}
+
+// ConvertTo converts the quantity into another unit of the same dimension.
+func (q Quantity) ConvertTo(unit string) (Quantity, bool) {
+	unit = canonicalOrSelf(unit)
+	if unit == q.Unit {
+		return q, true
+	}
+	from, ok := units[q.Unit]
+	to, ok2 := units[unit]
+	if !ok || !ok2 || from.dimension != to.dimension {
+		return Quantity{}, false
+	}
+	return Quantity{Amount: q.Amount * from.base / to.base, Unit: unit}, true
+}
+
+// Humanize rescales metric quantities to a friendlier unit (e.g. 1500 g to 1.5 kg).
+func (q Quantity) Humanize() Quantity {
+	switch q.Dimension() {
+	case DimensionMass:
+		if grams := q.Base(); grams >= 1000 {
+			return Quantity{Amount: grams / 1000, Unit: "kg"}
+		}
+		return Quantity{Amount: q.Base(), Unit: "g"}
+	case DimensionVolume:
+		if ml := q.Base(); ml >= 1000 {
+			return Quantity{Amount: ml / 1000, Unit: "l"}
+		}
+		return Quantity{Amount: q.Base(), Unit: "ml"}
+	}
+	return q
+}
+
+// String formats the quantity as text such as "1.5 cup" or "3".
+func (q Quantity) String() string {
+	amount := FormatAmount(q.Amount)
+	if q.Unit == "" {
+		return amount
+	}
+	return amount + " " + q.Unit
+}
+
+// FormatAmount renders an amount rounded to two decimals without trailing zeros.
+func FormatAmount(amount float64) string {
+	return strconv.FormatFloat(math.Round(amount*100)/100, 'f', -1, 64)
+}
+
+// ParseQuantity parses a quantity string such as "2 cups", "1 1/2 tbsp" or "200g".
+// Unknown units are kept lower-cased so that counts like "3 sprigs" still group.
+func ParseQuantity(s string) (Quantity, bool) {
+	amount, rest, ok := parseAmount(s)
+	if !ok {
+		return Quantity{}, false
+	}
+	unit, rest := parseUnit(rest)
+	if unit == "" && strings.TrimSpace(rest) != "" {
+		unit = strings.ToLower(strings.TrimSpace(rest))
+	}
+	return Quantity{Amount: amount, Unit: unit}, true
+}
+
+// ParseIngredientLine splits a free-text ingredient line such as
+// "1 1/2 cups flour, sifted" into its quantity and ingredient name.
+// Lines without a leading amount return a zero Quantity and the trimmed line.
+func ParseIngredientLine(line string) (Quantity, string) {
+	line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+	amount, rest, ok := parseAmount(line)
+	if !ok {
+		return Quantity{}, line
+	}
+	unit, rest := parseUnit(rest)
+	rest = strings.TrimSpace(rest)
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "of "))
+	return Quantity{Amount: amount, Unit: unit}, rest
+}
+
+// parseAmount reads a leading amount and returns the remainder of the string.
+// Ranges resolve to their upper bound.
+func parseAmount(s string) (float64, string, bool) {
+	s = strings.TrimSpace(s)
+	s = expandUnicodeFractions(s)
+	lower := strings.ToLower(s)
+	for _, word := range []string{"a ", "an ", "one "} {
+		if strings.HasPrefix(lower, word) {
+			return 1, s[len(word):], true
+		}
+	}
+	m := amountPattern.FindStringSubmatchIndex(s)
+	if m == nil {
+		return 0, s, false
+	}
+	amount, ok := parseNumber(s[m[2]:m[3]])
+	if !ok {
+		return 0, s, false
+	}
+	if m[4] >= 0 {
+		if upper, ok := parseNumber(s[m[4]:m[5]]); ok {
+			amount = upper
+		}
+	}
+	return amount, s[m[1]:], true
+}
+
+// expandUnicodeFractions rewrites "1½" as "1 1/2" so the amount pattern can read it.
+func expandUnicodeFractions(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		v, ok := unicodeFractions[r]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		if i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+			b.WriteByte(' ')
+		}
+		switch v {
+		case 0.25:
+			b.WriteString("1/4")
+		case 0.5:
+			b.WriteString("1/2")
+		case 0.75:
+			b.WriteString("3/4")
+		case 1.0 / 3:
+			b.WriteString("1/3")
+		case 2.0 / 3:
+			b.WriteString("2/3")
+		default:
+			b.WriteString(strconv.Itoa(int(v*8)) + "/8")
+		}
+	}
+	return b.String()
+}
+
+// parseNumber parses "2", "2.5", "1/2" or "1 1/2".
+func parseNumber(s string) (float64, bool) {
+	total := 0.0
+	for _, part := range strings.Fields(s) {
+		if num, den, found := strings.Cut(part, "/"); found {
+			n, err1 := strconv.ParseFloat(num, 64)
+			d, err2 := strconv.ParseFloat(den, 64)
+			if err1 != nil || err2 != nil || d == 0 {
+				return 0, false
+			}
+			total += n / d
+			continue
+		}
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+		total += v
+	}
+	return total, true
+}
+
+// parseUnit reads a leading unit word (or two words such as "fl oz") and
+// returns its canonical name and the remainder of the string.
+func parseUnit(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", s
+	}
+	if len(fields) >= 2 {
+		if unit := CanonicalUnit(fields[0] + " " + fields[1]); unit != "" {
+			return unit, strings.Join(fields[2:], " ")
+		}
+	}
+	word := strings.TrimRight(fields[0], ",")
+	if unit := CanonicalUnit(word); unit != "" {
+		return unit, strings.Join(fields[1:], " ")
+	}
+	return "", s
+}
+
+// canonicalOrSelf returns the canonical unit name, or the lower-cased input if unknown.
+func canonicalOrSelf(unit string) string {
+	if canonical := CanonicalUnit(unit); canonical != "" {
+		return canonical
+	}
+	return strings.ToLower(strings.TrimSpace(unit))
+}
+
+// NormalizeIngredientName reduces an ingredient name to a comparable key:
+// lower-cased, without parenthetical notes or trailing preparation text,
+// and with simple plurals made singular.
+func NormalizeIngredientName(name string) string {
+	name = strings.ToLower(name)
+	for {
+		open := strings.Index(name, "(")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(name[open:], ")")
+		if end < 0 {
+			name = name[:open]
+			break
+		}
+		name = name[:open] + name[open+end+1:]
+	}
+	if i := strings.Index(name, ","); i >= 0 {
+		name = name[:i]
+	}
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return ""
+	}
+	words[len(words)-1] = singularize(words[len(words)-1])
+	return strings.Join(words, " ")
+}
+
+// singularize strips common English plural endings.
+func singularize(word string) string {
+	switch {
+	case len(word) <= 3:
+		return word
+	case strings.HasSuffix(word, "ies"):
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "oes"), strings.HasSuffix(word, "ches"),
+		strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
@@ -0,0 +1,75 @@
+// quantity_test.go
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseQuantity verifies amounts, fractions, ranges and unit aliases.
+func TestParseQuantity(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected Quantity
+	}{
+		{"2 cups", Quantity{Amount: 2, Unit: "cup"}},
+		{"1 1/2 tbsp", Quantity{Amount: 1.5, Unit: "tbsp"}},
+		{"½ teaspoon", Quantity{Amount: 0.5, Unit: "tsp"}},
+		{"1½ lbs", Quantity{Amount: 1.5, Unit: "lb"}},
+		{"200g", Quantity{Amount: 200, Unit: "g"}},
+		{"3", Quantity{Amount: 3}},
+		{"1-2 cloves", Quantity{Amount: 2, Unit: "clove"}},
+		{"4 fl oz", Quantity{Amount: 4, Unit: "fl oz"}},
+		{"a pinch", Quantity{Amount: 1, Unit: "pinch"}},
+	}
+	for _, tc := range cases {
+		quantity, ok := ParseQuantity(tc.input)
+		assert.True(t, ok, tc.input)
+		assert.InDelta(t, tc.expected.Amount, quantity.Amount, 0.0001, tc.input)
+		assert.Equal(t, tc.expected.Unit, quantity.Unit, tc.input)
+	}
+
+	_, ok := ParseQuantity("to taste")
+	assert.False(t, ok)
+}
+
+// TestParseIngredientLine verifies splitting free-text lines into quantity and name.
+func TestParseIngredientLine(t *testing.T) {
+	quantity, name := ParseIngredientLine("- 1 1/2 cups flour, sifted")
+	assert.Equal(t, Quantity{Amount: 1.5, Unit: "cup"}, quantity)
+	assert.Equal(t, "flour, sifted", name)
+
+	quantity, name = ParseIngredientLine("3 large eggs")
+	assert.Equal(t, Quantity{Amount: 3}, quantity)
+	assert.Equal(t, "large eggs", name)
+
+	quantity, name = ParseIngredientLine("a pinch of salt")
+	assert.Equal(t, Quantity{Amount: 1, Unit: "pinch"}, quantity)
+	assert.Equal(t, "salt", name)
+
+	quantity, name = ParseIngredientLine("Salt to taste")
+	assert.Equal(t, Quantity{}, quantity)
+	assert.Equal(t, "Salt to taste", name)
+}
+
+// TestQuantityConversion verifies unit conversion and humanizing.
+func TestQuantityConversion(t *testing.T) {
+	converted, ok := Quantity{Amount: 3, Unit: "tsp"}.ConvertTo("tablespoon")
+	assert.True(t, ok)
+	assert.InDelta(t, 1, converted.Amount, 0.001)
+	assert.Equal(t, "tbsp", converted.Unit)
+
+	_, ok = Quantity{Amount: 1, Unit: "cup"}.ConvertTo("g")
+	assert.False(t, ok)
+
+	assert.Equal(t, "1.5 kg", Quantity{Amount: 1500, Unit: "g"}.Humanize().String())
+}
+
+// TestNormalizeIngredientName verifies ingredient names reduce to comparable keys.
+func TestNormalizeIngredientName(t *testing.T) {
+	assert.Equal(t, "tomato", NormalizeIngredientName("Tomatoes"))
+	assert.Equal(t, "red onion", NormalizeIngredientName("Red Onions (about 2), diced"))
+	assert.Equal(t, "blueberry", NormalizeIngredientName("blueberries"))
+	assert.Equal(t, "hummus", NormalizeIngredientName("hummus"))
+}
@@ -0,0 +1,468 @@
+// recipe_export.go
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Export media types offered by GetRecipe.
+const (
+	mediaTypeJSON     = "application/json"
+	mediaTypeJSONLD   = "application/ld+json"
+	mediaTypeMarkdown = "text/markdown"
+	mediaTypeCooklang = "text/x-cooklang"
+)
+
+// exportFormats maps the format query parameter of the export endpoint to
+// media types.
+var exportFormats = map[string]string{
+	"json":     mediaTypeJSON,
+	"jsonld":   mediaTypeJSONLD,
+	"json-ld":  mediaTypeJSONLD,
+	"markdown": mediaTypeMarkdown,
+	"md":       mediaTypeMarkdown,
+	"cooklang": mediaTypeCooklang,
+	"cook":     mediaTypeCooklang,
+}
+
+// markdownEscaper escapes characters with meaning in inline Markdown.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+// RecipeExport is a recipe with what its exports need beyond the recipe
+// row: ingredient rows, the author's name and the recipe's public URL.
+type RecipeExport struct {
+	Recipe      Recipe
+	Ingredients []Ingredient
+	Author      string
+	URL         string
+}
+
+// schemaHowToStep is a schema.org HowToStep.
+type schemaHowToStep struct {
+	Type string `json:"@type"`
+	Text string `json:"text"`
+}
+
+// schemaHowToSection is a schema.org HowToSection of steps.
+type schemaHowToSection struct {
+	Type            string            `json:"@type"`
+	Name            string            `json:"name"`
+	ItemListElement []schemaHowToStep `json:"itemListElement"`
+}
+
+// schemaNutritionInformation is a schema.org NutritionInformation.
+type schemaNutritionInformation struct {
+	Type                string `json:"@type"`
+	Calories            string `json:"calories,omitempty"`
+	ProteinContent      string `json:"proteinContent,omitempty"`
+	FatContent          string `json:"fatContent,omitempty"`
+	CarbohydrateContent string `json:"carbohydrateContent,omitempty"`
+	FiberContent        string `json:"fiberContent,omitempty"`
+	SugarContent        string `json:"sugarContent,omitempty"`
+	SodiumContent       string `json:"sodiumContent,omitempty"`
+}
+
+// schemaPerson is a schema.org Person.
+type schemaPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+// schemaAggregateRating is a schema.org AggregateRating.
+type schemaAggregateRating struct {
+	Type        string  `json:"@type"`
+	RatingValue float64 `json:"ratingValue"`
+	RatingCount int     `json:"ratingCount"`
+	BestRating  int     `json:"bestRating"`
+	WorstRating int     `json:"worstRating"`
+}
+
+// SchemaRecipe is a schema.org Recipe document.
+type SchemaRecipe struct {
+	Context            string                      `json:"@context"`
+	Type               string                      `json:"@type"`
+	Name               string                      `json:"name"`
+	URL                string                      `json:"url,omitempty"`
+	Image              []string                    `json:"image,omitempty"`
+	Author             *schemaPerson               `json:"author,omitempty"`
+	DatePublished      string                      `json:"datePublished,omitempty"`
+	DateModified       string                      `json:"dateModified,omitempty"`
+	PrepTime           string                      `json:"prepTime,omitempty"`
+	CookTime           string                      `json:"cookTime,omitempty"`
+	TotalTime          string                      `json:"totalTime,omitempty"`
+	RecipeYield        []string                    `json:"recipeYield,omitempty"`
+	RecipeCuisine      string                      `json:"recipeCuisine,omitempty"`
+	RecipeCategory     string                      `json:"recipeCategory,omitempty"`
+	Keywords           string                      `json:"keywords,omitempty"`
+	Tool               []string                    `json:"tool,omitempty"`
+	RecipeIngredient   []string                    `json:"recipeIngredient"`
+	RecipeInstructions []interface{}               `json:"recipeInstructions"`
+	Nutrition          *schemaNutritionInformation `json:"nutrition,omitempty"`
+	AggregateRating    *schemaAggregateRating      `json:"aggregateRating,omitempty"`
+	IsBasedOn          string                      `json:"isBasedOn,omitempty"`
+}
+
+// FormatISODuration formats minutes as an ISO 8601 duration such as "PT1H30M".
+func FormatISODuration(minutes int) string {
+	if minutes <= 0 {
+		return ""
+	}
+	d := "PT"
+	if minutes >= 60 {
+		d += strconv.Itoa(minutes/60) + "H"
+	}
+	if minutes%60 != 0 {
+		d += strconv.Itoa(minutes%60) + "M"
+	}
+	return d
+}
+
+// humanMinutes formats minutes as text such as "1 h 30 min".
+func humanMinutes(minutes int) string {
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%d min", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%d h", minutes/60)
+	default:
+		return fmt.Sprintf("%d h %d min", minutes/60, minutes%60)
+	}
+}
+
+// ingredientLines returns a recipe's ingredients as display lines, from its
+// ingredient rows or, failing that, its ingredient text.
+func (e RecipeExport) ingredientLines() []string {
+	lines := []string{}
+	if len(e.Ingredients) > 0 {
+		for _, ingredient := range e.Ingredients {
+			lines = append(lines, strings.TrimSpace(ingredient.Quantity+" "+ingredient.Name))
+		}
+		return lines
+	}
+	for _, line := range strings.Split(e.Recipe.Ingredients, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•")); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// steps returns the recipe's structured steps, splitting the instruction
+// text when the steps were not loaded.
+func (e RecipeExport) steps() []RecipeStep {
+	if len(e.Recipe.Steps) > 0 {
+		return e.Recipe.Steps
+	}
+	return SplitInstructions(e.Recipe.Instructions)
+}
+
+// yield describes what the recipe makes, e.g. "12 cookies".
+func (e RecipeExport) yield() string {
+	if e.Recipe.YieldAmount <= 0 {
+		return ""
+	}
+	return strings.TrimSpace(FormatAmount(e.Recipe.YieldAmount) + " " + e.Recipe.YieldUnit)
+}
+
+// nutrition returns per-serving nutrition, falling back to the calories on
+// the recipe when nothing has been computed.
+func (e RecipeExport) nutrition() *NutritionFacts {
+	if e.Recipe.Nutrition != nil {
+		return &e.Recipe.Nutrition.PerServing
+	}
+	if e.Recipe.Calories > 0 {
+		return &NutritionFacts{Calories: float64(e.Recipe.Calories)}
+	}
+	return nil
+}
+
+// JSONLD returns the recipe as a schema.org Recipe document.
+func (e RecipeExport) JSONLD() SchemaRecipe {
+	recipe := e.Recipe
+	doc := SchemaRecipe{
+		Context:            "https://schema.org",
+		Type:               "Recipe",
+		Name:               recipe.Title,
+		URL:                e.URL,
+		Image:              recipe.ImageURLs,
+		PrepTime:           FormatISODuration(recipe.PrepTimeMinutes),
+		CookTime:           FormatISODuration(recipe.CookTimeMinutes),
+		TotalTime:          FormatISODuration(recipe.TotalTimeMinutes),
+		RecipeCuisine:      recipe.Cuisine,
+		RecipeCategory:     recipe.Course,
+		Tool:               recipe.Equipment,
+		RecipeIngredient:   e.ingredientLines(),
+		RecipeInstructions: []interface{}{},
+		IsBasedOn:          recipe.SourceURL,
+	}
+	if e.Author != "" {
+		doc.Author = &schemaPerson{Type: "Person", Name: e.Author}
+	}
+	if recipe.PublishedAt != nil {
+		doc.DatePublished = recipe.PublishedAt.UTC().Format(time.RFC3339)
+	}
+	if !recipe.UpdatedAt.IsZero() {
+		doc.DateModified = recipe.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	if doc.IsBasedOn == "" && recipe.ForkedFrom != nil && !recipe.ForkedFrom.Hidden && e.URL != "" {
+		doc.IsBasedOn = fmt.Sprintf("%s/%d", e.URL[:strings.LastIndex(e.URL, "/")], recipe.ForkedFrom.ID)
+	}
+	if recipe.RatingCount > 0 {
+		doc.AggregateRating = &schemaAggregateRating{
+			Type:        "AggregateRating",
+			RatingValue: math.Round(recipe.RatingAverage*10) / 10,
+			RatingCount: recipe.RatingCount,
+			BestRating:  5,
+			WorstRating: 1,
+		}
+	}
+	if recipe.Servings > 0 {
+		doc.RecipeYield = append(doc.RecipeYield, strconv.Itoa(recipe.Servings))
+	}
+	if yield := e.yield(); yield != "" {
+		doc.RecipeYield = append(doc.RecipeYield, yield)
+	}
+
+	keywords := make([]string, 0, len(recipe.Tags))
+	for _, tag := range recipe.Tags {
+		keywords = append(keywords, tag.Name)
+	}
+	doc.Keywords = strings.Join(keywords, ", ")
+
+	var section *schemaHowToSection
+	for _, step := range e.steps() {
+		howTo := schemaHowToStep{Type: "HowToStep", Text: step.Text}
+		if step.Section == "" {
+			section = nil
+			doc.RecipeInstructions = append(doc.RecipeInstructions, howTo)
+			continue
+		}
+		if section == nil || section.Name != step.Section {
+			section = &schemaHowToSection{Type: "HowToSection", Name: step.Section}
+			doc.RecipeInstructions = append(doc.RecipeInstructions, section)
+		}
+		section.ItemListElement = append(section.ItemListElement, howTo)
+	}
+
+	if facts := e.nutrition(); facts != nil {
+		amount := func(value float64, unit string) string {
+			if value <= 0 {
+				return ""
+			}
+			return FormatAmount(value) + " " + unit
+		}
+		doc.Nutrition = &schemaNutritionInformation{
+			Type:                "NutritionInformation",
+			Calories:            amount(facts.Calories, "calories"),
+			ProteinContent:      amount(facts.ProteinG, "g"),
+			FatContent:          amount(facts.FatG, "g"),
+			CarbohydrateContent: amount(facts.CarbsG, "g"),
+			FiberContent:        amount(facts.FiberG, "g"),
+			SugarContent:        amount(facts.SugarG, "g"),
+			SodiumContent:       amount(facts.SodiumMg, "mg"),
+		}
+	}
+	return doc
+}
+
+// facts returns the byline of a printed recipe: its author, servings,
+// yield, times and difficulty.
+func (e RecipeExport) facts() []string {
+	recipe := e.Recipe
+	var facts []string
+	if e.Author != "" {
+		facts = append(facts, "By "+e.Author)
+	}
+	if recipe.Servings > 0 {
+		facts = append(facts, fmt.Sprintf("Serves %d", recipe.Servings))
+	}
+	if yield := e.yield(); yield != "" {
+		facts = append(facts, "Makes "+yield)
+	}
+	for _, duration := range []struct {
+		label   string
+		minutes int
+	}{{"Prep", recipe.PrepTimeMinutes}, {"Cook", recipe.CookTimeMinutes}, {"Total", recipe.TotalTimeMinutes}} {
+		if duration.minutes > 0 {
+			facts = append(facts, duration.label+" "+humanMinutes(duration.minutes))
+		}
+	}
+	if recipe.Difficulty != "" {
+		facts = append(facts, strings.ToUpper(recipe.Difficulty[:1])+recipe.Difficulty[1:])
+	}
+	return facts
+}
+
+// Markdown returns the recipe as a Markdown recipe card.
+func (e RecipeExport) Markdown() string {
+	recipe := e.Recipe
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", markdownEscaper.Replace(recipe.Title))
+
+	if facts := e.facts(); len(facts) > 0 {
+		for i, fact := range facts {
+			facts[i] = markdownEscaper.Replace(fact)
+		}
+		fmt.Fprintf(&b, "*%s*\n\n", strings.Join(facts, " · "))
+	}
+	if len(recipe.ImageURLs) > 0 {
+		fmt.Fprintf(&b, "![%s](%s)\n\n", markdownEscaper.Replace(recipe.Title), recipe.ImageURLs[0])
+	}
+
+	b.WriteString("## Ingredients\n\n")
+	for _, line := range e.ingredientLines() {
+		fmt.Fprintf(&b, "- %s\n", markdownEscaper.Replace(line))
+	}
+
+	b.WriteString("\n## Instructions\n")
+	section, number := "", 0
+	for i, step := range e.steps() {
+		if i == 0 || step.Section != section {
+			section, number = step.Section, 0
+			b.WriteString("\n")
+			if section != "" {
+				fmt.Fprintf(&b, "### %s\n\n", markdownEscaper.Replace(section))
+			}
+		}
+		number++
+		fmt.Fprintf(&b, "%d. %s\n", number, markdownEscaper.Replace(step.Text))
+	}
+
+	if facts := e.nutrition(); facts != nil {
+		b.WriteString("\n## Nutrition per serving\n\n")
+		b.WriteString("| Calories | Protein | Fat | Carbs | Fiber | Sugar | Sodium |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- | --- |\n")
+		fmt.Fprintf(&b, "| %s | %s g | %s g | %s g | %s g | %s g | %s mg |\n",
+			FormatAmount(facts.Calories), FormatAmount(facts.ProteinG), FormatAmount(facts.FatG),
+			FormatAmount(facts.CarbsG), FormatAmount(facts.FiberG), FormatAmount(facts.SugarG), FormatAmount(facts.SodiumMg))
+	}
+
+	if len(recipe.Tags) > 0 {
+		names := make([]string, 0, len(recipe.Tags))
+		for _, tag := range recipe.Tags {
+			names = append(names, markdownEscaper.Replace(tag.Name))
+		}
+		fmt.Fprintf(&b, "\nTags: %s\n", strings.Join(names, ", "))
+	}
+	if recipe.SourceURL != "" {
+		fmt.Fprintf(&b, "\nSource: <%s>\n", recipe.SourceURL)
+	}
+	return b.String()
+}
+
+// requestBaseURL returns the scheme and host the request was made to, or
+// PUBLIC_URL when set, for building absolute links. X-Forwarded-Proto is
+// only trusted to switch between http and https.
+func requestBaseURL(c *gin.Context) string {
+	if base := getEnv("PUBLIC_URL", ""); base != "" {
+		return strings.TrimSuffix(base, "/")
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if forwarded := strings.ToLower(c.GetHeader("X-Forwarded-Proto")); forwarded == "http" || forwarded == "https" {
+		scheme = forwarded
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// newRecipeExport loads what exporting a recipe needs beyond the recipe.
+func newRecipeExport(c *gin.Context, recipe Recipe) (RecipeExport, error) {
+	return loadRecipeExport(recipe, requestBaseURL(c))
+}
+
+// loadRecipeExport loads what exporting a recipe needs beyond the recipe,
+// linking to it under baseURL.
+func loadRecipeExport(recipe Recipe, baseURL string) (RecipeExport, error) {
+	export := RecipeExport{Recipe: recipe, URL: fmt.Sprintf("%s/recipes/%d", baseURL, recipe.ID)}
+	if err := DB.Where("recipe_id = ?", recipe.ID).Order("id").Find(&export.Ingredients).Error; err != nil {
+		return export, err
+	}
+	var author User
+	if err := DB.Select("username").First(&author, recipe.UserID).Error; err == nil {
+		export.Author = author.Username
+	}
+
+	// An uploaded hero image comes before any images the recipe was
+	// imported with.
+	var hero RecipeImage
+	if err := DB.Where("recipe_id = ? AND step_position IS NULL AND review_id IS NULL", recipe.ID).Order("id DESC").Limit(1).Find(&hero).Error; err != nil {
+		return export, err
+	}
+	if hero.ID != 0 {
+		if err := hero.withURLs(!recipeIsPublic(recipe)); err != nil {
+			return export, err
+		}
+		if strings.HasPrefix(hero.URL, "/") {
+			hero.URL = baseURL + hero.URL
+		}
+		export.Recipe.ImageURLs = append([]string{hero.URL}, recipe.ImageURLs...)
+	}
+	return export, nil
+}
+
+// respondRecipe writes a recipe in the given media type, defaulting to our
+// own JSON shape.
+func respondRecipe(c *gin.Context, recipe Recipe, mediaType string) {
+	if err := attachRecipeImages(&recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipe"})
+		return
+	}
+	if mediaType != mediaTypeJSONLD && mediaType != mediaTypeMarkdown && mediaType != mediaTypeCooklang {
+		c.JSON(http.StatusOK, recipe)
+		return
+	}
+
+	export, err := newRecipeExport(c, recipe)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export recipe"})
+		return
+	}
+	switch mediaType {
+	case mediaTypeMarkdown:
+		c.Data(http.StatusOK, mediaTypeMarkdown+"; charset=utf-8", []byte(export.Markdown()))
+		return
+	case mediaTypeCooklang:
+		c.Data(http.StatusOK, mediaTypeCooklang+"; charset=utf-8", []byte(export.Cooklang()))
+		return
+	}
+	body, err := json.Marshal(export.JSONLD())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export recipe"})
+		return
+	}
+	c.Data(http.StatusOK, mediaTypeJSONLD+"; charset=utf-8", body)
+}
+
+// ExportRecipe handles the GET /recipes/:id/export endpoint, an alias of
+// GetRecipe content negotiation for clients that cannot set Accept. The
+// format parameter is jsonld (the default), markdown, cooklang or json.
+func ExportRecipe(c *gin.Context) {
+	mediaType, ok := exportFormats[strings.ToLower(c.DefaultQuery("format", "jsonld"))]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected jsonld, markdown, cooklang or json"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB.Scopes(recipeDetails), &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if err := attachForkAttribution(c, &recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipe"})
+		return
+	}
+	respondRecipe(c, recipe, mediaType)
+}
@@ -0,0 +1,129 @@
+// recipe_export_test.go
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// exportFixture returns a recipe export exercising sections, tags and nutrition.
+func exportFixture() RecipeExport {
+	return RecipeExport{
+		Recipe: Recipe{
+			ID:               3,
+			Title:            "Lemon *Bars*",
+			Servings:         12,
+			YieldAmount:      24,
+			YieldUnit:        "bars",
+			PrepTimeMinutes:  20,
+			CookTimeMinutes:  45,
+			TotalTimeMinutes: 65,
+			Difficulty:       "easy",
+			Course:           "dessert",
+			Equipment:        []string{"baking pan"},
+			Tags:             []Tag{{Name: "citrus"}, {Name: "bake sale"}},
+			Nutrition:        &RecipeNutrition{PerServing: NutritionFacts{Calories: 210, ProteinG: 3, SodiumMg: 95}},
+			Steps: []RecipeStep{
+				{Section: "Crust", Text: "Press the dough into the pan."},
+				{Section: "Crust", Text: "Bake for 20 minutes."},
+				{Section: "Filling", Text: "Whisk eggs, sugar and lemon juice."},
+			},
+		},
+		Ingredients: []Ingredient{{Quantity: "2 cup", Name: "flour"}, {Name: "lemons"}},
+		Author:      "ana",
+		URL:         "https://recipes.example.com/recipes/3",
+	}
+}
+
+// TestFormatISODuration verifies minutes format as ISO 8601 durations that
+// the importer reads back.
+func TestFormatISODuration(t *testing.T) {
+	assert.Equal(t, "", FormatISODuration(0))
+	assert.Equal(t, "PT45M", FormatISODuration(45))
+	assert.Equal(t, "PT2H", FormatISODuration(120))
+	assert.Equal(t, "PT1H5M", FormatISODuration(65))
+
+	d, ok := ParseISODuration(FormatISODuration(65))
+	assert.True(t, ok)
+	assert.Equal(t, 65*time.Minute, d)
+}
+
+// TestRecipeJSONLD verifies the schema.org document, including sections.
+func TestRecipeJSONLD(t *testing.T) {
+	doc := exportFixture().JSONLD()
+	assert.Equal(t, "https://schema.org", doc.Context)
+	assert.Equal(t, "Recipe", doc.Type)
+	assert.Equal(t, "PT1H5M", doc.TotalTime)
+	assert.Equal(t, []string{"12", "24 bars"}, doc.RecipeYield)
+	assert.Equal(t, []string{"2 cup flour", "lemons"}, doc.RecipeIngredient)
+	assert.Equal(t, "citrus, bake sale", doc.Keywords)
+	assert.Equal(t, "210 calories", doc.Nutrition.Calories)
+	assert.Equal(t, "95 mg", doc.Nutrition.SodiumContent)
+	assert.Equal(t, "", doc.Nutrition.FatContent)
+	assert.Equal(t, "ana", doc.Author.Name)
+	assert.Nil(t, doc.AggregateRating)
+
+	encoded, err := json.Marshal(doc)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"recipeInstructions":[{"@type":"HowToSection","name":"Crust","itemListElement":[{"@type":"HowToStep","text":"Press the dough into the pan."},{"@type":"HowToStep","text":"Bake for 20 minutes."}]},{"@type":"HowToSection","name":"Filling"`)
+}
+
+// TestRecipeJSONLDRating verifies that reviewed recipes carry their rating.
+func TestRecipeJSONLDRating(t *testing.T) {
+	export := exportFixture()
+	export.Recipe.RatingAverage, export.Recipe.RatingCount = 4.2857, 7
+	rating := export.JSONLD().AggregateRating
+	assert.Equal(t, &schemaAggregateRating{Type: "AggregateRating", RatingValue: 4.3, RatingCount: 7, BestRating: 5, WorstRating: 1}, rating)
+}
+
+// TestRecipeJSONLDRoundTrip verifies an exported document imports back.
+func TestRecipeJSONLDRoundTrip(t *testing.T) {
+	encoded, err := json.Marshal(exportFixture().JSONLD())
+	assert.NoError(t, err)
+	page := `<script type="application/ld+json">` + string(encoded) + `</script>`
+
+	imported, err := ParseRecipeHTML(strings.NewReader(page))
+	assert.NoError(t, err)
+	assert.Equal(t, "Lemon *Bars*", imported.Title)
+	assert.Equal(t, 12, imported.Servings)
+	assert.Equal(t, 65, imported.TotalTimeMinutes)
+	assert.Equal(t, "dessert", imported.Course)
+	assert.Len(t, imported.Steps, 3)
+	assert.Equal(t, "Filling", imported.Steps[2].Section)
+	assert.Equal(t, 210.0, imported.Nutrition.Calories)
+}
+
+// TestRecipeMarkdown verifies the Markdown recipe card.
+func TestRecipeMarkdown(t *testing.T) {
+	markdown := exportFixture().Markdown()
+	assert.True(t, strings.HasPrefix(markdown, "# Lemon \\*Bars\\*\n\n"))
+	assert.Contains(t, markdown, "*By ana · Serves 12 · Makes 24 bars · Prep 20 min · Cook 45 min · Total 1 h 5 min · Easy*")
+	assert.Contains(t, markdown, "## Ingredients\n\n- 2 cup flour\n- lemons\n")
+	assert.Contains(t, markdown, "### Crust\n\n1. Press the dough into the pan.\n2. Bake for 20 minutes.\n\n### Filling\n\n1. Whisk eggs, sugar and lemon juice.\n")
+	assert.Contains(t, markdown, "| 210 | 3 g | 0 g | 0 g | 0 g | 0 g | 95 mg |")
+	assert.Contains(t, markdown, "Tags: citrus, bake sale")
+}
+
+// TestRequestBaseURL verifies X-Forwarded-Proto can only pick http or https.
+func TestRequestBaseURL(t *testing.T) {
+	t.Setenv("PUBLIC_URL", "")
+	gin.SetMode(gin.TestMode)
+	for proto, want := range map[string]string{
+		"":                    "http://recipes.example",
+		"https":               "https://recipes.example",
+		"HTTPS":               "https://recipes.example",
+		"javascript:alert(1)": "http://recipes.example",
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "http://recipes.example/recipes/3", nil)
+		c.Request.Header.Set("X-Forwarded-Proto", proto)
+		assert.Equal(t, want, requestBaseURL(c), proto)
+	}
+}
@@ -0,0 +1,776 @@
+// recipe_import.go
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/html"
+	"gorm.io/gorm"
+)
+
+// maxImportBytes bounds the size of an uploaded page.
+const maxImportBytes = 5 << 20
+
+// errNoRecipeFound is returned when a page has no schema.org Recipe.
+var errNoRecipeFound = errors.New("no schema.org Recipe found in the document")
+
+// isoDurationPattern matches ISO 8601 durations such as "PT1H30M" or "P1DT2H".
+var isoDurationPattern = regexp.MustCompile(`(?i)^P(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// leadingNumberPattern finds the first number in a text such as "Serves 4".
+var leadingNumberPattern = regexp.MustCompile(`(\d+(?:\.\d+)?)`)
+
+// htmlTagPattern matches markup left inside JSON-LD text values.
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// blockTagPattern matches markup that separates paragraphs or list items.
+var blockTagPattern = regexp.MustCompile(`(?i)<\s*(br|/p|/li|/div)\s*/?>`)
+
+// ImportedIngredient is an ingredient line split into quantity and name.
+type ImportedIngredient struct {
+	Text     string `json:"text"`
+	Name     string `json:"name"`
+	Quantity string `json:"quantity"`
+}
+
+// ImportedRecipe is a recipe extracted from a web page or another app's
+// export. It is returned as a preview and, once committed, becomes a Recipe
+// with Ingredient rows. ExternalID identifies the recipe in the app it came
+// from, so that importing it again updates rather than duplicates it.
+type ImportedRecipe struct {
+	Title            string               `json:"title"`
+	Ingredients      []ImportedIngredient `json:"ingredients"`
+	Steps            []recipeStepInput    `json:"steps"`
+	Servings         int                  `json:"servings"`
+	YieldAmount      float64              `json:"yield_amount"`
+	YieldUnit        string               `json:"yield_unit"`
+	PrepTimeMinutes  int                  `json:"prep_time_minutes"`
+	CookTimeMinutes  int                  `json:"cook_time_minutes"`
+	TotalTimeMinutes int                  `json:"total_time_minutes"`
+	Difficulty       string               `json:"difficulty,omitempty"`
+	Equipment        []string             `json:"equipment,omitempty"`
+	Cuisine          string               `json:"cuisine"`
+	Course           string               `json:"course"`
+	Tags             []string             `json:"tags"`
+	Nutrition        *NutritionFacts      `json:"nutrition,omitempty"`
+	Images           []string             `json:"images"`
+	SourceURL        string               `json:"source_url"`
+	Format           string               `json:"format"`
+	ExternalID       string               `json:"external_id,omitempty"`
+}
+
+// ParseRecipeHTML extracts a schema.org Recipe from an HTML document,
+// preferring JSON-LD and falling back to microdata.
+func ParseRecipeHTML(r io.Reader) (ImportedRecipe, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return ImportedRecipe{}, err
+	}
+
+	var item map[string]interface{}
+	format := "json-ld"
+	for _, script := range findElements(doc, isJSONLDScript) {
+		var data interface{}
+		if json.Unmarshal([]byte(nodeText(script)), &data) != nil {
+			continue
+		}
+		if item = findSchemaRecipe(data, 0); item != nil {
+			break
+		}
+	}
+	if item == nil {
+		format = "microdata"
+		if nodes := findElements(doc, isMicrodataRecipe); len(nodes) > 0 {
+			item = microdataItem(nodes[0])
+		}
+	}
+	if item == nil {
+		return ImportedRecipe{}, errNoRecipeFound
+	}
+
+	imported := recipeFromSchema(item)
+	imported.Format = format
+	if imported.SourceURL == "" {
+		imported.SourceURL = canonicalURL(doc)
+	}
+	return imported, nil
+}
+
+// findElements returns the element nodes below n matching fn, in document order.
+func findElements(n *html.Node, fn func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && fn(n) {
+			found = append(found, n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return found
+}
+
+// attr returns the value of an attribute of n.
+func attr(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// nodeText returns the concatenated text inside n, with a line break after
+// each block element so paragraphs and list items stay apart.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteString(" ")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		switch n.Data {
+		case "p", "li", "div", "br", "h1", "h2", "h3", "h4", "h5", "h6":
+			if n.Type == html.ElementNode {
+				b.WriteString("\n")
+			}
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+// isJSONLDScript reports whether n is a JSON-LD script element.
+func isJSONLDScript(n *html.Node) bool {
+	typ, _ := attr(n, "type")
+	return n.Data == "script" && strings.EqualFold(strings.TrimSpace(typ), "application/ld+json")
+}
+
+// isMicrodataRecipe reports whether n is the root of a microdata Recipe.
+func isMicrodataRecipe(n *html.Node) bool {
+	_, scoped := attr(n, "itemscope")
+	typ, _ := attr(n, "itemtype")
+	return scoped && hasSchemaType(strings.Fields(typ), "Recipe")
+}
+
+// canonicalURL returns the page's canonical or Open Graph URL.
+func canonicalURL(doc *html.Node) string {
+	for _, n := range findElements(doc, func(n *html.Node) bool { return n.Data == "link" || n.Data == "meta" }) {
+		rel, _ := attr(n, "rel")
+		property, _ := attr(n, "property")
+		switch {
+		case n.Data == "link" && strings.EqualFold(rel, "canonical"):
+			href, _ := attr(n, "href")
+			return strings.TrimSpace(href)
+		case n.Data == "meta" && property == "og:url":
+			content, _ := attr(n, "content")
+			return strings.TrimSpace(content)
+		}
+	}
+	return ""
+}
+
+// hasSchemaType reports whether any of the given types names the schema.org
+// type want, written as "Recipe", "schema:Recipe" or a full URL.
+func hasSchemaType(types []string, want string) bool {
+	for _, t := range types {
+		if i := strings.LastIndexAny(t, "/:"); i >= 0 {
+			t = t[i+1:]
+		}
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTypes returns the @type of a JSON-LD node as a list.
+func schemaTypes(node map[string]interface{}) []string {
+	switch t := node["@type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// findSchemaRecipe searches a JSON-LD document, including @graph lists and
+// nested entities, for the first Recipe node.
+func findSchemaRecipe(data interface{}, depth int) map[string]interface{} {
+	if depth > 8 {
+		return nil
+	}
+	switch v := data.(type) {
+	case map[string]interface{}:
+		if hasSchemaType(schemaTypes(v), "Recipe") {
+			return v
+		}
+		for _, key := range []string{"@graph", "mainEntity", "mainEntityOfPage", "itemListElement", "item"} {
+			if found := findSchemaRecipe(v[key], depth+1); found != nil {
+				return found
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if found := findSchemaRecipe(item, depth+1); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// microdataItem converts a microdata item into the shape of a JSON-LD node.
+// Repeated properties become lists and nested items become nested nodes.
+func microdataItem(root *html.Node) map[string]interface{} {
+	item := map[string]interface{}{}
+	if typ, ok := attr(root, "itemtype"); ok {
+		types := make([]interface{}, 0, 1)
+		for _, t := range strings.Fields(typ) {
+			types = append(types, t)
+		}
+		item["@type"] = types
+	}
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			props, hasProp := attr(child, "itemprop")
+			_, scoped := attr(child, "itemscope")
+			if hasProp {
+				var value interface{}
+				if scoped {
+					value = microdataItem(child)
+				} else {
+					value = microdataValue(child)
+				}
+				for _, prop := range strings.Fields(props) {
+					addProperty(item, prop, value)
+				}
+			}
+			if !scoped {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return item
+}
+
+// addProperty sets a property, collecting repeated values into a list.
+func addProperty(item map[string]interface{}, name string, value interface{}) {
+	switch existing := item[name].(type) {
+	case nil:
+		item[name] = value
+	case []interface{}:
+		item[name] = append(existing, value)
+	default:
+		item[name] = []interface{}{existing, value}
+	}
+}
+
+// microdataValue returns the value of a microdata property element.
+func microdataValue(n *html.Node) string {
+	var names []string
+	switch n.Data {
+	case "meta":
+		names = []string{"content"}
+	case "img", "audio", "video", "source", "embed", "iframe":
+		names = []string{"src"}
+	case "a", "link", "area":
+		names = []string{"href"}
+	case "time":
+		names = []string{"datetime"}
+	case "data", "meter":
+		names = []string{"value"}
+	}
+	for _, name := range append([]string{"content"}, names...) {
+		if value, ok := attr(n, name); ok {
+			return value
+		}
+	}
+	return nodeText(n)
+}
+
+// cleanText unescapes entities, strips markup and collapses whitespace.
+func cleanText(s string) string {
+	s = htmlTagPattern.ReplaceAllString(html.UnescapeString(s), " ")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// schemaText returns the text of a property value, reading the text, name
+// or @value of nested nodes and the first entry of lists.
+func schemaText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return cleanText(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		for _, key := range []string{"text", "name", "@value", "url"} {
+			if text := schemaText(v[key]); text != "" {
+				return text
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if text := schemaText(item); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// schemaTexts returns the texts of a property that may be a list.
+func schemaTexts(v interface{}) []string {
+	var texts []string
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			texts = append(texts, schemaTexts(item)...)
+		}
+		return texts
+	}
+	if text := schemaText(v); text != "" {
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+// ParseISODuration parses an ISO 8601 duration such as "PT1H30M". Years and
+// months are not accepted as their length varies.
+func ParseISODuration(s string) (time.Duration, bool) {
+	m := isoDurationPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || strings.EqualFold(strings.TrimSpace(s), "P") {
+		return 0, false
+	}
+	var total float64
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] != "" {
+			value, _ := strconv.ParseFloat(m[i+1], 64)
+			total += value * float64(unit)
+		}
+	}
+	return time.Duration(total), true
+}
+
+// schemaMinutes reads a duration property in minutes, accepting ISO 8601
+// durations and free text such as "1 hour 20 mins".
+func schemaMinutes(v interface{}) int {
+	text := schemaText(v)
+	if text == "" {
+		return 0
+	}
+	if d, ok := ParseISODuration(text); ok {
+		return int(math.Round(d.Minutes()))
+	}
+	return int(math.Round(float64(DetectStepDuration(text)) / 60))
+}
+
+// schemaYield reads recipeYield, which may be a number, a text such as
+// "12 cookies" or a list of both. Yields counted in servings or people also
+// set the servings.
+func schemaYield(v interface{}) (amount float64, unit string, servings int) {
+	for _, text := range schemaTexts(v) {
+		loc := leadingNumberPattern.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		value, _ := strconv.ParseFloat(text[loc[0]:loc[1]], 64)
+		rest := strings.ToLower(strings.Trim(strings.TrimSpace(text[loc[1]:]), ".()"))
+		if amount == 0 {
+			amount = value
+		}
+		if value != amount {
+			continue
+		}
+		switch singularize(rest) {
+		case "", "serving", "person", "people", "portion":
+			servings = int(math.Round(value))
+		default:
+			if unit == "" {
+				unit = rest
+			}
+		}
+	}
+	if unit == "" && servings > 0 {
+		unit = "servings"
+	}
+	return amount, unit, servings
+}
+
+// schemaSteps flattens recipeInstructions into steps. Instructions may be
+// plain text, a list of texts, HowToStep nodes or HowToSection nodes whose
+// name becomes the section of their steps.
+func schemaSteps(v interface{}, section string) []recipeStepInput {
+	var steps []recipeStepInput
+	switch v := v.(type) {
+	case string:
+		for _, step := range SplitInstructions(cleanInstructionText(v)) {
+			stepSection := step.Section
+			if stepSection == "" {
+				stepSection = section
+			}
+			steps = append(steps, recipeStepInput{Section: stepSection, Text: step.Text})
+		}
+	case []interface{}:
+		for _, item := range v {
+			steps = append(steps, schemaSteps(item, section)...)
+		}
+	case map[string]interface{}:
+		types := schemaTypes(v)
+		if hasSchemaType(types, "HowToSection") {
+			return schemaSteps(v["itemListElement"], schemaText(v["name"]))
+		}
+		if text := schemaText(v["text"]); text != "" {
+			return []recipeStepInput{{Section: section, Text: text}}
+		}
+		if v["itemListElement"] != nil {
+			return schemaSteps(v["itemListElement"], section)
+		}
+		if text := schemaText(v["name"]); text != "" {
+			return []recipeStepInput{{Section: section, Text: text}}
+		}
+	}
+	return steps
+}
+
+// cleanInstructionText converts block markup in instruction text to line
+// breaks before stripping it, so paragraphs stay separate steps.
+func cleanInstructionText(s string) string {
+	s = blockTagPattern.ReplaceAllString(s, "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = cleanText(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// schemaNutrition reads a NutritionInformation node. Amounts are per
+// serving; sodium given in grams is converted to milligrams.
+func schemaNutrition(v interface{}) *NutritionFacts {
+	node, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	amount := func(key string) (float64, string) {
+		text := strings.ReplaceAll(schemaText(node[key]), ",", "")
+		loc := leadingNumberPattern.FindStringIndex(text)
+		if loc == nil {
+			return 0, ""
+		}
+		value, _ := strconv.ParseFloat(text[loc[0]:loc[1]], 64)
+		return value, strings.ToLower(strings.TrimSpace(text[loc[1]:]))
+	}
+
+	facts := NutritionFacts{}
+	facts.Calories, _ = amount("calories")
+	facts.ProteinG, _ = amount("proteinContent")
+	facts.FatG, _ = amount("fatContent")
+	facts.CarbsG, _ = amount("carbohydrateContent")
+	facts.FiberG, _ = amount("fiberContent")
+	facts.SugarG, _ = amount("sugarContent")
+	sodium, unit := amount("sodiumContent")
+	if strings.HasPrefix(unit, "g") {
+		sodium *= 1000
+	}
+	facts.SodiumMg = sodium
+	if facts == (NutritionFacts{}) {
+		return nil
+	}
+	return &facts
+}
+
+// schemaImages reads image URLs from text, ImageObject nodes or lists.
+func schemaImages(v interface{}) []string {
+	images := []string{}
+	seen := map[string]bool{}
+	var add func(interface{})
+	add = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, item := range v {
+				add(item)
+			}
+		case map[string]interface{}:
+			add(v["url"])
+			add(v["contentUrl"])
+		case string:
+			url := strings.TrimSpace(v)
+			if url != "" && !seen[url] {
+				seen[url] = true
+				images = append(images, url)
+			}
+		}
+	}
+	add(v)
+	return images
+}
+
+// schemaCourse maps a recipeCategory to one of our courses.
+func schemaCourse(categories []string) string {
+	for _, category := range categories {
+		category = strings.ToLower(category)
+		category = strings.TrimSuffix(strings.TrimSuffix(category, " course"), " dish")
+		category = singularize(category)
+		if category == "entree" || category == "entrée" {
+			category = "main"
+		}
+		if checkEnum("course", []string{category}, recipeCourses) == nil {
+			return category
+		}
+	}
+	return ""
+}
+
+// importedIngredients splits ingredient lines into quantity and name.
+func importedIngredients(lines []string) []ImportedIngredient {
+	ingredients := make([]ImportedIngredient, 0, len(lines))
+	for _, line := range lines {
+		quantity, name := ParseIngredientLine(line)
+		ingredient := ImportedIngredient{Text: line, Name: name}
+		if quantity.Amount > 0 {
+			ingredient.Quantity = quantity.String()
+		}
+		ingredients = append(ingredients, ingredient)
+	}
+	return ingredients
+}
+
+// recipeFromSchema maps a schema.org Recipe node onto an imported recipe.
+func recipeFromSchema(node map[string]interface{}) ImportedRecipe {
+	imported := ImportedRecipe{
+		Title:     schemaText(node["name"]),
+		Images:    schemaImages(node["image"]),
+		SourceURL: schemaText(node["url"]),
+		Tags:      []string{},
+	}
+	if imported.Title == "" {
+		imported.Title = schemaText(node["headline"])
+	}
+
+	lines := schemaTexts(node["recipeIngredient"])
+	if len(lines) == 0 {
+		lines = schemaTexts(node["ingredients"])
+	}
+	imported.Ingredients = importedIngredients(lines)
+
+	imported.Steps = schemaSteps(node["recipeInstructions"], "")
+	if imported.Steps == nil {
+		imported.Steps = []recipeStepInput{}
+	}
+
+	imported.YieldAmount, imported.YieldUnit, imported.Servings = schemaYield(node["recipeYield"])
+	if imported.Servings == 0 {
+		imported.Servings = 1
+	}
+	imported.PrepTimeMinutes = schemaMinutes(node["prepTime"])
+	imported.CookTimeMinutes = schemaMinutes(node["cookTime"])
+	imported.TotalTimeMinutes = schemaMinutes(node["totalTime"])
+	if imported.TotalTimeMinutes == 0 {
+		imported.TotalTimeMinutes = imported.PrepTimeMinutes + imported.CookTimeMinutes
+	}
+
+	imported.Cuisine = normalizeCuisine(schemaText(node["recipeCuisine"]))
+	categories := schemaTexts(node["recipeCategory"])
+	imported.Course = schemaCourse(categories)
+
+	var keywords []string
+	for _, text := range schemaTexts(node["keywords"]) {
+		keywords = append(keywords, strings.Split(text, ",")...)
+	}
+	seen := map[string]bool{}
+	for _, keyword := range append(categories, keywords...) {
+		slug := Slugify(keyword)
+		if slug == "" || seen[slug] || len(imported.Tags) >= 20 {
+			continue
+		}
+		seen[slug] = true
+		imported.Tags = append(imported.Tags, strings.TrimSpace(keyword))
+	}
+
+	imported.Nutrition = schemaNutrition(node["nutrition"])
+	return imported
+}
+
+// createImportedRecipe stores an imported recipe for a user.
+func createImportedRecipe(userID uint, imported ImportedRecipe) (Recipe, error) {
+	lines := make([]string, 0, len(imported.Ingredients))
+	for _, ingredient := range imported.Ingredients {
+		lines = append(lines, ingredient.Text)
+	}
+	now := time.Now()
+	recipe := Recipe{
+		Title:            imported.Title,
+		Ingredients:      strings.Join(lines, "\n"),
+		Servings:         imported.Servings,
+		PrepTimeMinutes:  imported.PrepTimeMinutes,
+		CookTimeMinutes:  imported.CookTimeMinutes,
+		TotalTimeMinutes: imported.TotalTimeMinutes,
+		YieldAmount:      imported.YieldAmount,
+		YieldUnit:        imported.YieldUnit,
+		Cuisine:          imported.Cuisine,
+		Course:           imported.Course,
+		Equipment:        normalizeEquipment(imported.Equipment),
+		SourceURL:        imported.SourceURL,
+		ImageURLs:        imported.Images,
+		Visibility:       defaultVisibility(userID),
+		Status:           RecipeStatusPublished,
+		PublishedAt:      &now,
+		UserID:           userID,
+	}
+	if recipe.Servings == 0 {
+		recipe.Servings = 1
+	}
+	// Calories stated by the page win over the computed estimate.
+	if imported.Nutrition != nil && imported.Nutrition.Calories > 0 {
+		recipe.Calories, recipe.CaloriesManual = int(math.Round(imported.Nutrition.Calories)), true
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&recipe).Error; err != nil {
+			return err
+		}
+		for _, ingredient := range imported.Ingredients {
+			row := Ingredient{RecipeID: recipe.ID, Name: ingredient.Name, Quantity: ingredient.Quantity}
+			if err := tx.Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		return saveRecipeTags(tx, recipe, imported.Tags)
+	})
+	if err != nil {
+		return recipe, err
+	}
+	if err := saveRecipeSteps(DB, &recipe, imported.Steps); err != nil {
+		return recipe, err
+	}
+	saveRecipeRevisionLogged(recipe.ID, userID, "Imported recipe")
+	refreshRecipeNutritionLogged(recipe.ID)
+	recordRecipeActivity(recipe)
+	return recipe, nil
+}
+
+// importDocument returns the uploaded page: the "file" field of a multipart
+// form, or else the raw request body.
+func importDocument(c *gin.Context) (io.ReadCloser, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		return header.Open()
+	}
+	return c.Request.Body, nil
+}
+
+// uploadName returns the file name of an upload: the name query parameter,
+// or else the file name of a multipart upload.
+func uploadName(c *gin.Context) string {
+	if name := c.Query("name"); name != "" {
+		return name
+	}
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		if header, err := c.FormFile("file"); err == nil {
+			return header.Filename
+		}
+	}
+	return ""
+}
+
+// ImportRecipe handles the POST /recipes/import endpoint. The body is a
+// saved web page, or a Cooklang recipe with format=cooklang or a .cook file
+// name; nothing is fetched. The extracted recipe is returned as a preview
+// unless commit=true, which stores it.
+func ImportRecipe(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	format := strings.ToLower(c.DefaultQuery("format", "html"))
+	if err := checkEnum("format", []string{format}, []string{"html", ImportFormatCooklang}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	document, err := importDocument(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded document"})
+		return
+	}
+	defer document.Close()
+
+	name := uploadName(c)
+	var imported ImportedRecipe
+	if format == ImportFormatCooklang || strings.HasSuffix(name, cooklangExtension) {
+		imported, err = ParseCooklang(document, name)
+	} else {
+		imported, err = ParseRecipeHTML(io.LimitReader(document, maxImportBytes))
+	}
+	if errors.Is(err, errNoRecipeFound) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to parse document"})
+		return
+	}
+
+	if c.Query("commit") != "true" {
+		c.JSON(http.StatusOK, imported)
+		return
+	}
+
+	var missing []string
+	if imported.Title == "" {
+		missing = append(missing, "title")
+	}
+	if len(imported.Ingredients) == 0 {
+		missing = append(missing, "ingredients")
+	}
+	if len(imported.Steps) == 0 {
+		missing = append(missing, "instructions")
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Imported recipe is missing " + strings.Join(missing, ", "),
+			"preview": imported,
+		})
+		return
+	}
+
+	recipe, err := createImportedRecipe(userID, imported)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recipe"})
+		return
+	}
+	DB.Scopes(recipeDetails).First(&recipe, recipe.ID)
+	c.JSON(http.StatusCreated, recipe)
+}
@@ -0,0 +1,131 @@
+// recipe_import_test.go
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseISODuration verifies ISO 8601 durations used for recipe times.
+func TestParseISODuration(t *testing.T) {
+	for input, want := range map[string]time.Duration{
+		"PT15M":      15 * time.Minute,
+		"PT1H30M":    90 * time.Minute,
+		"P0DT2H":     2 * time.Hour,
+		"P1D":        24 * time.Hour,
+		"pt0.5h":     30 * time.Minute,
+		"PT1H0M30S":  time.Hour + 30*time.Second,
+		"PT90M":      90 * time.Minute,
+		" PT20M   ":  20 * time.Minute,
+		"P1W":        7 * 24 * time.Hour,
+		"PT0H10M0S":  10 * time.Minute,
+		"P0Y0M0DT5M": 0,
+	} {
+		got, ok := ParseISODuration(input)
+		if want == 0 {
+			assert.False(t, ok, input)
+			continue
+		}
+		assert.True(t, ok, input)
+		assert.Equal(t, want, got, input)
+	}
+	_, ok := ParseISODuration("P")
+	assert.False(t, ok)
+}
+
+// TestParseRecipeHTMLJSONLD verifies a JSON-LD recipe inside an @graph is
+// mapped, including sections, yield, times, nutrition and images.
+func TestParseRecipeHTMLJSONLD(t *testing.T) {
+	page := `<html><head>
+<link rel="canonical" href="https://example.com/pancakes">
+<script type="application/ld+json">{"@context":"https://schema.org","@graph":[
+ {"@type":"WebPage","name":"Pancakes page"},
+ {"@type":["Recipe"],"name":"Fluffy Pancakes &amp; Syrup",
+  "image":[{"@type":"ImageObject","url":"https://example.com/a.jpg"},"https://example.com/b.jpg"],
+  "recipeYield":["4","4 servings"],
+  "prepTime":"PT10M","cookTime":"PT20M",
+  "recipeCuisine":"American","recipeCategory":"Breakfast","keywords":"easy, weekend",
+  "recipeIngredient":["1 1/2 cups flour","2 eggs","Salt to taste"],
+  "recipeInstructions":[
+   {"@type":"HowToSection","name":"Batter","itemListElement":[
+    {"@type":"HowToStep","text":"Whisk the flour and eggs."}]},
+   {"@type":"HowToStep","text":"Fry in a hot pan for 3 minutes."}],
+  "nutrition":{"@type":"NutritionInformation","calories":"240 calories","proteinContent":"8 g","sodiumContent":"0.3 g"}}
+]}</script></head><body></body></html>`
+
+	imported, err := ParseRecipeHTML(strings.NewReader(page))
+	assert.NoError(t, err)
+	assert.Equal(t, "json-ld", imported.Format)
+	assert.Equal(t, "Fluffy Pancakes & Syrup", imported.Title)
+	assert.Equal(t, []string{"https://example.com/a.jpg", "https://example.com/b.jpg"}, imported.Images)
+	assert.Equal(t, "https://example.com/pancakes", imported.SourceURL)
+	assert.Equal(t, 4, imported.Servings)
+	assert.Equal(t, 4.0, imported.YieldAmount)
+	assert.Equal(t, "servings", imported.YieldUnit)
+	assert.Equal(t, 10, imported.PrepTimeMinutes)
+	assert.Equal(t, 20, imported.CookTimeMinutes)
+	assert.Equal(t, 30, imported.TotalTimeMinutes)
+	assert.Equal(t, "american", imported.Cuisine)
+	assert.Equal(t, "breakfast", imported.Course)
+	assert.Equal(t, []string{"Breakfast", "easy", "weekend"}, imported.Tags)
+
+	if assert.Len(t, imported.Ingredients, 3) {
+		assert.Equal(t, ImportedIngredient{Text: "1 1/2 cups flour", Name: "flour", Quantity: "1.5 cup"}, imported.Ingredients[0])
+		assert.Equal(t, "", imported.Ingredients[2].Quantity)
+	}
+	assert.Equal(t, []recipeStepInput{
+		{Section: "Batter", Text: "Whisk the flour and eggs."},
+		{Text: "Fry in a hot pan for 3 minutes."},
+	}, imported.Steps)
+	if assert.NotNil(t, imported.Nutrition) {
+		assert.Equal(t, 240.0, imported.Nutrition.Calories)
+		assert.Equal(t, 8.0, imported.Nutrition.ProteinG)
+		assert.Equal(t, 300.0, imported.Nutrition.SodiumMg)
+	}
+}
+
+// TestParseRecipeHTMLMicrodata verifies the microdata fallback.
+func TestParseRecipeHTMLMicrodata(t *testing.T) {
+	page := `<html><body>
+<div itemscope itemtype="http://schema.org/Recipe">
+  <h1 itemprop="name">Tomato Soup</h1>
+  <img itemprop="image" src="https://example.com/soup.jpg">
+  <meta itemprop="totalTime" content="PT45M">
+  <span itemprop="recipeYield">Makes 6 bowls</span>
+  <ul>
+    <li itemprop="recipeIngredient">2 lb tomatoes</li>
+    <li itemprop="recipeIngredient">1 onion</li>
+  </ul>
+  <div itemprop="recipeInstructions">
+    <p>Roast the tomatoes.</p>
+    <p>Blend with the onion.</p>
+  </div>
+  <div itemprop="nutrition" itemscope itemtype="http://schema.org/NutritionInformation">
+    <span itemprop="calories">180 kcal</span>
+  </div>
+</div></body></html>`
+
+	imported, err := ParseRecipeHTML(strings.NewReader(page))
+	assert.NoError(t, err)
+	assert.Equal(t, "microdata", imported.Format)
+	assert.Equal(t, "Tomato Soup", imported.Title)
+	assert.Equal(t, []string{"https://example.com/soup.jpg"}, imported.Images)
+	assert.Equal(t, 45, imported.TotalTimeMinutes)
+	assert.Equal(t, 6.0, imported.YieldAmount)
+	assert.Equal(t, "bowls", imported.YieldUnit)
+	assert.Equal(t, 1, imported.Servings)
+	assert.Len(t, imported.Ingredients, 2)
+	assert.Equal(t, []recipeStepInput{{Text: "Roast the tomatoes."}, {Text: "Blend with the onion."}}, imported.Steps)
+	if assert.NotNil(t, imported.Nutrition) {
+		assert.Equal(t, 180.0, imported.Nutrition.Calories)
+	}
+}
+
+// TestParseRecipeHTMLWithoutRecipe verifies pages without a recipe are rejected.
+func TestParseRecipeHTMLWithoutRecipe(t *testing.T) {
+	_, err := ParseRecipeHTML(strings.NewReader(`<html><script type="application/ld+json">{"@type":"Article"}</script></html>`))
+	assert.ErrorIs(t, err, errNoRecipeFound)
+}
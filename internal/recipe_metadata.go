@@ -0,0 +1,235 @@
+// recipe_metadata.go
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recipeDifficulties and recipeCourses are the accepted enum values. The
+// binding tags on recipe input repeat them for validation.
+var (
+	recipeDifficulties = []string{"easy", "medium", "hard"}
+	recipeCourses      = []string{"breakfast", "brunch", "lunch", "dinner", "main", "appetizer", "side", "dessert", "snack", "drink"}
+)
+
+// recipeMetadataInput holds the metadata fields accepted on recipe create
+// and update. Total time defaults to prep plus cook time.
+type recipeMetadataInput struct {
+	PrepTimeMinutes  *int     `json:"prep_time_minutes" binding:"omitempty,min=0,max=10080"`
+	CookTimeMinutes  *int     `json:"cook_time_minutes" binding:"omitempty,min=0,max=10080"`
+	TotalTimeMinutes *int     `json:"total_time_minutes" binding:"omitempty,min=0,max=20160"`
+	YieldAmount      *float64 `json:"yield_amount" binding:"omitempty,gt=0"`
+	YieldUnit        *string  `json:"yield_unit" binding:"omitempty,max=50"`
+	Difficulty       *string  `json:"difficulty" binding:"omitempty,oneof=easy medium hard"`
+	Cuisine          *string  `json:"cuisine" binding:"omitempty,max=50"`
+	Course           *string  `json:"course" binding:"omitempty,oneof=breakfast brunch lunch dinner main appetizer side dessert snack drink"`
+	Equipment        []string `json:"equipment" binding:"omitempty,dive,max=100"`
+}
+
+// apply copies the given metadata onto a recipe, keeping fields that were
+// left out, and recomputes the total time unless it was given explicitly.
+func (m recipeMetadataInput) apply(recipe *Recipe) {
+	if m.PrepTimeMinutes != nil {
+		recipe.PrepTimeMinutes = *m.PrepTimeMinutes
+	}
+	if m.CookTimeMinutes != nil {
+		recipe.CookTimeMinutes = *m.CookTimeMinutes
+	}
+	if m.TotalTimeMinutes != nil {
+		recipe.TotalTimeMinutes = *m.TotalTimeMinutes
+	} else if m.PrepTimeMinutes != nil || m.CookTimeMinutes != nil {
+		recipe.TotalTimeMinutes = recipe.PrepTimeMinutes + recipe.CookTimeMinutes
+	}
+	if m.YieldAmount != nil {
+		recipe.YieldAmount = *m.YieldAmount
+	}
+	if m.YieldUnit != nil {
+		recipe.YieldUnit = strings.TrimSpace(*m.YieldUnit)
+	}
+	if m.Difficulty != nil {
+		recipe.Difficulty = *m.Difficulty
+	}
+	if m.Cuisine != nil {
+		recipe.Cuisine = normalizeCuisine(*m.Cuisine)
+	}
+	if m.Course != nil {
+		recipe.Course = *m.Course
+	}
+	if m.Equipment != nil {
+		recipe.Equipment = normalizeEquipment(m.Equipment)
+	}
+}
+
+// merge overlays the metadata fields set in other.
+func (m *recipeMetadataInput) merge(other recipeMetadataInput) {
+	if other.PrepTimeMinutes != nil {
+		m.PrepTimeMinutes = other.PrepTimeMinutes
+	}
+	if other.CookTimeMinutes != nil {
+		m.CookTimeMinutes = other.CookTimeMinutes
+	}
+	if other.TotalTimeMinutes != nil {
+		m.TotalTimeMinutes = other.TotalTimeMinutes
+	}
+	if other.YieldAmount != nil {
+		m.YieldAmount = other.YieldAmount
+	}
+	if other.YieldUnit != nil {
+		m.YieldUnit = other.YieldUnit
+	}
+	if other.Difficulty != nil {
+		m.Difficulty = other.Difficulty
+	}
+	if other.Cuisine != nil {
+		m.Cuisine = other.Cuisine
+	}
+	if other.Course != nil {
+		m.Course = other.Course
+	}
+	if other.Equipment != nil {
+		m.Equipment = other.Equipment
+	}
+}
+
+// columns returns the names of the columns set by the given metadata.
+func (m recipeMetadataInput) columns() []string {
+	var columns []string
+	for column, given := range map[string]bool{
+		"prep_time_minutes":  m.PrepTimeMinutes != nil,
+		"cook_time_minutes":  m.CookTimeMinutes != nil,
+		"total_time_minutes": m.TotalTimeMinutes != nil || m.PrepTimeMinutes != nil || m.CookTimeMinutes != nil,
+		"yield_amount":       m.YieldAmount != nil,
+		"yield_unit":         m.YieldUnit != nil,
+		"difficulty":         m.Difficulty != nil,
+		"cuisine":            m.Cuisine != nil,
+		"course":             m.Course != nil,
+		"equipment":          m.Equipment != nil,
+	} {
+		if given {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// normalizeCuisine lowercases a cuisine so filters match regardless of case.
+func normalizeCuisine(cuisine string) string {
+	return strings.ToLower(strings.TrimSpace(cuisine))
+}
+
+// normalizeEquipment lowercases, trims and de-duplicates equipment names.
+func normalizeEquipment(equipment []string) []string {
+	seen := make(map[string]bool, len(equipment))
+	result := []string{}
+	for _, item := range equipment {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		result = append(result, item)
+	}
+	return result
+}
+
+// encodeJSONList encodes a string list as a JSON array literal.
+func encodeJSONList(items []string) string {
+	encoded, _ := json.Marshal(items)
+	return string(encoded)
+}
+
+// queryList splits a comma-separated query parameter into trimmed,
+// lower-case values.
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, value := range strings.Split(c.Query(name), ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// checkEnum reports the first value not in allowed.
+func checkEnum(name string, values, allowed []string) error {
+	for _, value := range values {
+		found := false
+		for _, a := range allowed {
+			if value == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid %s %q, expected one of %s", name, value, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// recipeMetadataFilters applies the time, yield and enum filters of GET
+// /recipes. Time maximums skip recipes whose time is unknown (zero), so
+// max_total_time=30 means "known to take under 30 minutes". List filters
+// (cuisine, course, difficulty) accept comma-separated values; equipment
+// matches recipes using all listed items and have_equipment those needing
+// nothing beyond them.
+func recipeMetadataFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	for param, column := range map[string]string{
+		"max_total_time": "total_time_minutes",
+		"max_prep_time":  "prep_time_minutes",
+		"max_cook_time":  "cook_time_minutes",
+	} {
+		raw := c.Query(param)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid %s", param)
+		}
+		query = query.Where(column+" > 0 AND "+column+" <= ?", value)
+	}
+	if raw := c.Query("min_total_time"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid min_total_time")
+		}
+		query = query.Where("total_time_minutes >= ?", value)
+	}
+	if raw := c.Query("min_yield"); raw != "" {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid min_yield")
+		}
+		query = query.Where("yield_amount >= ?", value)
+	}
+
+	if cuisines := queryList(c, "cuisine"); len(cuisines) > 0 {
+		query = query.Where("cuisine IN ?", cuisines)
+	}
+	if courses := queryList(c, "course"); len(courses) > 0 {
+		if err := checkEnum("course", courses, recipeCourses); err != nil {
+			return nil, err
+		}
+		query = query.Where("course IN ?", courses)
+	}
+	if difficulties := queryList(c, "difficulty"); len(difficulties) > 0 {
+		if err := checkEnum("difficulty", difficulties, recipeDifficulties); err != nil {
+			return nil, err
+		}
+		query = query.Where("difficulty IN ?", difficulties)
+	}
+	if equipment := queryList(c, "equipment"); len(equipment) > 0 {
+		query = query.Where("equipment @> ?::jsonb", encodeJSONList(equipment))
+	}
+	if owned := queryList(c, "have_equipment"); len(owned) > 0 {
+		query = query.Where("(jsonb_typeof(equipment) IS DISTINCT FROM 'array' OR equipment <@ ?::jsonb)", encodeJSONList(owned))
+	}
+	return query, nil
+}
@@ -0,0 +1,65 @@
+// recipe_metadata_test.go
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRecipeMetadataApply verifies metadata is normalized and total time derived.
+func TestRecipeMetadataApply(t *testing.T) {
+	prep, cook := 10, 15
+	cuisine, unit := " Thai ", " cookies "
+	recipe := Recipe{TotalTimeMinutes: 90, Difficulty: "hard"}
+
+	recipeMetadataInput{
+		PrepTimeMinutes: &prep,
+		CookTimeMinutes: &cook,
+		Cuisine:         &cuisine,
+		YieldUnit:       &unit,
+		Equipment:       []string{"Wok", "wok ", ""},
+	}.apply(&recipe)
+
+	assert.Equal(t, 25, recipe.TotalTimeMinutes)
+	assert.Equal(t, "thai", recipe.Cuisine)
+	assert.Equal(t, "cookies", recipe.YieldUnit)
+	assert.Equal(t, "hard", recipe.Difficulty)
+	assert.Equal(t, []string{"wok"}, recipe.Equipment)
+
+	total := 40
+	input := recipeMetadataInput{CookTimeMinutes: &cook, TotalTimeMinutes: &total}
+	input.apply(&recipe)
+	assert.Equal(t, 40, recipe.TotalTimeMinutes)
+	assert.ElementsMatch(t, []string{"cook_time_minutes", "total_time_minutes"}, input.columns())
+}
+
+// TestRecipeMetadataBinding verifies enum and range validation of recipe input.
+func TestRecipeMetadataBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bind := func(body string) error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/recipes", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		var input struct {
+			Title string `json:"title" binding:"required"`
+			recipeMetadataInput
+		}
+		return c.ShouldBindJSON(&input)
+	}
+
+	assert.NoError(t, bind(`{"title":"Pad thai","course":"dinner","difficulty":"easy","prep_time_minutes":20}`))
+	assert.Error(t, bind(`{"title":"Pad thai","course":"elevenses"}`))
+	assert.Error(t, bind(`{"title":"Pad thai","prep_time_minutes":-5}`))
+}
+
+// TestCheckEnum verifies filter values outside the allowed set are rejected.
+func TestCheckEnum(t *testing.T) {
+	assert.NoError(t, checkEnum("course", []string{"dinner", "dessert"}, recipeCourses))
+	assert.EqualError(t, checkEnum("difficulty", []string{"expert"}, recipeDifficulties),
+		`invalid difficulty "expert", expected one of easy, medium, hard`)
+}
@@ -0,0 +1,538 @@
+// recipe_pdf.go
+package internal
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Printed recipe layouts.
+const (
+	PDFLayoutPage  = "page"
+	PDFLayoutCard  = "card"
+	PDFLayoutLarge = "large"
+)
+
+// maxCookbookRecipes bounds the recipes printed in one cookbook.
+const maxCookbookRecipes = 500
+
+// pdfLayout sizes printed recipes: the paper and its margins in points and
+// the size of body text.
+type pdfLayout struct {
+	Width    float64
+	Height   float64
+	Margin   float64
+	FontSize float64
+}
+
+// pdfLayouts are the printed recipe layouts: US letter pages, 6x4 inch
+// index cards in landscape and large print on letter pages. Recipes too
+// long for one card continue on further cards.
+var pdfLayouts = map[string]pdfLayout{
+	PDFLayoutPage:  {Width: 612, Height: 792, Margin: 54, FontSize: 11},
+	PDFLayoutCard:  {Width: 432, Height: 288, Margin: 20, FontSize: 8},
+	PDFLayoutLarge: {Width: 612, Height: 792, Margin: 54, FontSize: 17},
+}
+
+// pdfLayoutNames lists the layouts for validation messages.
+var pdfLayoutNames = []string{PDFLayoutPage, PDFLayoutCard, PDFLayoutLarge}
+
+// lineHeight returns the distance between lines of text of a font size.
+func lineHeight(size float64) float64 {
+	return size * 1.3
+}
+
+// truncateText shortens text with an ellipsis to fit width.
+func truncateText(s string, font pdfFont, size, width float64) string {
+	if pdfTextWidth(s, font, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"…", font, size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "…"
+}
+
+// pdfWriter flows text down the pages of a document, starting a new page
+// when one is full. The bottom margin keeps room for page numbers.
+type pdfWriter struct {
+	doc    *pdfDocument
+	layout pdfLayout
+	page   int
+	y      float64
+}
+
+// newPDFWriter returns a writer for an empty document in a layout.
+func newPDFWriter(layout pdfLayout) *pdfWriter {
+	return &pdfWriter{doc: newPDFDocument(layout.Width, layout.Height), layout: layout, page: -1}
+}
+
+// width returns the width of the text area.
+func (w *pdfWriter) width() float64 {
+	return w.layout.Width - 2*w.layout.Margin
+}
+
+// bottom returns how far down the page text may go.
+func (w *pdfWriter) bottom() float64 {
+	return w.layout.Height - w.layout.Margin - w.layout.FontSize*1.5
+}
+
+// newPage starts a page.
+func (w *pdfWriter) newPage() {
+	w.page = w.doc.addPage()
+	w.y = w.layout.Margin
+}
+
+// ensure starts a new page unless height still fits on the current one.
+func (w *pdfWriter) ensure(height float64) {
+	if w.page < 0 || w.y+height > w.bottom() {
+		w.newPage()
+	}
+}
+
+// space adds vertical space, except at the top of a page.
+func (w *pdfWriter) space(height float64) {
+	if w.page >= 0 && w.y > w.layout.Margin {
+		w.y += height
+	}
+}
+
+// paragraph writes wrapped text indented by indent, with an optional label
+// such as a step number hanging in the indent.
+func (w *pdfWriter) paragraph(s string, font pdfFont, size, indent float64, label string) {
+	for i, line := range wrapText(s, font, size, w.width()-indent) {
+		w.ensure(lineHeight(size))
+		baseline := w.y + size
+		if i == 0 && label != "" {
+			x := w.layout.Margin + indent - size*0.4 - pdfTextWidth(label, font, size)
+			w.doc.text(w.page, x, baseline, font, size, label)
+		}
+		w.doc.text(w.page, w.layout.Margin+indent, baseline, font, size, line)
+		w.y += lineHeight(size)
+	}
+}
+
+// heading writes a heading, moving to a new page first unless a few lines
+// after it fit too.
+func (w *pdfWriter) heading(s string) {
+	size := w.layout.FontSize
+	w.space(size * 0.8)
+	w.ensure(lineHeight(size*1.25) + 2*lineHeight(size))
+	w.paragraph(s, pdfBold, size*1.25, 0, "")
+	w.y += size * 0.2
+}
+
+// rule draws a horizontal line across the text area.
+func (w *pdfWriter) rule() {
+	w.y += w.layout.FontSize * 0.3
+	w.doc.line(w.page, w.layout.Margin, w.y, w.layout.Width-w.layout.Margin, w.y, 0.5)
+	w.y += w.layout.FontSize * 0.5
+}
+
+// leader writes a contents or index line with its baseline at y: text,
+// dot leaders and a page number flush right.
+func (w *pdfWriter) leader(page int, y, indent float64, text string, number int) {
+	size := w.layout.FontSize
+	num := strconv.Itoa(number)
+	numWidth := pdfTextWidth(num, pdfRegular, size)
+	right := w.layout.Width - w.layout.Margin
+	x := w.layout.Margin + indent
+
+	text = truncateText(text, pdfRegular, size, w.width()-indent-numWidth-size*2)
+	w.doc.text(page, x, y, pdfRegular, size, text)
+	dotWidth := pdfTextWidth(" .", pdfRegular, size)
+	start := x + pdfTextWidth(text, pdfRegular, size) + size*0.3
+	end := right - numWidth - size*0.3
+	if dots := int((end - start) / dotWidth); dots > 0 {
+		w.doc.text(page, end-float64(dots)*dotWidth, y, pdfRegular, size, strings.Repeat(" .", dots))
+	}
+	w.doc.text(page, right-numWidth, y, pdfRegular, size, num)
+}
+
+// numberPages prints page numbers at the foot of the pages from index from
+// on, optionally with the page count, e.g. "2 of 3".
+func (w *pdfWriter) numberPages(from int, withTotal bool) {
+	size := w.layout.FontSize * 0.8
+	for page := from; page < len(w.doc.pages); page++ {
+		label := strconv.Itoa(page + 1)
+		if withTotal {
+			label += fmt.Sprintf(" of %d", len(w.doc.pages))
+		}
+		x := (w.layout.Width - pdfTextWidth(label, pdfRegular, size)) / 2
+		w.doc.text(page, x, w.layout.Height-w.layout.Margin+size, pdfRegular, size, label)
+	}
+}
+
+// writeRecipe prints a recipe from the current position: its title and
+// byline, ingredients, numbered steps by section, nutrition and source.
+func (w *pdfWriter) writeRecipe(e RecipeExport) {
+	size := w.layout.FontSize
+	w.ensure(lineHeight(size*1.8) + 3*lineHeight(size))
+	w.paragraph(e.Recipe.Title, pdfBold, size*1.8, 0, "")
+	if facts := e.facts(); len(facts) > 0 {
+		w.paragraph(strings.Join(facts, " · "), pdfItalic, size*0.9, 0, "")
+	}
+	w.rule()
+
+	w.heading("Ingredients")
+	for _, line := range e.ingredientLines() {
+		w.paragraph(line, pdfRegular, size, size*1.2, "•")
+	}
+
+	w.heading("Instructions")
+	section, number := "", 0
+	for i, step := range e.steps() {
+		if i == 0 || step.Section != section {
+			section, number = step.Section, 0
+			if section != "" {
+				w.space(size * 0.4)
+				w.ensure(3 * lineHeight(size))
+				w.paragraph(section, pdfBold, size, 0, "")
+			}
+		}
+		number++
+		w.paragraph(step.Text, pdfRegular, size, size*2, strconv.Itoa(number)+".")
+		w.space(size * 0.3)
+	}
+
+	if facts := e.nutrition(); facts != nil {
+		w.space(size * 0.5)
+		w.paragraph(fmt.Sprintf("Per serving: %s kcal · protein %s g · fat %s g · carbs %s g · fiber %s g · sugar %s g · sodium %s mg",
+			FormatAmount(facts.Calories), FormatAmount(facts.ProteinG), FormatAmount(facts.FatG), FormatAmount(facts.CarbsG),
+			FormatAmount(facts.FiberG), FormatAmount(facts.SugarG), FormatAmount(facts.SodiumMg)), pdfItalic, size*0.85, 0, "")
+	}
+	if e.Recipe.SourceURL != "" {
+		w.space(size * 0.3)
+		w.paragraph("Source: "+e.Recipe.SourceURL, pdfItalic, size*0.85, 0, "")
+	}
+}
+
+// ingredientNames returns the normalized names of a recipe's ingredients.
+func (e RecipeExport) ingredientNames() []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if name = NormalizeIngredientName(name); name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(e.Ingredients) > 0 {
+		for _, ingredient := range e.Ingredients {
+			add(ingredient.Name)
+		}
+		return names
+	}
+	for _, line := range e.ingredientLines() {
+		_, name := ParseIngredientLine(line)
+		add(name)
+	}
+	return names
+}
+
+// RenderRecipePDF prints a recipe as a PDF in a layout. Recipes running
+// over several pages get numbered pages.
+func RenderRecipePDF(e RecipeExport, layout pdfLayout) []byte {
+	w := newPDFWriter(layout)
+	w.doc.Title = e.Recipe.Title
+	w.newPage()
+	w.writeRecipe(e)
+	if len(w.doc.pages) > 1 {
+		w.numberPages(0, true)
+	}
+	return w.doc.Bytes()
+}
+
+// cookbookEntry is a recipe of a cookbook and the page it starts on.
+type cookbookEntry struct {
+	Title string
+	Page  int
+}
+
+// renderCookbook prints recipes as a cookbook: a title page, a table of
+// contents, each recipe from a new page and an index of the recipes by
+// ingredient. Pages after the title page are numbered.
+func renderCookbook(title string, exports []RecipeExport, layout pdfLayout) (*pdfDocument, []cookbookEntry) {
+	w := newPDFWriter(layout)
+	w.doc.Title = title
+	size := layout.FontSize
+
+	w.newPage()
+	titleSize := size * 3
+	y := layout.Height / 3
+	for _, line := range wrapText(title, pdfBold, titleSize, w.width()) {
+		w.doc.text(w.page, (layout.Width-pdfTextWidth(line, pdfBold, titleSize))/2, y, pdfBold, titleSize, line)
+		y += lineHeight(titleSize)
+	}
+	subtitle := fmt.Sprintf("%d recipes", len(exports))
+	if len(exports) == 1 {
+		subtitle = "1 recipe"
+	}
+	w.doc.text(w.page, (layout.Width-pdfTextWidth(subtitle, pdfItalic, size*1.2))/2, y+size, pdfItalic, size*1.2, subtitle)
+
+	// The contents are one line per recipe, so their pages can be set
+	// aside now and filled in once the recipes' pages are known.
+	headingSize := size * 1.8
+	headingHeight := lineHeight(headingSize) + size
+	rowHeight := lineHeight(size)
+	perPage := max(1, int((w.bottom()-layout.Margin-headingHeight)/rowHeight))
+	contentsPage := len(w.doc.pages)
+	for i := 0; i < max(1, (len(exports)+perPage-1)/perPage); i++ {
+		w.doc.addPage()
+	}
+
+	entries := make([]cookbookEntry, len(exports))
+	index := map[string][]int{}
+	for i, e := range exports {
+		w.newPage()
+		entries[i] = cookbookEntry{Title: e.Recipe.Title, Page: w.page + 1}
+		w.writeRecipe(e)
+		for _, name := range e.ingredientNames() {
+			index[name] = append(index[name], i)
+		}
+	}
+
+	w.doc.text(contentsPage, layout.Margin, layout.Margin+headingSize, pdfBold, headingSize, "Contents")
+	for i, entry := range entries {
+		baseline := layout.Margin + headingHeight + float64(i%perPage)*rowHeight + size
+		w.leader(contentsPage+i/perPage, baseline, 0, entry.Title, entry.Page)
+	}
+
+	if len(index) > 0 {
+		names := make([]string, 0, len(index))
+		for name := range index {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		w.newPage()
+		w.paragraph("Index", pdfBold, headingSize, 0, "")
+		w.y += size
+		for _, name := range names {
+			w.ensure(2 * rowHeight)
+			w.paragraph(name, pdfBold, size, 0, "")
+			for _, i := range index[name] {
+				w.ensure(rowHeight)
+				w.leader(w.page, w.y+size, size*1.2, entries[i].Title, entries[i].Page)
+				w.y += rowHeight
+			}
+		}
+	}
+
+	w.numberPages(1, false)
+	return w.doc, entries
+}
+
+// pdfFilename returns the download name of a PDF titled title.
+func pdfFilename(title string) string {
+	if slug := Slugify(title); slug != "" {
+		return slug + ".pdf"
+	}
+	return "recipe.pdf"
+}
+
+// pdfLayoutQuery reads the layout query parameter, responding with an
+// error and returning false when it is not a layout.
+func pdfLayoutQuery(c *gin.Context) (string, bool) {
+	name := c.DefaultQuery("layout", PDFLayoutPage)
+	if err := checkEnum("layout", []string{name}, pdfLayoutNames); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return name, true
+}
+
+// GetRecipePDF handles the GET /recipes/:id.pdf endpoint, printing a recipe
+// in the layout named by the layout parameter: page (the default), card or
+// large.
+func GetRecipePDF(c *gin.Context) {
+	layout, ok := pdfLayoutQuery(c)
+	if !ok {
+		return
+	}
+
+	var recipe Recipe
+	id := strings.TrimSuffix(c.Param("id"), ".pdf")
+	if err := findVisibleRecipe(c, DB.Scopes(recipeDetails), &recipe, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	export, err := newRecipeExport(c, recipe)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to print recipe"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, pdfFilename(recipe.Title)))
+	c.Data(http.StatusOK, "application/pdf", RenderRecipePDF(export, pdfLayouts[layout]))
+}
+
+// saveCookbookJob stores the state of a cookbook job.
+func saveCookbookJob(job *CookbookJob) {
+	if err := DB.Save(job).Error; err != nil {
+		log.Printf("Failed to save cookbook job %d: %v", job.ID, err)
+	}
+}
+
+// runCookbookJob prints a cookbook of the job's recipes and stores the PDF
+// with the job.
+func runCookbookJob(job CookbookJob, baseURL string) {
+	fail := func(err interface{}) {
+		log.Printf("Cookbook job %d failed: %v", job.ID, err)
+		now := time.Now()
+		job.Status, job.Error, job.FinishedAt = JobFailed, "Failed to generate cookbook", &now
+		saveCookbookJob(&job)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fail(r)
+		}
+	}()
+
+	now := time.Now()
+	job.Status, job.StartedAt = JobRunning, &now
+	saveCookbookJob(&job)
+
+	var recipes []Recipe
+	if err := DB.Scopes(recipeDetails).Where("id IN ?", job.RecipeIDs).Order("title").Find(&recipes).Error; err != nil {
+		fail(err)
+		return
+	}
+	exports := make([]RecipeExport, 0, len(recipes))
+	for _, recipe := range recipes {
+		export, err := loadRecipeExport(recipe, baseURL)
+		if err != nil {
+			fail(err)
+			return
+		}
+		exports = append(exports, export)
+	}
+
+	doc, _ := renderCookbook(job.Title, exports, pdfLayouts[job.Layout])
+	finished := time.Now()
+	job.PDF, job.Pages = doc.Bytes(), len(doc.pages)
+	job.Status, job.FinishedAt = JobCompleted, &finished
+	saveCookbookJob(&job)
+}
+
+// CreateCookbook handles the POST /me/collections/:id/cookbook endpoint. It
+// starts printing the collection's recipes the caller may read as a PDF
+// cookbook in the background; the returned job reports progress at
+// GET /me/cookbooks/:id and the PDF downloads from GET /me/cookbooks/:id/pdf.
+func CreateCookbook(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	layout, ok := pdfLayoutQuery(c)
+	if !ok {
+		return
+	}
+	var input struct {
+		Title string `json:"title" binding:"max=200"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var collection Collection
+	if err := DB.Scopes(ownerScope(c)).First(&collection, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+	var recipeIDs []uint
+	err := DB.Model(&Recipe{}).Scopes(visibleRecipes(c)).
+		Joins("JOIN collection_recipes ON collection_recipes.recipe_id = recipes.id").
+		Where("collection_recipes.collection_id = ? AND recipes.status = ?", collection.ID, RecipeStatusPublished).
+		Pluck("recipes.id", &recipeIDs).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start cookbook"})
+		return
+	}
+	if len(recipeIDs) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Collection has no recipes to print"})
+		return
+	}
+	if len(recipeIDs) > maxCookbookRecipes {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Cookbooks are limited to %d recipes", maxCookbookRecipes)})
+		return
+	}
+
+	job := CookbookJob{
+		UserID:       userID,
+		CollectionID: collection.ID,
+		Title:        collection.Name,
+		Layout:       layout,
+		Status:       JobPending,
+		RecipeIDs:    recipeIDs,
+	}
+	if input.Title != "" {
+		job.Title = input.Title
+	}
+	if err := DB.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start cookbook"})
+		return
+	}
+	go runCookbookJob(job, requestBaseURL(c))
+
+	c.Header("Location", fmt.Sprintf("/me/cookbooks/%d", job.ID))
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetCookbookJobs handles the GET /me/cookbooks endpoint, listing the
+// caller's recent cookbooks.
+func GetCookbookJobs(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var jobs []CookbookJob
+	if err := DB.Omit("pdf").Where("user_id = ?", userID).Order("created_at DESC").Limit(20).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve cookbooks"})
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// findCookbookJob loads one of the caller's cookbook jobs, responding with
+// an error and returning false when it does not exist.
+func findCookbookJob(c *gin.Context, job *CookbookJob, columns ...string) bool {
+	userID, _ := currentUserID(c)
+	query := DB.Where("user_id = ?", userID)
+	if len(columns) > 0 {
+		query = query.Omit(columns...)
+	}
+	if err := query.First(job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cookbook not found"})
+		return false
+	}
+	return true
+}
+
+// GetCookbookJob handles the GET /me/cookbooks/:id endpoint, reporting the
+// status of a cookbook.
+func GetCookbookJob(c *gin.Context) {
+	var job CookbookJob
+	if findCookbookJob(c, &job, "pdf") {
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// DownloadCookbook handles the GET /me/cookbooks/:id/pdf endpoint.
+func DownloadCookbook(c *gin.Context) {
+	var job CookbookJob
+	if !findCookbookJob(c, &job) {
+		return
+	}
+	if job.Status != JobCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cookbook is not ready", "status": job.Status})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, pdfFilename(job.Title)))
+	c.Data(http.StatusOK, "application/pdf", job.PDF)
+}
@@ -0,0 +1,101 @@
+// recipe_pdf_test.go
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pageTexts returns the text drawn on each page of a document.
+func pageTexts(doc *pdfDocument) []string {
+	texts := make([]string, len(doc.pages))
+	for i, page := range doc.pages {
+		texts[i] = page.String()
+	}
+	return texts
+}
+
+func TestRenderRecipePDFLayouts(t *testing.T) {
+	export := exportFixture()
+	for i := 0; i < 12; i++ {
+		export.Recipe.Steps = append(export.Recipe.Steps, RecipeStep{Section: "Filling", Text: "Stir the filling gently and keep whisking until it thickens enough to coat the back of a spoon."})
+	}
+
+	pages := map[string]int{}
+	for name, layout := range pdfLayouts {
+		w := newPDFWriter(layout)
+		w.newPage()
+		w.writeRecipe(export)
+		pages[name] = len(w.doc.pages)
+	}
+	assert.Equal(t, 1, pages[PDFLayoutPage])
+	assert.Greater(t, pages[PDFLayoutLarge], pages[PDFLayoutPage])
+	assert.Greater(t, pages[PDFLayoutCard], pages[PDFLayoutPage])
+
+	data := RenderRecipePDF(export, pdfLayouts[PDFLayoutCard])
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+	assert.Contains(t, string(data), fmt.Sprintf("/Count %d ", pages[PDFLayoutCard]))
+}
+
+func TestWriteRecipe(t *testing.T) {
+	w := newPDFWriter(pdfLayouts[PDFLayoutPage])
+	w.newPage()
+	w.writeRecipe(exportFixture())
+	text := pageTexts(w.doc)[0]
+
+	for _, want := range []string{
+		"(Lemon *Bars*)", "(By ana \xb7 Serves 12 \xb7 Makes 24 bars",
+		"(Ingredients)", "(\x95)", "(2 cup flour)", "(Instructions)",
+		"(Crust)", "(1.)", "(2.)", "(Press the dough into the pan.)", "(Filling)", "(Per serving: 210 kcal",
+	} {
+		assert.Contains(t, text, want)
+	}
+}
+
+func TestRenderCookbook(t *testing.T) {
+	var exports []RecipeExport
+	for i := 0; i < 60; i++ {
+		export := exportFixture()
+		export.Recipe.Title = fmt.Sprintf("Recipe %02d", i)
+		if i%2 == 1 {
+			export.Ingredients = []Ingredient{{Quantity: "3", Name: "eggs"}}
+		}
+		exports = append(exports, export)
+	}
+
+	layout := pdfLayouts[PDFLayoutPage]
+	doc, entries := renderCookbook("Family Favourites", exports, layout)
+	texts := pageTexts(doc)
+
+	assert.Contains(t, texts[0], "(Family Favourites)")
+	assert.Contains(t, texts[0], "(60 recipes)")
+	assert.Contains(t, texts[1], "(Contents)")
+
+	// Sixty contents lines take two pages, so recipes start on page 4, one
+	// page each, and their contents lines point at those pages.
+	assert.Len(t, entries, 60)
+	assert.Equal(t, 4, entries[0].Page)
+	assert.Equal(t, 63, entries[59].Page)
+	assert.Contains(t, texts[3], "(Recipe 00)")
+	assert.Contains(t, texts[62], "(Recipe 59)")
+	assert.Contains(t, texts[1], "(Recipe 00)")
+	assert.Contains(t, texts[2], "(Recipe 59)")
+	assert.Contains(t, texts[2], "(63)")
+
+	// The index follows the recipes, grouping them by ingredient.
+	index := strings.Join(texts[63:], "")
+	assert.Contains(t, texts[63], "(Index)")
+	assert.Contains(t, index, "(egg)")
+	assert.Contains(t, index, "(flour)")
+	assert.Contains(t, index, "(lemon)")
+	assert.Equal(t, 90, strings.Count(index, "(Recipe "))
+
+	// Every page but the title page is numbered.
+	assert.NotContains(t, texts[0], "(1) Tj")
+	assert.Contains(t, texts[1], "(2) Tj")
+	assert.Contains(t, texts[len(texts)-1], fmt.Sprintf("(%d) Tj", len(texts)))
+}
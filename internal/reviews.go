@@ -0,0 +1,329 @@
+// reviews.go
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Ratings are ranked by their Bayesian average: the reviews are averaged
+// together with ratingPriorWeight imaginary reviews of ratingPriorMean, so
+// a single 5-star review does not outrank many good ones.
+const (
+	ratingPriorMean   = 3.0
+	ratingPriorWeight = 5
+)
+
+// maxReviewPhotos is how many "I made this" photos a review may have.
+const maxReviewPhotos = 4
+
+// ratingAggregate returns the average and Bayesian score of count ratings
+// adding up to sum, both zero when there are none.
+func ratingAggregate(count, sum int) (average, score float64) {
+	if count == 0 {
+		return 0, 0
+	}
+	average = float64(sum) / float64(count)
+	score = (ratingPriorMean*ratingPriorWeight + float64(sum)) / float64(ratingPriorWeight+count)
+	return average, score
+}
+
+// refreshRecipeRating recomputes the rating summary of a recipe from its
+// reviews that are not hidden. UpdatedAt is left alone, since reviews do not edit the recipe.
+func refreshRecipeRating(tx *gorm.DB, recipeID uint) error {
+	var stats struct {
+		Count int
+		Sum   int
+	}
+	err := tx.Model(&RecipeReview{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(rating), 0) AS sum").
+		Where("recipe_id = ? AND hidden_at IS NULL", recipeID).
+		Scan(&stats).Error
+	if err != nil {
+		return err
+	}
+	average, score := ratingAggregate(stats.Count, stats.Sum)
+	return tx.Model(&Recipe{}).Where("id = ?", recipeID).UpdateColumns(map[string]interface{}{
+		"rating_average": average,
+		"rating_count":   stats.Count,
+		"rating_score":   score,
+	}).Error
+}
+
+// lockRecipe locks the row of a recipe until the transaction ends, so that
+// changes to its reviews are counted one after the other. Deleted recipes
+// are locked too, since moderators may still act on their reviews.
+func lockRecipe(tx *gorm.DB, recipeID uint) error {
+	return tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&Recipe{}, recipeID).Error
+}
+
+// changeRecipeReviews runs fn and refreshes the recipe's rating in one
+// transaction, with the recipe locked.
+func changeRecipeReviews(recipeID uint, fn func(tx *gorm.DB) error) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockRecipe(tx, recipeID); err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return refreshRecipeRating(tx, recipeID)
+	})
+}
+
+// attachReviewDetails fills in the reviewers' usernames and the links to
+// the reviews' photos.
+func attachReviewDetails(recipe Recipe, reviews []RecipeReview) error {
+	if len(reviews) == 0 {
+		return nil
+	}
+	userIDs := make([]uint, len(reviews))
+	for i, review := range reviews {
+		userIDs[i] = review.UserID
+	}
+	var users []User
+	if err := DB.Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	for i := range reviews {
+		reviews[i].Username = usernames[reviews[i].UserID]
+		if reviews[i].Photos == nil {
+			reviews[i].Photos = []RecipeImage{}
+		}
+		for j := range reviews[i].Photos {
+			if err := reviews[i].Photos[j].withURLs(!recipeIsPublic(recipe)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteReview deletes a review with the records of its photos. Their blobs
+// are left for the caller to delete once the transaction is committed.
+func deleteReview(tx *gorm.DB, reviewID uint) error {
+	if err := tx.Where("review_id = ?", reviewID).Delete(&RecipeImage{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&RecipeReview{}, reviewID).Error
+}
+
+// reviewPhotos preloads the photos of reviews, oldest first.
+func reviewPhotos(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+// findOwnReview loads the caller's review of a recipe they may read,
+// responding with an error and returning false when there is none.
+func findOwnReview(c *gin.Context, recipe *Recipe, review *RecipeReview) bool {
+	userID, _ := currentUserID(c)
+	if err := findVisibleRecipe(c, DB, recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return false
+	}
+	err := DB.Preload("Photos", reviewPhotos).
+		Where("recipe_id = ? AND user_id = ?", recipe.ID, userID).
+		First(review).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+		return false
+	}
+	return true
+}
+
+// GetRecipeReviews handles the GET /recipes/:id/reviews endpoint, listing
+// the newest reviews first. The rating query parameter keeps only reviews
+// with that many stars.
+func GetRecipeReviews(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	query := DB.Preload("Photos", reviewPhotos).
+		Where("recipe_id = ? AND (hidden_at IS NULL OR user_id = ?)", recipe.ID, userID)
+	if rating := c.Query("rating"); rating != "" {
+		if err := checkEnum("rating", []string{rating}, []string{"1", "2", "3", "4", "5"}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		query = query.Where("rating = ?", rating)
+	}
+
+	reviews := []RecipeReview{}
+	if err := query.Order("created_at DESC, id DESC").Limit(queryLimit(c, 50, 200)).Find(&reviews).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reviews"})
+		return
+	}
+	if err := attachReviewDetails(recipe, reviews); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reviews"})
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// GetMyRecipeReview handles the GET /recipes/:id/review endpoint, returning
+// the caller's review of the recipe.
+func GetMyRecipeReview(c *gin.Context) {
+	var recipe Recipe
+	var review RecipeReview
+	if !findOwnReview(c, &recipe, &review) {
+		return
+	}
+	reviews := []RecipeReview{review}
+	if err := attachReviewDetails(recipe, reviews); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve review"})
+		return
+	}
+	c.JSON(http.StatusOK, reviews[0])
+}
+
+// PutRecipeReview handles the PUT /recipes/:id/review endpoint, rating and
+// reviewing a recipe or editing the caller's existing review. Authors
+// cannot review their own recipes.
+func PutRecipeReview(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if recipe.UserID == userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot review your own recipe"})
+		return
+	}
+	if recipe.Status != RecipeStatusPublished {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only published recipes can be reviewed"})
+		return
+	}
+
+	var input struct {
+		Rating int    `json:"rating" binding:"required,min=1,max=5"`
+		Body   string `json:"body" binding:"max=5000"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var review RecipeReview
+	created := false
+	err := changeRecipeReviews(recipe.ID, func(tx *gorm.DB) error {
+		err := tx.Where("recipe_id = ? AND user_id = ?", recipe.ID, userID).First(&review).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			created = true
+			review = RecipeReview{RecipeID: recipe.ID, UserID: userID, Rating: input.Rating, Body: strings.TrimSpace(input.Body)}
+			return tx.Create(&review).Error
+		}
+		if err != nil {
+			return err
+		}
+		review.Rating, review.Body = input.Rating, strings.TrimSpace(input.Body)
+		return tx.Model(&review).Select("rating", "body").Updates(&review).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+		return
+	}
+
+	DB.Preload("Photos", reviewPhotos).First(&review, review.ID)
+	reviews := []RecipeReview{review}
+	if err := attachReviewDetails(recipe, reviews); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save review"})
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		if recipeIsPublic(recipe) {
+			recordActivityLogged(userID, ActivityReview, review.ID)
+		}
+	}
+	c.JSON(status, reviews[0])
+}
+
+// DeleteRecipeReview handles the DELETE /recipes/:id/review endpoint,
+// removing the caller's review and its photos.
+func DeleteRecipeReview(c *gin.Context) {
+	var recipe Recipe
+	var review RecipeReview
+	if !findOwnReview(c, &recipe, &review) {
+		return
+	}
+	err := changeRecipeReviews(recipe.ID, func(tx *gorm.DB) error {
+		return deleteReview(tx, review.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete review"})
+		return
+	}
+	for _, photo := range review.Photos {
+		deleteImageBlobs(c.Request.Context(), photo)
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Review deleted"})
+}
+
+// UploadReviewPhoto handles the POST /recipes/:id/review/photos endpoint,
+// adding an "I made this" photo to the caller's review.
+func UploadReviewPhoto(c *gin.Context) {
+	var recipe Recipe
+	var review RecipeReview
+	if !findOwnReview(c, &recipe, &review) {
+		return
+	}
+	if len(review.Photos) >= maxReviewPhotos {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("Reviews are limited to %d photos", maxReviewPhotos)})
+		return
+	}
+	processed, ok := readImageUpload(c)
+	if !ok {
+		return
+	}
+
+	img, err := storeRecipeImage(c.Request.Context(), RecipeImage{RecipeID: recipe.ID, UserID: review.UserID, ReviewID: &review.ID}, processed)
+	if err != nil {
+		log.Printf("Failed to store photo of review %d: %v", review.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	if err := img.withURLs(!recipeIsPublic(recipe)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store image"})
+		return
+	}
+	c.JSON(http.StatusCreated, img)
+}
+
+// DeleteReviewPhoto handles the DELETE /recipes/:id/review/photos/:photoId
+// endpoint.
+func DeleteReviewPhoto(c *gin.Context) {
+	var recipe Recipe
+	var review RecipeReview
+	if !findOwnReview(c, &recipe, &review) {
+		return
+	}
+	var photo RecipeImage
+	if err := DB.Where("review_id = ?", review.ID).First(&photo, c.Param("photoId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+		return
+	}
+	if err := DB.Delete(&photo).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete image"})
+		return
+	}
+	deleteImageBlobs(c.Request.Context(), photo)
+	c.JSON(http.StatusOK, gin.H{"status": "Image deleted"})
+}
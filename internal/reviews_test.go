@@ -0,0 +1,28 @@
+// reviews_test.go
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRatingAggregate verifies that few ratings are pulled towards the
+// prior, so many good ratings outrank a single perfect one.
+func TestRatingAggregate(t *testing.T) {
+	average, score := ratingAggregate(0, 0)
+	assert.Equal(t, 0.0, average)
+	assert.Equal(t, 0.0, score)
+
+	average, single := ratingAggregate(1, 5)
+	assert.Equal(t, 5.0, average)
+	assert.InDelta(t, 3.33, single, 0.01)
+
+	average, many := ratingAggregate(40, 180)
+	assert.Equal(t, 4.5, average)
+	assert.Greater(t, many, single)
+
+	_, poor := ratingAggregate(1, 1)
+	assert.Less(t, poor, ratingPriorMean)
+	assert.Greater(t, poor, 1.0)
+}
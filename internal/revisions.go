@@ -0,0 +1,370 @@
+// revisions.go
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SnapshotIngredient is an ingredient row captured in a revision.
+type SnapshotIngredient struct {
+	Name     string `json:"name"`
+	Quantity string `json:"quantity"`
+}
+
+// SnapshotStep is an instruction step captured in a revision.
+type SnapshotStep struct {
+	Section         string   `json:"section,omitempty"`
+	Text            string   `json:"text"`
+	DurationSeconds int      `json:"duration_seconds,omitempty"`
+	Temperature     float64  `json:"temperature,omitempty"`
+	TemperatureUnit string   `json:"temperature_unit,omitempty"`
+	Ingredients     []string `json:"ingredients,omitempty"`
+}
+
+// RecipeSnapshot is the full editable state of a recipe at one revision.
+// Tags are the user-applied tag slugs; system tags follow the metadata.
+type RecipeSnapshot struct {
+	Title            string               `json:"title"`
+	Ingredients      string               `json:"ingredients"`
+	Instructions     string               `json:"instructions"`
+	Calories         int                  `json:"calories"`
+	CaloriesManual   bool                 `json:"calories_manual"`
+	Servings         int                  `json:"servings"`
+	PrepTimeMinutes  int                  `json:"prep_time_minutes"`
+	CookTimeMinutes  int                  `json:"cook_time_minutes"`
+	TotalTimeMinutes int                  `json:"total_time_minutes"`
+	YieldAmount      float64              `json:"yield_amount"`
+	YieldUnit        string               `json:"yield_unit"`
+	Difficulty       string               `json:"difficulty"`
+	Cuisine          string               `json:"cuisine"`
+	Course           string               `json:"course"`
+	Equipment        []string             `json:"equipment"`
+	IngredientRows   []SnapshotIngredient `json:"ingredient_rows"`
+	Steps            []SnapshotStep       `json:"steps"`
+	Tags             []string             `json:"tags"`
+}
+
+// FieldChange is one field that differs between two revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// DiffSnapshots lists the fields that differ from a to b, in field order,
+// using the JSON field names.
+func DiffSnapshots(a, b RecipeSnapshot) []FieldChange {
+	changes := []FieldChange{}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	typ := av.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		from, to := av.Field(i).Interface(), bv.Field(i).Interface()
+		if isEmptyList(from) && isEmptyList(to) {
+			continue
+		}
+		if !reflect.DeepEqual(from, to) {
+			changes = append(changes, FieldChange{Field: typ.Field(i).Tag.Get("json"), From: from, To: to})
+		}
+	}
+	return changes
+}
+
+// isEmptyList reports whether v is a nil or empty slice, so that the two
+// compare equal in diffs.
+func isEmptyList(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Slice && rv.Len() == 0
+}
+
+// snapshotRecipe captures the current state of a recipe.
+func snapshotRecipe(tx *gorm.DB, recipeID uint) (RecipeSnapshot, error) {
+	var recipe Recipe
+	err := tx.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&recipe, recipeID).Error
+	if err != nil {
+		return RecipeSnapshot{}, err
+	}
+
+	snapshot := RecipeSnapshot{
+		Title:            recipe.Title,
+		Ingredients:      recipe.Ingredients,
+		Instructions:     recipe.Instructions,
+		Calories:         recipe.Calories,
+		CaloriesManual:   recipe.CaloriesManual,
+		Servings:         recipe.Servings,
+		PrepTimeMinutes:  recipe.PrepTimeMinutes,
+		CookTimeMinutes:  recipe.CookTimeMinutes,
+		TotalTimeMinutes: recipe.TotalTimeMinutes,
+		YieldAmount:      recipe.YieldAmount,
+		YieldUnit:        recipe.YieldUnit,
+		Difficulty:       recipe.Difficulty,
+		Cuisine:          recipe.Cuisine,
+		Course:           recipe.Course,
+		Equipment:        recipe.Equipment,
+	}
+
+	var ingredients []Ingredient
+	if err := tx.Where("recipe_id = ?", recipeID).Order("id").Find(&ingredients).Error; err != nil {
+		return snapshot, err
+	}
+	for _, ingredient := range ingredients {
+		snapshot.IngredientRows = append(snapshot.IngredientRows, SnapshotIngredient{Name: ingredient.Name, Quantity: ingredient.Quantity})
+	}
+	snapshot.Steps = snapshotSteps(recipe.Steps)
+	err = tx.Model(&Tag{}).
+		Joins("JOIN recipe_tags ON recipe_tags.tag_id = tags.id").
+		Where("recipe_tags.recipe_id = ? AND recipe_tags.user_id IS NOT NULL", recipeID).
+		Order("tags.slug").
+		Pluck("tags.slug", &snapshot.Tags).Error
+	return snapshot, err
+}
+
+// saveRecipeRevision records the current state of a recipe as its next
+// revision, unless it is unchanged since the latest revision. It reports
+// whether a revision was written.
+func saveRecipeRevision(db *gorm.DB, recipeID, userID uint, message string) (bool, error) {
+	saved := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		snapshot, err := snapshotRecipe(tx, recipeID)
+		if err != nil {
+			return err
+		}
+
+		var latest RecipeRevision
+		err = tx.Where("recipe_id = ?", recipeID).Order("number DESC").First(&latest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && len(DiffSnapshots(latest.state(), snapshot)) == 0 {
+			return nil
+		}
+
+		revision := RecipeRevision{
+			RecipeID: recipeID,
+			Number:   latest.Number + 1,
+			UserID:   userID,
+			Message:  message,
+			Snapshot: &snapshot,
+		}
+		saved = true
+		return tx.Create(&revision).Error
+	})
+	return saved, err
+}
+
+// saveRecipeRevisionLogged records a revision after an edit, logging rather
+// than failing the already applied edit. The next edit snapshots the state
+// first if this one failed.
+func saveRecipeRevisionLogged(recipeID, userID uint, message string) {
+	if _, err := saveRecipeRevision(DB, recipeID, userID, message); err != nil {
+		log.Printf("Failed to record revision of recipe %d: %v", recipeID, err)
+	}
+}
+
+// snapshotSteps captures recipe steps, or returns nil when there are none.
+func snapshotSteps(steps []RecipeStep) []SnapshotStep {
+	var snapshot []SnapshotStep
+	for _, step := range steps {
+		snapshot = append(snapshot, SnapshotStep{
+			Section:         step.Section,
+			Text:            step.Text,
+			DurationSeconds: step.DurationSeconds,
+			Temperature:     step.Temperature,
+			TemperatureUnit: step.TemperatureUnit,
+			Ingredients:     step.Ingredients,
+		})
+	}
+	return snapshot
+}
+
+// restoreSnapshot writes a snapshot back onto a recipe, replacing its
+// ingredient rows, steps and user-applied tags.
+func restoreSnapshot(tx *gorm.DB, recipe *Recipe, snapshot RecipeSnapshot) error {
+	recipe.Title = snapshot.Title
+	recipe.Ingredients = snapshot.Ingredients
+	recipe.Instructions = snapshot.Instructions
+	recipe.Calories = snapshot.Calories
+	recipe.CaloriesManual = snapshot.CaloriesManual
+	recipe.Servings = snapshot.Servings
+	recipe.PrepTimeMinutes = snapshot.PrepTimeMinutes
+	recipe.CookTimeMinutes = snapshot.CookTimeMinutes
+	recipe.TotalTimeMinutes = snapshot.TotalTimeMinutes
+	recipe.YieldAmount = snapshot.YieldAmount
+	recipe.YieldUnit = snapshot.YieldUnit
+	recipe.Difficulty = snapshot.Difficulty
+	recipe.Cuisine = snapshot.Cuisine
+	recipe.Course = snapshot.Course
+	recipe.Equipment = snapshot.Equipment
+	if recipe.Equipment == nil {
+		recipe.Equipment = []string{}
+	}
+	err := tx.Model(recipe).Select(
+		"title", "ingredients", "instructions", "calories", "calories_manual", "servings",
+		"prep_time_minutes", "cook_time_minutes", "total_time_minutes", "yield_amount", "yield_unit",
+		"difficulty", "cuisine", "course", "equipment",
+	).Updates(recipe).Error
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("recipe_id = ?", recipe.ID).Delete(&Ingredient{}).Error; err != nil {
+		return err
+	}
+	for _, row := range snapshot.IngredientRows {
+		if err := tx.Create(&Ingredient{RecipeID: recipe.ID, Name: row.Name, Quantity: row.Quantity}).Error; err != nil {
+			return err
+		}
+	}
+
+	steps := make([]RecipeStep, 0, len(snapshot.Steps))
+	for i, step := range snapshot.Steps {
+		steps = append(steps, RecipeStep{
+			Position:        i + 1,
+			Section:         step.Section,
+			Text:            step.Text,
+			DurationSeconds: step.DurationSeconds,
+			Temperature:     step.Temperature,
+			TemperatureUnit: step.TemperatureUnit,
+			Ingredients:     step.Ingredients,
+		})
+	}
+	if err := replaceRecipeSteps(tx, recipe.ID, steps); err != nil {
+		return err
+	}
+
+	tags := snapshot.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return saveRecipeTags(tx, *recipe, tags)
+}
+
+// state returns the snapshot of a revision; the zero snapshot stands for
+// the empty recipe before the first revision.
+func (r RecipeRevision) state() RecipeSnapshot {
+	if r.Snapshot == nil {
+		return RecipeSnapshot{}
+	}
+	return *r.Snapshot
+}
+
+// findRevision loads a revision of a recipe by its number.
+func findRevision(recipeID uint, number string) (RecipeRevision, error) {
+	var revision RecipeRevision
+	err := DB.Where("recipe_id = ? AND number = ?", recipeID, number).First(&revision).Error
+	return revision, err
+}
+
+// GetRecipeRevisions handles the GET /recipes/:id/revisions endpoint,
+// listing revisions newest first without their snapshots.
+func GetRecipeRevisions(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	var revisions []RecipeRevision
+	err := DB.Omit("snapshot").Where("recipe_id = ?", recipe.ID).Order("number DESC").Find(&revisions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve revisions"})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetRecipeRevision handles the GET /recipes/:id/revisions/:rev endpoint.
+func GetRecipeRevision(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	revision, err := findRevision(recipe.ID, c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+// DiffRecipeRevisions handles the GET /recipes/:id/revisions/:rev/diff
+// endpoint. The revision is compared against the one given by the against
+// query parameter, defaulting to the revision before it.
+func DiffRecipeRevisions(c *gin.Context) {
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	to, err := findRevision(recipe.ID, c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	against := c.DefaultQuery("against", strconv.Itoa(to.Number-1))
+	var from RecipeRevision
+	if against != "0" {
+		if from, err = findRevision(recipe.ID, against); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Revision %s not found", against)})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recipe_id": recipe.ID,
+		"from":      from.Number,
+		"to":        to.Number,
+		"changes":   DiffSnapshots(from.state(), to.state()),
+	})
+}
+
+// RestoreRecipeRevision handles the POST /recipes/:id/revisions/:rev/restore
+// endpoint. The current state is kept in history, so a restore can itself
+// be undone by restoring the revision before it.
+func RestoreRecipeRevision(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if recipe.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only restore your own recipes"})
+		return
+	}
+	revision, err := findRevision(recipe.ID, c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	if _, err := saveRecipeRevision(DB, recipe.ID, userID, "Snapshot before restore"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record recipe revision"})
+		return
+	}
+	if err := DB.Transaction(func(tx *gorm.DB) error { return restoreSnapshot(tx, &recipe, revision.state()) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	saveRecipeRevisionLogged(recipe.ID, userID, fmt.Sprintf("Restored revision %d", revision.Number))
+	refreshRecipeNutritionLogged(recipe.ID)
+
+	DB.Scopes(recipeDetails).First(&recipe, recipe.ID)
+	c.JSON(http.StatusOK, recipe)
+}
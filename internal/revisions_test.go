@@ -0,0 +1,50 @@
+// revisions_test.go
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDiffSnapshots verifies only changed fields are reported, by JSON name.
+func TestDiffSnapshots(t *testing.T) {
+	before := RecipeSnapshot{
+		Title:          "Pancakes",
+		Servings:       2,
+		IngredientRows: []SnapshotIngredient{{Name: "flour", Quantity: "1 cup"}},
+		Tags:           []string{"breakfast"},
+	}
+	after := before
+	after.Servings = 4
+	after.IngredientRows = []SnapshotIngredient{{Name: "flour", Quantity: "2 cups"}}
+
+	changes := DiffSnapshots(before, after)
+	if assert.Len(t, changes, 2) {
+		assert.Equal(t, FieldChange{Field: "servings", From: 2, To: 4}, changes[0])
+		assert.Equal(t, "ingredient_rows", changes[1].Field)
+	}
+	assert.Empty(t, DiffSnapshots(before, before))
+}
+
+// TestDiffSnapshotsEmptyLists verifies nil and empty lists compare equal, so
+// a snapshot read back from JSON matches the one it was written from.
+func TestDiffSnapshotsEmptyLists(t *testing.T) {
+	snapshot := RecipeSnapshot{Title: "Toast", Equipment: []string{}}
+	encoded, err := json.Marshal(snapshot)
+	assert.NoError(t, err)
+
+	var decoded RecipeSnapshot
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	decoded.Equipment = nil
+	assert.Empty(t, DiffSnapshots(snapshot, decoded))
+}
+
+// TestRevisionState verifies a missing snapshot reads as the empty recipe.
+func TestRevisionState(t *testing.T) {
+	assert.Equal(t, RecipeSnapshot{}, RecipeRevision{}.state())
+
+	changes := DiffSnapshots(RecipeRevision{}.state(), RecipeSnapshot{Title: "Soup"})
+	assert.Equal(t, []FieldChange{{Field: "title", From: "", To: "Soup"}}, changes)
+}
@@ -2,8 +2,11 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -22,27 +25,96 @@ func SetupRoutes(router *gin.Engine) {
 
 	// Group routes related to recipes
 	recipes := router.Group("/recipes")
+	recipes.Use(OptionalJWTMiddleware())
 	{
 		// GET endpoint for listing recipes.
 		recipes.GET("", GetRecipes)
 
-		// GET endpoint for retrieving a specific recipe.
+		// GET endpoint for retrieving a specific recipe, or printing it as /:id.pdf.
 		recipes.GET("/:id", GetRecipe)
+		recipes.GET("/:id/export", ExportRecipe)
 
 		// PUT endpoint for updating a specific recipe.
-		recipes.PUT("/:id", UpdateRecipe)
+		recipes.PUT("/:id", JWTMiddleware(), SuspensionMiddleware(), UpdateRecipe)
 
 		// DELETE endpoint for deleting a specific recipe.
-		recipes.DELETE("/:id", DeleteRecipe)
+		recipes.DELETE("/:id", JWTMiddleware(), DeleteRecipe)
 
 		// POST endpoint for creating a new recipe.
-		recipes.POST("", CreateRecipe)
+		recipes.POST("", JWTMiddleware(), SuspensionMiddleware(), CreateRecipe)
+
+		// POST endpoint for importing a recipe from a saved web page.
+		recipes.POST("/import", JWTMiddleware(), SuspensionMiddleware(), ImportRecipe)
+
+		// Drafts with autosave, published after full validation.
+		recipes.POST("/drafts", JWTMiddleware(), SuspensionMiddleware(), CreateRecipeDraft)
+		recipes.GET("/:id/draft", JWTMiddleware(), GetRecipeDraft)
+		recipes.PATCH("/:id/draft", JWTMiddleware(), SuspensionMiddleware(), SaveRecipeDraft)
+		recipes.DELETE("/:id/draft", JWTMiddleware(), DiscardRecipeDraft)
+		recipes.POST("/:id/publish", JWTMiddleware(), SuspensionMiddleware(), PublishRecipe)
+
+		// POST endpoint for marking a recipe as cooked (decrements the pantry).
+		recipes.POST("/:id/cooked", JWTMiddleware(), HouseholdMiddleware(), MarkRecipeCooked)
+
+		// Endpoints for tagging a recipe.
+		recipes.POST("/:id/tags", JWTMiddleware(), SuspensionMiddleware(), AddRecipeTags)
+		recipes.DELETE("/:id/tags/:slug", JWTMiddleware(), RemoveRecipeTag)
+
+		// POST endpoint for starting an interactive cooking session.
+		recipes.POST("/:id/cook", JWTMiddleware(), StartCookingSession)
+
+		// Nutrition computed from the ingredients, with manual overrides.
+		recipes.GET("/:id/nutrition", GetRecipeNutrition)
+		recipes.PUT("/:id/nutrition/overrides", JWTMiddleware(), SuspensionMiddleware(), PutNutritionOverrides)
+
+		// Version history of a recipe, with diffs and restore.
+		recipes.GET("/:id/revisions", GetRecipeRevisions)
+		recipes.GET("/:id/revisions/:rev", GetRecipeRevision)
+		recipes.GET("/:id/revisions/:rev/diff", DiffRecipeRevisions)
+		recipes.POST("/:id/revisions/:rev/restore", JWTMiddleware(), SuspensionMiddleware(), RestoreRecipeRevision)
+
+		// Forking a recipe into the caller's own copy, and its lineage.
+		recipes.POST("/:id/fork", JWTMiddleware(), SuspensionMiddleware(), ForkRecipe)
+		recipes.GET("/:id/forks", GetRecipeForks)
+		recipes.GET("/:id/lineage", GetRecipeLineage)
+		recipes.GET("/:id/compare", CompareWithOriginal)
+
+		// Share links for unlisted recipes.
+		recipes.POST("/:id/share", JWTMiddleware(), SuspensionMiddleware(), CreateShareLink)
+		recipes.DELETE("/:id/share", JWTMiddleware(), RevokeShareLink)
+
+		// Hero image and step photos, stored with thumbnails.
+		recipes.PUT("/:id/image", JWTMiddleware(), SuspensionMiddleware(), UploadRecipeImage)
+		recipes.POST("/:id/steps/:position/photos", JWTMiddleware(), SuspensionMiddleware(), UploadStepPhoto)
+		recipes.GET("/:id/images", GetRecipeImages)
+		recipes.DELETE("/:id/images/:imageId", JWTMiddleware(), DeleteRecipeImage)
+
+		// Star ratings and reviews, one per user, with "I made this" photos.
+		recipes.GET("/:id/reviews", GetRecipeReviews)
+		recipes.GET("/:id/review", JWTMiddleware(), GetMyRecipeReview)
+		recipes.PUT("/:id/review", JWTMiddleware(), SuspensionMiddleware(), PutRecipeReview)
+		recipes.DELETE("/:id/review", JWTMiddleware(), DeleteRecipeReview)
+		recipes.POST("/:id/review/photos", JWTMiddleware(), SuspensionMiddleware(), UploadReviewPhoto)
+		recipes.DELETE("/:id/review/photos/:photoId", JWTMiddleware(), DeleteReviewPhoto)
+
+		// Threaded comments, paged by thread.
+		recipes.GET("/:id/comments", GetComments)
+		recipes.POST("/:id/comments", JWTMiddleware(), SuspensionMiddleware(), CreateComment)
+		recipes.GET("/:id/comments/:commentId", GetComment)
+		recipes.PATCH("/:id/comments/:commentId", JWTMiddleware(), SuspensionMiddleware(), UpdateComment)
+		recipes.DELETE("/:id/comments/:commentId", JWTMiddleware(), DeleteComment)
+
+		// Reporting recipes, reviews and comments to moderators.
+		recipes.POST("/:id/report", JWTMiddleware(), SuspensionMiddleware(), ReportRecipe)
+		recipes.POST("/:id/reviews/:reviewId/report", JWTMiddleware(), SuspensionMiddleware(), ReportReview)
+		recipes.POST("/:id/comments/:commentId/report", JWTMiddleware(), SuspensionMiddleware(), ReportComment)
 
 		// You can add more recipe-related routes here (e.g., GET /recipes/:id, PUT /recipes/:id, DELETE /recipes/:id)
 	}
 
 	// Group routes related to ingredients
 	ingredients := router.Group("/ingredients")
+	ingredients.Use(OptionalJWTMiddleware())
 	{
 		// GET endpoint for listing ingredients.
 		ingredients.GET("", GetIngredients)
@@ -51,15 +123,57 @@ func SetupRoutes(router *gin.Engine) {
 		ingredients.GET("/:id", GetIngredient)
 
 		// PUT endpoint for updating a specific ingredient.
-		ingredients.PUT("/:id", UpdateIngredient)
+		ingredients.PUT("/:id", JWTMiddleware(), SuspensionMiddleware(), UpdateIngredient)
 
 		// DELETE endpoint for deleting a specific ingredient.
-		ingredients.DELETE("/:id", DeleteIngredient)
+		ingredients.DELETE("/:id", JWTMiddleware(), SuspensionMiddleware(), DeleteIngredient)
 
 		// POST endpoint for creating a new ingredient.
-		ingredients.POST("", CreateIngredient)
+		ingredients.POST("", JWTMiddleware(), SuspensionMiddleware(), CreateIngredient)
 	}
 
+	// GET endpoint for reading a recipe through its share link.
+	router.GET("/shared/:token", GetSharedRecipe)
+
+	// GET endpoint for stored images, by signed link or recipe visibility.
+	router.GET("/blobs/*key", OptionalJWTMiddleware(), ServeBlob)
+
+	// Public profiles and the follow graph.
+	users := router.Group("/users")
+	users.Use(OptionalJWTMiddleware())
+	{
+		users.GET("/:username", GetUserProfile)
+		users.GET("/:username/followers", GetFollowers)
+		users.GET("/:username/following", GetFollowing)
+		users.POST("/:username/follow", JWTMiddleware(), FollowUser)
+		users.DELETE("/:username/follow", JWTMiddleware(), UnfollowUser)
+	}
+
+	// Tag browsing and autocomplete.
+	router.GET("/tags", GetTags)
+	router.GET("/tags/autocomplete", AutocompleteTags)
+
+	// Administration of tags and moderation.
+	admin := router.Group("/admin")
+	admin.Use(JWTMiddleware(), AdminMiddleware())
+	{
+		admin.POST("/tags", CreateTag)
+		admin.PUT("/tags/:slug", UpdateTag)
+		admin.POST("/tags/:slug/merge", MergeTag)
+		admin.POST("/tags/:slug/aliases", AddTagAlias)
+
+		// Moderation queue of reported content and its audit trail.
+		admin.GET("/moderation/cases", GetModerationCases)
+		admin.GET("/moderation/cases/:id", GetModerationCase)
+		admin.POST("/moderation/cases/:id/claim", ClaimModerationCase)
+		admin.DELETE("/moderation/cases/:id/claim", ReleaseModerationCase)
+		admin.POST("/moderation/cases/:id/resolve", ResolveModerationCase)
+		admin.GET("/moderation/actions", GetModerationActions)
+	}
+
+	// GET endpoint for searching the nutrient database.
+	router.GET("/foods", SearchFoods)
+
 	// Group routes related to authentication
 	auth := router.Group("/auth")
 	{
@@ -67,6 +181,125 @@ func SetupRoutes(router *gin.Engine) {
 		auth.POST("/login", Login)
 		auth.GET("/profile", Profile).Use(JWTMiddleware()) // Protected route
 	}
+
+	// Group routes scoped to the authenticated user and their household
+	me := router.Group("/me")
+	me.Use(JWTMiddleware(), HouseholdMiddleware())
+	{
+		// Account preferences such as the default recipe visibility.
+		me.GET("/preferences", GetPreferences)
+		me.PUT("/preferences", UpdatePreferences)
+
+		// The caller's drafts and recipes with unpublished edits.
+		me.GET("/drafts", GetMyDrafts)
+
+		// Library export and import, including from other recipe apps.
+		me.GET("/export", ExportLibrary)
+		me.POST("/import", SuspensionMiddleware(), ImportLibrary)
+		me.GET("/imports", GetImportJobs)
+		me.GET("/imports/:id", GetImportJob)
+
+		// Meal plan entries by date.
+		me.GET("/meal-plan", GetMealPlan)
+		me.POST("/meal-plan", CreateMealPlanEntry)
+		me.DELETE("/meal-plan/:id", DeleteMealPlanEntry)
+		me.POST("/meal-plan/:id/eaten", LogMealPlanEntry)
+
+		// Food log and daily nutrition goals.
+		me.GET("/food-log", GetFoodLog)
+		me.POST("/food-log", CreateFoodLogEntry)
+		me.DELETE("/food-log/:id", DeleteFoodLogEntry)
+		me.GET("/nutrition/goals", GetNutritionGoals)
+		me.PUT("/nutrition/goals", UpdateNutritionGoals)
+		me.GET("/nutrition/daily", GetDailyNutrition)
+		me.GET("/nutrition/weekly", GetWeeklyNutrition)
+
+		// Shopping lists generated from recipes or the meal plan.
+		me.GET("/shopping-lists", GetShoppingLists)
+		me.POST("/shopping-lists", CreateShoppingList)
+		me.GET("/shopping-lists/:id", GetShoppingList)
+		me.PUT("/shopping-lists/:id", UpdateShoppingList)
+		me.DELETE("/shopping-lists/:id", DeleteShoppingList)
+		me.POST("/shopping-lists/:id/items", AddShoppingListItem)
+		me.PUT("/shopping-lists/:id/items/:itemId", UpdateShoppingListItem)
+		me.DELETE("/shopping-lists/:id/items/:itemId", DeleteShoppingListItem)
+		me.POST("/shopping-lists/:id/cart", BuildShoppingListCart)
+
+		// Grocery retailer SKU mappings and brand preferences.
+		me.GET("/grocery/mappings", GetSKUMappings)
+		me.PUT("/grocery/mappings", PutSKUMapping)
+		me.DELETE("/grocery/mappings/:id", DeleteSKUMapping)
+		me.GET("/grocery/brands", GetPreferredBrands)
+		me.PUT("/grocery/brands", PutPreferredBrands)
+
+		// Pantry inventory with expiry tracking.
+		me.GET("/pantry", GetPantryItems)
+		me.POST("/pantry", CreatePantryItem)
+		me.POST("/pantry/scan", ScanPantryItem)
+		me.GET("/pantry/expiring", GetExpiringPantryItems)
+		me.GET("/pantry/use-it-up", GetUseItUpSuggestions)
+		me.GET("/pantry/:id", GetPantryItem)
+		me.PUT("/pantry/:id", UpdatePantryItem)
+		me.DELETE("/pantry/:id", DeletePantryItem)
+
+		// Cooking sessions with step navigation, timers and live events.
+		me.GET("/cooking-sessions", GetCookingSessions)
+		me.GET("/cooking-sessions/:id", GetCookingSession)
+		me.DELETE("/cooking-sessions/:id", AbandonCookingSession)
+		me.GET("/cooking-sessions/:id/events", StreamCookingSession)
+		me.POST("/cooking-sessions/:id/next", NextCookingStep)
+		me.POST("/cooking-sessions/:id/previous", PreviousCookingStep)
+		me.POST("/cooking-sessions/:id/finish", FinishCookingSession)
+		me.POST("/cooking-sessions/:id/timers", CreateCookingTimer)
+		me.POST("/cooking-sessions/:id/timers/:timerId/start", StartCookingTimer)
+		me.POST("/cooking-sessions/:id/timers/:timerId/pause", PauseCookingTimer)
+
+		// Recipe collections.
+		me.GET("/collections", GetCollections)
+		me.POST("/collections", SuspensionMiddleware(), CreateCollection)
+		me.GET("/collections/:id", GetCollection)
+		me.PUT("/collections/:id", SuspensionMiddleware(), UpdateCollection)
+		me.DELETE("/collections/:id", DeleteCollection)
+		me.POST("/collections/:id/recipes", SuspensionMiddleware(), AddCollectionRecipe)
+		me.DELETE("/collections/:id/recipes/:recipeId", RemoveCollectionRecipe)
+
+		// Collections printed as PDF cookbooks in the background.
+		me.POST("/collections/:id/cookbook", CreateCookbook)
+		me.GET("/cookbooks", GetCookbookJobs)
+		me.GET("/cookbooks/:id", GetCookbookJob)
+		me.GET("/cookbooks/:id/pdf", DownloadCookbook)
+
+		// Activity of the users the caller follows.
+		me.GET("/feed", GetFeed)
+
+		// Household membership, invitations and sharing.
+		me.GET("/household", GetHousehold)
+		me.POST("/household", CreateHousehold)
+		me.POST("/household/leave", LeaveHousehold)
+		me.POST("/household/join/:token", JoinHousehold)
+		me.GET("/household/invites", GetHouseholdInvites)
+		me.POST("/household/invites", CreateHouseholdInvite)
+		me.DELETE("/household/invites/:id", RevokeHouseholdInvite)
+		me.PUT("/household/members/:userId", UpdateHouseholdMember)
+		me.DELETE("/household/members/:userId", RemoveHouseholdMember)
+	}
+}
+
+// currentUserID returns the authenticated user's ID set by JWTMiddleware.
+func currentUserID(c *gin.Context) (uint, bool) {
+	value, exists := c.Get("userID")
+	if !exists {
+		return 0, false
+	}
+	userID, ok := value.(uint)
+	return userID, ok
+}
+
+// recipeSorts maps the sort query parameter of GetRecipes to orderings.
+// Ratings rank by Bayesian score, so unrated recipes come last.
+var recipeSorts = map[string]string{
+	"rating": "rating_score DESC, rating_count DESC, id",
+	"newest": "published_at DESC, id DESC",
 }
 
 // GetRecipes handles the GET /recipes endpoint.
@@ -76,17 +309,60 @@ func GetRecipes(c *gin.Context) {
 
 	var recipes []Recipe
 
-	query := DB
+	// Drafts are listed separately under /me/drafts.
+	query := DB.Scopes(visibleRecipes(c)).Where("recipes.status = ?", RecipeStatusPublished)
 
 	if title != "" {
-		query = query.Where("title ILIKE ?", fmt.Sprintf("%%%s%%", title))
+		query = query.Where("title ILIKE ?", "%"+likeEscaper.Replace(title)+"%")
 	}
 
 	if ingredient != "" {
-		query = query.Where("ingredients ILIKE ?", fmt.Sprintf("%%%s%%", ingredient))
+		query = query.Where("ingredients ILIKE ?", "%"+likeEscaper.Replace(ingredient)+"%")
+	}
+
+	if maxCalories := c.Query("max_calories"); maxCalories != "" {
+		value, err := strconv.Atoi(maxCalories)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid max_calories"})
+			return
+		}
+		query = query.Where("calories > 0 AND calories <= ?", value)
+	}
+
+	if minProtein := c.Query("min_protein"); minProtein != "" {
+		value, err := strconv.ParseFloat(minProtein, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid min_protein"})
+			return
+		}
+		query = query.Where("id IN (SELECT recipe_id FROM recipe_nutritions WHERE protein_g >= ?)", value)
+	}
+
+	query, err := recipeMetadataFilters(c, query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = recipeSearchFilter(query, q)
+	}
+
+	if query, err = recipeTagFilter(c, query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		order, ok := recipeSorts[sort]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected one of newest, rating"})
+			return
+		}
+		query = query.Order(order)
 	}
 
-	if err := query.Find(&recipes).Error; err != nil {
+	if err := query.Preload("Tags").Find(&recipes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipes"})
 		return
 	}
@@ -94,31 +370,48 @@ func GetRecipes(c *gin.Context) {
 	c.JSON(http.StatusOK, recipes)
 }
 
-// GetRecipe handles the GET /recipes/:id endpoint.
+// GetRecipe handles the GET /recipes/:id endpoint. An ID ending in .pdf
+// prints the recipe instead.
 func GetRecipe(c *gin.Context) {
 	id := c.Param("id")
+	if strings.HasSuffix(id, ".pdf") {
+		GetRecipePDF(c)
+		return
+	}
 	var recipe Recipe
-	if err := DB.First(&recipe, id).Error; err != nil {
+	if err := findVisibleRecipe(c, DB.Scopes(recipeDetails), &recipe, id); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
 		return
 	}
-	c.JSON(http.StatusOK, recipe)
+	if err := attachForkAttribution(c, &recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipe"})
+		return
+	}
+
+	// Accept picks our JSON, a schema.org JSON-LD document, Markdown or Cooklang.
+	c.Header("Vary", "Accept")
+	respondRecipe(c, recipe, c.NegotiateFormat(mediaTypeJSON, mediaTypeJSONLD, mediaTypeMarkdown, mediaTypeCooklang))
 }
 
-// UpdateRecipe handles the PUT /recipes/:id endpoint.
+// UpdateRecipe handles the PUT /recipes/:id endpoint. Only the recipe's
+// owner can edit it.
 func UpdateRecipe(c *gin.Context) {
-	id := c.Param("id")
 	var recipe Recipe
-	if err := DB.First(&recipe, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+	if !findOwnRecipe(c, &recipe) {
 		return
 	}
 
 	var input struct {
-		Title        string `json:"title"`
-		Ingredients  string `json:"ingredients"`
-		Instructions string `json:"instructions"`
-		Calories     int    `json:"calories"`
+		Title        string            `json:"title"`
+		Ingredients  string            `json:"ingredients"`
+		Instructions string            `json:"instructions"`
+		Steps        []recipeStepInput `json:"steps" binding:"omitempty,dive"`
+		Calories     *int              `json:"calories" binding:"omitempty,min=0"`
+		Servings     int               `json:"servings" binding:"omitempty,min=1"`
+		Tags         []string          `json:"tags" binding:"omitempty,dive,max=64"`
+		Visibility   string            `json:"visibility" binding:"omitempty,oneof=private unlisted public"`
+		Message      string            `json:"revision_message" binding:"max=255"`
+		recipeMetadataInput
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -126,11 +419,19 @@ func UpdateRecipe(c *gin.Context) {
 		return
 	}
 
+	// Keep the current state in history before changing it.
+	editorID := c.GetUint("userID")
+	if _, err := saveRecipeRevision(DB, recipe.ID, editorID, "Snapshot before edit"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record recipe revision"})
+		return
+	}
+
 	updated := Recipe{
 		Title:        input.Title,
 		Ingredients:  input.Ingredients,
 		Instructions: input.Instructions,
-		Calories:     input.Calories,
+		Servings:     input.Servings,
+		Visibility:   input.Visibility,
 	}
 
 	if err := DB.Model(&recipe).Updates(updated).Error; err != nil {
@@ -138,15 +439,53 @@ func UpdateRecipe(c *gin.Context) {
 		return
 	}
 
+	// Only the metadata fields that were sent are changed.
+	if columns := input.columns(); len(columns) > 0 {
+		input.apply(&recipe)
+		if err := DB.Model(&recipe).Select(columns).Updates(&recipe).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recipe"})
+			return
+		}
+	}
+
+	// A typed calorie count takes precedence over the computed one.
+	if input.Calories != nil {
+		if err := DB.Model(&recipe).Updates(map[string]interface{}{"calories": *input.Calories, "calories_manual": true}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recipe"})
+			return
+		}
+	}
+
+	// Tags are replaced when given; system tags follow the metadata.
+	if err := saveRecipeTags(DB, recipe, input.Tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recipe tags"})
+		return
+	}
+
+	// Steps are replaced when given, or re-split from new instruction text.
+	if input.Steps != nil || input.Instructions != "" {
+		if err := saveRecipeSteps(DB, &recipe, input.Steps); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recipe steps"})
+			return
+		}
+	}
+
+	if input.Message == "" {
+		input.Message = "Updated recipe"
+	}
+	saveRecipeRevisionLogged(recipe.ID, editorID, input.Message)
+	refreshRecipeNutritionLogged(recipe.ID)
+	DB.Scopes(recipeDetails).First(&recipe, recipe.ID)
+	recordRecipeActivity(recipe)
+
 	c.JSON(http.StatusOK, recipe)
 }
 
-// DeleteRecipe handles the DELETE /recipes/:id endpoint.
+// DeleteRecipe handles the DELETE /recipes/:id endpoint. Only the recipe's
+// owner can delete it.
 func DeleteRecipe(c *gin.Context) {
-	id := c.Param("id")
 	var recipe Recipe
-	if err := DB.First(&recipe, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+	if !findOwnRecipe(c, &recipe) {
 		return
 	}
 
@@ -162,10 +501,15 @@ func DeleteRecipe(c *gin.Context) {
 func CreateRecipe(c *gin.Context) {
 	// Define a struct to bind incoming JSON data.
 	var input struct {
-		Title        string `json:"title" binding:"required"`
-		Ingredients  string `json:"ingredients" binding:"required"`
-		Instructions string `json:"instructions" binding:"required"`
-		Calories     int    `json:"calories" binding:"required,min=0"`
+		Title        string            `json:"title" binding:"required"`
+		Ingredients  string            `json:"ingredients" binding:"required"`
+		Instructions string            `json:"instructions" binding:"required_without=Steps"`
+		Steps        []recipeStepInput `json:"steps" binding:"omitempty,dive"`
+		Calories     *int              `json:"calories" binding:"omitempty,min=0"`
+		Servings     int               `json:"servings" binding:"omitempty,min=1"`
+		Tags         []string          `json:"tags" binding:"omitempty,dive,max=64"`
+		Visibility   string            `json:"visibility" binding:"omitempty,oneof=private unlisted public"`
+		recipeMetadataInput
 	}
 
 	// Bind JSON input to the input struct.
@@ -179,8 +523,26 @@ func CreateRecipe(c *gin.Context) {
 		Title:        input.Title,
 		Ingredients:  input.Ingredients,
 		Instructions: input.Instructions,
-		Calories:     input.Calories,
-		UserID:       uint(c.GetUint("userID")), // Assuming userID is set in context
+		Servings:     input.Servings,
+		UserID:       c.GetUint("userID"),
+	}
+	if recipe.Servings == 0 {
+		recipe.Servings = 1
+	}
+	// New recipes get the owner's default visibility unless one is given.
+	recipe.Visibility = input.Visibility
+	if recipe.Visibility == "" {
+		recipe.Visibility = defaultVisibility(recipe.UserID)
+	}
+	now := time.Now()
+	recipe.Status, recipe.PublishedAt = RecipeStatusPublished, &now
+	recipe.Equipment = []string{}
+	input.apply(&recipe)
+
+	// Calories are computed from the ingredients unless typed in.
+	if input.Calories != nil {
+		recipe.Calories = *input.Calories
+		recipe.CaloriesManual = true
 	}
 
 	// Insert the new recipe into the database.
@@ -189,21 +551,40 @@ func CreateRecipe(c *gin.Context) {
 		return
 	}
 
+	// Apply the given tags along with the system tags from the metadata.
+	if err := saveRecipeTags(DB, recipe, input.Tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to tag recipe"})
+		return
+	}
+
+	// Store structured steps, or split them from the instruction text.
+	if err := saveRecipeSteps(DB, &recipe, input.Steps); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create recipe steps"})
+		return
+	}
+
+	saveRecipeRevisionLogged(recipe.ID, recipe.UserID, "Created recipe")
+	refreshRecipeNutritionLogged(recipe.ID)
+	recordRecipeActivity(recipe)
+	DB.Scopes(recipeDetails).First(&recipe, recipe.ID)
+
 	// Return the created recipe to the client.
 	c.JSON(http.StatusCreated, recipe)
 }
 
-// GetIngredients handles the GET /ingredients endpoint.
+// GetIngredients handles the GET /ingredients endpoint, listing the
+// ingredients of the recipes the caller may see.
 func GetIngredients(c *gin.Context) {
 	var ingredients []Ingredient
-	if err := DB.Find(&ingredients).Error; err != nil {
+	if err := DB.Scopes(visibleIngredients(c)).Find(&ingredients).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve ingredients"})
 		return
 	}
 	c.JSON(http.StatusOK, ingredients)
 }
 
-// GetIngredient handles the GET /ingredients/:id endpoint.
+// GetIngredient handles the GET /ingredients/:id endpoint. Ingredients of
+// recipes hidden from the caller are not found.
 func GetIngredient(c *gin.Context) {
 	id := c.Param("id")
 	var ingredient Ingredient
@@ -211,10 +592,59 @@ func GetIngredient(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
 		return
 	}
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, ingredient.RecipeID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
+		return
+	}
 	c.JSON(http.StatusOK, ingredient)
 }
 
-// UpdateIngredient handles the PUT /ingredients/:id endpoint.
+// CreateIngredient handles the POST /ingredients endpoint. Only the
+// recipe's owner can add ingredients to it.
+func CreateIngredient(c *gin.Context) {
+	// Define a struct to bind incoming JSON data.
+	var input struct {
+		Name     string `json:"name" binding:"required"`
+		Quantity string `json:"quantity"`
+		RecipeID uint   `json:"recipe_id" binding:"required"`
+	}
+
+	// Bind JSON input to the input struct.
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Make sure the ingredient belongs to a recipe of the caller's.
+	var recipe Recipe
+	if !findOwnRecipeByID(c, &recipe, input.RecipeID) {
+		return
+	}
+
+	ingredient := Ingredient{
+		Name:     input.Name,
+		Quantity: input.Quantity,
+		RecipeID: recipe.ID,
+	}
+
+	editorID := c.GetUint("userID")
+	if _, err := saveRecipeRevision(DB, recipe.ID, editorID, "Snapshot before edit"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record recipe revision"})
+		return
+	}
+	if err := DB.Create(&ingredient).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ingredient"})
+		return
+	}
+	saveRecipeRevisionLogged(recipe.ID, editorID, "Added ingredient "+ingredient.Name)
+	refreshRecipeNutritionLogged(ingredient.RecipeID)
+
+	c.JSON(http.StatusCreated, ingredient)
+}
+
+// UpdateIngredient handles the PUT /ingredients/:id endpoint. Only the
+// recipe's owner can edit its ingredients.
 func UpdateIngredient(c *gin.Context) {
 	id := c.Param("id")
 	var ingredient Ingredient
@@ -222,6 +652,10 @@ func UpdateIngredient(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
 		return
 	}
+	var recipe Recipe
+	if !findOwnRecipeByID(c, &recipe, ingredient.RecipeID) {
+		return
+	}
 
 	var input struct {
 		Name     string `json:"name" binding:"required"`
@@ -238,15 +672,23 @@ func UpdateIngredient(c *gin.Context) {
 		Quantity: input.Quantity,
 	}
 
+	editorID := c.GetUint("userID")
+	if _, err := saveRecipeRevision(DB, ingredient.RecipeID, editorID, "Snapshot before edit"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record recipe revision"})
+		return
+	}
 	if err := DB.Model(&ingredient).Updates(updated).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update ingredient"})
 		return
 	}
+	saveRecipeRevisionLogged(ingredient.RecipeID, editorID, "Updated ingredient "+ingredient.Name)
+	refreshRecipeNutritionLogged(ingredient.RecipeID)
 
 	c.JSON(http.StatusOK, ingredient)
 }
 
-// DeleteIngredient handles the DELETE /ingredients/:id endpoint.
+// DeleteIngredient handles the DELETE /ingredients/:id endpoint. Only the
+// recipe's owner can remove its ingredients.
 func DeleteIngredient(c *gin.Context) {
 	id := c.Param("id")
 	var ingredient Ingredient
@@ -254,11 +696,22 @@ func DeleteIngredient(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
 		return
 	}
+	var recipe Recipe
+	if !findOwnRecipeByID(c, &recipe, ingredient.RecipeID) {
+		return
+	}
 
+	editorID := c.GetUint("userID")
+	if _, err := saveRecipeRevision(DB, ingredient.RecipeID, editorID, "Snapshot before edit"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record recipe revision"})
+		return
+	}
 	if err := DB.Delete(&ingredient).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete ingredient"})
 		return
 	}
+	saveRecipeRevisionLogged(ingredient.RecipeID, editorID, "Removed ingredient "+ingredient.Name)
+	refreshRecipeNutritionLogged(ingredient.RecipeID)
 
 	c.JSON(http.StatusOK, gin.H{"status": "Ingredient deleted"})
 }
@@ -388,6 +841,42 @@ func generateJWT(userID uint) (string, error) {
 	return token.SignedString(jwtSecret)
 }
 
+// parseUserToken returns the user ID in a "Bearer <token>" header value.
+func parseUserToken(authHeader string) (uint, error) {
+	// Expecting header value in the format "Bearer <token>"
+	var tokenString string
+	fmt.Sscanf(authHeader, "Bearer %s", &tokenString)
+	if tokenString == "" {
+		return 0, errors.New("Authorization token missing")
+	}
+
+	// Parse the token.
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		// Ensure the token method conforms to "SigningMethodHMAC".
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+
+	if err != nil || !token.Valid {
+		return 0, errors.New("Invalid token")
+	}
+
+	// Extract claims
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("Invalid token claims")
+	}
+
+	// Extract user ID from claims.
+	userIDFloat, ok := claims["userID"].(float64)
+	if !ok {
+		return 0, errors.New("Invalid user ID in token")
+	}
+	return uint(userIDFloat), nil
+}
+
 // JWTMiddleware is a middleware function for validating JWT tokens.
 func JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -399,46 +888,12 @@ func JWTMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Expecting header value in the format "Bearer <token>"
-		var tokenString string
-		fmt.Sscanf(authHeader, "Bearer %s", &tokenString)
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization token missing"})
-			c.Abort()
-			return
-		}
-
-		// Parse the token.
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Ensure the token method conforms to "SigningMethodHMAC".
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
-
-		// Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
-			return
-		}
-
-		// Extract user ID from claims.
-		userIDFloat, ok := claims["userID"].(float64)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		userID, err := parseUserToken(authHeader)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
-		userID := uint(userIDFloat)
 
 		// Set user ID in context.
 		c.Set("userID", userID)
@@ -446,3 +901,17 @@ func JWTMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalJWTMiddleware sets the user ID from a valid token when one is
+// sent, letting public endpoints show signed-in users their own content.
+// Requests without a valid token continue anonymously.
+func OptionalJWTMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			if userID, err := parseUserToken(authHeader); err == nil {
+				c.Set("userID", userID)
+			}
+		}
+		c.Next()
+	}
+}
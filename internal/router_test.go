@@ -14,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
 // setupRouter initializes the router with routes and middleware for testing.
@@ -237,3 +238,27 @@ func TestGetRecipe(t *testing.T) {
 	assert.Equal(t, recipe.Calories, fetchedRecipe.Calories)
 	assert.Equal(t, recipe.UserID, fetchedRecipe.UserID)
 }
+
+// TestGetRecipesEscapesWildcards verifies % and _ in the title and
+// ingredient filters match themselves rather than anything.
+func TestGetRecipesEscapesWildcards(t *testing.T) {
+	saved := DB
+	defer func() { DB = saved }()
+	DB = DryRunDB(t)
+	var vars []interface{}
+	DB.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		if vars == nil {
+			vars = tx.Statement.Vars
+		}
+	})
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/recipes?title=100%25&ingredient=a_b", nil)
+	GetRecipes(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, vars, `%100\%%`)
+	assert.Contains(t, vars, `%a\_b%`)
+}
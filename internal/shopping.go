@@ -0,0 +1,474 @@
+// shopping.go
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ShoppingNeed is an ingredient requirement (or stock on hand) fed into
+// shopping list consolidation.
+type ShoppingNeed struct {
+	Name     string
+	Quantity Quantity
+}
+
+// aisleCategories lists store aisles in walking order with the keywords that
+// place an ingredient in them. More specific aisles come first so that
+// "garlic powder" lands in spices rather than produce.
+var aisleCategories = []struct {
+	name     string
+	keywords []string
+}{
+	{"spices & seasonings", []string{"salt", "pepper flakes", "black pepper", "peppercorn", "powder", "cumin", "paprika", "oregano", "cinnamon", "nutmeg", "thyme", "dried", "seasoning", "spice", "bay leaf", "turmeric", "vanilla", "clove"}},
+	{"condiments & oils", []string{"oil", "vinegar", "sauce", "ketchup", "mustard", "mayonnaise", "honey", "syrup", "peanut butter", "jam", "salsa", "dressing"}},
+	{"canned & jarred", []string{"canned", "can", "tomato paste", "broth", "stock", "coconut milk", "beans", "chickpea", "olive"}},
+	{"frozen", []string{"frozen", "ice cream"}},
+	{"bakery", []string{"bread", "bun", "roll", "tortilla", "pita", "bagel", "baguette"}},
+	{"dairy & eggs", []string{"milk", "butter", "cheese", "cream", "yogurt", "egg", "parmesan", "mozzarella", "cheddar"}},
+	{"meat & seafood", []string{"chicken", "beef", "pork", "lamb", "turkey", "bacon", "sausage", "ham", "fish", "salmon", "tuna", "shrimp", "prawn", "cod", "steak", "mince"}},
+	{"produce", []string{"apple", "banana", "lemon", "lime", "orange", "berry", "tomato", "onion", "garlic", "potato", "carrot", "celery", "lettuce", "spinach", "pepper", "cucumber", "zucchini", "mushroom", "broccoli", "cabbage", "ginger", "herb", "parsley", "cilantro", "basil", "mint", "avocado", "scallion", "shallot", "leek", "kale", "squash"}},
+	{"dry goods", []string{"flour", "sugar", "rice", "pasta", "spaghetti", "noodle", "oat", "lentil", "quinoa", "baking soda", "baking powder", "yeast", "cornstarch", "cereal", "nut", "almond", "walnut", "chocolate", "cocoa"}},
+	{"beverages", []string{"wine", "beer", "juice", "coffee", "tea", "water", "soda"}},
+}
+
+// aisleOther is the category for ingredients no keyword matches.
+const aisleOther = "other"
+
+// AisleCategory returns the store aisle an ingredient is usually found in.
+func AisleCategory(name string) string {
+	padded := " " + NormalizeIngredientName(name) + " "
+	for _, aisle := range aisleCategories {
+		for _, keyword := range aisle.keywords {
+			if strings.Contains(padded, " "+keyword+" ") || strings.Contains(padded, " "+keyword+"s ") {
+				return aisle.name
+			}
+		}
+	}
+	return aisleOther
+}
+
+// aisleOrder returns the walking-order position of an aisle category.
+func aisleOrder(category string) int {
+	for i, aisle := range aisleCategories {
+		if aisle.name == category {
+			return i
+		}
+	}
+	return len(aisleCategories)
+}
+
+// shoppingKey identifies ingredients that can be summed together.
+type shoppingKey struct {
+	name      string
+	dimension string
+	unit      string
+}
+
+// newShoppingKey builds the consolidation key for a need. Volumes and masses
+// sum across units; counts only sum within the same unit.
+func newShoppingKey(need ShoppingNeed) shoppingKey {
+	key := shoppingKey{name: NormalizeIngredientName(need.Name), dimension: need.Quantity.Dimension()}
+	if key.dimension == DimensionCount {
+		key.unit = need.Quantity.Unit
+	}
+	return key
+}
+
+// ConsolidateShoppingItems sums the needs for the same ingredient across
+// recipes with unit normalization, subtracts what is already on hand and
+// returns list items ordered by aisle.
+func ConsolidateShoppingItems(needs, onHand []ShoppingNeed) []ShoppingListItem {
+	type group struct {
+		base  float64
+		units map[string]bool
+		stock bool
+	}
+	groups := make(map[shoppingKey]*group)
+	var order []shoppingKey
+	for _, need := range needs {
+		key := newShoppingKey(need)
+		if key.name == "" {
+			continue
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{units: make(map[string]bool)}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.base += need.Quantity.Base()
+		g.units[need.Quantity.Unit] = true
+	}
+	for _, stock := range onHand {
+		if g, ok := groups[newShoppingKey(stock)]; ok {
+			g.base -= stock.Quantity.Base()
+			g.stock = true
+		}
+	}
+
+	items := make([]ShoppingListItem, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		// Unquantified needs ("salt to taste") are skipped once any stock exists.
+		if g.base <= 0 && (g.stock || len(g.units) > 1 || !g.units[""]) {
+			continue
+		}
+		quantity := Quantity{Amount: g.base}
+		if len(g.units) == 1 {
+			for unit := range g.units {
+				quantity.Unit = unit
+			}
+			if info, ok := units[quantity.Unit]; ok {
+				quantity.Amount = g.base / info.base
+			}
+		} else if key.dimension == DimensionMass {
+			quantity = Quantity{Amount: g.base, Unit: "g"}.Humanize()
+		} else if key.dimension == DimensionVolume {
+			quantity = Quantity{Amount: g.base, Unit: "ml"}.Humanize()
+		}
+		items = append(items, ShoppingListItem{
+			Name:     key.name,
+			Amount:   quantity.Amount,
+			Unit:     quantity.Unit,
+			Category: AisleCategory(key.name),
+		})
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		oi, oj := aisleOrder(items[i].Category), aisleOrder(items[j].Category)
+		if oi != oj {
+			return oi < oj
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+// recipeIngredientNeeds loads the ingredient requirements of each recipe,
+// keyed by recipe ID. Recipes without Ingredient rows fall back to parsing
+// their ingredients text.
+func recipeIngredientNeeds(db *gorm.DB, recipeIDs []uint) (map[uint][]ShoppingNeed, error) {
+	needsByRecipe := make(map[uint][]ShoppingNeed)
+	if len(recipeIDs) == 0 {
+		return needsByRecipe, nil
+	}
+	var recipes []Recipe
+	if err := db.Where("id IN ?", recipeIDs).Find(&recipes).Error; err != nil {
+		return nil, err
+	}
+	var ingredients []Ingredient
+	if err := db.Where("recipe_id IN ?", recipeIDs).Find(&ingredients).Error; err != nil {
+		return nil, err
+	}
+
+	for _, ingredient := range ingredients {
+		quantity, _ := ParseQuantity(ingredient.Quantity)
+		needsByRecipe[ingredient.RecipeID] = append(needsByRecipe[ingredient.RecipeID], ShoppingNeed{Name: ingredient.Name, Quantity: quantity})
+	}
+	for _, recipe := range recipes {
+		if _, ok := needsByRecipe[recipe.ID]; ok {
+			continue
+		}
+		needs := []ShoppingNeed{}
+		for _, line := range strings.Split(recipe.Ingredients, "\n") {
+			quantity, name := ParseIngredientLine(line)
+			if name != "" {
+				needs = append(needs, ShoppingNeed{Name: name, Quantity: quantity})
+			}
+		}
+		needsByRecipe[recipe.ID] = needs
+	}
+	return needsByRecipe, nil
+}
+
+// recipeShoppingNeeds flattens the ingredient requirements for the given
+// recipes. A recipe listed more than once contributes once per occurrence.
+func recipeShoppingNeeds(db *gorm.DB, recipeIDs []uint) ([]ShoppingNeed, error) {
+	needsByRecipe, err := recipeIngredientNeeds(db, recipeIDs)
+	if err != nil {
+		return nil, err
+	}
+	var needs []ShoppingNeed
+	for _, id := range recipeIDs {
+		needs = append(needs, needsByRecipe[id]...)
+	}
+	return needs, nil
+}
+
+// findShoppingList loads a shopping list visible to the user along with its items.
+func findShoppingList(c *gin.Context, id string) (ShoppingList, error) {
+	var list ShoppingList
+	err := DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("category, name")
+	}).Scopes(ownerScope(c)).First(&list, id).Error
+	return list, err
+}
+
+// GetShoppingLists handles the GET /me/shopping-lists endpoint.
+func GetShoppingLists(c *gin.Context) {
+	var lists []ShoppingList
+	if err := DB.Scopes(ownerScope(c)).Order("created_at DESC").Find(&lists).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve shopping lists"})
+		return
+	}
+	c.JSON(http.StatusOK, lists)
+}
+
+// GetShoppingList handles the GET /me/shopping-lists/:id endpoint.
+func GetShoppingList(c *gin.Context) {
+	list, err := findShoppingList(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list not found"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// CreateShoppingList handles the POST /me/shopping-lists endpoint.
+// Items are generated from the given recipes and/or the meal plan entries
+// between from and to (inclusive, YYYY-MM-DD), minus what is in the pantry.
+func CreateShoppingList(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Name      string `json:"name"`
+		RecipeIDs []uint `json:"recipe_ids"`
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Private   bool   `json:"private"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only recipes the caller may read can be shopped for, so that their
+	// ingredients are not revealed through the list.
+	if len(input.RecipeIDs) > 0 {
+		var visibleIDs []uint
+		err := DB.Model(&Recipe{}).Scopes(visibleRecipes(c)).Where("recipes.id IN ?", input.RecipeIDs).Pluck("recipes.id", &visibleIDs).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipes"})
+			return
+		}
+		visible := make(map[uint]bool, len(visibleIDs))
+		for _, id := range visibleIDs {
+			visible[id] = true
+		}
+		for _, id := range input.RecipeIDs {
+			if !visible[id] {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Recipe %d not found", id)})
+				return
+			}
+		}
+	}
+
+	recipeIDs := input.RecipeIDs
+	if input.From != "" || input.To != "" {
+		from, to, err := parseDateRange(input.From, input.To)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var entries []MealPlanEntry
+		if err := DB.Scopes(ownerScope(c)).Where("date BETWEEN ? AND ?", from, to).Find(&entries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve meal plan"})
+			return
+		}
+		for _, entry := range entries {
+			recipeIDs = append(recipeIDs, entry.RecipeID)
+		}
+	}
+
+	needs, err := recipeShoppingNeeds(DB, recipeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipe ingredients"})
+		return
+	}
+
+	var pantry []PantryItem
+	if err := DB.Scopes(ownerScope(c)).Find(&pantry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve pantry"})
+		return
+	}
+
+	name := input.Name
+	if name == "" {
+		name = "Shopping list"
+	}
+	list := ShoppingList{
+		UserID: userID,
+		Name:   name,
+		Items:  ConsolidateShoppingItems(needs, pantryStock(pantry)),
+	}
+	if !input.Private {
+		list.HouseholdID = currentHouseholdID(c)
+	}
+	if err := DB.Create(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create shopping list"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
+
+// UpdateShoppingList handles the PUT /me/shopping-lists/:id endpoint.
+func UpdateShoppingList(c *gin.Context) {
+	list, err := findShoppingList(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list not found"})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := DB.Model(&list).Update("name", input.Name).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shopping list"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// DeleteShoppingList handles the DELETE /me/shopping-lists/:id endpoint.
+func DeleteShoppingList(c *gin.Context) {
+	list, err := findShoppingList(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list not found"})
+		return
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shopping_list_id = ?", list.ID).Delete(&ShoppingListItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&list).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shopping list"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Shopping list deleted"})
+}
+
+// AddShoppingListItem handles the POST /me/shopping-lists/:id/items endpoint.
+func AddShoppingListItem(c *gin.Context) {
+	list, err := findShoppingList(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list not found"})
+		return
+	}
+
+	var input struct {
+		Name     string `json:"name" binding:"required"`
+		Quantity string `json:"quantity"`
+		Category string `json:"category"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quantity, _ := ParseQuantity(input.Quantity)
+	category := input.Category
+	if category == "" {
+		category = AisleCategory(input.Name)
+	}
+	item := ShoppingListItem{
+		ShoppingListID: list.ID,
+		Name:           input.Name,
+		Amount:         quantity.Amount,
+		Unit:           quantity.Unit,
+		Category:       category,
+		AdHoc:          true,
+	}
+	if err := DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add shopping list item"})
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateShoppingListItem handles the PUT /me/shopping-lists/:id/items/:itemId endpoint.
+// Only the fields present in the payload are changed, so clients can check
+// off an item by sending {"checked": true}.
+func UpdateShoppingListItem(c *gin.Context) {
+	list, err := findShoppingList(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list not found"})
+		return
+	}
+
+	var item ShoppingListItem
+	if err := DB.Where("shopping_list_id = ?", list.ID).First(&item, c.Param("itemId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list item not found"})
+		return
+	}
+
+	var input struct {
+		Name     *string `json:"name"`
+		Quantity *string `json:"quantity"`
+		Category *string `json:"category"`
+		Checked  *bool   `json:"checked"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		updates["name"] = *input.Name
+	}
+	if input.Quantity != nil {
+		quantity, _ := ParseQuantity(*input.Quantity)
+		updates["amount"] = quantity.Amount
+		updates["unit"] = quantity.Unit
+	}
+	if input.Category != nil {
+		updates["category"] = *input.Category
+	}
+	if input.Checked != nil {
+		updates["checked"] = *input.Checked
+	}
+
+	if err := DB.Model(&item).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update shopping list item"})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteShoppingListItem handles the DELETE /me/shopping-lists/:id/items/:itemId endpoint.
+func DeleteShoppingListItem(c *gin.Context) {
+	list, err := findShoppingList(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list not found"})
+		return
+	}
+
+	result := DB.Where("shopping_list_id = ?", list.ID).Delete(&ShoppingListItem{}, c.Param("itemId"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete shopping list item"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Shopping list item not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Shopping list item deleted"})
+}
@@ -0,0 +1,53 @@
+// shopping_test.go
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConsolidateShoppingItems verifies summing across recipes, unit
+// normalization, pantry subtraction and aisle ordering.
+func TestConsolidateShoppingItems(t *testing.T) {
+	needs := []ShoppingNeed{
+		{Name: "Flour", Quantity: Quantity{Amount: 1, Unit: "cup"}},
+		{Name: "flour, sifted", Quantity: Quantity{Amount: 2, Unit: "cup"}},
+		{Name: "Butter", Quantity: Quantity{Amount: 200, Unit: "g"}},
+		{Name: "butter", Quantity: Quantity{Amount: 1, Unit: "kg"}},
+		{Name: "Eggs", Quantity: Quantity{Amount: 2}},
+		{Name: "egg", Quantity: Quantity{Amount: 1}},
+		{Name: "Milk", Quantity: Quantity{Amount: 1, Unit: "cup"}},
+		{Name: "Salt", Quantity: Quantity{}},
+	}
+	onHand := []ShoppingNeed{
+		{Name: "milk", Quantity: Quantity{Amount: 1, Unit: "l"}},
+	}
+
+	items := ConsolidateShoppingItems(needs, onHand)
+
+	byName := map[string]ShoppingListItem{}
+	for _, item := range items {
+		byName[item.Name] = item
+	}
+	assert.Len(t, items, 4)
+	assert.InDelta(t, 3, byName["flour"].Amount, 0.001)
+	assert.Equal(t, "cup", byName["flour"].Unit)
+	assert.InDelta(t, 1.2, byName["butter"].Amount, 0.001)
+	assert.Equal(t, "kg", byName["butter"].Unit)
+	assert.InDelta(t, 3, byName["egg"].Amount, 0.001)
+	assert.NotContains(t, byName, "milk", "milk on hand covers the need")
+	assert.Contains(t, byName, "salt", "unquantified needs stay on the list")
+
+	assert.Equal(t, "spices & seasonings", items[0].Category)
+	assert.Equal(t, "dry goods", items[len(items)-1].Category)
+}
+
+// TestAisleCategory verifies keyword-based aisle assignment.
+func TestAisleCategory(t *testing.T) {
+	assert.Equal(t, "produce", AisleCategory("Red onions"))
+	assert.Equal(t, "spices & seasonings", AisleCategory("garlic powder"))
+	assert.Equal(t, "condiments & oils", AisleCategory("peanut butter"))
+	assert.Equal(t, "dairy & eggs", AisleCategory("unsalted butter"))
+	assert.Equal(t, aisleOther, AisleCategory("xanthan gum"))
+}
@@ -0,0 +1,260 @@
+// steps.go
+package internal
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// stepNumberPattern matches a leading step number such as "1.", "2)" or "Step 3:".
+var stepNumberPattern = regexp.MustCompile(`(?i)^\s*(?:step\s+)?\d+\s*[.):-]\s*`)
+
+// inlineStepPattern finds step numbers inside a single line of numbered steps.
+var inlineStepPattern = regexp.MustCompile(`(?:^|\s)\d+[.)]\s+`)
+
+// durationPattern matches durations such as "25 minutes", "1-2 hrs" or "30 sec".
+var durationPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)(?:\s*(?:-|–|to)\s*(\d+(?:\.\d+)?))?\s*(hours?|hrs?|minutes?|mins?|seconds?|secs?)\b`)
+
+// temperaturePattern matches oven temperatures such as "180°C", "350 F" or "400 degrees".
+var temperaturePattern = regexp.MustCompile(`(?i)(\d{2,3})\s*(?:°\s*|degrees?\s*)?(c|f|celsius|fahrenheit)?\b`)
+
+// SplitInstructions splits an instruction blob into ordered steps. Lines
+// are steps with any leading numbering removed; a short line ending in a
+// colon ("For the sauce:") becomes the section of the steps that follow.
+// A single line of inline numbered steps ("1. Mix. 2. Bake.") is split on
+// the numbers. Durations and temperatures are detected from the text.
+func SplitInstructions(text string) []RecipeStep {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if len(lines) == 1 && len(inlineStepPattern.FindAllStringIndex(text, -1)) > 1 {
+		lines = inlineStepPattern.Split(text, -1)
+	}
+
+	var steps []RecipeStep
+	section := ""
+	for _, line := range lines {
+		line = strings.TrimSpace(stepNumberPattern.ReplaceAllString(line, ""))
+		line = strings.TrimLeft(line, "-•* ")
+		if line == "" {
+			continue
+		}
+		if strings.HasSuffix(line, ":") && len(line) <= 60 && !strings.Contains(line, ". ") {
+			section = strings.TrimSuffix(line, ":")
+			continue
+		}
+		steps = append(steps, newRecipeStep(len(steps)+1, section, line))
+	}
+	return steps
+}
+
+// newRecipeStep builds a step with its timer and temperature detected from the text.
+func newRecipeStep(position int, section, text string) RecipeStep {
+	step := RecipeStep{Position: position, Section: section, Text: text, DurationSeconds: DetectStepDuration(text)}
+	step.Temperature, step.TemperatureUnit = DetectStepTemperature(text)
+	return step
+}
+
+// DetectStepDuration returns the total duration mentioned in a step in
+// seconds, using the upper bound of ranges ("10-12 minutes").
+func DetectStepDuration(text string) int {
+	total := 0.0
+	for _, m := range durationPattern.FindAllStringSubmatch(text, -1) {
+		amount, _ := strconv.ParseFloat(m[1], 64)
+		if m[2] != "" {
+			amount, _ = strconv.ParseFloat(m[2], 64)
+		}
+		switch unit := strings.ToLower(m[3]); {
+		case strings.HasPrefix(unit, "h"):
+			total += amount * 3600
+		case strings.HasPrefix(unit, "m"):
+			total += amount * 60
+		default:
+			total += amount
+		}
+	}
+	return int(math.Round(total))
+}
+
+// DetectStepTemperature returns the first temperature mentioned in a step.
+// Bare "degrees" are taken as Fahrenheit above 250 and Celsius otherwise.
+func DetectStepTemperature(text string) (float64, string) {
+	for _, m := range temperaturePattern.FindAllStringSubmatch(text, -1) {
+		if m[2] == "" && !strings.Contains(m[0], "°") && !strings.Contains(strings.ToLower(m[0]), "degree") {
+			continue
+		}
+		value, _ := strconv.ParseFloat(m[1], 64)
+		var unit string
+		if m[2] != "" {
+			unit = strings.ToUpper(m[2][:1])
+		} else {
+			unit = "C"
+			if value > 250 {
+				unit = "F"
+			}
+		}
+		return value, unit
+	}
+	return 0, ""
+}
+
+// stepIngredientRefs returns the ingredient names mentioned in a step's text.
+func stepIngredientRefs(text string, names []string) []string {
+	lower := strings.ToLower(text)
+	refs := []string{}
+	for _, name := range names {
+		normalized := NormalizeIngredientName(name)
+		if normalized != "" && strings.Contains(lower, normalized) {
+			refs = append(refs, normalized)
+		}
+	}
+	return refs
+}
+
+// RenderInstructions joins steps back into numbered instruction text with
+// section headings, keeping Recipe.Instructions in sync with the steps.
+func RenderInstructions(steps []RecipeStep) string {
+	var b strings.Builder
+	section := ""
+	for i, step := range steps {
+		if step.Section != section {
+			section = step.Section
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			if section != "" {
+				fmt.Fprintf(&b, "%s:\n", section)
+			}
+		}
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step.Text)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// recipeStepInput is the JSON format for a structured step. Durations and
+// temperatures left out are detected from the text.
+type recipeStepInput struct {
+	Section         string   `json:"section"`
+	Text            string   `json:"text" binding:"required"`
+	DurationSeconds *int     `json:"duration_seconds" binding:"omitempty,min=0"`
+	Temperature     *float64 `json:"temperature" binding:"omitempty,min=0"`
+	TemperatureUnit string   `json:"temperature_unit" binding:"omitempty,oneof=C F"`
+	Ingredients     []string `json:"ingredients"`
+}
+
+// buildRecipeSteps turns step input into ordered steps. Ingredient
+// references default to the ingredients named in the step text.
+func buildRecipeSteps(inputs []recipeStepInput, ingredientNames []string) []RecipeStep {
+	steps := make([]RecipeStep, 0, len(inputs))
+	for i, input := range inputs {
+		step := newRecipeStep(i+1, strings.TrimSpace(input.Section), strings.TrimSpace(input.Text))
+		if input.DurationSeconds != nil {
+			step.DurationSeconds = *input.DurationSeconds
+		}
+		if input.Temperature != nil {
+			step.Temperature, step.TemperatureUnit = *input.Temperature, input.TemperatureUnit
+			if step.TemperatureUnit == "" {
+				step.TemperatureUnit = "C"
+			}
+		}
+		step.Ingredients = stepIngredientRefs(step.Text, ingredientNames)
+		if input.Ingredients != nil {
+			step.Ingredients = make([]string, 0, len(input.Ingredients))
+			for _, name := range input.Ingredients {
+				step.Ingredients = append(step.Ingredients, NormalizeIngredientName(name))
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// splitRecipeSteps splits instruction text into steps referencing the
+// recipe's ingredients.
+func splitRecipeSteps(instructions string, ingredientNames []string) []RecipeStep {
+	steps := SplitInstructions(instructions)
+	for i := range steps {
+		steps[i].Ingredients = stepIngredientRefs(steps[i].Text, ingredientNames)
+	}
+	return steps
+}
+
+// recipeIngredientNames returns the ingredient names of a recipe from its
+// ingredient rows or, failing that, its ingredient text.
+func recipeIngredientNames(recipeID uint) ([]string, error) {
+	needsByRecipe, err := recipeIngredientNeeds(DB, []uint{recipeID})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(needsByRecipe[recipeID]))
+	for _, need := range needsByRecipe[recipeID] {
+		names = append(names, need.Name)
+	}
+	return names, nil
+}
+
+// replaceRecipeSteps replaces all steps of a recipe.
+func replaceRecipeSteps(tx *gorm.DB, recipeID uint, steps []RecipeStep) error {
+	if err := tx.Where("recipe_id = ?", recipeID).Delete(&RecipeStep{}).Error; err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	for i := range steps {
+		steps[i].ID = 0
+		steps[i].RecipeID = recipeID
+	}
+	return tx.Create(&steps).Error
+}
+
+// saveRecipeSteps stores a recipe's steps from structured input or, when
+// none is given, by splitting its instruction text. Structured steps are
+// rendered back into Recipe.Instructions for clients reading the text.
+func saveRecipeSteps(db *gorm.DB, recipe *Recipe, inputs []recipeStepInput) error {
+	names, err := recipeIngredientNames(recipe.ID)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if inputs == nil {
+			return replaceRecipeSteps(tx, recipe.ID, splitRecipeSteps(recipe.Instructions, names))
+		}
+		steps := buildRecipeSteps(inputs, names)
+		if err := replaceRecipeSteps(tx, recipe.ID, steps); err != nil {
+			return err
+		}
+		recipe.Instructions = RenderInstructions(steps)
+		return tx.Model(recipe).UpdateColumn("instructions", recipe.Instructions).Error
+	})
+}
+
+// backfillRecipeSteps splits the instructions of recipes that have no
+// structured steps yet. It runs after migrations.
+func backfillRecipeSteps(db *gorm.DB) error {
+	var recipes []Recipe
+	err := db.Where("instructions <> '' AND NOT EXISTS (SELECT 1 FROM recipe_steps WHERE recipe_steps.recipe_id = recipes.id)").
+		FindInBatches(&recipes, 200, func(tx *gorm.DB, batch int) error {
+			for _, recipe := range recipes {
+				names, err := recipeIngredientNames(recipe.ID)
+				if err != nil {
+					return err
+				}
+				if err := replaceRecipeSteps(db, recipe.ID, splitRecipeSteps(recipe.Instructions, names)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	return err
+}
+
+// recipeDetails preloads the associations returned with a single recipe.
+func recipeDetails(db *gorm.DB) *gorm.DB {
+	return db.Preload("Nutrition").Preload("Tags").Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("Images", recipePhotos)
+}
@@ -0,0 +1,63 @@
+// steps_test.go
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSplitInstructions verifies numbering is stripped, sections are detected and timers parsed.
+func TestSplitInstructions(t *testing.T) {
+	text := "For the sauce:\n1. Melt the butter.\n2) Simmer for 10-12 minutes.\n\nFor the cake:\nStep 3: Bake at 180°C for 1 hour 30 minutes."
+
+	steps := SplitInstructions(text)
+
+	assert.Len(t, steps, 3)
+	assert.Equal(t, "Melt the butter.", steps[0].Text)
+	assert.Equal(t, "For the sauce", steps[0].Section)
+	assert.Equal(t, 1, steps[0].Position)
+	assert.Equal(t, 720, steps[1].DurationSeconds)
+	assert.Equal(t, "For the cake", steps[2].Section)
+	assert.Equal(t, 5400, steps[2].DurationSeconds)
+	assert.Equal(t, 180.0, steps[2].Temperature)
+	assert.Equal(t, "C", steps[2].TemperatureUnit)
+	assert.Equal(t, 3, steps[2].Position)
+}
+
+// TestSplitInstructionsInline verifies a single line of numbered steps is split.
+func TestSplitInstructionsInline(t *testing.T) {
+	steps := SplitInstructions("1. Mix the flour and 2 cups of milk. 2. Bake.")
+	assert.Len(t, steps, 2)
+	assert.Equal(t, "Mix the flour and 2 cups of milk.", steps[0].Text)
+	assert.Equal(t, "Bake.", steps[1].Text)
+}
+
+// TestDetectStepTemperature verifies explicit and inferred temperature units.
+func TestDetectStepTemperature(t *testing.T) {
+	value, unit := DetectStepTemperature("Preheat the oven to 350 degrees.")
+	assert.Equal(t, 350.0, value)
+	assert.Equal(t, "F", unit)
+
+	value, unit = DetectStepTemperature("Roast at 200 C until golden")
+	assert.Equal(t, 200.0, value)
+	assert.Equal(t, "C", unit)
+
+	value, unit = DetectStepTemperature("Add 12 cups of stock and 45 minutes later serve")
+	assert.Equal(t, 0.0, value)
+	assert.Equal(t, "", unit)
+}
+
+// TestBuildRecipeSteps verifies explicit values win and ingredient references are detected.
+func TestBuildRecipeSteps(t *testing.T) {
+	duration := 300
+	steps := buildRecipeSteps([]recipeStepInput{
+		{Section: "Dough", Text: "Knead the flour and eggs for 10 minutes", DurationSeconds: &duration},
+		{Section: "Dough", Text: "Rest", Ingredients: []string{"Eggs"}},
+	}, []string{"flour", "eggs", "sugar"})
+
+	assert.Equal(t, 300, steps[0].DurationSeconds)
+	assert.Equal(t, []string{"flour", "egg"}, steps[0].Ingredients)
+	assert.Equal(t, []string{"egg"}, steps[1].Ingredients)
+	assert.Equal(t, "Dough:\n1. Knead the flour and eggs for 10 minutes\n2. Rest", RenderInstructions(steps))
+}
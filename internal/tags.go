@@ -0,0 +1,559 @@
+// tags.go
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTagSlugLength matches the size of the tags.slug column.
+const maxTagSlugLength = 64
+
+// maxAliasDepth bounds how many alias links are followed to a canonical tag.
+const maxAliasDepth = 8
+
+// quickRecipeMinutes is the total time at or under which recipes get the
+// system "quick" tag.
+const quickRecipeMinutes = 30
+
+// TagUsage is a tag with the number of recipes it is applied to.
+type TagUsage struct {
+	Tag
+	UsageCount int64 `json:"usage_count"`
+}
+
+// Slugify normalizes a tag name to its slug: lower case letters and digits
+// separated by single hyphens ("Gluten Free!" becomes "gluten-free").
+func Slugify(name string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		hyphen = true
+	}
+	slug := b.String()
+	for len(slug) > maxTagSlugLength {
+		_, size := utf8.DecodeLastRuneInString(slug)
+		slug = slug[:len(slug)-size]
+	}
+	return strings.TrimRight(slug, "-")
+}
+
+// SystemTagNames derives the system tags of a recipe from its metadata.
+func SystemTagNames(recipe Recipe) []string {
+	var names []string
+	for _, name := range []string{recipe.Cuisine, recipe.Course, recipe.Difficulty} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if recipe.TotalTimeMinutes > 0 && recipe.TotalTimeMinutes <= quickRecipeMinutes {
+		names = append(names, "quick")
+	}
+	return names
+}
+
+// canonicalTag follows alias links to the canonical tag.
+func canonicalTag(tx *gorm.DB, tag Tag) (Tag, error) {
+	for depth := 0; tag.AliasOfID != nil; depth++ {
+		if depth == maxAliasDepth {
+			return tag, fmt.Errorf("tag %q has too many alias links", tag.Slug)
+		}
+		var next Tag
+		if err := tx.First(&next, *tag.AliasOfID).Error; err != nil {
+			return tag, err
+		}
+		tag = next
+	}
+	return tag, nil
+}
+
+// resolveTags finds the canonical tags for names, creating missing ones.
+// Tags created here are marked as system tags when system is set.
+func resolveTags(tx *gorm.DB, names []string, system bool) ([]Tag, error) {
+	var tags []Tag
+	seen := make(map[uint]bool)
+	for _, name := range names {
+		slug := Slugify(name)
+		if slug == "" {
+			continue
+		}
+		tag := Tag{Name: strings.TrimSpace(name), Slug: slug, System: system}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag).Error
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Where("slug = ?", slug).First(&tag).Error; err != nil {
+			return nil, err
+		}
+		if tag, err = canonicalTag(tx, tag); err != nil {
+			return nil, err
+		}
+		if !seen[tag.ID] {
+			seen[tag.ID] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// canonicalTagIDs resolves slugs through aliases to canonical tag IDs.
+// Unknown slugs are left out of the result.
+func canonicalTagIDs(tx *gorm.DB, slugs []string) (map[string]uint, error) {
+	var tags []Tag
+	if err := tx.Where("slug IN ?", slugs).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]uint, len(tags))
+	for _, tag := range tags {
+		canonical, err := canonicalTag(tx, tag)
+		if err != nil {
+			return nil, err
+		}
+		ids[tag.Slug] = canonical.ID
+	}
+	return ids, nil
+}
+
+// applyRecipeTags links tags to a recipe, keeping existing links.
+func applyRecipeTags(tx *gorm.DB, recipeID uint, userID *uint, tags []Tag) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	rows := make([]RecipeTag, 0, len(tags))
+	for _, tag := range tags {
+		rows = append(rows, RecipeTag{RecipeID: recipeID, TagID: tag.ID, UserID: userID})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// saveRecipeTags replaces the user-applied tags of a recipe when names is
+// not nil and re-derives its system tags from the metadata.
+func saveRecipeTags(tx *gorm.DB, recipe Recipe, names []string) error {
+	if names != nil {
+		tags, err := resolveTags(tx, names, false)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("recipe_id = ? AND user_id IS NOT NULL", recipe.ID).Delete(&RecipeTag{}).Error; err != nil {
+			return err
+		}
+		if err := applyRecipeTags(tx, recipe.ID, &recipe.UserID, tags); err != nil {
+			return err
+		}
+	}
+
+	tags, err := resolveTags(tx, SystemTagNames(recipe), true)
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("recipe_id = ? AND user_id IS NULL", recipe.ID).Delete(&RecipeTag{}).Error; err != nil {
+		return err
+	}
+	return applyRecipeTags(tx, recipe.ID, nil, tags)
+}
+
+// recipeTagFilter applies the tags filter of GET /recipes. Tags are
+// comma-separated slugs or names; tag_mode=all (the default) requires every
+// tag and tag_mode=any at least one.
+func recipeTagFilter(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	var slugs []string
+	for _, name := range strings.Split(c.Query("tags"), ",") {
+		if slug := Slugify(name); slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	if len(slugs) == 0 {
+		return query, nil
+	}
+
+	mode := c.DefaultQuery("tag_mode", "all")
+	if mode != "all" && mode != "any" {
+		return nil, fmt.Errorf("invalid tag_mode %q, expected all or any", mode)
+	}
+	ids, err := canonicalTagIDs(DB, slugs)
+	if err != nil {
+		return nil, err
+	}
+	unique := make(map[uint]bool, len(ids))
+	tagIDs := []uint{}
+	for _, id := range ids {
+		if !unique[id] {
+			unique[id] = true
+			tagIDs = append(tagIDs, id)
+		}
+	}
+
+	if mode == "any" {
+		return query.Where("id IN (SELECT recipe_id FROM recipe_tags WHERE tag_id IN ?)", tagIDs), nil
+	}
+	if len(ids) < len(slugs) {
+		// An unknown tag can never be matched by every recipe.
+		return query.Where("1 = 0"), nil
+	}
+	return query.Where(
+		"id IN (SELECT recipe_id FROM recipe_tags WHERE tag_id IN ? GROUP BY recipe_id HAVING COUNT(DISTINCT tag_id) = ?)",
+		tagIDs, len(tagIDs),
+	), nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns in search text with
+// backslashes, the default LIKE escape character in Postgres.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// recipeSearchFilter applies the q search of GET /recipes over titles,
+// ingredients and tags, including tag aliases.
+func recipeSearchFilter(query *gorm.DB, q string) *gorm.DB {
+	like := "%" + likeEscaper.Replace(q) + "%"
+	return query.Where(
+		`(title ILIKE ? OR ingredients ILIKE ? OR id IN (
+			SELECT recipe_tags.recipe_id FROM recipe_tags
+			JOIN tags ON tags.id = recipe_tags.tag_id OR tags.alias_of_id = recipe_tags.tag_id
+			WHERE tags.name ILIKE ? OR tags.slug = ?))`,
+		like, like, like, Slugify(q),
+	)
+}
+
+// tagUsageQuery selects canonical tags with their recipe counts.
+func tagUsageQuery() *gorm.DB {
+	return DB.Model(&Tag{}).
+		Select("tags.*, COUNT(recipe_tags.recipe_id) AS usage_count").
+		Joins("LEFT JOIN recipe_tags ON recipe_tags.tag_id = tags.id").
+		Where("tags.alias_of_id IS NULL").
+		Group("tags.id")
+}
+
+// queryLimit reads the limit query parameter, bounded to max.
+func queryLimit(c *gin.Context, fallback, max int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 {
+		return fallback
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// queryCursor reads the cursor query parameter of pages ordered newest
+// first: the ID the page starts below, or zero for the first page.
+func queryCursor(c *gin.Context) (uint, error) {
+	cursor := c.Query("cursor")
+	if cursor == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(cursor, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return uint(id), nil
+}
+
+// GetTags handles the GET /tags endpoint, listing tags by usage. The system
+// query parameter restricts the list to system or user tags.
+func GetTags(c *gin.Context) {
+	query := tagUsageQuery()
+	if system := c.Query("system"); system != "" {
+		query = query.Where("tags.system = ?", system == "true")
+	}
+
+	var tags []TagUsage
+	if err := query.Order("usage_count DESC, tags.name").Limit(queryLimit(c, 100, 500)).Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// AutocompleteTags handles the GET /tags/autocomplete endpoint. Tags whose
+// name, slug or alias starts with the prefix are returned, most used first.
+func AutocompleteTags(c *gin.Context) {
+	prefix := strings.TrimSpace(c.Query("prefix"))
+	if prefix == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter prefix is required"})
+		return
+	}
+
+	like := likeEscaper.Replace(prefix) + "%"
+	slugLike := Slugify(prefix) + "%"
+	var tags []TagUsage
+	err := tagUsageQuery().
+		Where("tags.name ILIKE ? OR tags.slug LIKE ? OR tags.id IN (SELECT alias_of_id FROM tags WHERE slug LIKE ? OR name ILIKE ?)", like, slugLike, slugLike, like).
+		Order("usage_count DESC, tags.name").
+		Limit(queryLimit(c, 10, 50)).
+		Scan(&tags).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tags"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// AddRecipeTags handles the POST /recipes/:id/tags endpoint. Any signed-in
+// user can tag a recipe; existing tags are kept.
+func AddRecipeTags(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+
+	var input struct {
+		Tags []string `json:"tags" binding:"required,min=1,dive,required,max=64"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		tags, err := resolveTags(tx, input.Tags, false)
+		if err != nil {
+			return err
+		}
+		return applyRecipeTags(tx, recipe.ID, &userID, tags)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to tag recipe"})
+		return
+	}
+
+	DB.Preload("Tags").First(&recipe, recipe.ID)
+	c.JSON(http.StatusOK, recipe.Tags)
+}
+
+// RemoveRecipeTag handles the DELETE /recipes/:id/tags/:slug endpoint. The
+// recipe owner can remove any user-applied tag; other users only their own.
+// System tags follow the recipe metadata and cannot be removed.
+func RemoveRecipeTag(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var recipe Recipe
+	if err := findVisibleRecipe(c, DB, &recipe, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	slug := Slugify(c.Param("slug"))
+	ids, err := canonicalTagIDs(DB, []string{slug})
+	if err != nil || ids[slug] == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+
+	var link RecipeTag
+	if err := DB.Where("recipe_id = ? AND tag_id = ?", recipe.ID, ids[slug]).First(&link).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe does not have this tag"})
+		return
+	}
+	if link.UserID == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "System tags follow the recipe details and cannot be removed"})
+		return
+	}
+	if recipe.UserID != userID && *link.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only remove tags you applied"})
+		return
+	}
+
+	if err := DB.Where("recipe_id = ? AND tag_id = ?", link.RecipeID, link.TagID).Delete(&RecipeTag{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove tag"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Tag removed"})
+}
+
+// findTagBySlug loads the tag named by the slug parameter.
+func findTagBySlug(c *gin.Context) (Tag, bool) {
+	var tag Tag
+	if err := DB.Where("slug = ?", Slugify(c.Param("slug"))).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return tag, false
+	}
+	return tag, true
+}
+
+// CreateTag handles the POST /admin/tags endpoint.
+func CreateTag(c *gin.Context) {
+	var input struct {
+		Name   string `json:"name" binding:"required,max=64"`
+		System bool   `json:"system"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := Tag{Name: strings.TrimSpace(input.Name), Slug: Slugify(input.Name), System: input.System}
+	if tag.Slug == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name must contain letters or digits"})
+		return
+	}
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&tag)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tag already exists"})
+		return
+	}
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag handles the PUT /admin/tags/:slug endpoint. Renaming keeps the slug.
+func UpdateTag(c *gin.Context) {
+	tag, ok := findTagBySlug(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name   *string `json:"name" binding:"omitempty,min=1,max=64"`
+		System *bool   `json:"system"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.Name != nil {
+		updates["name"] = strings.TrimSpace(*input.Name)
+	}
+	if input.System != nil {
+		updates["system"] = *input.System
+	}
+	if err := DB.Model(&tag).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+		return
+	}
+	c.JSON(http.StatusOK, tag)
+}
+
+// mergeTags moves every use of source onto target and turns source into an
+// alias of target, so the old slug keeps resolving.
+func mergeTags(tx *gorm.DB, source, target Tag) error {
+	err := tx.Exec(`INSERT INTO recipe_tags (recipe_id, tag_id, user_id, created_at)
+		SELECT recipe_id, ?, user_id, created_at FROM recipe_tags WHERE tag_id = ?
+		ON CONFLICT DO NOTHING`, target.ID, source.ID).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("tag_id = ?", source.ID).Delete(&RecipeTag{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Tag{}).Where("alias_of_id = ?", source.ID).Update("alias_of_id", target.ID).Error; err != nil {
+		return err
+	}
+	return tx.Model(&source).Update("alias_of_id", target.ID).Error
+}
+
+// MergeTag handles the POST /admin/tags/:slug/merge endpoint, merging the
+// tag into the tag given as into.
+func MergeTag(c *gin.Context) {
+	source, ok := findTagBySlug(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Into string `json:"into" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var target Tag
+	if err := DB.Where("slug = ?", Slugify(input.Into)).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target tag not found"})
+		return
+	}
+	target, err := canonicalTag(DB, target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve target tag"})
+		return
+	}
+	if target.ID == source.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot merge a tag into itself"})
+		return
+	}
+
+	if err := DB.Transaction(func(tx *gorm.DB) error { return mergeTags(tx, source, target) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge tags"})
+		return
+	}
+	c.JSON(http.StatusOK, target)
+}
+
+// AddTagAlias handles the POST /admin/tags/:slug/aliases endpoint. An
+// existing tag with the alias slug is merged into the tag.
+func AddTagAlias(c *gin.Context) {
+	tag, ok := findTagBySlug(c)
+	if !ok {
+		return
+	}
+	tag, err := canonicalTag(DB, tag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve tag"})
+		return
+	}
+
+	var input struct {
+		Alias string `json:"alias" binding:"required,max=64"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	slug := Slugify(input.Alias)
+	if slug == "" || slug == tag.Slug {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alias"})
+		return
+	}
+
+	alias := Tag{Name: strings.TrimSpace(input.Alias), Slug: slug, AliasOfID: &tag.ID}
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		var existing Tag
+		err := tx.Where("slug = ?", slug).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&alias).Error
+		}
+		if err != nil {
+			return err
+		}
+		alias = existing
+		alias.AliasOfID = &tag.ID
+		return mergeTags(tx, existing, tag)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add alias"})
+		return
+	}
+	c.JSON(http.StatusCreated, alias)
+}
@@ -0,0 +1,71 @@
+// tags_test.go
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSlugify verifies tag names normalize to hyphenated lower-case slugs.
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "gluten-free", Slugify("  Gluten Free! "))
+	assert.Equal(t, "one-pot-meals", Slugify("one--pot / meals"))
+	assert.Equal(t, "crème-brûlée", Slugify("Crème Brûlée"))
+	assert.Equal(t, "", Slugify("!!!"))
+
+	long := Slugify(strings.Repeat("é", 40))
+	assert.LessOrEqual(t, len(long), maxTagSlugLength)
+	assert.Equal(t, strings.Repeat("é", 32), long)
+}
+
+// TestSystemTagNames verifies system tags are derived from recipe metadata.
+func TestSystemTagNames(t *testing.T) {
+	recipe := Recipe{Cuisine: "thai", Course: "dinner", TotalTimeMinutes: 25}
+	assert.Equal(t, []string{"thai", "dinner", "quick"}, SystemTagNames(recipe))
+
+	recipe = Recipe{Difficulty: "hard", TotalTimeMinutes: 90}
+	assert.Equal(t, []string{"hard"}, SystemTagNames(recipe))
+}
+
+// TestRecipeTagFilterMode verifies an unknown tag_mode is rejected.
+func TestRecipeTagFilterMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/recipes?tags=vegan&tag_mode=some", nil)
+
+	_, err := recipeTagFilter(c, nil)
+	assert.EqualError(t, err, `invalid tag_mode "some", expected all or any`)
+}
+
+// TestQueryCursor verifies cursors parse as positive IDs and are optional.
+func TestQueryCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for query, want := range map[string]uint{"": 0, "?cursor=42": 42} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/me/feed"+query, nil)
+		cursor, err := queryCursor(c)
+		assert.NoError(t, err)
+		assert.Equal(t, want, cursor)
+	}
+
+	for _, query := range []string{"?cursor=0", "?cursor=abc", "?cursor=-3"} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/me/feed"+query, nil)
+		_, err := queryCursor(c)
+		assert.Error(t, err, query)
+	}
+}
+
+// TestRecipeSearchFilterEscapesWildcards verifies % and _ in the search
+// text match themselves rather than anything.
+func TestRecipeSearchFilterEscapesWildcards(t *testing.T) {
+	var recipes []Recipe
+	stmt := recipeSearchFilter(DryRunDB(t).Model(&Recipe{}), "100%_juice").Find(&recipes).Statement
+	assert.Equal(t, `%100\%\_juice%`, stmt.Vars[0])
+	assert.Equal(t, `%100\%\_juice%`, stmt.Vars[2])
+}
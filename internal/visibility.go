@@ -0,0 +1,222 @@
+// visibility.go
+package internal
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Recipe visibilities. Unlisted recipes are hidden from listings and only
+// readable by their owner or through a share link.
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
+// visibleRecipes limits a recipe query to published public recipes not
+// hidden by moderators and, for a signed-in user, their own recipes.
+func visibleRecipes(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if userID, ok := currentUserID(c); ok {
+			return db.Where("((recipes.visibility = ? AND recipes.status = ? AND recipes.hidden_at IS NULL) OR recipes.user_id = ?)", VisibilityPublic, RecipeStatusPublished, userID)
+		}
+		return db.Where("recipes.visibility = ? AND recipes.status = ? AND recipes.hidden_at IS NULL", VisibilityPublic, RecipeStatusPublished)
+	}
+}
+
+// visibleIngredients limits an ingredient query to the ingredients of
+// recipes the caller may list, as visibleRecipes does for recipes.
+func visibleIngredients(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Joins("JOIN recipes ON recipes.id = ingredients.recipe_id AND recipes.deleted_at IS NULL").
+			Scopes(visibleRecipes(c))
+	}
+}
+
+// canViewRecipe reports whether the caller may read a recipe. Unlisted
+// recipes are readable with their share token in the share query parameter;
+// drafts and recipes hidden by moderators only by their owner.
+func canViewRecipe(c *gin.Context, recipe Recipe) bool {
+	if userID, ok := currentUserID(c); ok && userID == recipe.UserID {
+		return true
+	}
+	if recipe.Status == RecipeStatusDraft || recipe.HiddenAt != nil {
+		return false
+	}
+	if recipe.Visibility == VisibilityPublic {
+		return true
+	}
+	return recipe.Visibility == VisibilityUnlisted && validShareToken(recipe, c.Query("share"))
+}
+
+// validShareToken reports whether token is the recipe's current share token.
+func validShareToken(recipe Recipe, token string) bool {
+	return recipe.ShareToken != nil && token != "" &&
+		subtle.ConstantTimeCompare([]byte(*recipe.ShareToken), []byte(token)) == 1
+}
+
+// findVisibleRecipe loads a recipe the caller may read. Recipes hidden from
+// the caller are reported as not found rather than forbidden, so their
+// existence is not revealed.
+func findVisibleRecipe(c *gin.Context, db *gorm.DB, recipe *Recipe, id interface{}) error {
+	if err := db.First(recipe, id).Error; err != nil {
+		return err
+	}
+	if !canViewRecipe(c, *recipe) {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// defaultVisibility returns the visibility a user's new recipes get.
+func defaultVisibility(userID uint) string {
+	if userID == 0 {
+		return VisibilityPublic
+	}
+	pref, err := userPreference(userID)
+	if err != nil || pref.DefaultVisibility == "" {
+		return VisibilityPublic
+	}
+	return pref.DefaultVisibility
+}
+
+// findOwnRecipe loads the recipe named by the id path parameter if the
+// caller owns it, responding with an error and returning false otherwise.
+func findOwnRecipe(c *gin.Context, recipe *Recipe) bool {
+	return findOwnRecipeByID(c, recipe, c.Param("id"))
+}
+
+// findOwnRecipeByID loads a recipe owned by the caller, responding with an
+// error and returning false otherwise.
+func findOwnRecipeByID(c *gin.Context, recipe *Recipe, id interface{}) bool {
+	userID, ok := currentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	if err := findVisibleRecipe(c, DB, recipe, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return false
+	}
+	if recipe.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only edit your own recipes"})
+		return false
+	}
+	return true
+}
+
+// CreateShareLink handles the POST /recipes/:id/share endpoint. It issues a
+// new share token, invalidating any previous link, and makes a private
+// recipe unlisted so the link works.
+func CreateShareLink(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+	updates := map[string]interface{}{"share_token": token}
+	if recipe.Visibility == VisibilityPrivate {
+		updates["visibility"] = VisibilityUnlisted
+		recipe.Visibility = VisibilityUnlisted
+	}
+	if err := DB.Model(&recipe).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"recipe_id":   recipe.ID,
+		"visibility":  recipe.Visibility,
+		"share_token": token,
+		"path":        "/shared/" + token,
+	})
+}
+
+// RevokeShareLink handles the DELETE /recipes/:id/share endpoint. Without
+// a token an unlisted recipe is only readable by its owner.
+func RevokeShareLink(c *gin.Context) {
+	var recipe Recipe
+	if !findOwnRecipe(c, &recipe) {
+		return
+	}
+	if err := DB.Model(&recipe).Update("share_token", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "Share link revoked"})
+}
+
+// GetSharedRecipe handles the GET /shared/:token endpoint, returning the
+// recipe a share link points at.
+func GetSharedRecipe(c *gin.Context) {
+	var recipe Recipe
+	err := DB.Scopes(recipeDetails).
+		Where("share_token = ? AND visibility <> ? AND status = ? AND hidden_at IS NULL", c.Param("token"), VisibilityPrivate, RecipeStatusPublished).
+		First(&recipe).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe not found"})
+		return
+	}
+	if err := attachForkAttribution(c, &recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipe"})
+		return
+	}
+	if err := attachRecipeImages(&recipe); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve recipe"})
+		return
+	}
+	c.JSON(http.StatusOK, recipe)
+}
+
+// GetPreferences handles the GET /me/preferences endpoint.
+func GetPreferences(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	pref, err := userPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, pref)
+}
+
+// UpdatePreferences handles the PUT /me/preferences endpoint.
+func UpdatePreferences(c *gin.Context) {
+	userID, _ := currentUserID(c)
+	var input struct {
+		Preference        *string `json:"preference"`
+		DefaultVisibility *string `json:"default_visibility" binding:"omitempty,oneof=private unlisted public"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pref, err := userPreference(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve preferences"})
+		return
+	}
+	updates := map[string]interface{}{}
+	if input.Preference != nil {
+		updates["preference"] = *input.Preference
+	}
+	if input.DefaultVisibility != nil {
+		updates["default_visibility"] = *input.DefaultVisibility
+	}
+	if len(updates) > 0 {
+		if err := DB.Model(&pref).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, pref)
+}
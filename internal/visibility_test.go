@@ -0,0 +1,107 @@
+// visibility_test.go
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// visibilityContext returns a test context for a request to target, signed
+// in as userID unless it is zero.
+func visibilityContext(target string, userID uint) *gin.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	if userID != 0 {
+		c.Set("userID", userID)
+	}
+	return c
+}
+
+// TestCanViewRecipe verifies who may read recipes of each visibility.
+func TestCanViewRecipe(t *testing.T) {
+	token := "secret"
+	public := Recipe{UserID: 1, Visibility: VisibilityPublic}
+	unlisted := Recipe{UserID: 1, Visibility: VisibilityUnlisted, ShareToken: &token}
+	private := Recipe{UserID: 1, Visibility: VisibilityPrivate, ShareToken: &token}
+
+	assert.True(t, canViewRecipe(visibilityContext("/recipes/1", 0), public))
+	assert.False(t, canViewRecipe(visibilityContext("/recipes/1", 2), unlisted))
+	assert.True(t, canViewRecipe(visibilityContext("/recipes/1?share=secret", 0), unlisted))
+	assert.False(t, canViewRecipe(visibilityContext("/recipes/1?share=wrong", 0), unlisted))
+	assert.True(t, canViewRecipe(visibilityContext("/recipes/1", 1), private))
+	assert.False(t, canViewRecipe(visibilityContext("/recipes/1?share=secret", 2), private))
+
+	revoked := Recipe{UserID: 1, Visibility: VisibilityUnlisted}
+	assert.False(t, canViewRecipe(visibilityContext("/recipes/1?share=", 0), revoked))
+
+	hiddenAt := time.Now()
+	hidden := Recipe{UserID: 1, Visibility: VisibilityPublic, HiddenAt: &hiddenAt}
+	assert.False(t, canViewRecipe(visibilityContext("/recipes/1", 0), hidden))
+	assert.True(t, canViewRecipe(visibilityContext("/recipes/1", 1), hidden))
+}
+
+// TestOptionalJWTMiddleware verifies a valid token signs the request in and
+// a missing or invalid one leaves it anonymous.
+func TestOptionalJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/whoami", OptionalJWTMiddleware(), func(c *gin.Context) {
+		userID, ok := currentUserID(c)
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "signed_in": ok})
+	})
+
+	for _, tc := range []struct {
+		header string
+		body   string
+	}{
+		{"", `{"signed_in":false,"user_id":0}`},
+		{"Bearer not-a-token", `{"signed_in":false,"user_id":0}`},
+		{"Bearer " + generateTestJWT(42), `{"signed_in":true,"user_id":42}`},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, tc.body, w.Body.String())
+	}
+}
+
+// TestRecipeWritesRequireSignIn verifies anonymous callers cannot edit or
+// delete recipes or their ingredients.
+func TestRecipeWritesRequireSignIn(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	SetupRoutes(router)
+
+	routes := []struct{ method, path string }{
+		{http.MethodPut, "/recipes/1"},
+		{http.MethodDelete, "/recipes/1"},
+		{http.MethodPost, "/ingredients"},
+		{http.MethodPut, "/ingredients/1"},
+		{http.MethodDelete, "/ingredients/1"},
+	}
+	for _, route := range routes {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(route.method, route.path, strings.NewReader(`{"visibility":"private"}`)))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "%s %s", route.method, route.path)
+	}
+}
+
+// TestVisibleIngredients verifies ingredients are listed only for recipes
+// the caller may list.
+func TestVisibleIngredients(t *testing.T) {
+	var ingredients []Ingredient
+	stmt := DryRunDB(t).Scopes(visibleIngredients(visibilityContext("/ingredients", 3))).Find(&ingredients).Statement
+	assert.Equal(t, `SELECT "ingredients"."id","ingredients"."name","ingredients"."quantity","ingredients"."recipe_id","ingredients"."created_at","ingredients"."updated_at","ingredients"."deleted_at" FROM "ingredients" JOIN recipes ON recipes.id = ingredients.recipe_id AND recipes.deleted_at IS NULL WHERE (((recipes.visibility = $1 AND recipes.status = $2 AND recipes.hidden_at IS NULL) OR recipes.user_id = $3)) AND "ingredients"."deleted_at" IS NULL`, stmt.SQL.String())
+	assert.Equal(t, []interface{}{VisibilityPublic, RecipeStatusPublished, uint(3)}, stmt.Vars)
+}